@@ -24,7 +24,12 @@ type appConfig struct {
 	// the data source name (DSN) for connecting to the database. required.
 	DBName string `mapstructure:"db_name"`
 
+	// ApiAuthKey is required by write requests and lifts the rate limit of read requests
 	ApiAuthKey string `mapstructure:"api_auth_key"`
+	// RateLimit is the number of read requests by minute of a client without api key. Unlimited if 0
+	RateLimit int `mapstructure:"rate_limit"`
+	// TrustedProxies are the ip addresses or cidr ranges of proxies whose X-Forwarded-For header gives the client address
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
 
 	// the RabbitMQURL is the URI of rabbitmq to use
 	RabbitMQURL string `mapstructure:"rabbitmq_url"`
@@ -32,6 +37,19 @@ type appConfig struct {
 	// TickDuration is user by tick streaming cron
 	TickDuration map[string][]int64 `mapstructure:"tick_duration"`
 
+	// TradeStatuses are the trade statuses counted in volume. Defaults to SUCCESS
+	TradeStatuses []string `mapstructure:"trade_statuses"`
+
+	// CacheStore is the storage of volume cache, leveldb or file. Defaults to leveldb
+	CacheStore string `mapstructure:"cache_store"`
+	// CacheLoadDays is the number of days of volume cache loaded at startup. Defaults to 90, the full history if negative
+	CacheLoadDays int64 `mapstructure:"cache_load_days"`
+
+	// PriceSource is the source of USD prices, file or http. Prices are only derived from trades if empty
+	PriceSource string `mapstructure:"price_source"`
+	// PriceSourceURL is the json file path or http url of the price source
+	PriceSourceURL string `mapstructure:"price_source_url"`
+
 	Tomochain map[string]string `mapstructure:"tomochain"`
 
 	Env         string `mapstructure:"env"`
@@ -0,0 +1,81 @@
+package daos
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/tomochain/tomox-stats/app"
+	"github.com/tomochain/tomox-stats/types"
+)
+
+// BotAddressDao contains:
+// collectionName: MongoDB collection name
+// dbName: name of mongodb to interact with
+type BotAddressDao struct {
+	collectionName string
+	dbName         string
+}
+
+// NewBotAddressDao returns a new instance of BotAddressDao.
+func NewBotAddressDao() *BotAddressDao {
+	dbName := app.Config.DBName
+	collection := "bot_addresses"
+	index := mgo.Index{
+		Key:    []string{"address"},
+		Unique: true,
+	}
+
+	err := db.Session.DB(dbName).C(collection).EnsureIndex(index)
+	if err != nil {
+		panic(err)
+	}
+
+	return &BotAddressDao{collection, dbName}
+}
+
+// Upsert add bot address or update its name
+func (dao *BotAddressDao) Upsert(b *types.BotAddress) error {
+	q := bson.M{"address": b.Address.Hex()}
+	update := bson.M{
+		"$set": bson.M{
+			"name": b.Name,
+		},
+		"$setOnInsert": bson.M{
+			"_id":       bson.NewObjectId(),
+			"createdAt": time.Now(),
+		},
+	}
+
+	_, err := db.Upsert(dao.dbName, dao.collectionName, q, update)
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+// GetAll fetches all bot addresses
+func (dao *BotAddressDao) GetAll() ([]*types.BotAddress, error) {
+	res := []*types.BotAddress{}
+	err := db.Get(dao.dbName, dao.collectionName, bson.M{}, 0, 0, &res)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+	return res, nil
+}
+
+// DeleteByAddress remove bot address
+func (dao *BotAddressDao) DeleteByAddress(addr common.Address) error {
+	return db.RemoveItem(dao.dbName, dao.collectionName, bson.M{"address": addr.Hex()})
+}
+
+// Watch notify bot address changes
+func (dao *BotAddressDao) Watch() (*mgo.ChangeStream, *mgo.Session, error) {
+	return db.Watch(dao.dbName, dao.collectionName, mgo.ChangeStreamOptions{
+		MaxAwaitTimeMS: 500,
+	})
+}
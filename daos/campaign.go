@@ -0,0 +1,119 @@
+package daos
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/tomochain/tomox-stats/app"
+	"github.com/tomochain/tomox-stats/types"
+)
+
+// CampaignDao contains:
+// collectionName: MongoDB collection name
+// dbName: name of mongodb to interact with
+type CampaignDao struct {
+	collectionName string
+	dbName         string
+}
+
+// NewCampaignDao returns a new instance of CampaignDao.
+func NewCampaignDao() *CampaignDao {
+	dbName := app.Config.DBName
+	collection := "campaigns"
+	index := mgo.Index{
+		Key: []string{"relayerAddress", "endTime"},
+	}
+
+	err := db.Session.DB(dbName).C(collection).EnsureIndex(index)
+	if err != nil {
+		panic(err)
+	}
+
+	return &CampaignDao{collection, dbName}
+}
+
+// Create insert a new campaign
+func (dao *CampaignDao) Create(c *types.Campaign) error {
+	c.ID = bson.NewObjectId()
+	c.Finalized = false
+	c.CreatedAt = time.Now()
+	c.UpdatedAt = time.Now()
+
+	err := db.Create(dao.dbName, dao.collectionName, c)
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+// GetAll fetches campaigns sorted by end time, empty relayer address for all relayers
+func (dao *CampaignDao) GetAll(relayerAddress common.Address) ([]*types.Campaign, error) {
+	q := bson.M{}
+	if (relayerAddress != common.Address{}) {
+		q["relayerAddress"] = relayerAddress.Hex()
+	}
+	res := []*types.Campaign{}
+	err := db.GetAndSort(dao.dbName, dao.collectionName, q, []string{"-endTime"}, 0, 0, &res)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+	return res, nil
+}
+
+// GetByID fetches campaign, return nil if not found
+func (dao *CampaignDao) GetByID(id bson.ObjectId) (*types.Campaign, error) {
+	res := []*types.Campaign{}
+	err := db.Get(dao.dbName, dao.collectionName, bson.M{"_id": id}, 0, 1, &res)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+	if len(res) == 0 {
+		return nil, nil
+	}
+	return res[0], nil
+}
+
+// GetEndedNotFinalized fetches campaigns ended before endTime and not finalized yet
+func (dao *CampaignDao) GetEndedNotFinalized(endTime int64) ([]*types.Campaign, error) {
+	q := bson.M{
+		"endTime":   bson.M{"$lt": endTime},
+		"finalized": false,
+	}
+	res := []*types.Campaign{}
+	err := db.Get(dao.dbName, dao.collectionName, q, 0, 0, &res)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+	return res, nil
+}
+
+// UpdateNotFinalized replace campaign if it is not finalized, mgo.ErrNotFound otherwise
+func (dao *CampaignDao) UpdateNotFinalized(c *types.Campaign) error {
+	c.Finalized = false
+	c.UpdatedAt = time.Now()
+	q := bson.M{"_id": c.ID, "finalized": false}
+	return db.Update(dao.dbName, dao.collectionName, q, c)
+}
+
+// SetFinalized mark campaign finalized
+func (dao *CampaignDao) SetFinalized(id bson.ObjectId) error {
+	update := bson.M{
+		"$set": bson.M{
+			"finalized": true,
+			"updatedAt": time.Now(),
+		},
+	}
+	return db.Update(dao.dbName, dao.collectionName, bson.M{"_id": id}, update)
+}
+
+// DeleteNotFinalized remove campaign if it is not finalized, mgo.ErrNotFound otherwise
+func (dao *CampaignDao) DeleteNotFinalized(id bson.ObjectId) error {
+	return db.RemoveItem(dao.dbName, dao.collectionName, bson.M{"_id": id, "finalized": false})
+}
@@ -0,0 +1,64 @@
+package daos
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/tomochain/tomox-stats/app"
+	"github.com/tomochain/tomox-stats/types"
+)
+
+// CampaignLeaderboardDao contains:
+// collectionName: MongoDB collection name
+// dbName: name of mongodb to interact with
+// final leaderboards are only inserted, never updated
+type CampaignLeaderboardDao struct {
+	collectionName string
+	dbName         string
+}
+
+// NewCampaignLeaderboardDao returns a new instance of CampaignLeaderboardDao.
+func NewCampaignLeaderboardDao() *CampaignLeaderboardDao {
+	dbName := app.Config.DBName
+	collection := "campaign_leaderboards"
+	index := mgo.Index{
+		Key:    []string{"campaignId"},
+		Unique: true,
+	}
+
+	err := db.Session.DB(dbName).C(collection).EnsureIndex(index)
+	if err != nil {
+		panic(err)
+	}
+
+	return &CampaignLeaderboardDao{collection, dbName}
+}
+
+// Create insert the final leaderboard of a campaign
+func (dao *CampaignLeaderboardDao) Create(l *types.CampaignLeaderboard) error {
+	l.ID = bson.NewObjectId()
+	l.CreatedAt = time.Now()
+
+	err := db.Create(dao.dbName, dao.collectionName, l)
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+// GetByCampaignID fetches final leaderboard of campaign, return nil if not found
+func (dao *CampaignLeaderboardDao) GetByCampaignID(id bson.ObjectId) (*types.CampaignLeaderboard, error) {
+	res := []*types.CampaignLeaderboard{}
+	err := db.Get(dao.dbName, dao.collectionName, bson.M{"campaignId": id}, 0, 1, &res)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+	if len(res) == 0 {
+		return nil, nil
+	}
+	return res[0], nil
+}
@@ -27,6 +27,11 @@ func NewLendingTradeDao() *LendingTradeDao {
 	}
 	db.Session.DB(dbName).C(collection).EnsureIndex(i3)
 
+	i4 := mgo.Index{
+		Key: []string{"status"},
+	}
+	db.Session.DB(dbName).C(collection).EnsureIndex(i4)
+
 	return &LendingTradeDao{collection, dbName}
 }
 
@@ -36,11 +41,13 @@ func (dao *LendingTradeDao) GetCollection() *mgo.Collection {
 }
 
 // Watch changing database
-func (dao *LendingTradeDao) Watch() (*mgo.ChangeStream, *mgo.Session, error) {
+// resumeToken is nil to start watching from current time
+func (dao *LendingTradeDao) Watch(resumeToken *bson.Raw) (*mgo.ChangeStream, *mgo.Session, error) {
 	return db.Watch(dao.dbName, dao.collectionName, mgo.ChangeStreamOptions{
 		FullDocument:   mgo.UpdateLookup,
 		MaxAwaitTimeMS: 500,
 		BatchSize:      1000,
+		ResumeAfter:    resumeToken,
 	})
 }
 
@@ -73,3 +80,30 @@ func (dao *LendingTradeDao) GetLendingTradeByTime(dateFrom, dateTo int64, pageOf
 	}
 	return trades, nil
 }
+
+// GetLendingTradeByStatusSince get page of trades having status, updated from dateFrom
+func (dao *LendingTradeDao) GetLendingTradeByStatusSince(status string, dateFrom int64, pageOffset int, pageSize int) ([]*types.LendingTrade, error) {
+	q := bson.M{
+		"status":    status,
+		"updatedAt": bson.M{"$gte": time.Unix(dateFrom, 0)},
+	}
+	trades := []*types.LendingTrade{}
+	err := db.GetAndSort(dao.dbName, dao.collectionName, q, []string{"_id"}, pageOffset, pageSize, &trades)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+	return trades, nil
+}
+
+// GetLendingTradeByStatus get page of trades having status
+func (dao *LendingTradeDao) GetLendingTradeByStatus(status string, pageOffset int, pageSize int) ([]*types.LendingTrade, error) {
+	q := bson.M{"status": status}
+	trades := []*types.LendingTrade{}
+	_, err := db.GetEx(dao.dbName, dao.collectionName, q, []string{"_id"}, pageOffset, pageSize, &trades)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+	return trades, nil
+}
@@ -0,0 +1,113 @@
+package daos
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/tomochain/tomox-stats/app"
+	"github.com/tomochain/tomox-stats/types"
+)
+
+// LiquidationDao contains:
+// collectionName: MongoDB collection name
+// dbName: name of mongodb to interact with
+type LiquidationDao struct {
+	collectionName string
+	dbName         string
+}
+
+// NewLiquidationDao returns a new instance of LiquidationDao.
+func NewLiquidationDao() *LiquidationDao {
+	dbName := app.Config.DBName
+	collection := "liquidations"
+	index := mgo.Index{
+		Key:    []string{"hash"},
+		Unique: true,
+	}
+	err := db.Session.DB(dbName).C(collection).EnsureIndex(index)
+	if err != nil {
+		panic(err)
+	}
+
+	i2 := mgo.Index{
+		Key: []string{"liquidatedAt"},
+	}
+	db.Session.DB(dbName).C(collection).EnsureIndex(i2)
+
+	return &LiquidationDao{collection, dbName}
+}
+
+// Add insert liquidation if its trade hash is not stored yet
+func (dao *LiquidationDao) Add(l *types.Liquidation) error {
+	l.ID = bson.NewObjectId()
+	l.CreatedAt = time.Now()
+	q := bson.M{"hash": l.Hash.Hex()}
+	update := bson.M{
+		"$setOnInsert": l,
+	}
+
+	_, err := db.Upsert(dao.dbName, dao.collectionName, q, update)
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+// GetLastLiquidatedAt get time of the latest liquidation, zero time if there is none
+func (dao *LiquidationDao) GetLastLiquidatedAt() (time.Time, error) {
+	var res []*types.Liquidation
+	err := db.GetAndSort(dao.dbName, dao.collectionName, bson.M{}, []string{"-liquidatedAt"}, 0, 1, &res)
+	if err != nil {
+		logger.Error(err)
+		return time.Time{}, err
+	}
+	if len(res) == 0 {
+		return time.Time{}, nil
+	}
+	return res[0].LiquidatedAt, nil
+}
+
+// Query get page of liquidations matching spec, latest first
+func (dao *LiquidationDao) Query(spec *types.LiquidationSpec, offset int, size int) (*types.LiquidationRes, error) {
+	q := bson.M{}
+	if (spec.RelayerAddress != common.Address{}) {
+		q["$or"] = []bson.M{
+			{"borrowingRelayer": spec.RelayerAddress.Hex()},
+			{"investingRelayer": spec.RelayerAddress.Hex()},
+		}
+	}
+	if (spec.Borrower != common.Address{}) {
+		q["borrower"] = spec.Borrower.Hex()
+	}
+	if (spec.LendingToken != common.Address{}) {
+		q["lendingToken"] = spec.LendingToken.Hex()
+	}
+	if (spec.CollateralToken != common.Address{}) {
+		q["collateralToken"] = spec.CollateralToken.Hex()
+	}
+	dateFilter := bson.M{}
+	if spec.DateFrom != 0 {
+		dateFilter["$gte"] = time.Unix(spec.DateFrom, 0)
+	}
+	if spec.DateTo != 0 {
+		dateFilter["$lt"] = time.Unix(spec.DateTo, 0)
+	}
+	if len(dateFilter) > 0 {
+		q["liquidatedAt"] = dateFilter
+	}
+
+	res := &types.LiquidationRes{
+		Liquidations: []*types.Liquidation{},
+	}
+	total, err := db.GetEx(dao.dbName, dao.collectionName, q, []string{"-liquidatedAt"}, offset, size, &res.Liquidations)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+	res.Total = total
+	return res, nil
+}
@@ -0,0 +1,63 @@
+package daos
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/tomochain/tomox-stats/app"
+)
+
+// MigrationDao contains:
+// collectionName: MongoDB collection name
+// dbName: name of mongodb to interact with
+type MigrationDao struct {
+	collectionName string
+	dbName         string
+}
+
+// NewMigrationDao returns a new instance of MigrationDao.
+func NewMigrationDao() *MigrationDao {
+	dbName := app.Config.DBName
+	collection := "migrations"
+	index := mgo.Index{
+		Key:    []string{"name"},
+		Unique: true,
+	}
+
+	err := db.Session.DB(dbName).C(collection).EnsureIndex(index)
+	if err != nil {
+		panic(err)
+	}
+
+	return &MigrationDao{collection, dbName}
+}
+
+// IsApplied check migration marker of name exists
+func (dao *MigrationDao) IsApplied(name string) (bool, error) {
+	n, err := db.Count(dao.dbName, dao.collectionName, bson.M{"name": name})
+	if err != nil {
+		logger.Error(err)
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// MarkApplied insert migration marker of name, once
+func (dao *MigrationDao) MarkApplied(name string) error {
+	q := bson.M{"name": name}
+	update := bson.M{
+		"$setOnInsert": bson.M{
+			"_id":       bson.NewObjectId(),
+			"createdAt": time.Now(),
+		},
+	}
+
+	_, err := db.Upsert(dao.dbName, dao.collectionName, q, update)
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
@@ -98,6 +98,21 @@ func (dao *PairDao) GetAllByCoinbase(addr common.Address) ([]types.Pair, error)
 	return res, nil
 }
 
+// GetAllByCoinbases get pairs of all coinbase addresses in one query
+func (dao *PairDao) GetAllByCoinbases(addrs []common.Address) ([]types.Pair, error) {
+	hexes := []string{}
+	for _, addr := range addrs {
+		hexes = append(hexes, addr.Hex())
+	}
+	var res []types.Pair
+	err := db.Get(dao.dbName, dao.collectionName, bson.M{"relayerAddress": bson.M{"$in": hexes}}, 0, 0, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
 // GetActivePairsByCoinbase get active pair by coinbase address
 func (dao *PairDao) GetActivePairsByCoinbase(addr common.Address) ([]*types.Pair, error) {
 	var res []*types.Pair
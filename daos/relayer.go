@@ -31,6 +31,19 @@ func NewRelayerDao() *RelayerDao {
 	return &RelayerDao{collection, dbName}
 }
 
+// EnsureDomainIndex build the unique index of relayer domains
+// a domain resolves one active relayer, relayers without domain or resigned are not indexed
+// it fails while several active relayers have the same domain
+func (dao *RelayerDao) EnsureDomainIndex() error {
+	index := mgo.Index{
+		Key:           []string{"domain"},
+		Unique:        true,
+		PartialFilter: bson.M{"domain": bson.M{"$gt": ""}, "resign": false},
+	}
+
+	return db.Session.DB(dao.dbName).C(dao.collectionName).EnsureIndex(index)
+}
+
 func (dao *RelayerDao) Create(a *types.Relayer) error {
 	a.ID = bson.NewObjectId()
 	a.CreatedAt = time.Now()
@@ -134,6 +147,9 @@ func (dao *RelayerDao) UpdateByAddress(addr common.Address, relayer *types.Relay
 			"rid":        relayer.RID,
 			"resign":     relayer.Resign,
 			"lockTime":   relayer.LockTime,
+			// lending pairs and collaterals of the lending contract
+			"lendingPairs":     relayer.LendingPairs,
+			"collateralTokens": relayer.CollateralTokens,
 		},
 	}
 	err := db.Update(dao.dbName, dao.collectionName, q, update)
@@ -163,3 +179,53 @@ func (dao *RelayerDao) UpdateNameByAddress(addr common.Address, name string, url
 
 	return nil
 }
+
+// ClearDomainByAddress remove the domain of relayer
+func (dao *RelayerDao) ClearDomainByAddress(addr common.Address) error {
+	q := bson.M{"address": addr.Hex()}
+	update := bson.M{
+		"$set": bson.M{
+			"domain":    "",
+			"updatedAt": time.Now(),
+		},
+	}
+
+	err := db.Update(dao.dbName, dao.collectionName, q, update)
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+// UpdateMetadataByAddress set the admin edited information of relayer, nil fields are not changed
+// a domain used by another active relayer returns a duplicate key error
+func (dao *RelayerDao) UpdateMetadataByAddress(addr common.Address, m *types.RelayerMetadata) error {
+	q := bson.M{"address": addr.Hex()}
+
+	set := bson.M{"updatedAt": time.Now()}
+	if m.Name != nil {
+		set["name"] = *m.Name
+	}
+	if m.Domain != nil {
+		set["domain"] = *m.Domain
+	}
+	if m.Logo != nil {
+		set["logo"] = *m.Logo
+	}
+	if m.Description != nil {
+		set["description"] = *m.Description
+	}
+	update := bson.M{"$set": set}
+
+	err := db.Update(dao.dbName, dao.collectionName, q, update)
+	if err != nil {
+		if !mgo.IsDup(err) {
+			logger.Error(err)
+		}
+		return err
+	}
+
+	return nil
+}
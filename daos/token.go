@@ -113,6 +113,22 @@ func (dao *TokenDao) GetAllByCoinbase(addr common.Address) ([]types.Token, error
 	return response, nil
 }
 
+// GetAllByCoinbases get tokens of all coinbase addresses in one query
+func (dao *TokenDao) GetAllByCoinbases(addrs []common.Address) ([]types.Token, error) {
+	hexes := []string{}
+	for _, addr := range addrs {
+		hexes = append(hexes, addr.Hex())
+	}
+	var response []types.Token
+	err := db.Get(dao.dbName, dao.collectionName, bson.M{"relayerAddress": bson.M{"$in": hexes}}, 0, 0, &response)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	return response, nil
+}
+
 // GetQuote function fetches all the quote tokens in the token collection of mongodb.
 func (dao *TokenDao) GetQuoteTokens() ([]types.Token, error) {
 	var response []types.Token
@@ -22,15 +22,26 @@ type TradeDao struct {
 func NewTradeDao() *TradeDao {
 	dbName := app.Config.DBName
 	collection := "trades"
+
+	// first and last trades of a user are sorted by createdAt on either side
+	for _, key := range [][]string{{"maker", "createdAt"}, {"taker", "createdAt"}} {
+		index := mgo.Index{
+			Key:        key,
+			Background: true,
+		}
+		db.Session.DB(dbName).C(collection).EnsureIndex(index)
+	}
 	return &TradeDao{collection, dbName}
 }
 
 // Watch notfy trade record
-func (dao *TradeDao) Watch() (*mgo.ChangeStream, *mgo.Session, error) {
+// resumeToken is nil to start watching from current time
+func (dao *TradeDao) Watch(resumeToken *bson.Raw) (*mgo.ChangeStream, *mgo.Session, error) {
 	return db.Watch(dao.dbName, dao.collectionName, mgo.ChangeStreamOptions{
 		FullDocument:   mgo.UpdateLookup,
 		MaxAwaitTimeMS: 500,
 		BatchSize:      1000,
+		ResumeAfter:    resumeToken,
 	})
 }
 
@@ -62,7 +73,45 @@ func (dao *TradeDao) GetByHash(h common.Hash) (*types.Trade, error) {
 
 // GetTrades filter trade
 func (dao *TradeDao) GetTrades(tradeSpec *types.TradeSpec, sortedBy []string, pageOffset int, pageSize int) (*types.TradeRes, error) {
+	q := dao.getTradeSpecQuery(tradeSpec)
+
+	var res types.TradeRes
+	trades := []*types.Trade{}
+	c, err := db.GetEx(dao.dbName, dao.collectionName, q, sortedBy, pageOffset, pageSize, &trades)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+	res.Total = c
+	res.Trades = trades
+	return &res, nil
+}
+
+// GetTradesByCursor filter trade from newest to oldest, starting after cursor if not nil
+// paging by cursor uses the createdAt index instead of skipping documents
+func (dao *TradeDao) GetTradesByCursor(tradeSpec *types.TradeSpec, cursor *types.TradeCursor, pageSize int) ([]*types.Trade, error) {
+	q := dao.getTradeSpecQuery(tradeSpec)
+	if cursor != nil {
+		and, _ := q["$and"].([]bson.M)
+		q["$and"] = append(and, bson.M{
+			"$or": []bson.M{
+				{"createdAt": bson.M{"$lt": cursor.CreatedAt}},
+				{"createdAt": cursor.CreatedAt, "_id": bson.M{"$lt": cursor.ID}},
+			},
+		})
+	}
+	trades := []*types.Trade{}
+	err := db.GetAndSort(dao.dbName, dao.collectionName, q, []string{"-createdAt", "-_id"}, 0, pageSize, &trades)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+	return trades, nil
+}
+
+func (dao *TradeDao) getTradeSpecQuery(tradeSpec *types.TradeSpec) bson.M {
 	q := bson.M{}
+	and := []bson.M{}
 
 	if tradeSpec.DateFrom != 0 || tradeSpec.DateTo != 0 {
 		dateFilter := bson.M{}
@@ -80,17 +129,66 @@ func (dao *TradeDao) GetTrades(tradeSpec *types.TradeSpec, sortedBy []string, pa
 	if tradeSpec.QuoteToken != "" {
 		q["quoteToken"] = tradeSpec.QuoteToken
 	}
+	if tradeSpec.Status != "" {
+		q["status"] = tradeSpec.Status
+	}
+	if (tradeSpec.RelayerAddress != common.Address{}) {
+		and = append(and, bson.M{
+			"$or": []bson.M{
+				{"makerExchange": tradeSpec.RelayerAddress.Hex()},
+				{"takerExchange": tradeSpec.RelayerAddress.Hex()},
+			},
+		})
+	}
+	if (tradeSpec.UserAddress != common.Address{}) {
+		user := tradeSpec.UserAddress.Hex()
+		if tradeSpec.Side != "" {
+			// the maker order side is the opposite of the taker order side
+			makerSide := types.TradeSideBuy
+			if tradeSpec.Side == types.TradeSideBuy {
+				makerSide = types.TradeSideSell
+			}
+			and = append(and, bson.M{
+				"$or": []bson.M{
+					{"taker": user, "takerOrderSide": tradeSpec.Side},
+					{"maker": user, "takerOrderSide": makerSide},
+				},
+			})
+		} else {
+			and = append(and, bson.M{
+				"$or": []bson.M{
+					{"maker": user},
+					{"taker": user},
+				},
+			})
+		}
+	} else if tradeSpec.Side != "" {
+		q["takerOrderSide"] = tradeSpec.Side
+	}
+	if len(and) > 0 {
+		q["$and"] = and
+	}
+	return q
+}
 
-	var res types.TradeRes
-	trades := []*types.Trade{}
-	c, err := db.GetEx(dao.dbName, dao.collectionName, q, sortedBy, pageOffset, pageSize, &trades)
+// GetUserTrade get the first trade of user as maker or taker in sort order, nil if user has no trade
+func (dao *TradeDao) GetUserTrade(address common.Address, sortedBy []string) (*types.Trade, error) {
+	q := bson.M{
+		"$or": []bson.M{
+			{"maker": address.Hex()},
+			{"taker": address.Hex()},
+		},
+	}
+	var trade types.Trade
+	err := db.GetSortOne(dao.dbName, dao.collectionName, q, sortedBy, &trade)
+	if err == mgo.ErrNotFound {
+		return nil, nil
+	}
 	if err != nil {
 		logger.Error(err)
 		return nil, err
 	}
-	res.Total = c
-	res.Trades = trades
-	return &res, nil
+	return &trade, nil
 }
 
 // GetTradeByTime get range trade
@@ -110,3 +208,29 @@ func (dao *TradeDao) GetTradeByTime(dateFrom, dateTo int64, pageOffset int, page
 	}
 	return trades, nil
 }
+
+// GetTradeByUsers get range trade having maker or taker in addresses
+func (dao *TradeDao) GetTradeByUsers(addresses []common.Address, dateFrom, dateTo int64, pageOffset int, pageSize int) ([]*types.Trade, error) {
+	hexAddresses := []string{}
+	for _, a := range addresses {
+		hexAddresses = append(hexAddresses, a.Hex())
+	}
+	q := bson.M{
+		"createdAt": bson.M{
+			"$gte": time.Unix(dateFrom, 0),
+			"$lt":  time.Unix(dateTo, 0),
+		},
+		"$or": []bson.M{
+			{"maker": bson.M{"$in": hexAddresses}},
+			{"taker": bson.M{"$in": hexAddresses}},
+		},
+	}
+
+	trades := []*types.Trade{}
+	_, err := db.GetEx(dao.dbName, dao.collectionName, q, []string{"+createdAt"}, pageOffset, pageSize, &trades)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+	return trades, nil
+}
@@ -0,0 +1,72 @@
+package daos
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/tomochain/tomox-stats/app"
+	"github.com/tomochain/tomox-stats/types"
+)
+
+// WashGroupDao contains:
+// collectionName: MongoDB collection name
+// dbName: name of mongodb to interact with
+type WashGroupDao struct {
+	collectionName string
+	dbName         string
+}
+
+// NewWashGroupDao returns a new instance of WashGroupDao.
+func NewWashGroupDao() *WashGroupDao {
+	dbName := app.Config.DBName
+	collection := "wash_groups"
+	index := mgo.Index{
+		Key: []string{"addresses"},
+	}
+
+	err := db.Session.DB(dbName).C(collection).EnsureIndex(index)
+	if err != nil {
+		panic(err)
+	}
+
+	return &WashGroupDao{collection, dbName}
+}
+
+// Create insert a new wash group
+func (dao *WashGroupDao) Create(g *types.WashGroup) error {
+	g.ID = bson.NewObjectId()
+	g.CreatedAt = time.Now()
+	g.UpdatedAt = time.Now()
+
+	err := db.Create(dao.dbName, dao.collectionName, g)
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+// GetAll fetches all wash groups
+func (dao *WashGroupDao) GetAll() ([]*types.WashGroup, error) {
+	res := []*types.WashGroup{}
+	err := db.Get(dao.dbName, dao.collectionName, bson.M{}, 0, 0, &res)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+	return res, nil
+}
+
+// DeleteByID remove a wash group
+func (dao *WashGroupDao) DeleteByID(id bson.ObjectId) error {
+	return db.RemoveItem(dao.dbName, dao.collectionName, bson.M{"_id": id})
+}
+
+// Watch notify wash group changes
+func (dao *WashGroupDao) Watch() (*mgo.ChangeStream, *mgo.Session, error) {
+	return db.Watch(dao.dbName, dao.collectionName, mgo.ChangeStreamOptions{
+		MaxAwaitTimeMS: 500,
+	})
+}
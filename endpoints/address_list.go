@@ -0,0 +1,110 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/globalsign/mgo/bson"
+	"github.com/gorilla/mux"
+	"github.com/tomochain/tomox-stats/services"
+	"github.com/tomochain/tomox-stats/types"
+	"github.com/tomochain/tomox-stats/utils/httputils"
+)
+
+type addressListEndpoint struct {
+	addressListService *services.AddressListService
+}
+
+// ServeAddressListResource sets up the routing of wash group and bot address endpoints and the corresponding handlers.
+// adding and removing entries require the api key
+func ServeAddressListResource(
+	r *mux.Router,
+	addressListService *services.AddressListService,
+) {
+	e := &addressListEndpoint{addressListService}
+	r.HandleFunc("/stats/washgroups", e.handleGetWashGroups).Methods("GET")
+	r.HandleFunc("/stats/washgroups", adminOnly(e.handleCreateWashGroup)).Methods("POST")
+	r.HandleFunc("/stats/washgroups/{id}", adminOnly(e.handleDeleteWashGroup)).Methods("DELETE")
+	r.HandleFunc("/stats/bots", e.handleGetBotAddresses).Methods("GET")
+	r.HandleFunc("/stats/bots", adminOnly(e.handleAddBotAddress)).Methods("POST")
+	r.HandleFunc("/stats/bots/{address}", adminOnly(e.handleRemoveBotAddress)).Methods("DELETE")
+}
+
+func (e *addressListEndpoint) handleGetWashGroups(w http.ResponseWriter, r *http.Request) {
+	res, err := e.addressListService.GetWashGroups()
+	if err != nil {
+		httputils.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	httputils.WriteJSON(w, http.StatusOK, res)
+}
+
+func (e *addressListEndpoint) handleCreateWashGroup(w http.ResponseWriter, r *http.Request) {
+	g := &types.WashGroup{}
+	if err := json.NewDecoder(r.Body).Decode(g); err != nil {
+		httputils.WriteError(w, http.StatusBadRequest, "Invalid payload")
+		return
+	}
+	if err := g.Validate(); err != nil {
+		httputils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := e.addressListService.CreateWashGroup(g); err != nil {
+		httputils.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	httputils.WriteJSON(w, http.StatusCreated, g)
+}
+
+func (e *addressListEndpoint) handleDeleteWashGroup(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if !bson.IsObjectIdHex(id) {
+		httputils.WriteError(w, http.StatusBadRequest, "Invalid wash group id")
+		return
+	}
+	if err := e.addressListService.DeleteWashGroup(bson.ObjectIdHex(id)); err != nil {
+		httputils.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	httputils.WriteMessage(w, http.StatusOK, "Wash group removed")
+}
+
+func (e *addressListEndpoint) handleGetBotAddresses(w http.ResponseWriter, r *http.Request) {
+	res, err := e.addressListService.GetBotAddresses()
+	if err != nil {
+		httputils.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	httputils.WriteJSON(w, http.StatusOK, res)
+}
+
+func (e *addressListEndpoint) handleAddBotAddress(w http.ResponseWriter, r *http.Request) {
+	b := &types.BotAddress{}
+	if err := json.NewDecoder(r.Body).Decode(b); err != nil {
+		httputils.WriteError(w, http.StatusBadRequest, "Invalid payload")
+		return
+	}
+	if err := b.Validate(); err != nil {
+		httputils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := e.addressListService.AddBotAddress(b); err != nil {
+		httputils.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	httputils.WriteMessage(w, http.StatusOK, "Bot address added")
+}
+
+func (e *addressListEndpoint) handleRemoveBotAddress(w http.ResponseWriter, r *http.Request) {
+	addr := mux.Vars(r)["address"]
+	if !common.IsHexAddress(addr) {
+		httputils.WriteError(w, http.StatusBadRequest, "Invalid bot address")
+		return
+	}
+	if err := e.addressListService.RemoveBotAddress(common.HexToAddress(addr)); err != nil {
+		httputils.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	httputils.WriteMessage(w, http.StatusOK, "Bot address removed")
+}
@@ -0,0 +1,164 @@
+package endpoints
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/tomochain/tomox-stats/app"
+	"github.com/tomochain/tomox-stats/errors"
+	"github.com/tomochain/tomox-stats/utils/httputils"
+)
+
+// getAPIKey get the api key of a request, sent as "Authorization: Bearer <key>"
+func getAPIKey(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return ""
+	}
+	return strings.TrimPrefix(auth, "Bearer ")
+}
+
+// isAuthorized check the api key of a request, false if no api key is configured
+func isAuthorized(r *http.Request) bool {
+	if app.Config.ApiAuthKey == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(getAPIKey(r)), []byte(app.Config.ApiAuthKey)) == 1
+}
+
+// isReadRequest return true for read methods, other methods require the api key on every endpoint
+func isReadRequest(r *http.Request) bool {
+	return r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions
+}
+
+// adminOnly wraps the handler of an admin endpoint, requests without a valid api key are rejected whatever their method
+func adminOnly(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !isAuthorized(r) {
+			writeAPIError(w, errors.Unauthorized("api key required"))
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// rateLimiter counts requests by client in one minute windows
+type rateLimiter struct {
+	limit  int
+	window int64
+	counts map[string]int
+	mutex  sync.Mutex
+}
+
+func (l *rateLimiter) allow(client string) bool {
+	window := time.Now().Unix() / 60
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if window != l.window {
+		l.window = window
+		l.counts = make(map[string]int)
+	}
+	l.counts[client]++
+	return l.counts[client] <= l.limit
+}
+
+// parseTrustedProxies parse ip addresses and cidr ranges of trusted proxies
+func parseTrustedProxies(proxies []string) ([]*net.IPNet, error) {
+	var res []*net.IPNet
+	for _, p := range proxies {
+		if !strings.Contains(p, "/") {
+			if ip := net.ParseIP(p); ip != nil && ip.To4() != nil {
+				p += "/32"
+			} else {
+				p += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(p)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid trusted proxy %s", p)
+		}
+		res = append(res, network)
+	}
+	return res, nil
+}
+
+func isTrustedProxy(ip net.IP, trustedProxies []*net.IPNet) bool {
+	if ip == nil {
+		return false
+	}
+	for _, network := range trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// getClientAddress get the address of the client of a request
+// X-Forwarded-For is read only when the request comes from a trusted proxy, the client is its last address
+// which is not a trusted proxy, as addresses before it can be set by the client
+func getClientAddress(r *http.Request, trustedProxies []*net.IPNet) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !isTrustedProxy(net.ParseIP(host), trustedProxies) {
+		return host
+	}
+	var forwarded []string
+	for _, h := range r.Header[http.CanonicalHeaderKey("X-Forwarded-For")] {
+		forwarded = append(forwarded, strings.Split(h, ",")...)
+	}
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		address := strings.TrimSpace(forwarded[i])
+		ip := net.ParseIP(address)
+		if ip == nil {
+			break
+		}
+		host = address
+		if !isTrustedProxy(ip, trustedProxies) {
+			break
+		}
+	}
+	return host
+}
+
+// Authenticate middleware checks the api key of requests
+// write requests require the api key, admin endpoints also require it for read methods with adminOnly
+// read requests are public and limited to rateLimit requests by minute and client address unless they
+// have the api key, 0 for no limit. The client address is read from X-Forwarded-For of trustedProxies
+func Authenticate(rateLimit int, trustedProxies []string) (mux.MiddlewareFunc, error) {
+	proxies, err := parseTrustedProxies(trustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	limiter := &rateLimiter{limit: rateLimit}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authorized := isAuthorized(r)
+			if !authorized && getAPIKey(r) != "" && app.Config.ApiAuthKey != "" {
+				writeAPIError(w, errors.Unauthorized("invalid api key"))
+				return
+			}
+			if !authorized && !isReadRequest(r) {
+				writeAPIError(w, errors.Unauthorized("api key required"))
+				return
+			}
+			if !authorized && rateLimit > 0 && !limiter.allow(getClientAddress(r, proxies)) {
+				writeAPIError(w, errors.TooManyRequests(rateLimit))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}, nil
+}
+
+func writeAPIError(w http.ResponseWriter, err *errors.APIError) {
+	httputils.Write(w, err.StatusCode(), err)
+}
@@ -0,0 +1,86 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tomochain/tomox-stats/app"
+	"github.com/tomochain/tomox-stats/errors"
+)
+
+type testAuthRequest struct {
+	method     string
+	remoteAddr string
+	forwarded  string
+	key        string
+	admin      bool
+	code       int
+	errorCode  string
+}
+
+func TestAuthenticate(t *testing.T) {
+	apiKey := app.Config.ApiAuthKey
+	app.Config.ApiAuthKey = "key"
+	defer func() {
+		app.Config.ApiAuthKey = apiKey
+	}()
+
+	authenticate, err := Authenticate(2, []string{"10.0.0.1", "192.168.0.0/16"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = Authenticate(2, []string{"proxy"})
+	assert.Error(t, err)
+
+	requests := []testAuthRequest{
+		{method: "GET", remoteAddr: "1.1.1.1:1000", code: http.StatusOK},
+		{method: "GET", remoteAddr: "1.1.1.1:1001", code: http.StatusOK},
+		{method: "GET", remoteAddr: "1.1.1.1:1002", code: http.StatusTooManyRequests, errorCode: "TOO_MANY_REQUESTS"},
+		// the api key lifts the rate limit
+		{method: "GET", remoteAddr: "1.1.1.1:1003", key: "key", code: http.StatusOK},
+		{method: "GET", remoteAddr: "1.1.1.1:1004", key: "invalid", code: http.StatusUnauthorized, errorCode: "UNAUTHORIZED"},
+		// X-Forwarded-For of an untrusted address is not read
+		{method: "GET", remoteAddr: "1.1.1.1:1005", forwarded: "2.2.2.2", code: http.StatusTooManyRequests, errorCode: "TOO_MANY_REQUESTS"},
+		// clients behind trusted proxies are limited by their own address
+		{method: "GET", remoteAddr: "10.0.0.1:1000", forwarded: "1.1.1.1, 3.3.3.3, 192.168.1.1", code: http.StatusOK},
+		{method: "GET", remoteAddr: "10.0.0.1:1001", forwarded: "3.3.3.3", code: http.StatusOK},
+		{method: "GET", remoteAddr: "10.0.0.1:1002", forwarded: "4.4.4.4, 3.3.3.3", code: http.StatusTooManyRequests, errorCode: "TOO_MANY_REQUESTS"},
+		{method: "GET", remoteAddr: "10.0.0.1:1003", forwarded: "4.4.4.4", code: http.StatusOK},
+		// write methods and admin endpoints require the api key
+		{method: "POST", remoteAddr: "5.5.5.5:1000", code: http.StatusUnauthorized, errorCode: "UNAUTHORIZED"},
+		{method: "POST", remoteAddr: "5.5.5.5:1001", key: "key", code: http.StatusOK},
+		{method: "GET", remoteAddr: "5.5.5.5:1002", admin: true, code: http.StatusUnauthorized, errorCode: "UNAUTHORIZED"},
+		{method: "GET", remoteAddr: "5.5.5.5:1003", admin: true, key: "key", code: http.StatusOK},
+	}
+
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+	public := authenticate(http.HandlerFunc(ok))
+	admin := authenticate(adminOnly(ok))
+	for i, req := range requests {
+		r := httptest.NewRequest(req.method, "/stats/trades", nil)
+		r.RemoteAddr = req.remoteAddr
+		if req.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", req.forwarded)
+		}
+		if req.key != "" {
+			r.Header.Set("Authorization", "Bearer "+req.key)
+		}
+		w := httptest.NewRecorder()
+		if req.admin {
+			admin.ServeHTTP(w, r)
+		} else {
+			public.ServeHTTP(w, r)
+		}
+		assert.Equal(t, req.code, w.Code, "request %d", i)
+		if req.errorCode != "" {
+			var res errors.APIError
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res), "request %d", i)
+			assert.Equal(t, req.errorCode, res.ErrorCode, "request %d", i)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/globalsign/mgo/bson"
+	"github.com/gorilla/mux"
+	"github.com/tomochain/tomox-stats/services"
+	"github.com/tomochain/tomox-stats/types"
+	"github.com/tomochain/tomox-stats/utils/httputils"
+)
+
+type campaignEndpoint struct {
+	campaignService *services.CampaignService
+}
+
+// ServeCampaignResource sets up the routing of campaign endpoints and the corresponding handlers.
+// creating, updating and deleting campaigns require the api key
+func ServeCampaignResource(
+	r *mux.Router,
+	campaignService *services.CampaignService,
+) {
+	e := &campaignEndpoint{campaignService}
+	r.HandleFunc("/stats/campaigns", e.handleGetCampaigns).Methods("GET")
+	r.HandleFunc("/stats/campaigns", adminOnly(e.handleCreateCampaign)).Methods("POST")
+	r.HandleFunc("/stats/campaigns/{id}", e.handleGetCampaign).Methods("GET")
+	r.HandleFunc("/stats/campaigns/{id}", adminOnly(e.handleUpdateCampaign)).Methods("PUT")
+	r.HandleFunc("/stats/campaigns/{id}", adminOnly(e.handleDeleteCampaign)).Methods("DELETE")
+	r.HandleFunc("/stats/campaigns/{id}/leaderboard", e.handleGetLeaderboard).Methods("GET")
+}
+
+func (e *campaignEndpoint) handleGetCampaigns(w http.ResponseWriter, r *http.Request) {
+	var relayerAddress common.Address
+	rAddress := r.URL.Query().Get("relayerAddress")
+	if rAddress != "" {
+		if !common.IsHexAddress(rAddress) {
+			httputils.WriteError(w, http.StatusBadRequest, "Invalid relayer address")
+			return
+		}
+		relayerAddress = common.HexToAddress(rAddress)
+	}
+	res, err := e.campaignService.GetAll(relayerAddress)
+	if err != nil {
+		httputils.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	httputils.WriteJSON(w, http.StatusOK, res)
+}
+
+// getCampaign get campaign of the request, write the error response if it is not found
+func (e *campaignEndpoint) getCampaign(w http.ResponseWriter, r *http.Request) *types.Campaign {
+	id := mux.Vars(r)["id"]
+	if !bson.IsObjectIdHex(id) {
+		httputils.WriteError(w, http.StatusBadRequest, "Invalid campaign id")
+		return nil
+	}
+	c, err := e.campaignService.GetByID(bson.ObjectIdHex(id))
+	if err != nil {
+		httputils.WriteError(w, http.StatusInternalServerError, err.Error())
+		return nil
+	}
+	if c == nil {
+		httputils.WriteError(w, http.StatusNotFound, "Campaign not found")
+		return nil
+	}
+	return c
+}
+
+func (e *campaignEndpoint) handleGetCampaign(w http.ResponseWriter, r *http.Request) {
+	c := e.getCampaign(w, r)
+	if c == nil {
+		return
+	}
+	httputils.WriteJSON(w, http.StatusOK, c)
+}
+
+func (e *campaignEndpoint) handleCreateCampaign(w http.ResponseWriter, r *http.Request) {
+	c := &types.Campaign{}
+	if err := json.NewDecoder(r.Body).Decode(c); err != nil {
+		httputils.WriteError(w, http.StatusBadRequest, "Invalid payload")
+		return
+	}
+	if err := c.Validate(); err != nil {
+		httputils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := e.campaignService.Create(c); err != nil {
+		httputils.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	httputils.WriteJSON(w, http.StatusCreated, c)
+}
+
+func (e *campaignEndpoint) handleUpdateCampaign(w http.ResponseWriter, r *http.Request) {
+	last := e.getCampaign(w, r)
+	if last == nil {
+		return
+	}
+	c := &types.Campaign{}
+	if err := json.NewDecoder(r.Body).Decode(c); err != nil {
+		httputils.WriteError(w, http.StatusBadRequest, "Invalid payload")
+		return
+	}
+	c.ID = last.ID
+	if err := c.Validate(); err != nil {
+		httputils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	err := e.campaignService.Update(c)
+	if err == services.ErrCampaignFinalized {
+		httputils.WriteError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		httputils.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	httputils.WriteJSON(w, http.StatusOK, c)
+}
+
+func (e *campaignEndpoint) handleDeleteCampaign(w http.ResponseWriter, r *http.Request) {
+	c := e.getCampaign(w, r)
+	if c == nil {
+		return
+	}
+	err := e.campaignService.Delete(c.ID)
+	if err == services.ErrCampaignFinalized {
+		httputils.WriteError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		httputils.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	httputils.WriteMessage(w, http.StatusOK, "Campaign removed")
+}
+
+func (e *campaignEndpoint) handleGetLeaderboard(w http.ResponseWriter, r *http.Request) {
+	c := e.getCampaign(w, r)
+	if c == nil {
+		return
+	}
+	res, err := e.campaignService.GetLeaderboard(c)
+	if err != nil {
+		httputils.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if res == nil {
+		httputils.WriteError(w, http.StatusNotFound, "Leaderboard not found")
+		return
+	}
+	httputils.WriteJSON(w, http.StatusOK, res)
+}
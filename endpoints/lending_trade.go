@@ -2,6 +2,7 @@ package endpoints
 
 import (
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -12,6 +13,7 @@ import (
 
 type lendingTradeEndpoint struct {
 	lendingtradeService *services.LendingTradeService
+	priceService        *services.PriceService
 }
 
 // ServeLendingTradeResource sets up the routing of trade endpoints and the corresponding handlers.
@@ -19,9 +21,14 @@ type lendingTradeEndpoint struct {
 func ServeLendingTradeResource(
 	r *mux.Router,
 	lendingtradeService *services.LendingTradeService,
+	priceService *services.PriceService,
 ) {
-	e := &lendingTradeEndpoint{lendingtradeService}
+	e := &lendingTradeEndpoint{lendingtradeService, priceService}
+	// an empty relayerAddress counts the distinct users of all relayers
 	r.HandleFunc("/stats/lending/users/count", e.handleGetNumberUser)
+	r.HandleFunc("/stats/lending/volume", e.handleGetLendingVolume)
+	r.HandleFunc("/stats/lending/markets", e.handleGetLendingMarkets)
+	r.HandleFunc("/stats/lending/leaderboard", e.handleGetLendingLeaderboard)
 }
 
 func (e *lendingTradeEndpoint) handleGetNumberUser(w http.ResponseWriter, r *http.Request) {
@@ -30,12 +37,18 @@ func (e *lendingTradeEndpoint) handleGetNumberUser(w http.ResponseWriter, r *htt
 	v := r.URL.Query()
 	rAddress := v.Get("relayerAddress")
 	duration := v.Get("duration")
+	role := v.Get("role")
 
 	type NumberTrader struct {
 		ActiveUser int    `json:"activeUser"`
 		Duration   string `json:"duration"`
+		Role       string `json:"role,omitempty"`
 	}
 	var res NumberTrader
+	if !e.checkRole(w, role) {
+		return
+	}
+	res.Role = role
 	if rAddress != "" {
 		if !common.IsHexAddress(rAddress) {
 			httputils.WriteError(w, http.StatusBadRequest, "Invalid relayer address")
@@ -45,27 +58,178 @@ func (e *lendingTradeEndpoint) handleGetNumberUser(w http.ResponseWriter, r *htt
 	}
 	if duration == "" {
 		res.Duration = duration
-		res.ActiveUser = e.lendingtradeService.GetNumberTraderByTime(relayerAddress, 0, 0)
+		res.ActiveUser = e.lendingtradeService.GetNumberTraderByTime(relayerAddress, role, 0, 0)
 		httputils.WriteJSON(w, http.StatusOK, res)
 		return
 	}
 	if duration == "1d" {
 		res.Duration = duration
-		res.ActiveUser = e.lendingtradeService.GetNumberTraderByTime(relayerAddress, time.Now().AddDate(0, 0, -1).Unix(), 0)
+		res.ActiveUser = e.lendingtradeService.GetNumberTraderByTime(relayerAddress, role, time.Now().AddDate(0, 0, -1).Unix(), 0)
 		httputils.WriteJSON(w, http.StatusOK, res)
 		return
 	}
 	if duration == "7d" {
 		res.Duration = duration
-		res.ActiveUser = e.lendingtradeService.GetNumberTraderByTime(relayerAddress, time.Now().AddDate(0, 0, -7).Unix(), 0)
+		res.ActiveUser = e.lendingtradeService.GetNumberTraderByTime(relayerAddress, role, time.Now().AddDate(0, 0, -7).Unix(), 0)
 		httputils.WriteJSON(w, http.StatusOK, res)
 		return
 	}
 	if duration == "30d" {
 		res.Duration = duration
-		res.ActiveUser = e.lendingtradeService.GetNumberTraderByTime(relayerAddress, time.Now().AddDate(0, 0, -30).Unix(), 0)
+		res.ActiveUser = e.lendingtradeService.GetNumberTraderByTime(relayerAddress, role, time.Now().AddDate(0, 0, -30).Unix(), 0)
 		httputils.WriteJSON(w, http.StatusOK, res)
 		return
 	}
 	httputils.WriteJSON(w, http.StatusBadRequest, "duration must be empty/1d/7d/30d")
 }
+
+type lendingMarketParams struct {
+	relayerAddress common.Address
+	lendingToken   common.Address
+	term           uint64
+	role           string
+	from           int64
+	to             int64
+}
+
+// checkRole check role param of lending endpoints, return false if an error is written
+func (e *lendingTradeEndpoint) checkRole(w http.ResponseWriter, role string) bool {
+	if !e.lendingtradeService.IsValidRole(role) {
+		httputils.WriteError(w, http.StatusBadRequest, "role must be empty/borrower/investor/any")
+		return false
+	}
+	return true
+}
+
+// parseLendingMarketParams parse query params of lending statistics endpoints, return false if an error is written
+func (e *lendingTradeEndpoint) parseLendingMarketParams(w http.ResponseWriter, r *http.Request) (*lendingMarketParams, bool) {
+	params := &lendingMarketParams{}
+	v := r.URL.Query()
+	rAddress := v.Get("relayerAddress")
+	lToken := v.Get("lendingToken")
+	term := v.Get("term")
+	role := v.Get("role")
+	fromParam := v.Get("from")
+	toParam := v.Get("to")
+
+	if rAddress != "" {
+		if !common.IsHexAddress(rAddress) {
+			httputils.WriteError(w, http.StatusBadRequest, "Invalid relayer address")
+			return nil, false
+		}
+		params.relayerAddress = common.HexToAddress(rAddress)
+	}
+	if lToken != "" {
+		if !common.IsHexAddress(lToken) {
+			httputils.WriteError(w, http.StatusBadRequest, "Invalid lending token address")
+			return nil, false
+		}
+		params.lendingToken = common.HexToAddress(lToken)
+	}
+	if term != "" {
+		t, err := strconv.ParseUint(term, 10, 64)
+		if err != nil {
+			httputils.WriteError(w, http.StatusBadRequest, "Invalid term")
+			return nil, false
+		}
+		params.term = t
+	}
+	if !e.checkRole(w, role) {
+		return nil, false
+	}
+	params.role = role
+	if toParam != "" {
+		t, _ := strconv.Atoi(toParam)
+		params.to = int64(t)
+	}
+	if fromParam != "" {
+		t, _ := strconv.Atoi(fromParam)
+		params.from = int64(t)
+	}
+	return params, true
+}
+
+// getPriceService get price service if volumes are valued in USD by currency param, return false if an error is written
+func (e *lendingTradeEndpoint) getPriceService(w http.ResponseWriter, r *http.Request) (*services.PriceService, bool) {
+	currency := r.URL.Query().Get("currency")
+	if !e.priceService.IsValidCurrency(currency) {
+		httputils.WriteError(w, http.StatusBadRequest, "Invalid currency")
+		return nil, false
+	}
+	if currency == "" {
+		return nil, true
+	}
+	return e.priceService, true
+}
+
+func (e *lendingTradeEndpoint) handleGetLendingVolume(w http.ResponseWriter, r *http.Request) {
+	params, ok := e.parseLendingMarketParams(w, r)
+	if !ok {
+		return
+	}
+	priceService, ok := e.getPriceService(w, r)
+	if !ok {
+		return
+	}
+	res := e.lendingtradeService.GetLendingVolume(params.relayerAddress, params.lendingToken, params.term, params.role, params.from, params.to, priceService)
+	httputils.WriteJSON(w, http.StatusOK, res)
+}
+
+func (e *lendingTradeEndpoint) handleGetLendingMarkets(w http.ResponseWriter, r *http.Request) {
+	params, ok := e.parseLendingMarketParams(w, r)
+	if !ok {
+		return
+	}
+	priceService, ok := e.getPriceService(w, r)
+	if !ok {
+		return
+	}
+	res := e.lendingtradeService.GetLendingMarkets(params.relayerAddress, params.lendingToken, params.term, params.role, params.from, params.to, priceService)
+	httputils.WriteJSON(w, http.StatusOK, res)
+}
+
+func (e *lendingTradeEndpoint) handleGetLendingLeaderboard(w http.ResponseWriter, r *http.Request) {
+	params, ok := e.parseLendingMarketParams(w, r)
+	if !ok {
+		return
+	}
+	v := r.URL.Query()
+	role := params.role
+	sortBy := v.Get("sortBy")
+	uAddress := v.Get("userAddress")
+	topParam := v.Get("top")
+
+	if role == "" {
+		role = services.LendingRoleBorrower
+	}
+	if sortBy == "" {
+		sortBy = services.LendingSortVolume
+	}
+	if (params.lendingToken == common.Address{}) {
+		httputils.WriteError(w, http.StatusBadRequest, "lendingToken is required")
+		return
+	}
+	if !e.lendingtradeService.IsValidRanking(role, sortBy) {
+		httputils.WriteError(w, http.StatusBadRequest, "role must be borrower/investor, sortBy must be volume/interest/count")
+		return
+	}
+	var userAddress common.Address
+	if uAddress != "" {
+		if !common.IsHexAddress(uAddress) {
+			httputils.WriteError(w, http.StatusBadRequest, "Invalid user address")
+			return
+		}
+		userAddress = common.HexToAddress(uAddress)
+	}
+	top := 10
+	if topParam != "" {
+		t, err := strconv.Atoi(topParam)
+		if err != nil || t <= 0 {
+			httputils.WriteError(w, http.StatusBadRequest, "Invalid top")
+			return
+		}
+		top = t
+	}
+	res := e.lendingtradeService.GetTopLendingUsers(params.relayerAddress, userAddress, params.lendingToken, params.term, params.from, params.to, role, sortBy, top)
+	httputils.WriteJSON(w, http.StatusOK, res)
+}
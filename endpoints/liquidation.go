@@ -0,0 +1,105 @@
+package endpoints
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gorilla/mux"
+	"github.com/tomochain/tomox-stats/services"
+	"github.com/tomochain/tomox-stats/types"
+	"github.com/tomochain/tomox-stats/utils/httputils"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 1000
+)
+
+type liquidationEndpoint struct {
+	liquidationService *services.LiquidationService
+}
+
+// ServeLiquidationResource sets up the routing of liquidation endpoints and the corresponding handlers.
+func ServeLiquidationResource(
+	r *mux.Router,
+	liquidationService *services.LiquidationService,
+) {
+	e := &liquidationEndpoint{liquidationService}
+	r.HandleFunc("/stats/lending/liquidations", e.handleGetLiquidations)
+	r.HandleFunc("/stats/lending/risk", e.handleGetLoansAtRisk)
+}
+
+func (e *liquidationEndpoint) handleGetLiquidations(w http.ResponseWriter, r *http.Request) {
+	spec := &types.LiquidationSpec{}
+	v := r.URL.Query()
+	addresses := map[string]*common.Address{
+		"relayerAddress":  &spec.RelayerAddress,
+		"borrower":        &spec.Borrower,
+		"lendingToken":    &spec.LendingToken,
+		"collateralToken": &spec.CollateralToken,
+	}
+	for param, address := range addresses {
+		if a := v.Get(param); a != "" {
+			if !common.IsHexAddress(a) {
+				httputils.WriteError(w, http.StatusBadRequest, "Invalid "+param)
+				return
+			}
+			*address = common.HexToAddress(a)
+		}
+	}
+	if fromParam := v.Get("from"); fromParam != "" {
+		t, _ := strconv.Atoi(fromParam)
+		spec.DateFrom = int64(t)
+	}
+	if toParam := v.Get("to"); toParam != "" {
+		t, _ := strconv.Atoi(toParam)
+		spec.DateTo = int64(t)
+	}
+
+	offset := 0
+	size := defaultPageSize
+	if o := v.Get("offset"); o != "" {
+		offset, _ = strconv.Atoi(o)
+	}
+	if s := v.Get("size"); s != "" {
+		size, _ = strconv.Atoi(s)
+	}
+	if offset < 0 || size <= 0 || size > maxPageSize {
+		httputils.WriteError(w, http.StatusBadRequest, "Invalid offset or size")
+		return
+	}
+
+	res, err := e.liquidationService.GetLiquidations(spec, offset, size)
+	if err != nil {
+		httputils.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	httputils.WriteJSON(w, http.StatusOK, res)
+}
+
+func (e *liquidationEndpoint) handleGetLoansAtRisk(w http.ResponseWriter, r *http.Request) {
+	var relayerAddress common.Address
+	v := r.URL.Query()
+	rAddress := v.Get("relayerAddress")
+	top := v.Get("top")
+	topLoans := 10
+
+	if rAddress != "" {
+		if !common.IsHexAddress(rAddress) {
+			httputils.WriteError(w, http.StatusBadRequest, "Invalid relayer address")
+			return
+		}
+		relayerAddress = common.HexToAddress(rAddress)
+	}
+	if top != "" {
+		t, err := strconv.Atoi(top)
+		if err != nil || t <= 0 {
+			httputils.WriteError(w, http.StatusBadRequest, "Invalid top")
+			return
+		}
+		topLoans = t
+	}
+
+	httputils.WriteJSON(w, http.StatusOK, e.liquidationService.GetLoansAtRisk(relayerAddress, topLoans))
+}
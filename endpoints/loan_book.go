@@ -0,0 +1,85 @@
+package endpoints
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gorilla/mux"
+	"github.com/tomochain/tomox-stats/services"
+	"github.com/tomochain/tomox-stats/utils/httputils"
+)
+
+const (
+	// defaultMaturityDays is the window of upcoming maturities if no end time is given
+	defaultMaturityDays = 30
+)
+
+type loanBookEndpoint struct {
+	loanBookService *services.LoanBookService
+}
+
+// ServeLoanBookResource sets up the routing of open loan endpoints and the corresponding handlers.
+func ServeLoanBookResource(
+	r *mux.Router,
+	loanBookService *services.LoanBookService,
+) {
+	e := &loanBookEndpoint{loanBookService}
+	r.HandleFunc("/stats/lending/book", e.handleGetLoanBook)
+	r.HandleFunc("/stats/lending/maturities", e.handleGetMaturities)
+}
+
+func (e *loanBookEndpoint) handleGetLoanBook(w http.ResponseWriter, r *http.Request) {
+	var relayerAddress common.Address
+	v := r.URL.Query()
+	rAddress := v.Get("relayerAddress")
+
+	if rAddress != "" {
+		if !common.IsHexAddress(rAddress) {
+			httputils.WriteError(w, http.StatusBadRequest, "Invalid relayer address")
+			return
+		}
+		relayerAddress = common.HexToAddress(rAddress)
+	}
+
+	httputils.WriteJSON(w, http.StatusOK, e.loanBookService.GetLoanBook(relayerAddress))
+}
+
+func (e *loanBookEndpoint) handleGetMaturities(w http.ResponseWriter, r *http.Request) {
+	var relayerAddress common.Address
+	var lendingToken common.Address
+	v := r.URL.Query()
+	rAddress := v.Get("relayerAddress")
+	lToken := v.Get("lendingToken")
+	fromParam := v.Get("from")
+	toParam := v.Get("to")
+
+	if rAddress != "" {
+		if !common.IsHexAddress(rAddress) {
+			httputils.WriteError(w, http.StatusBadRequest, "Invalid relayer address")
+			return
+		}
+		relayerAddress = common.HexToAddress(rAddress)
+	}
+	if lToken != "" {
+		if !common.IsHexAddress(lToken) {
+			httputils.WriteError(w, http.StatusBadRequest, "Invalid lending token address")
+			return
+		}
+		lendingToken = common.HexToAddress(lToken)
+	}
+
+	from := time.Now().Unix()
+	to := time.Now().AddDate(0, 0, defaultMaturityDays).Unix()
+	if fromParam != "" {
+		t, _ := strconv.Atoi(fromParam)
+		from = int64(t)
+	}
+	if toParam != "" {
+		t, _ := strconv.Atoi(toParam)
+		to = int64(t)
+	}
+
+	httputils.WriteJSON(w, http.StatusOK, e.loanBookService.GetMaturities(relayerAddress, lendingToken, from, to))
+}
@@ -0,0 +1,91 @@
+package endpoints
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gorilla/mux"
+	"github.com/tomochain/tomox-stats/services"
+	"github.com/tomochain/tomox-stats/utils/httputils"
+)
+
+type ohlcvEndpoint struct {
+	ohlcvService *services.OHLCVService
+}
+
+// ServeOHLCVResource sets up the routing of ohlcv endpoints and the corresponding handlers.
+func ServeOHLCVResource(
+	r *mux.Router,
+	ohlcvService *services.OHLCVService,
+) {
+	e := &ohlcvEndpoint{ohlcvService}
+	r.HandleFunc("/stats/trades/ohlcv", e.handleGetOHLCV)
+}
+
+func (e *ohlcvEndpoint) handleGetOHLCV(w http.ResponseWriter, r *http.Request) {
+
+	var baseToken common.Address
+	var quoteToken common.Address
+	var relayerAddress common.Address
+	var from int64
+	var to int64
+	var duration int64
+	v := r.URL.Query()
+	bt := v.Get("baseToken")
+	qt := v.Get("quoteToken")
+	rAddress := v.Get("relayerAddress")
+	fromParam := v.Get("from")
+	toParam := v.Get("to")
+	timeInterval := v.Get("timeInterval")
+	timeUnit := v.Get("timeUnit")
+
+	if bt == "" || !common.IsHexAddress(bt) {
+		httputils.WriteError(w, http.StatusBadRequest, "Invalid basetoken address")
+		return
+	}
+	baseToken = common.HexToAddress(bt)
+
+	if qt == "" || !common.IsHexAddress(qt) {
+		httputils.WriteError(w, http.StatusBadRequest, "Invalid quotetoken address")
+		return
+	}
+	quoteToken = common.HexToAddress(qt)
+
+	if rAddress != "" {
+		if !common.IsHexAddress(rAddress) {
+			httputils.WriteError(w, http.StatusBadRequest, "Invalid relayer address")
+			return
+		}
+		relayerAddress = common.HexToAddress(rAddress)
+	}
+
+	duration = 1
+	if timeInterval != "" {
+		t, err := strconv.Atoi(timeInterval)
+		if err != nil {
+			httputils.WriteError(w, http.StatusBadRequest, "Invalid time interval")
+			return
+		}
+		duration = int64(t)
+	}
+	if timeUnit == "" {
+		timeUnit = "hour"
+	}
+	if !e.ohlcvService.IsValidDuration(duration, timeUnit) {
+		httputils.WriteError(w, http.StatusBadRequest, "Time interval is not supported")
+		return
+	}
+
+	if toParam != "" {
+		t, _ := strconv.Atoi(toParam)
+		to = int64(t)
+	}
+	if fromParam != "" {
+		t, _ := strconv.Atoi(fromParam)
+		from = int64(t)
+	}
+
+	res := e.ohlcvService.GetOHLCV(relayerAddress, baseToken, quoteToken, duration, timeUnit, from, to)
+	httputils.WriteJSON(w, http.StatusOK, res)
+}
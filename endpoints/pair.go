@@ -0,0 +1,52 @@
+package endpoints
+
+import (
+	"net/http"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gorilla/mux"
+	"github.com/tomochain/tomox-stats/services"
+	"github.com/tomochain/tomox-stats/utils/httputils"
+)
+
+type pairEndpoint struct {
+	pairService  *services.PairService
+	priceService *services.PriceService
+}
+
+// ServePairResource sets up the routing of pair endpoints and the corresponding handlers.
+func ServePairResource(
+	r *mux.Router,
+	pairService *services.PairService,
+	priceService *services.PriceService,
+) {
+	e := &pairEndpoint{pairService, priceService}
+	r.HandleFunc("/stats/pairs", e.handleGetPairTickers).Methods("GET")
+}
+
+func (e *pairEndpoint) handleGetPairTickers(w http.ResponseWriter, r *http.Request) {
+	var relayerAddress common.Address
+	rAddress := r.URL.Query().Get("relayerAddress")
+	if rAddress != "" {
+		if !common.IsHexAddress(rAddress) {
+			httputils.WriteError(w, http.StatusBadRequest, "Invalid relayer address")
+			return
+		}
+		relayerAddress = common.HexToAddress(rAddress)
+	}
+	currency := r.URL.Query().Get("currency")
+	if !e.priceService.IsValidCurrency(currency) {
+		httputils.WriteError(w, http.StatusBadRequest, "Invalid currency")
+		return
+	}
+	var priceService *services.PriceService
+	if currency != "" {
+		priceService = e.priceService
+	}
+	res, err := e.pairService.GetPairTickers(relayerAddress, priceService)
+	if err != nil {
+		httputils.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	httputils.WriteJSON(w, http.StatusOK, res)
+}
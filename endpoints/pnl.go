@@ -0,0 +1,66 @@
+package endpoints
+
+import (
+	"net/http"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gorilla/mux"
+	"github.com/tomochain/tomox-stats/services"
+	"github.com/tomochain/tomox-stats/utils/httputils"
+)
+
+type pnlEndpoint struct {
+	pnlService *services.PnLService
+}
+
+// ServePnLResource sets up the routing of position endpoints and the corresponding handlers.
+func ServePnLResource(
+	r *mux.Router,
+	pnlService *services.PnLService,
+) {
+	e := &pnlEndpoint{pnlService}
+	r.HandleFunc("/stats/trades/users/{address}/pnl", e.handleGetUserPnL)
+}
+
+func (e *pnlEndpoint) handleGetUserPnL(w http.ResponseWriter, r *http.Request) {
+	var baseToken common.Address
+	var quoteToken common.Address
+	v := r.URL.Query()
+	bt := v.Get("baseToken")
+	qt := v.Get("quoteToken")
+	method := v.Get("method")
+
+	addr := mux.Vars(r)["address"]
+	if !common.IsHexAddress(addr) {
+		httputils.WriteError(w, http.StatusBadRequest, "Invalid user address")
+		return
+	}
+	userAddress := common.HexToAddress(addr)
+
+	if bt != "" {
+		if !common.IsHexAddress(bt) {
+			httputils.WriteError(w, http.StatusBadRequest, "Invalid basetoken address")
+			return
+		}
+		baseToken = common.HexToAddress(bt)
+	}
+
+	if qt != "" {
+		if !common.IsHexAddress(qt) {
+			httputils.WriteError(w, http.StatusBadRequest, "Invalid quotetoken address")
+			return
+		}
+		quoteToken = common.HexToAddress(qt)
+	}
+
+	if method == "" {
+		method = services.PnLMethodFIFO
+	}
+	if !e.pnlService.IsValidMethod(method) {
+		httputils.WriteError(w, http.StatusBadRequest, "Invalid method")
+		return
+	}
+
+	res := e.pnlService.GetUserPositions(userAddress, baseToken, quoteToken, method)
+	httputils.WriteJSON(w, http.StatusOK, res)
+}
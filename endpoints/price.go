@@ -0,0 +1,26 @@
+package endpoints
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/tomochain/tomox-stats/services"
+	"github.com/tomochain/tomox-stats/utils/httputils"
+)
+
+type priceEndpoint struct {
+	priceService *services.PriceService
+}
+
+// ServePriceResource sets up the routing of price endpoints and the corresponding handlers.
+func ServePriceResource(
+	r *mux.Router,
+	priceService *services.PriceService,
+) {
+	e := &priceEndpoint{priceService}
+	r.HandleFunc("/stats/prices", e.handleGetPrices).Methods("GET")
+}
+
+func (e *priceEndpoint) handleGetPrices(w http.ResponseWriter, r *http.Request) {
+	httputils.WriteJSON(w, http.StatusOK, e.priceService.GetPrices())
+}
@@ -0,0 +1,100 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gorilla/mux"
+	"github.com/tomochain/tomox-stats/services"
+	"github.com/tomochain/tomox-stats/types"
+	"github.com/tomochain/tomox-stats/utils/httputils"
+)
+
+type relayerEndpoint struct {
+	relayerService *services.RelayerService
+}
+
+// ServeRelayerResource sets up the routing of relayer endpoints and the corresponding handlers.
+// updating relayer information requires the api key, fields missing from the payload are not changed
+func ServeRelayerResource(
+	r *mux.Router,
+	relayerService *services.RelayerService,
+) {
+	e := &relayerEndpoint{relayerService}
+	r.HandleFunc("/relayers", e.handleGetRelayers).Methods("GET")
+	r.HandleFunc("/relayers/{address}", e.handleGetRelayer).Methods("GET")
+	r.HandleFunc("/relayers/{address}", adminOnly(e.handleUpdateRelayer)).Methods("PUT")
+}
+
+func (e *relayerEndpoint) handleGetRelayers(w http.ResponseWriter, r *http.Request) {
+	res, err := e.relayerService.GetAll()
+	if err != nil {
+		httputils.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if res == nil {
+		httputils.WriteJSON(w, http.StatusOK, []types.Relayer{})
+		return
+	}
+	httputils.WriteJSON(w, http.StatusOK, res)
+}
+
+func (e *relayerEndpoint) handleGetRelayer(w http.ResponseWriter, r *http.Request) {
+	addr := mux.Vars(r)["address"]
+	if !common.IsHexAddress(addr) {
+		httputils.WriteError(w, http.StatusBadRequest, "Invalid relayer address")
+		return
+	}
+	res, err := e.relayerService.GetByAddress(common.HexToAddress(addr))
+	if err != nil {
+		httputils.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if res == nil {
+		httputils.WriteError(w, http.StatusNotFound, "Relayer not found")
+		return
+	}
+	httputils.WriteJSON(w, http.StatusOK, res)
+}
+
+func (e *relayerEndpoint) handleUpdateRelayer(w http.ResponseWriter, r *http.Request) {
+	addr := mux.Vars(r)["address"]
+	if !common.IsHexAddress(addr) {
+		httputils.WriteError(w, http.StatusBadRequest, "Invalid relayer address")
+		return
+	}
+	m := &types.RelayerMetadata{}
+	if err := json.NewDecoder(r.Body).Decode(m); err != nil {
+		httputils.WriteError(w, http.StatusBadRequest, "Invalid payload")
+		return
+	}
+	if m.Name != nil {
+		*m.Name = strings.TrimSpace(*m.Name)
+	}
+	if m.Domain != nil {
+		*m.Domain = strings.ToLower(strings.TrimSpace(*m.Domain))
+	}
+	if m.Logo != nil {
+		*m.Logo = strings.TrimSpace(*m.Logo)
+	}
+	if err := m.Validate(); err != nil {
+		httputils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	res, err := e.relayerService.UpdateMetadata(common.HexToAddress(addr), m)
+	if err == services.ErrRelayerDomainUsed {
+		httputils.WriteError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		httputils.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if res == nil {
+		httputils.WriteError(w, http.StatusNotFound, "Relayer not found")
+		return
+	}
+	httputils.WriteJSON(w, http.StatusOK, res)
+}
@@ -0,0 +1,38 @@
+package endpoints
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/tomochain/tomox-stats/services"
+	"github.com/tomochain/tomox-stats/utils/httputils"
+)
+
+type relayerDirectoryEndpoint struct {
+	relayerDirectoryService *services.RelayerDirectoryService
+}
+
+// ServeRelayerDirectoryResource sets up the routing of relayer directory endpoints and the corresponding handlers.
+func ServeRelayerDirectoryResource(
+	r *mux.Router,
+	relayerDirectoryService *services.RelayerDirectoryService,
+) {
+	e := &relayerDirectoryEndpoint{relayerDirectoryService}
+	r.HandleFunc("/stats/relayers", e.handleGetRelayers).Methods("GET")
+}
+
+// handleGetRelayers list registered relayers, sorted by 24h volume by default
+// the directory is refreshed every minute, volumes of quote tokens without USD price are listed apart
+func (e *relayerDirectoryEndpoint) handleGetRelayers(w http.ResponseWriter, r *http.Request) {
+	sortBy := r.URL.Query().Get("sortBy")
+	if !e.relayerDirectoryService.IsValidSort(sortBy) {
+		httputils.WriteError(w, http.StatusBadRequest, "sortBy must be volume/deposit")
+		return
+	}
+	res, err := e.relayerDirectoryService.GetRelayers(sortBy)
+	if err != nil {
+		httputils.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	httputils.WriteJSON(w, http.StatusOK, res)
+}
@@ -0,0 +1,108 @@
+package endpoints
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gorilla/mux"
+	"github.com/tomochain/tomox-stats/services"
+	"github.com/tomochain/tomox-stats/utils/httputils"
+)
+
+type relayerFlowEndpoint struct {
+	tradeService *services.TradeService
+	priceService *services.PriceService
+}
+
+// ServeRelayerFlowResource sets up the routing of cross relayer endpoints and the corresponding handlers.
+func ServeRelayerFlowResource(
+	r *mux.Router,
+	tradeService *services.TradeService,
+	priceService *services.PriceService,
+) {
+	e := &relayerFlowEndpoint{tradeService, priceService}
+	r.HandleFunc("/stats/relayers/flows", e.handleGetRelayerFlows)
+	r.HandleFunc("/stats/relayers/share", e.handleGetMarketShares)
+}
+
+type relayerFlowParams struct {
+	baseToken  common.Address
+	quoteToken common.Address
+	from       int64
+	to         int64
+}
+
+// parseRelayerFlowParams parse query params of cross relayer endpoints, return false if an error is written
+func parseRelayerFlowParams(w http.ResponseWriter, r *http.Request) (*relayerFlowParams, bool) {
+	params := &relayerFlowParams{}
+	v := r.URL.Query()
+	bt := v.Get("baseToken")
+	qt := v.Get("quoteToken")
+	fromParam := v.Get("from")
+	toParam := v.Get("to")
+
+	if bt != "" {
+		if !common.IsHexAddress(bt) {
+			httputils.WriteError(w, http.StatusBadRequest, "Invalid base token address")
+			return nil, false
+		}
+		params.baseToken = common.HexToAddress(bt)
+	}
+	if qt != "" {
+		if !common.IsHexAddress(qt) {
+			httputils.WriteError(w, http.StatusBadRequest, "Invalid quote token address")
+			return nil, false
+		}
+		params.quoteToken = common.HexToAddress(qt)
+	}
+	if toParam != "" {
+		t, _ := strconv.Atoi(toParam)
+		params.to = int64(t)
+	}
+	if fromParam != "" {
+		t, _ := strconv.Atoi(fromParam)
+		params.from = int64(t)
+	}
+	return params, true
+}
+
+func (e *relayerFlowEndpoint) handleGetRelayerFlows(w http.ResponseWriter, r *http.Request) {
+	params, ok := parseRelayerFlowParams(w, r)
+	if !ok {
+		return
+	}
+	currency := r.URL.Query().Get("currency")
+	if !e.priceService.IsValidCurrency(currency) {
+		httputils.WriteError(w, http.StatusBadRequest, "Invalid currency")
+		return
+	}
+	var priceService *services.PriceService
+	if currency != "" {
+		priceService = e.priceService
+	}
+	res := e.tradeService.GetRelayerFlows(params.baseToken, params.quoteToken, params.from, params.to, priceService)
+	httputils.WriteJSON(w, http.StatusOK, res)
+}
+
+// handleGetMarketShares default to the daily market share of the last 30 days
+func (e *relayerFlowEndpoint) handleGetMarketShares(w http.ResponseWriter, r *http.Request) {
+	params, ok := parseRelayerFlowParams(w, r)
+	if !ok {
+		return
+	}
+	interval := r.URL.Query().Get("interval")
+	if interval == "" {
+		interval = services.RevenueIntervalDay
+	}
+	if !e.tradeService.IsValidInterval(interval) {
+		httputils.WriteError(w, http.StatusBadRequest, "interval must be hour/day/month")
+		return
+	}
+	if params.from == 0 {
+		params.from = time.Now().AddDate(0, 0, -30).Unix()
+	}
+	res := e.tradeService.GetMarketShares(params.baseToken, params.quoteToken, params.from, params.to, interval)
+	httputils.WriteJSON(w, http.StatusOK, res)
+}
@@ -0,0 +1,68 @@
+package endpoints
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gorilla/mux"
+	"github.com/tomochain/tomox-stats/services"
+	"github.com/tomochain/tomox-stats/utils/httputils"
+)
+
+type revenueEndpoint struct {
+	revenueService *services.RevenueService
+}
+
+// ServeRevenueResource sets up the routing of relayer revenue endpoints and the corresponding handlers.
+func ServeRevenueResource(
+	r *mux.Router,
+	revenueService *services.RevenueService,
+) {
+	e := &revenueEndpoint{revenueService}
+	r.HandleFunc("/stats/relayers/{address}/revenue", e.handleGetRelayerRevenue)
+}
+
+// handleGetRelayerRevenue default to the daily revenue of the last 30 days
+func (e *revenueEndpoint) handleGetRelayerRevenue(w http.ResponseWriter, r *http.Request) {
+	v := r.URL.Query()
+	fromParam := v.Get("from")
+	toParam := v.Get("to")
+	interval := v.Get("interval")
+
+	addr := mux.Vars(r)["address"]
+	if !common.IsHexAddress(addr) {
+		httputils.WriteError(w, http.StatusBadRequest, "Invalid relayer address")
+		return
+	}
+	relayerAddress := common.HexToAddress(addr)
+
+	if interval == "" {
+		interval = services.RevenueIntervalDay
+	}
+	if !e.revenueService.IsValidInterval(interval) {
+		httputils.WriteError(w, http.StatusBadRequest, "interval must be hour/day/month")
+		return
+	}
+	to := time.Now().Unix()
+	if toParam != "" {
+		t, err := strconv.ParseInt(toParam, 10, 64)
+		if err != nil {
+			httputils.WriteError(w, http.StatusBadRequest, "Invalid to")
+			return
+		}
+		to = t
+	}
+	from := to - 30*24*60*60
+	if fromParam != "" {
+		t, err := strconv.ParseInt(fromParam, 10, 64)
+		if err != nil {
+			httputils.WriteError(w, http.StatusBadRequest, "Invalid from")
+			return
+		}
+		from = t
+	}
+	res := e.revenueService.GetRevenue(relayerAddress, from, to, interval)
+	httputils.WriteJSON(w, http.StatusOK, res)
+}
@@ -3,6 +3,7 @@ package endpoints
 import (
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -14,6 +15,7 @@ import (
 
 type tradeEndpoint struct {
 	tradeService *services.TradeService
+	priceService *services.PriceService
 }
 
 // ServeTradeResource sets up the routing of trade endpoints and the corresponding handlers.
@@ -21,13 +23,26 @@ type tradeEndpoint struct {
 func ServeTradeResource(
 	r *mux.Router,
 	tradeService *services.TradeService,
+	priceService *services.PriceService,
 ) {
-	e := &tradeEndpoint{tradeService}
+	e := &tradeEndpoint{tradeService, priceService}
+	r.HandleFunc("/stats/trades", e.handleGetTrades)
 	r.HandleFunc("/stats/trades/volume", e.handleQueryVolume)
 	r.HandleFunc("/stats/trades/total", e.handleQueryVolume)
 	r.HandleFunc("/stats/trades/volume24h", e.handleQuery24h)
 	r.HandleFunc("/stats/trades/top/pnl", e.handleGetRelayerTopPnLTrades)
 	r.HandleFunc("/stats/trades/users/count", e.handleGetNumberUser)
+	r.HandleFunc("/stats/trades/pending", e.handleGetPendingVolume)
+	r.HandleFunc("/stats/trades/cache/rebuild", adminOnly(e.handleRebuildCache)).Methods("POST")
+}
+
+// handleRebuildCache start the rebuild of relayer fees and flows of trade cache
+func (e *tradeEndpoint) handleRebuildCache(w http.ResponseWriter, r *http.Request) {
+	if !e.tradeService.RebuildRelayerStats() {
+		httputils.WriteError(w, http.StatusConflict, "Cache rebuild is already running")
+		return
+	}
+	httputils.WriteMessage(w, http.StatusAccepted, "Cache rebuild started")
 }
 
 // HandleGetTrades is responsible for getting pair's trade history requests
@@ -43,6 +58,7 @@ func (e *tradeEndpoint) handleQueryVolume(w http.ResponseWriter, r *http.Request
 	var topVolume int
 	v := r.URL.Query()
 	qt := v.Get("quoteToken")
+	currency := v.Get("currency")
 	fromParam := v.Get("from")
 	toParam := v.Get("to")
 	rAddress := v.Get("relayerAddress")
@@ -66,6 +82,11 @@ func (e *tradeEndpoint) handleQueryVolume(w http.ResponseWriter, r *http.Request
 
 	}
 
+	if !e.priceService.IsValidCurrency(currency) {
+		httputils.WriteError(w, http.StatusBadRequest, "Invalid currency")
+		return
+	}
+
 	if rAddress != "" {
 		if !common.IsHexAddress(rAddress) {
 			httputils.WriteError(w, http.StatusBadRequest, "Invalid relayer address")
@@ -92,7 +113,12 @@ func (e *tradeEndpoint) handleQueryVolume(w http.ResponseWriter, r *http.Request
 		}
 	}
 
-	res := e.tradeService.QueryVolume(relayerAddress, userAddress, baseTokens, quoteToken, from, to, topVolume)
+	var res []*types.UserVolume
+	if currency != "" {
+		res = e.tradeService.QueryVolumeUSD(relayerAddress, userAddress, baseTokens, quoteToken, from, to, topVolume, e.priceService)
+	} else {
+		res = e.tradeService.QueryVolume(relayerAddress, userAddress, baseTokens, quoteToken, from, to, topVolume)
+	}
 
 	if res == nil {
 
@@ -168,6 +194,7 @@ func (e *tradeEndpoint) handleQuery24h(w http.ResponseWriter, r *http.Request) {
 	var topVolume int
 	v := r.URL.Query()
 	qt := v.Get("quoteToken")
+	currency := v.Get("currency")
 	rAddress := v.Get("relayerAddress")
 	uaddr := v.Get("userAddress")
 	top := v.Get("top")
@@ -189,6 +216,11 @@ func (e *tradeEndpoint) handleQuery24h(w http.ResponseWriter, r *http.Request) {
 
 	}
 
+	if !e.priceService.IsValidCurrency(currency) {
+		httputils.WriteError(w, http.StatusBadRequest, "Invalid currency")
+		return
+	}
+
 	if rAddress != "" {
 		if !common.IsHexAddress(rAddress) {
 			httputils.WriteError(w, http.StatusBadRequest, "Invalid relayer address")
@@ -207,7 +239,12 @@ func (e *tradeEndpoint) handleQuery24h(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	res := e.tradeService.Query24hVolume(relayerAddress, userAddress, baseTokens, quoteToken, topVolume)
+	var res []*types.UserVolume
+	if currency != "" {
+		res = e.tradeService.Query24hVolumeUSD(relayerAddress, userAddress, baseTokens, quoteToken, topVolume, e.priceService)
+	} else {
+		res = e.tradeService.Query24hVolume(relayerAddress, userAddress, baseTokens, quoteToken, topVolume)
+	}
 
 	if res == nil {
 
@@ -228,6 +265,7 @@ func (e *tradeEndpoint) handleQueryTotal(w http.ResponseWriter, r *http.Request)
 	var to int64
 	v := r.URL.Query()
 	qt := v.Get("quoteToken")
+	currency := v.Get("currency")
 	fromParam := v.Get("from")
 	toParam := v.Get("to")
 	rAddress := v.Get("relayerAddress")
@@ -248,6 +286,11 @@ func (e *tradeEndpoint) handleQueryTotal(w http.ResponseWriter, r *http.Request)
 
 	}
 
+	if !e.priceService.IsValidCurrency(currency) {
+		httputils.WriteError(w, http.StatusBadRequest, "Invalid currency")
+		return
+	}
+
 	if rAddress != "" {
 		if !common.IsHexAddress(rAddress) {
 			httputils.WriteError(w, http.StatusBadRequest, "Invalid relayer address")
@@ -265,7 +308,12 @@ func (e *tradeEndpoint) handleQueryTotal(w http.ResponseWriter, r *http.Request)
 		from = int64(t)
 	}
 
-	res := e.tradeService.QueryTotal(relayerAddress, baseTokens, quoteToken, from, to)
+	var res *types.TradeVolume
+	if currency != "" {
+		res = e.tradeService.QueryTotalUSD(relayerAddress, baseTokens, quoteToken, from, to, e.priceService)
+	} else {
+		res = e.tradeService.QueryTotal(relayerAddress, baseTokens, quoteToken, from, to)
+	}
 
 	if res == nil {
 
@@ -344,3 +392,135 @@ func (e *tradeEndpoint) handleGetNumberUser(w http.ResponseWriter, r *http.Reque
 	}
 	httputils.WriteJSON(w, http.StatusBadRequest, "duration must be empty/1d/7d/30d")
 }
+
+func (e *tradeEndpoint) handleGetPendingVolume(w http.ResponseWriter, r *http.Request) {
+
+	var baseTokens []common.Address
+	var quoteToken common.Address
+	var relayerAddress common.Address
+	v := r.URL.Query()
+	qt := v.Get("quoteToken")
+	currency := v.Get("currency")
+	rAddress := v.Get("relayerAddress")
+	for _, bt := range v["baseToken"] {
+		if bt != "" {
+			if common.IsHexAddress(bt) {
+				baseTokens = append(baseTokens, common.HexToAddress(bt))
+			}
+		}
+	}
+
+	if qt != "" {
+		if !common.IsHexAddress(qt) {
+			httputils.WriteError(w, http.StatusBadRequest, "Invalid quotetoken address")
+			return
+		}
+		quoteToken = common.HexToAddress(qt)
+	}
+
+	if !e.priceService.IsValidCurrency(currency) {
+		httputils.WriteError(w, http.StatusBadRequest, "Invalid currency")
+		return
+	}
+	var priceService *services.PriceService
+	if currency != "" {
+		priceService = e.priceService
+	}
+
+	if rAddress != "" {
+		if !common.IsHexAddress(rAddress) {
+			httputils.WriteError(w, http.StatusBadRequest, "Invalid relayer address")
+			return
+		}
+		relayerAddress = common.HexToAddress(rAddress)
+	}
+
+	res := e.tradeService.GetPendingVolume(relayerAddress, baseTokens, quoteToken, priceService)
+	httputils.WriteJSON(w, http.StatusOK, res)
+}
+
+// handleGetTrades page through trade history from newest to oldest
+// next page is requested with the cursor returned as next
+func (e *tradeEndpoint) handleGetTrades(w http.ResponseWriter, r *http.Request) {
+	v := r.URL.Query()
+	rAddress := v.Get("relayerAddress")
+	bt := v.Get("baseToken")
+	qt := v.Get("quoteToken")
+	uAddress := v.Get("userAddress")
+	side := strings.ToUpper(v.Get("side"))
+	status := strings.ToUpper(v.Get("status"))
+	fromParam := v.Get("from")
+	toParam := v.Get("to")
+	cursorParam := v.Get("cursor")
+	sizeParam := v.Get("size")
+
+	spec := &types.TradeSpec{
+		Side:   side,
+		Status: status,
+	}
+	if rAddress != "" {
+		if !common.IsHexAddress(rAddress) {
+			httputils.WriteError(w, http.StatusBadRequest, "Invalid relayer address")
+			return
+		}
+		spec.RelayerAddress = common.HexToAddress(rAddress)
+	}
+	if bt != "" {
+		if !common.IsHexAddress(bt) {
+			httputils.WriteError(w, http.StatusBadRequest, "Invalid basetoken address")
+			return
+		}
+		spec.BaseToken = common.HexToAddress(bt).Hex()
+	}
+	if qt != "" {
+		if !common.IsHexAddress(qt) {
+			httputils.WriteError(w, http.StatusBadRequest, "Invalid quotetoken address")
+			return
+		}
+		spec.QuoteToken = common.HexToAddress(qt).Hex()
+	}
+	if uAddress != "" {
+		if !common.IsHexAddress(uAddress) {
+			httputils.WriteError(w, http.StatusBadRequest, "Invalid user address")
+			return
+		}
+		spec.UserAddress = common.HexToAddress(uAddress)
+	}
+	if side != "" && side != types.TradeSideBuy && side != types.TradeSideSell {
+		httputils.WriteError(w, http.StatusBadRequest, "side must be empty/buy/sell")
+		return
+	}
+	if toParam != "" {
+		t, _ := strconv.Atoi(toParam)
+		spec.DateTo = int64(t)
+	}
+	if fromParam != "" {
+		t, _ := strconv.Atoi(fromParam)
+		spec.DateFrom = int64(t)
+	}
+
+	var cursor *types.TradeCursor
+	if cursorParam != "" {
+		c, err := types.ParseTradeCursor(cursorParam)
+		if err != nil {
+			httputils.WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		cursor = c
+	}
+	size := defaultPageSize
+	if sizeParam != "" {
+		size, _ = strconv.Atoi(sizeParam)
+	}
+	if size <= 0 || size > maxPageSize {
+		httputils.WriteError(w, http.StatusBadRequest, "Invalid size")
+		return
+	}
+
+	res, err := e.tradeService.GetTradeHistory(spec, cursor, size)
+	if err != nil {
+		httputils.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	httputils.WriteJSON(w, http.StatusOK, res)
+}
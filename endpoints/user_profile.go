@@ -0,0 +1,37 @@
+package endpoints
+
+import (
+	"net/http"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gorilla/mux"
+	"github.com/tomochain/tomox-stats/services"
+	"github.com/tomochain/tomox-stats/utils/httputils"
+)
+
+type userProfileEndpoint struct {
+	userProfileService *services.UserProfileService
+}
+
+// ServeUserProfileResource sets up the routing of user profile endpoints and the corresponding handlers.
+func ServeUserProfileResource(
+	r *mux.Router,
+	userProfileService *services.UserProfileService,
+) {
+	e := &userProfileEndpoint{userProfileService}
+	r.HandleFunc("/stats/users/{address}", e.handleGetUserProfile).Methods("GET")
+}
+
+func (e *userProfileEndpoint) handleGetUserProfile(w http.ResponseWriter, r *http.Request) {
+	addr := mux.Vars(r)["address"]
+	if !common.IsHexAddress(addr) {
+		httputils.WriteError(w, http.StatusBadRequest, "Invalid user address")
+		return
+	}
+	res, err := e.userProfileService.GetUserProfile(common.HexToAddress(addr))
+	if err != nil {
+		httputils.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	httputils.WriteJSON(w, http.StatusOK, res)
+}
@@ -0,0 +1,133 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gorilla/mux"
+	"github.com/tomochain/tomox-stats/services"
+	"github.com/tomochain/tomox-stats/types"
+	"github.com/tomochain/tomox-stats/utils/httputils"
+)
+
+const (
+	// streamKeepAlive is the period of comments keeping idle streams open through proxies
+	streamKeepAlive = 30 * time.Second
+)
+
+type volumeStreamEndpoint struct {
+	volumeStreamService *services.VolumeStreamService
+}
+
+// ServeVolumeStreamResource sets up the routing of volume stream endpoints and the corresponding handlers.
+func ServeVolumeStreamResource(
+	r *mux.Router,
+	volumeStreamService *services.VolumeStreamService,
+) {
+	e := &volumeStreamEndpoint{volumeStreamService}
+	r.HandleFunc("/stats/trades/volume/stream", e.handleStreamVolume).Methods("GET")
+}
+
+// handleStreamVolume push user volumes of a relayer, pair or quote token as server sent events
+// the first event is a snapshot of the top users, then a delta event is sent for every trade changing them
+// window is the rolling time range of volumes: all, 24h, 7d or 30d
+func (e *volumeStreamEndpoint) handleStreamVolume(w http.ResponseWriter, r *http.Request) {
+	var relayerAddress common.Address
+	var baseToken common.Address
+	topVolume := 10
+
+	v := r.URL.Query()
+	rAddress := v.Get("relayerAddress")
+	bt := v.Get("baseToken")
+	qt := v.Get("quoteToken")
+	window := v.Get("window")
+	top := v.Get("top")
+
+	if !common.IsHexAddress(qt) {
+		httputils.WriteError(w, http.StatusBadRequest, "Invalid quote token address")
+		return
+	}
+	quoteToken := common.HexToAddress(qt)
+	if bt != "" {
+		if !common.IsHexAddress(bt) {
+			httputils.WriteError(w, http.StatusBadRequest, "Invalid base token address")
+			return
+		}
+		baseToken = common.HexToAddress(bt)
+	}
+	if rAddress != "" {
+		if !common.IsHexAddress(rAddress) {
+			httputils.WriteError(w, http.StatusBadRequest, "Invalid relayer address")
+			return
+		}
+		relayerAddress = common.HexToAddress(rAddress)
+	}
+	if !e.volumeStreamService.IsValidVolumeWindow(window) {
+		httputils.WriteError(w, http.StatusBadRequest, "window must be empty/all/24h/7d/30d")
+		return
+	}
+	if top != "" {
+		t, err := strconv.Atoi(top)
+		if err != nil || t <= 0 || t > maxPageSize {
+			httputils.WriteError(w, http.StatusBadRequest, "Invalid top")
+			return
+		}
+		topVolume = t
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		httputils.WriteError(w, http.StatusInternalServerError, "Streaming not supported")
+		return
+	}
+
+	sub, err := e.volumeStreamService.Subscribe(relayerAddress, baseToken, quoteToken, window, topVolume)
+	if err != nil {
+		httputils.WriteError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	defer e.volumeStreamService.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+	done := r.Context().Done()
+	for {
+		select {
+		case event, ok := <-sub.Events:
+			if !ok {
+				// dropped for lagging behind, client reconnects for a new snapshot
+				return
+			}
+			if err := writeStreamEvent(w, event); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case <-done:
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+func writeStreamEvent(w http.ResponseWriter, event *types.VolumeStreamEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Event, data)
+	return err
+}
@@ -0,0 +1,77 @@
+package endpoints
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gorilla/mux"
+	"github.com/tomochain/tomox-stats/services"
+	"github.com/tomochain/tomox-stats/utils/httputils"
+)
+
+type washDetectorEndpoint struct {
+	washDetectorService *services.WashDetectorService
+}
+
+// ServeWashDetectorResource sets up the routing of wash trade detection endpoints and the corresponding handlers.
+func ServeWashDetectorResource(
+	r *mux.Router,
+	washDetectorService *services.WashDetectorService,
+) {
+	e := &washDetectorEndpoint{washDetectorService}
+	r.HandleFunc("/stats/washclusters", e.handleGetClusters).Methods("GET")
+	r.HandleFunc("/stats/washclusters/{id}/promote", adminOnly(e.handlePromoteCluster)).Methods("POST")
+}
+
+func (e *washDetectorEndpoint) handleGetClusters(w http.ResponseWriter, r *http.Request) {
+	var baseToken common.Address
+	var quoteToken common.Address
+	minScore := services.WashMinScore
+	v := r.URL.Query()
+	bt := v.Get("baseToken")
+	qt := v.Get("quoteToken")
+	score := v.Get("minScore")
+
+	if bt != "" {
+		if !common.IsHexAddress(bt) {
+			httputils.WriteError(w, http.StatusBadRequest, "Invalid basetoken address")
+			return
+		}
+		baseToken = common.HexToAddress(bt)
+	}
+
+	if qt != "" {
+		if !common.IsHexAddress(qt) {
+			httputils.WriteError(w, http.StatusBadRequest, "Invalid quotetoken address")
+			return
+		}
+		quoteToken = common.HexToAddress(qt)
+	}
+
+	if score != "" {
+		s, err := strconv.ParseFloat(score, 64)
+		if err != nil {
+			httputils.WriteError(w, http.StatusBadRequest, "Invalid min score")
+			return
+		}
+		minScore = s
+	}
+
+	res := e.washDetectorService.GetClusters(baseToken, quoteToken, minScore)
+	httputils.WriteJSON(w, http.StatusOK, res)
+}
+
+func (e *washDetectorEndpoint) handlePromoteCluster(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	g, err := e.washDetectorService.PromoteCluster(id)
+	if err != nil {
+		httputils.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if g == nil {
+		httputils.WriteError(w, http.StatusNotFound, "Cluster not found")
+		return
+	}
+	httputils.WriteJSON(w, http.StatusCreated, g)
+}
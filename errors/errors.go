@@ -27,6 +27,11 @@ func Unauthorized(err string) *APIError {
 	return NewHTTPError(http.StatusUnauthorized, "UNAUTHORIZED", Params{"error": err})
 }
 
+// TooManyRequests creates a new API error representing a rate limited request (HTTP 429)
+func TooManyRequests(limit int) *APIError {
+	return NewHTTPError(http.StatusTooManyRequests, "TOO_MANY_REQUESTS", Params{"limit": limit})
+}
+
 // InvalidData converts a data validation error into an API error (HTTP 400)
 func InvalidData(errs validation.Errors) *APIError {
 	result := []validationError{}
@@ -16,6 +16,19 @@ func TestUnauthorized(t *testing.T) {
 	assert.Equal(t, http.StatusUnauthorized, Unauthorized("t").Status)
 }
 
+func TestTooManyRequests(t *testing.T) {
+	defer func() {
+		templates = nil
+	}()
+
+	assert.Nil(t, LoadMessages(MESSAGE_FILE))
+	err := TooManyRequests(60)
+	assert.Equal(t, http.StatusTooManyRequests, err.Status)
+	assert.Equal(t, "TOO_MANY_REQUESTS", err.ErrorCode)
+	assert.NotEqual(t, "TOO_MANY_REQUESTS", err.Message)
+	assert.Contains(t, err.DeveloperMessage, "60 requests")
+}
+
 func TestInvalidData(t *testing.T) {
 	err := InvalidData(validation.Errors{
 		"abc": New("1"),
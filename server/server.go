@@ -18,6 +18,7 @@ import (
 	"github.com/tomochain/tomox-stats/relayer"
 	"github.com/tomochain/tomox-stats/services"
 	"github.com/tomochain/tomox-stats/utils"
+	"github.com/tomochain/tomox-stats/utils/httputils"
 )
 
 import pp "net/http/pprof"
@@ -79,6 +80,12 @@ func Start() {
 func NewRouter() *mux.Router {
 
 	r := mux.NewRouter()
+	// api key of admin requests and rate limit of public requests
+	authenticate, err := endpoints.Authenticate(app.Config.RateLimit, app.Config.TrustedProxies)
+	if err != nil {
+		panic(err)
+	}
+	r.Use(authenticate)
 
 	// get daos for dependency injection
 	tokenDao := daos.NewTokenDao()
@@ -86,20 +93,101 @@ func NewRouter() *mux.Router {
 	tradeDao := daos.NewTradeDao()
 	lendingTradeDao := daos.NewLendingTradeDao()
 	relayerDao := daos.NewRelayerDao()
-	tradeService := services.NewTradeService(tokenDao, tradeDao)
+	washGroupDao := daos.NewWashGroupDao()
+	botAddressDao := daos.NewBotAddressDao()
+	campaignDao := daos.NewCampaignDao()
+	campaignLeaderboardDao := daos.NewCampaignLeaderboardDao()
+	liquidationDao := daos.NewLiquidationDao()
+	migrationDao := daos.NewMigrationDao()
+
+	addressListService := services.NewAddressListService(washGroupDao, botAddressDao, migrationDao)
+	addressListService.Init()
+
+	tradeStore, err := services.NewCacheStore(app.Config.CacheStore, "trade")
+	if err != nil {
+		panic(err)
+	}
+	lendingTradeStore, err := services.NewCacheStore(app.Config.CacheStore, "lending.trade")
+	if err != nil {
+		panic(err)
+	}
+	tradeService := services.NewTradeService(tokenDao, tradeDao, addressListService, tradeStore)
 	tradeService.Init()
+	addressListService.AddNotifier(tradeService)
+
+	ohlcvService := services.NewOHLCVService(tradeService)
+	ohlcvService.Init()
+	tradeService.AddNotifier(ohlcvService)
+
+	washDetectorService := services.NewWashDetectorService(tradeDao, addressListService)
+	washDetectorService.Init()
+	tradeService.AddNotifier(washDetectorService)
+
+	pairService := services.NewPairService(pairDao, tradeService)
 
-	lendingTradeService := services.NewLendingTradeService(lendingTradeDao)
+	pnlStore, err := services.NewCacheStore(app.Config.CacheStore, "pnl")
+	if err != nil {
+		panic(err)
+	}
+	pnlService := services.NewPnLService(tradeDao, tokenDao, pnlStore)
+	pnlService.Init()
+	tradeService.AddNotifier(pnlService)
+
+	volumeStreamService := services.NewVolumeStreamService(tradeService)
+	volumeStreamService.Init()
+	tradeService.AddNotifier(volumeStreamService)
+
+	priceSource, err := services.NewPriceSource(app.Config.PriceSource, app.Config.PriceSourceURL)
+	if err != nil {
+		panic(err)
+	}
+	priceService := services.NewPriceService(tokenDao, tradeService, priceSource)
+	priceService.Init()
+	// csv and ndjson export of json responses, amounts are written as decimals of their token
+	r.Use(httputils.NewExporter(priceService.GetTokenDecimals).Export)
+
+	campaignService := services.NewCampaignService(campaignDao, campaignLeaderboardDao, tradeDao, tradeService, pnlService, addressListService)
+	campaignService.Init()
+
+	lendingTradeService := services.NewLendingTradeService(lendingTradeDao, addressListService, lendingTradeStore)
 	lendingTradeService.Init()
 
+	loanBookService := services.NewLoanBookService(lendingTradeDao, priceService)
+	loanBookService.Init()
+	lendingTradeService.AddNotifier(loanBookService)
+
+	liquidationService := services.NewLiquidationService(liquidationDao, lendingTradeDao, tradeDao, loanBookService, tradeService, priceService)
+	liquidationService.Init()
+	lendingTradeService.AddNotifier(liquidationService)
+
+	revenueService := services.NewRevenueService(tradeService, lendingTradeService, priceService)
+	userProfileService := services.NewUserProfileService(tradeDao, tradeService, lendingTradeService)
+
 	exchangeAddress := common.HexToAddress(app.Config.Tomochain["exchange_address"])
 	contractAddress := common.HexToAddress(app.Config.Tomochain["exchange_contract_address"])
 	lendingContractAddress := common.HexToAddress(app.Config.Tomochain["lending_contract_address"])
 	relayerEngine := relayer.NewRelayer(app.Config.Tomochain["http_url"], exchangeAddress, contractAddress, lendingContractAddress)
-	relayerService := services.NewRelayerService(relayerEngine, tokenDao, pairDao, relayerDao)
-	endpoints.ServeTradeResource(r, tradeService)
-
-	endpoints.ServeLendingTradeResource(r, lendingTradeService)
+	relayerService := services.NewRelayerService(relayerEngine, tokenDao, pairDao, relayerDao, migrationDao)
+	relayerService.Init()
+	relayerDirectoryService := services.NewRelayerDirectoryService(relayerDao, pairDao, tokenDao, tradeService, priceService)
+	endpoints.ServeTradeResource(r, tradeService, priceService)
+	endpoints.ServePriceResource(r, priceService)
+	endpoints.ServeOHLCVResource(r, ohlcvService)
+	endpoints.ServePairResource(r, pairService, priceService)
+	endpoints.ServePnLResource(r, pnlService)
+	endpoints.ServeRelayerFlowResource(r, tradeService, priceService)
+	endpoints.ServeVolumeStreamResource(r, volumeStreamService)
+	endpoints.ServeRelayerResource(r, relayerService)
+	endpoints.ServeRelayerDirectoryResource(r, relayerDirectoryService)
+
+	endpoints.ServeLendingTradeResource(r, lendingTradeService, priceService)
+	endpoints.ServeLoanBookResource(r, loanBookService)
+	endpoints.ServeLiquidationResource(r, liquidationService)
+	endpoints.ServeRevenueResource(r, revenueService)
+	endpoints.ServeUserProfileResource(r, userProfileService)
+	endpoints.ServeAddressListResource(r, addressListService)
+	endpoints.ServeWashDetectorResource(r, washDetectorService)
+	endpoints.ServeCampaignResource(r, campaignService)
 
 	// deploy http and ws endpoints
 
@@ -107,6 +195,7 @@ func NewRouter() *mux.Router {
 	// initialize MongoDB Change Streams
 	go tradeService.WatchChanges()
 	go lendingTradeService.WatchChanges()
+	addressListService.WatchChanges()
 
 	cronService.InitCrons()
 	return r
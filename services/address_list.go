@@ -0,0 +1,383 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/tomochain/tomox-stats/daos"
+	"github.com/tomochain/tomox-stats/types"
+)
+
+// addressListSeedMigration is the migration marker of default lists seed
+const addressListSeedMigration = "seed_address_lists"
+
+// e1 and e2 are the wash trade address pairs seeded into wash groups
+var e1 = []string{
+	"0xbfc6e92daae38d49a978245e04acc98178770a36",
+	"0x9d62a70c8e3587f0051ad56a111feb738a43103e",
+	"0x0798ca0782fddf1a57d09587cc2dea4c139294f7",
+	"0xab45d2804945a5f443189a435d61808d4659447c",
+	"0xc9c75e98d79a8987d5a0b1ed9e2226e637570587",
+	"0xf17278d65fd53ae68661ab0361fcefb0919b64cf",
+	"0x881e1caae2f97601701c762d73f1c83fd186cf0c",
+	"0x92875d7345d8447eb9cba401caa4959343772336",
+	"0x2a941348945811697d669802d7abaaa4fc544af2",
+	"0xc5352eff365260a640fc237d2c22ccfeb68c44e6",
+	"0x95a05686f861be57823bbb308e2f71fbaab05cc6",
+	"0xb76dbf54d84954f0a5da6f9ea00047541853688c",
+	"0xa1f9950b0673b3df6aecec0e040c835c6d78b51a",
+	"0xe681eeb30bad216e1d679cdfdb7eda5d9c362f9b",
+	"0xd081777a391b9bbb15b0ae1e4103fd18689bdb3c",
+	"0xf17278d65fd53ae68661ab0361fcefb0919b64cf",
+}
+
+var e2 = []string{
+	"0x9d62A70c8E3587f0051AD56A111fEb738a43103E",
+	"0xbfc6e92daae38d49a978245e04acc98178770a36",
+	"0x90cBE91913075dD48ceC8528bD82e47BA15ffab4",
+	"0x62bb16DC0aED004EF877acE866E8f558E6e351A2",
+	"0xab45d2804945a5f443189a435d61808d4659447c",
+	"0xe36dEd7Bc36f60D0Cd6D1A0dEBda6dD999BBf199",
+	"0xf996aE46adad51f2Bb0C0879670CbAe466202E4D",
+	"0x02e45aDF6025553d6df357415FF624d375Abf2B8",
+	"0x717f876aD79773AB3290A2bcf9244B1C8F9602e1",
+	"0xf742536b95B2E3bCfC48543AE93d4Ebf93f3c537",
+	"0xB76dbf54d84954F0A5DA6F9EA00047541853688c",
+	"0x95a05686f861be57823bbb308e2f71fbaab05cc6",
+	"0x0798ca0782fddF1a57d09587CC2Dea4C139294f7",
+	"0x0798ca0782fddF1a57d09587CC2Dea4C139294f7",
+	"0xe04AEc262fBbc434cF1781c49d4236914765a33b",
+	"0xab45d2804945a5f443189a435d61808d4659447c",
+}
+
+// bot are the market maker addresses seeded into bot addresses
+var bot = []string{
+	"0x9E7c130D6EA105450dD8DdD51dd6fAB4b5c955d7",
+	"0xF8ec1939AF7F37F53156d554fe8187E2Cdf4A060",
+	"0x0F8469Ead31Ffd4b6b504dba35B0Cf3C8DFf4e14",
+	"0x569874387d94F9efF87Bbe1e94f2308681F64223",
+	"0x01F294DBdD3207fE86Af1b68a697dA4d1296B75B",
+
+	"0x0cd9d70a38b71ed056e5d20fef6e3c9d2f6bc253",
+	"0xc923f5f834b45674bac07c85f0328fa713de3fe2",
+	"0xf742536b95b2e3bcfc48543ae93d4ebf93f3c537",
+	"0xf742536b95b2e3bcfc48543ae93d4ebf93f3c537",
+	"0xf996ae46adad51f2bb0c0879670cbae466202e4d",
+
+	"0x988cE4471422Af884Ef42c7d6dd6B6986F1Eaf80",
+	"0xA13948aD209FD8967da18B7c2E4841133f725F01",
+	"0xa9E590c7B76be6f473a308669B9373199219581C",
+	"0xa2191f5aeA9aCAF5875E5b42A6DbA378C5f0fbed",
+	"0xC65Ac99fC0c96330504B733EB01e9d58dAD93FA6",
+
+	"0x90CA4896779C8BC4cAf9085Cfc73F54B17Bc0e09",
+	"0x02e45aDF6025553d6df357415FF624d375Abf2B8",
+	"0x42224E7404D0c037B6D7aB7027A74b22f3190302",
+	"0x0BDbC4E0e19CE1C8129612CcD1EB4eBa94FE064B",
+	"0xF8032b6ef6843cE4938EB742742F4cB5C91236A0",
+
+	"0x548bA11486a2bC522Ab864773a5fC6Ffd9777A1f",
+	"0xb10010Bf1C3AA108170e1C3fE2662B8a462E6040",
+	"0x859695a2C648014a31d307e73CF688e009317F9E",
+	"0x717f876aD79773AB3290A2bcf9244B1C8F9602e1",
+	"0x628Cd98302aBD2Cf30a8e54539410D726Bb0e5F6",
+
+	"0xc683608c1125717F0C459442d5830DDCd321704C",
+	"0x95A47f00F14AEbE3C893F6978957af036Aae4627",
+	"0xe92a0B625E8Be02AD88918B67d752274737Db09b",
+	"0x0bF9BDEE7d4f572033187D001dEc079331D833Ed",
+	"0xa46fbe3Bf444ffFb8BDAd3F682bda5cBec7F0ebC",
+}
+
+// AddressIndex is a snapshot of wash groups and bot addresses, it is never modified once built
+type AddressIndex struct {
+	// userAddress => ids of wash groups having the address
+	washGroups map[common.Address]map[bson.ObjectId]bool
+	bots       map[common.Address]bool
+}
+
+// AddressListNotifier is implemented by services depending on wash groups and bot addresses
+type AddressListNotifier interface {
+	// NotifyAddressListChange is called with the new index and the addresses whose wash groups have changed
+	NotifyAddressListChange(index *AddressIndex, addresses []common.Address) error
+}
+
+// AddressListService keeps wash groups and bot addresses of db in memory
+// lists are reloaded when their collections change
+type AddressListService struct {
+	washGroupDao  *daos.WashGroupDao
+	botAddressDao *daos.BotAddressDao
+	migrationDao  *daos.MigrationDao
+	index         *AddressIndex
+	notifiers     []AddressListNotifier
+	mutex         sync.RWMutex
+	// reloadMutex keeps notifications in reload order
+	reloadMutex sync.Mutex
+}
+
+func newAddressIndex(washGroups []*types.WashGroup, bots []*types.BotAddress) *AddressIndex {
+	index := &AddressIndex{
+		washGroups: make(map[common.Address]map[bson.ObjectId]bool),
+		bots:       make(map[common.Address]bool),
+	}
+	for _, g := range washGroups {
+		for _, a := range g.Addresses {
+			if _, ok := index.washGroups[a]; !ok {
+				index.washGroups[a] = make(map[bson.ObjectId]bool)
+			}
+			index.washGroups[a][g.ID] = true
+		}
+	}
+	for _, b := range bots {
+		index.bots[b.Address] = true
+	}
+	return index
+}
+
+// IsWashTrade check trade is between the same user or two addresses of a wash group
+func (i *AddressIndex) IsWashTrade(t1, t2 common.Address) bool {
+	if t1 == t2 {
+		return true
+	}
+	for id := range i.washGroups[t1] {
+		if i.washGroups[t2][id] {
+			return true
+		}
+	}
+	return false
+}
+
+// IsBotAddress check address is a bot address
+func (i *AddressIndex) IsBotAddress(a common.Address) bool {
+	return i.bots[a]
+}
+
+// diffWashAddresses return addresses whose wash groups are different in other index
+func (i *AddressIndex) diffWashAddresses(other *AddressIndex) []common.Address {
+	var addresses []common.Address
+	isSame := func(g1, g2 map[bson.ObjectId]bool) bool {
+		if len(g1) != len(g2) {
+			return false
+		}
+		for id := range g1 {
+			if !g2[id] {
+				return false
+			}
+		}
+		return true
+	}
+	for a, groups := range i.washGroups {
+		if !isSame(groups, other.washGroups[a]) {
+			addresses = append(addresses, a)
+		}
+	}
+	for a := range other.washGroups {
+		if _, ok := i.washGroups[a]; !ok {
+			addresses = append(addresses, a)
+		}
+	}
+	return addresses
+}
+
+// NewAddressListService init new instance
+func NewAddressListService(washGroupDao *daos.WashGroupDao, botAddressDao *daos.BotAddressDao, migrationDao *daos.MigrationDao) *AddressListService {
+	return &AddressListService{
+		washGroupDao:  washGroupDao,
+		botAddressDao: botAddressDao,
+		migrationDao:  migrationDao,
+		index:         newAddressIndex(nil, nil),
+	}
+}
+
+// Init load lists, default lists are seeded once if both collections are empty
+// lists emptied later by admins are not seeded again
+func (s *AddressListService) Init() {
+	if err := s.seedOnce(); err != nil {
+		logger.Error(err)
+	}
+	if err := s.reload(); err != nil {
+		logger.Error(err)
+	}
+}
+
+// seedOnce seed default lists unless the seed migration marker exists
+// the marker is also set for lists of previous versions which are not empty
+func (s *AddressListService) seedOnce() error {
+	applied, err := s.migrationDao.IsApplied(addressListSeedMigration)
+	if err != nil || applied {
+		return err
+	}
+	washGroups, err := s.washGroupDao.GetAll()
+	if err != nil {
+		return err
+	}
+	bots, err := s.botAddressDao.GetAll()
+	if err != nil {
+		return err
+	}
+	if len(washGroups) == 0 && len(bots) == 0 {
+		s.seed()
+	}
+	return s.migrationDao.MarkApplied(addressListSeedMigration)
+}
+
+func (s *AddressListService) seed() {
+	logger.Info("Seed default wash groups and bot addresses")
+	for i := range e1 {
+		g := &types.WashGroup{
+			Name:      "default",
+			Addresses: []common.Address{common.HexToAddress(e1[i]), common.HexToAddress(e2[i])},
+		}
+		if g.Validate() != nil {
+			continue
+		}
+		if err := s.washGroupDao.Create(g); err != nil {
+			logger.Error(err)
+		}
+	}
+	for _, a := range bot {
+		if err := s.botAddressDao.Upsert(&types.BotAddress{Address: common.HexToAddress(a)}); err != nil {
+			logger.Error(err)
+		}
+	}
+}
+
+// AddNotifier register service to be notified of list changes
+func (s *AddressListService) AddNotifier(n AddressListNotifier) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.notifiers = append(s.notifiers, n)
+}
+
+// GetIndex get current lists
+func (s *AddressListService) GetIndex() *AddressIndex {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.index
+}
+
+// reload read lists from db and notify changed wash addresses
+func (s *AddressListService) reload() error {
+	s.reloadMutex.Lock()
+	defer s.reloadMutex.Unlock()
+	washGroups, err := s.washGroupDao.GetAll()
+	if err != nil {
+		return err
+	}
+	bots, err := s.botAddressDao.GetAll()
+	if err != nil {
+		return err
+	}
+	index := newAddressIndex(washGroups, bots)
+
+	s.mutex.Lock()
+	addresses := s.index.diffWashAddresses(index)
+	s.index = index
+	notifiers := s.notifiers
+	s.mutex.Unlock()
+
+	logger.Infof("Address lists reloaded: %d wash groups, %d bot addresses, %d wash addresses changed", len(washGroups), len(bots), len(addresses))
+	for _, n := range notifiers {
+		if err := n.NotifyAddressListChange(index, addresses); err != nil {
+			logger.Error(err)
+		}
+	}
+	return nil
+}
+
+// WatchChanges reload lists when wash group or bot address collections change
+func (s *AddressListService) WatchChanges() {
+	go s.watchCollection(s.washGroupDao.Watch)
+	go s.watchCollection(s.botAddressDao.Watch)
+}
+
+func (s *AddressListService) watchCollection(watch func() (*mgo.ChangeStream, *mgo.Session, error)) {
+	for {
+		s.watchChanges(watch)
+		time.Sleep(watchRetryInterval)
+	}
+}
+
+func (s *AddressListService) watchChanges(watch func() (*mgo.ChangeStream, *mgo.Session, error)) {
+	ct, sc, err := watch()
+	defer sc.Close()
+	if err != nil {
+		logger.Error("Failed to open change stream")
+		return
+	}
+	defer ct.Close()
+
+	// changes may have been missed while the stream was closed
+	if err := s.reload(); err != nil {
+		logger.Error(err)
+	}
+	ctx := context.Background()
+
+	//Handling change stream in a cycle
+	for {
+		select {
+		case <-ctx.Done(): // if parent context was cancelled
+			return
+		default:
+			ev := types.AddressListChangeEvent{}
+
+			//getting next item from the steam
+			ok := ct.Next(&ev)
+			if ok {
+				logger.Debugf("Operation Type: %s", ev.OperationType)
+				if err := s.reload(); err != nil {
+					logger.Error(err)
+				}
+			} else if err := ct.Err(); err != nil {
+				logger.Error("Address list change stream failed:", err)
+				return
+			}
+		}
+	}
+}
+
+// GetWashGroups get all wash groups
+func (s *AddressListService) GetWashGroups() ([]*types.WashGroup, error) {
+	return s.washGroupDao.GetAll()
+}
+
+// CreateWashGroup add wash group and reload lists
+func (s *AddressListService) CreateWashGroup(g *types.WashGroup) error {
+	if err := s.washGroupDao.Create(g); err != nil {
+		return err
+	}
+	return s.reload()
+}
+
+// DeleteWashGroup remove wash group and reload lists
+func (s *AddressListService) DeleteWashGroup(id bson.ObjectId) error {
+	if err := s.washGroupDao.DeleteByID(id); err != nil {
+		return err
+	}
+	return s.reload()
+}
+
+// GetBotAddresses get all bot addresses
+func (s *AddressListService) GetBotAddresses() ([]*types.BotAddress, error) {
+	return s.botAddressDao.GetAll()
+}
+
+// AddBotAddress add bot address and reload lists
+func (s *AddressListService) AddBotAddress(b *types.BotAddress) error {
+	if err := s.botAddressDao.Upsert(b); err != nil {
+		return err
+	}
+	return s.reload()
+}
+
+// RemoveBotAddress remove bot address and reload lists
+func (s *AddressListService) RemoveBotAddress(addr common.Address) error {
+	if err := s.botAddressDao.DeleteByAddress(addr); err != nil {
+		return err
+	}
+	return s.reload()
+}
@@ -0,0 +1,300 @@
+package services
+
+import (
+	"errors"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/tomochain/tomox-stats/daos"
+	"github.com/tomochain/tomox-stats/types"
+)
+
+const (
+	// campaignFinalizeDelay is the time waited after a campaign end for last trades to settle
+	campaignFinalizeDelay = 10 * 60
+	// campaignLeaderboardCacheTime is the number of seconds a live leaderboard is cached
+	campaignLeaderboardCacheTime = 60
+	// campaignTradePageSize is the page size of campaign trades fetched from trade collection
+	campaignTradePageSize = 1000
+)
+
+// ErrCampaignFinalized is returned when changing a campaign whose final leaderboard is stored
+var ErrCampaignFinalized = errors.New("Campaign is finalized")
+
+// CampaignService manages trading campaigns and their leaderboards
+// ranks are computed from the trades of the trade collection between the exact campaign times
+type CampaignService struct {
+	campaignDao            *daos.CampaignDao
+	campaignLeaderboardDao *daos.CampaignLeaderboardDao
+	tradeDao               *daos.TradeDao
+	tradeService           *TradeService
+	pnlService             *PnLService
+	addressListService     *AddressListService
+	// campaignID => live leaderboard
+	leaderboards map[bson.ObjectId]*types.CampaignLeaderboard
+	mutex        sync.Mutex
+}
+
+// NewCampaignService init new instance
+func NewCampaignService(
+	campaignDao *daos.CampaignDao,
+	campaignLeaderboardDao *daos.CampaignLeaderboardDao,
+	tradeDao *daos.TradeDao,
+	tradeService *TradeService,
+	pnlService *PnLService,
+	addressListService *AddressListService,
+) *CampaignService {
+	return &CampaignService{
+		campaignDao:            campaignDao,
+		campaignLeaderboardDao: campaignLeaderboardDao,
+		tradeDao:               tradeDao,
+		tradeService:           tradeService,
+		pnlService:             pnlService,
+		addressListService:     addressListService,
+		leaderboards:           make(map[bson.ObjectId]*types.CampaignLeaderboard),
+	}
+}
+
+// Init finalize ended campaigns every minute
+func (s *CampaignService) Init() {
+	ticker := time.NewTicker(60 * time.Second)
+	go func() {
+		for range ticker.C {
+			s.finalizeCampaigns()
+		}
+	}()
+}
+
+// GetAll get campaigns, empty relayer address for all relayers
+func (s *CampaignService) GetAll(relayerAddress common.Address) ([]*types.Campaign, error) {
+	return s.campaignDao.GetAll(relayerAddress)
+}
+
+// GetByID get campaign, nil if not found
+func (s *CampaignService) GetByID(id bson.ObjectId) (*types.Campaign, error) {
+	return s.campaignDao.GetByID(id)
+}
+
+// Create add campaign
+func (s *CampaignService) Create(c *types.Campaign) error {
+	if c.Metric == "" {
+		c.Metric = types.CampaignMetricVolume
+	}
+	return s.campaignDao.Create(c)
+}
+
+// Update replace campaign, ErrCampaignFinalized if its final leaderboard is stored
+func (s *CampaignService) Update(c *types.Campaign) error {
+	last, err := s.campaignDao.GetByID(c.ID)
+	if err != nil || last == nil {
+		return err
+	}
+	if last.Finalized {
+		return ErrCampaignFinalized
+	}
+	if c.Metric == "" {
+		c.Metric = types.CampaignMetricVolume
+	}
+	c.CreatedAt = last.CreatedAt
+	err = s.campaignDao.UpdateNotFinalized(c)
+	if err == mgo.ErrNotFound {
+		return ErrCampaignFinalized
+	}
+	return err
+}
+
+// Delete remove campaign, ErrCampaignFinalized if its final leaderboard is stored
+func (s *CampaignService) Delete(id bson.ObjectId) error {
+	err := s.campaignDao.DeleteNotFinalized(id)
+	if err == mgo.ErrNotFound {
+		c, _ := s.campaignDao.GetByID(id)
+		if c != nil {
+			return ErrCampaignFinalized
+		}
+		return nil
+	}
+	return err
+}
+
+// GetLeaderboard get final leaderboard of finalized campaign, live leaderboard otherwise
+// live leaderboards are cached for campaignLeaderboardCacheTime seconds
+func (s *CampaignService) GetLeaderboard(c *types.Campaign) (*types.CampaignLeaderboard, error) {
+	if c.Finalized {
+		return s.campaignLeaderboardDao.GetByCampaignID(c.ID)
+	}
+	s.mutex.Lock()
+	leaderboard, ok := s.leaderboards[c.ID]
+	s.mutex.Unlock()
+	if ok && leaderboard.CreatedAt.After(c.UpdatedAt) && time.Since(leaderboard.CreatedAt) < campaignLeaderboardCacheTime*time.Second {
+		return leaderboard, nil
+	}
+	ranks, err := s.computeRanks(c)
+	if err != nil {
+		return nil, err
+	}
+	leaderboard = &types.CampaignLeaderboard{
+		CampaignID: c.ID,
+		Final:      false,
+		Ranks:      ranks,
+		CreatedAt:  time.Now(),
+	}
+	s.mutex.Lock()
+	s.leaderboards[c.ID] = leaderboard
+	s.mutex.Unlock()
+	return leaderboard, nil
+}
+
+// getTrades get trades of campaign pairs and relayer between campaign start and end times included
+func (s *CampaignService) getTrades(c *types.Campaign) ([]*types.Trade, error) {
+	var res []*types.Trade
+	for _, p := range c.Pairs {
+		spec := &types.TradeSpec{
+			BaseToken:      p.BaseToken.Hex(),
+			QuoteToken:     p.QuoteToken.Hex(),
+			RelayerAddress: c.RelayerAddress,
+			DateFrom:       c.StartTime,
+			DateTo:         c.EndTime + 1,
+		}
+		var cursor *types.TradeCursor
+		for {
+			trades, err := s.tradeDao.GetTradesByCursor(spec, cursor, campaignTradePageSize)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, trades...)
+			if len(trades) < campaignTradePageSize {
+				break
+			}
+			last := trades[len(trades)-1]
+			cursor = &types.TradeCursor{CreatedAt: last.CreatedAt, ID: last.ID}
+		}
+	}
+	return res, nil
+}
+
+// computeRanks rank eligible traders of campaign by its metric
+// volume is counted like relayer volume, PnL is the fifo PnL of campaign trades valued at their last price
+func (s *CampaignService) computeRanks(c *types.Campaign) ([]*types.CampaignRank, error) {
+	trades, err := s.getTrades(c)
+	if err != nil {
+		return nil, err
+	}
+	tradeVolumes := s.tradeService.getCountedVolumes(trades)
+	var counted []*types.Trade
+	volumes := make(map[common.Address]*big.Int)
+	for _, trade := range trades {
+		volume, ok := tradeVolumes[trade.Hash]
+		if !ok {
+			continue
+		}
+		counted = append(counted, trade)
+		// the volume of all relayers counts the trade once by relayer
+		if (c.RelayerAddress == common.Address{}) && trade.MakerExchange != trade.TakerExchange {
+			volume = new(big.Int).Mul(volume, big.NewInt(2))
+		}
+		for _, userAddress := range []common.Address{trade.Taker, trade.Maker} {
+			if v, ok := volumes[userAddress]; ok {
+				volumes[userAddress] = new(big.Int).Add(v, volume)
+			} else {
+				volumes[userAddress] = volume
+			}
+		}
+	}
+
+	var pnls map[common.Address]*big.Int
+	if c.Metric == types.CampaignMetricPnL {
+		pnls = s.pnlService.GetTradesPnL(counted)
+	}
+
+	excluded := make(map[common.Address]bool)
+	for _, a := range c.ExcludedAddresses {
+		excluded[a] = true
+	}
+	index := s.addressListService.GetIndex()
+
+	ranks := []*types.CampaignRank{}
+	for address, volume := range volumes {
+		if excluded[address] || index.IsBotAddress(address) {
+			continue
+		}
+		if c.MinVolume != nil && volume.Cmp(c.MinVolume) < 0 {
+			continue
+		}
+		rank := &types.CampaignRank{
+			UserAddress: address,
+			Volume:      volume,
+		}
+		if pnls != nil {
+			rank.PnL = big.NewInt(0)
+			if pnl, ok := pnls[address]; ok {
+				rank.PnL = pnl
+			}
+		}
+		ranks = append(ranks, rank)
+	}
+	sort.Slice(ranks, func(i, j int) bool {
+		score1, score2 := ranks[i].Volume, ranks[j].Volume
+		if pnls != nil {
+			score1, score2 = ranks[i].PnL, ranks[j].PnL
+		}
+		if cmp := score1.Cmp(score2); cmp != 0 {
+			return cmp > 0
+		}
+		return strings.ToLower(ranks[i].UserAddress.Hex()) < strings.ToLower(ranks[j].UserAddress.Hex())
+	})
+	for i, rank := range ranks {
+		rank.Rank = i + 1
+		for _, p := range c.Prizes {
+			if rank.Rank >= p.FromRank && rank.Rank <= p.ToRank {
+				rank.Prize = p.Prize
+				break
+			}
+		}
+	}
+	return ranks, nil
+}
+
+// finalizeCampaigns store the final leaderboard of ended campaigns
+// a campaign which fails is retried on next run
+func (s *CampaignService) finalizeCampaigns() {
+	campaigns, err := s.campaignDao.GetEndedNotFinalized(time.Now().Unix() - campaignFinalizeDelay)
+	if err != nil {
+		logger.Error(err)
+		return
+	}
+	for _, c := range campaigns {
+		if err := s.finalizeCampaign(c); err != nil {
+			logger.Error("Finalize campaign failed", c.ID.Hex(), err)
+		}
+	}
+}
+
+func (s *CampaignService) finalizeCampaign(c *types.Campaign) error {
+	logger.Info("Finalize campaign", c.ID.Hex())
+	ranks, err := s.computeRanks(c)
+	if err != nil {
+		return err
+	}
+	leaderboard := &types.CampaignLeaderboard{
+		CampaignID: c.ID,
+		Final:      true,
+		Ranks:      ranks,
+	}
+	// a leaderboard stored before a failed update is kept as is
+	if err := s.campaignLeaderboardDao.Create(leaderboard); err != nil && !mgo.IsDup(err) {
+		return err
+	}
+	if err := s.campaignDao.SetFinalized(c.ID); err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	delete(s.leaderboards, c.ID)
+	s.mutex.Unlock()
+	return nil
+}
@@ -1,25 +1,51 @@
 package services
 
 import (
-	"bufio"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"math/big"
-	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/globalsign/mgo/bson"
 	"github.com/tomochain/tomox-stats/daos"
 	"github.com/tomochain/tomox-stats/types"
 	"github.com/tomochain/tomox-stats/utils"
+	"github.com/tomochain/tomox-stats/utils/math"
 )
 
 const (
+	// lendingCacheFile is the json cache of previous versions, imported once into the cache store
 	lendingCacheFile = "lending.trade.cache"
+
+	lendingStoreMeta             = "meta"
+	lendingStoreRelayerUserTrade = "lut/"
+	lendingStoreMarketTrade      = "lmt/"
+	lendingStoreTrade            = "ltr/"
+
+	// lendingInterestDecimals is the precision of LendingTrade.Interest, 10% is 10 * 10^8
+	lendingInterestDecimals = 100000000
+	lendingOneYear          = 365 * 24 * 60 * 60
+
+	// LendingRoleBorrower rank borrowers
+	LendingRoleBorrower = "borrower"
+	// LendingRoleInvestor rank investors
+	LendingRoleInvestor = "investor"
+	// LendingRoleAny count both borrowers and investors
+	LendingRoleAny = "any"
+
+	// LendingSortVolume rank users by principal
+	LendingSortVolume = "volume"
+	// LendingSortInterest rank users by interest paid or earned
+	LendingSortInterest = "interest"
+	// LendingSortCount rank users by number of loans
+	LendingSortCount = "count"
 )
 
 // LendingTradeService struct with daos required, responsible for communicating with daos.
@@ -27,34 +53,98 @@ const (
 type LendingTradeService struct {
 	lendingTradeDao   *daos.LendingTradeDao
 	lendingTradeCache *lendingTradeCache
-	mutex             sync.RWMutex
+	store             CacheStore
+	// bot addresses are excluded from rankings
+	addressListService *AddressListService
+	notifiers          []LendingTradeNotifier
+	mutex              sync.RWMutex
+}
+
+// LendingTradeNotifier is implemented by services consuming the lending trade change stream
+// every change of a lending trade is notified, status changes included
+type LendingTradeNotifier interface {
+	NotifyLendingTrade(trade *types.LendingTrade) error
 }
 
 type lendingTradeCache struct {
-	lastTime          int64
-	relayerUserTrades map[common.Address]map[common.Address]map[int64]*types.LendingUserTrade
+	lastTime    int64
+	resumeToken *bson.Raw
+	// relayerAddress => term::lendingToken => userAddress => time => LendingUserTrade
+	relayerUserTrades map[common.Address]map[string]map[common.Address]map[int64]*types.LendingUserTrade
+	// relayerAddress => term::lendingToken => time => LendingMarketTrade, empty relayer address for all relayers
+	marketTrades map[common.Address]map[string]map[int64]*types.LendingMarketTrade
+	// tradeHash => trades already counted
+	trades map[common.Hash]*lendingTradeRecord
+	// store key => user trade changed since last commit
+	dirtyUserTrades map[string]*types.LendingUserTrade
+	// store key => market trade changed since last commit
+	dirtyMarketTrades map[string]*types.LendingMarketTrade
+	// tradeHash => true if trade is changed since last commit
+	dirtyTrades map[common.Hash]bool
+}
+
+// lendingTradeRecord is a counted trade, the record of an open loan is kept until its close updates its interest
+type lendingTradeRecord struct {
+	Time     int64 `json:"time"`
+	Maturity int64 `json:"maturity,omitempty"`
+	Closed   bool  `json:"closed,omitempty"`
 }
 
+// lendingStoreMetadata is committed with every batch, so that it matches the stored time frames
+type lendingStoreMetadata struct {
+	LastTime    int64     `json:"lastTime"`
+	ResumeToken *bson.Raw `json:"resumeToken,omitempty"`
+	// Markets is false for stores written before time frames were keyed by lending token and term
+	Markets bool `json:"markets"`
+}
+
+// cachelendingtradefile is the json cache of previous versions
+// its time frames have no lending token and term, they are rebuilt from lending trade collection
 type cachelendingtradefile struct {
-	LastTime          int64                     `json:"lastTime"`
-	RelayerUserTrades []*types.LendingUserTrade `json:"relayerUserTrades"`
+	LastTime    int64     `json:"lastTime"`
+	ResumeToken *bson.Raw `json:"resumeToken,omitempty"`
 }
 
 // NewLendingTradeService init new instance
-func NewLendingTradeService(lendingTradeDao *daos.LendingTradeDao) *LendingTradeService {
+func NewLendingTradeService(lendingTradeDao *daos.LendingTradeDao, addressListService *AddressListService, store CacheStore) *LendingTradeService {
 
 	cache := &lendingTradeCache{
-		relayerUserTrades: make(map[common.Address]map[common.Address]map[int64]*types.LendingUserTrade),
+		relayerUserTrades: make(map[common.Address]map[string]map[common.Address]map[int64]*types.LendingUserTrade),
+		marketTrades:      make(map[common.Address]map[string]map[int64]*types.LendingMarketTrade),
+		trades:            make(map[common.Hash]*lendingTradeRecord),
+		dirtyUserTrades:   make(map[string]*types.LendingUserTrade),
+		dirtyMarketTrades: make(map[string]*types.LendingMarketTrade),
+		dirtyTrades:       make(map[common.Hash]bool),
 	}
 	return &LendingTradeService{
-		lendingTradeDao:   lendingTradeDao,
-		lendingTradeCache: cache,
+		lendingTradeDao:    lendingTradeDao,
+		lendingTradeCache:  cache,
+		store:              store,
+		addressListService: addressListService,
 	}
 }
 
 // WatchChanges watch lending trade notify
+// the stream is resumed from the last processed event after restart or stream failure
 func (s *LendingTradeService) WatchChanges() {
-	ct, sc, err := s.lendingTradeDao.Watch()
+	for {
+		s.watchChanges()
+		time.Sleep(watchRetryInterval)
+	}
+}
+
+func (s *LendingTradeService) watchChanges() {
+	s.mutex.RLock()
+	resumeToken := s.lendingTradeCache.resumeToken
+	s.mutex.RUnlock()
+
+	ct, sc, err := s.lendingTradeDao.Watch(resumeToken)
+	if err != nil && resumeToken != nil {
+		logger.Warning("Failed to resume lending change stream, backfill from last trade time:", err)
+		sc.Close()
+		resumeToken = nil
+		ct, sc, err = s.lendingTradeDao.Watch(nil)
+	}
 
 	defer sc.Close()
 	if err != nil {
@@ -64,6 +154,14 @@ func (s *LendingTradeService) WatchChanges() {
 
 	defer ct.Close()
 
+	// the stream is opened before backfill so that no trade is missed in between
+	if resumeToken == nil {
+		s.mutex.RLock()
+		lastTime := s.lendingTradeCache.lastTime
+		s.mutex.RUnlock()
+		s.fetch(lastTime, time.Now().Unix()+1)
+	}
+
 	ctx := context.Background()
 
 	//Handling change stream in a cycle
@@ -82,29 +180,64 @@ func (s *LendingTradeService) WatchChanges() {
 			if ok {
 				logger.Debugf("Operation Type: %s", ev.OperationType)
 				s.NotifyTrade(ev.FullDocument)
+				s.setResumeToken(ct.ResumeToken())
+			} else if err := ct.Err(); err != nil {
+				logger.Error("Lending trade change stream failed:", err)
+				return
 			}
 		}
 	}
 }
 
+func (s *LendingTradeService) setResumeToken(resumeToken *bson.Raw) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.lendingTradeCache.resumeToken = resumeToken
+}
+
 // NotifyTrade handle trade insert/update db trigger
+// an update of an unknown trade older than the hash retention is not counted, its hash may have been pruned
 func (s *LendingTradeService) NotifyTrade(trade *types.LendingTrade) error {
+	if trade == nil {
+		return nil
+	}
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
-	s.updateRelayerUserTrade(trade)
+	if _, ok := s.lendingTradeCache.trades[trade.Hash]; ok || trade.CreatedAt.Unix() >= time.Now().Unix()-tradeHashRetention {
+		s.addTrade(trade)
+	}
+	s.notify(trade)
+	if trade.CreatedAt.Unix() > s.lendingTradeCache.lastTime {
+		s.lendingTradeCache.lastTime = trade.CreatedAt.Unix()
+	}
 	return nil
 }
 
+// AddNotifier register service to be notified of every lending trade change
+func (s *LendingTradeService) AddNotifier(n LendingTradeNotifier) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.notifiers = append(s.notifiers, n)
+}
+
+// notify need to be lock
+func (s *LendingTradeService) notify(trade *types.LendingTrade) {
+	for _, n := range s.notifiers {
+		if err := n.NotifyLendingTrade(trade); err != nil {
+			logger.Error(err)
+		}
+	}
+}
+
 // Init init cache
 // ensure add current time frame before trade notify come
 func (s *LendingTradeService) Init() {
-	now := time.Now().Unix()
-	s.loadCache()
+	if err := s.loadCache(); err != nil {
+		logger.Error("Failed to load lending trade cache:", err)
+	}
 	if s.lendingTradeCache.lastTime == 0 {
 		s.lendingTradeCache.lastTime = time.Now().Unix() - intervalCrawl
 	}
-	s.fetch(s.lendingTradeCache.lastTime, now)
-	s.commitCache()
 	ticker := time.NewTicker(60 * time.Second)
 	quit := make(chan struct{})
 	go func() {
@@ -134,8 +267,11 @@ func (s *LendingTradeService) fetch(fromdate int64, todate int64) {
 		}
 		s.mutex.Lock()
 		for _, trade := range trades {
-			s.updateRelayerUserTrade(trade)
-			s.lendingTradeCache.lastTime = trade.CreatedAt.Unix()
+			s.addTrade(trade)
+			s.notify(trade)
+			if trade.CreatedAt.Unix() > s.lendingTradeCache.lastTime {
+				s.lendingTradeCache.lastTime = trade.CreatedAt.Unix()
+			}
 
 		}
 		s.mutex.Unlock()
@@ -143,197 +279,780 @@ func (s *LendingTradeService) fetch(fromdate int64, todate int64) {
 	}
 }
 
-func (s *LendingTradeService) flattenRelayerUserTrades() []*types.LendingUserTrade {
-	var relayerUserTrades []*types.LendingUserTrade
-	for _, tradebyRelayer := range s.lendingTradeCache.relayerUserTrades {
-		for _, tradebyUserAddess := range tradebyRelayer {
-			for _, trade := range tradebyUserAddess {
-				relayerUserTrades = append(relayerUserTrades, trade)
+// commitCache write time frames and trades changed since last commit to cache store
+func (s *LendingTradeService) commitCache() error {
+	s.mutex.Lock()
+	s.pruneTrades()
+	dirtyUserTrades := s.lendingTradeCache.dirtyUserTrades
+	dirtyMarketTrades := s.lendingTradeCache.dirtyMarketTrades
+	dirtyTrades := s.lendingTradeCache.dirtyTrades
+	s.lendingTradeCache.dirtyUserTrades = make(map[string]*types.LendingUserTrade)
+	s.lendingTradeCache.dirtyMarketTrades = make(map[string]*types.LendingMarketTrade)
+	s.lendingTradeCache.dirtyTrades = make(map[common.Hash]bool)
+	batch, err := s.newCommitBatch(dirtyUserTrades, dirtyMarketTrades, dirtyTrades)
+	s.mutex.Unlock()
+
+	if err == nil {
+		err = s.store.Commit(batch)
+	}
+	if err != nil {
+		// keep changes for next commit
+		s.mutex.Lock()
+		for key, userTrade := range dirtyUserTrades {
+			s.lendingTradeCache.dirtyUserTrades[key] = userTrade
+		}
+		for key, marketTrade := range dirtyMarketTrades {
+			s.lendingTradeCache.dirtyMarketTrades[key] = marketTrade
+		}
+		for hash := range dirtyTrades {
+			s.lendingTradeCache.dirtyTrades[hash] = true
+		}
+		s.mutex.Unlock()
+		return err
+	}
+	return nil
+}
+
+// newCommitBatch need to be lock
+func (s *LendingTradeService) newCommitBatch(dirtyUserTrades map[string]*types.LendingUserTrade, dirtyMarketTrades map[string]*types.LendingMarketTrade, dirtyTrades map[common.Hash]bool) (*CacheBatch, error) {
+	batch := NewCacheBatch()
+	for key, userTrade := range dirtyUserTrades {
+		if err := batch.Put(key, userTrade); err != nil {
+			return nil, err
+		}
+	}
+	for key, marketTrade := range dirtyMarketTrades {
+		if err := batch.Put(key, marketTrade); err != nil {
+			return nil, err
+		}
+	}
+	for hash := range dirtyTrades {
+		key := lendingStoreTrade + hash.Hex()
+		if record, ok := s.lendingTradeCache.trades[hash]; ok {
+			if err := batch.Put(key, record); err != nil {
+				return nil, err
 			}
+		} else {
+			batch.Delete(key)
 		}
 	}
-	return relayerUserTrades
+	meta := &lendingStoreMetadata{
+		LastTime:    s.lendingTradeCache.lastTime,
+		ResumeToken: s.lendingTradeCache.resumeToken,
+		Markets:     true,
+	}
+	if err := batch.Put(lendingStoreMeta, meta); err != nil {
+		return nil, err
+	}
+	return batch, nil
 }
 
-func (s *LendingTradeService) commitCache() error {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
-	relayerUserTrades := s.flattenRelayerUserTrades()
-	cachefile := &cachelendingtradefile{
-		LastTime:          s.lendingTradeCache.lastTime,
-		RelayerUserTrades: relayerUserTrades,
+// pruneTrades forget trades older than tradeHashRetention, need to be lock
+// open loans are kept until they are closed or expired after maturity
+func (s *LendingTradeService) pruneTrades() {
+	now := time.Now().Unix()
+	horizon := now - tradeHashRetention
+	for hash, record := range s.lendingTradeCache.trades {
+		if record.Time < horizon && (record.Closed || record.Maturity+loanExpiryGrace < now) {
+			delete(s.lendingTradeCache.trades, hash)
+			s.lendingTradeCache.dirtyTrades[hash] = true
+		}
 	}
-	cacheData, err := json.Marshal(cachefile)
+}
+
+// loadCache load time frames from cache load horizon
+// the json cache file of previous versions is imported if the store is empty
+// time frames of stores written before they were keyed by lending token and term are rebuilt
+func (s *LendingTradeService) loadCache() error {
+	data, err := s.store.Get(lendingStoreMeta)
 	if err != nil {
 		return err
 	}
-	file, err := os.Create(lendingCacheFile)
-	defer file.Close()
-	if err == nil {
-		_, err = file.Write(cacheData)
-		if err != nil {
+	if data == nil {
+		return s.importCacheFile()
+	}
+	var meta lendingStoreMetadata
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return err
+	}
+	if !meta.Markets {
+		return s.rebuildCache(meta.LastTime, meta.ResumeToken)
+	}
+	err = iterateCacheFrames(s.store, lendingStoreRelayerUserTrade, func(key string, value []byte) error {
+		var userTrade types.LendingUserTrade
+		if err := json.Unmarshal(value, &userTrade); err != nil {
 			return err
 		}
+		s.addRelayerUserTrade(&userTrade)
+		return nil
+	})
+	if err != nil {
+		return err
 	}
-	return nil
-}
-
-func (s *LendingTradeService) loadCache() error {
-	file, err := os.Open(lendingCacheFile)
-	defer file.Close()
+	err = iterateCacheFrames(s.store, lendingStoreMarketTrade, func(key string, value []byte) error {
+		var marketTrade types.LendingMarketTrade
+		if err := json.Unmarshal(value, &marketTrade); err != nil {
+			return err
+		}
+		s.addMarketTrade(&marketTrade)
+		return nil
+	})
 	if err != nil {
 		return err
 	}
-	stats, statsErr := file.Stat()
-	if statsErr != nil {
-		return statsErr
+	err = s.store.Iterate(lendingStoreTrade, "", func(key string, value []byte) error {
+		record := &lendingTradeRecord{}
+		if err := json.Unmarshal(value, record); err != nil {
+			// previous versions stored the trade time only
+			if err := json.Unmarshal(value, &record.Time); err != nil {
+				return err
+			}
+		}
+		s.lendingTradeCache.trades[common.HexToHash(strings.TrimPrefix(key, lendingStoreTrade))] = record
+		return nil
+	})
+	if err != nil {
+		return err
 	}
+	s.lendingTradeCache.lastTime = meta.LastTime
+	s.lendingTradeCache.resumeToken = meta.ResumeToken
+	return nil
+}
 
-	size := stats.Size()
-	bytes := make([]byte, size)
-	bufr := bufio.NewReader(file)
-	_, err = bufr.Read(bytes)
+// importCacheFile rebuild the time frames of json cache file up to its last time
+// the stream is then resumed from the token of the cache file
+func (s *LendingTradeService) importCacheFile() error {
 	var cache cachelendingtradefile
-	err = json.Unmarshal(bytes, &cache)
-	if err != nil {
+	ok, err := readCacheFile(lendingCacheFile, &cache)
+	if !ok || err != nil {
+		return err
+	}
+	logger.Info("Import lending trade cache file to cache store")
+	return s.rebuildCache(cache.LastTime, cache.ResumeToken)
+}
+
+// rebuildCache replace stored time frames by the ones of lending trades created until lastTime
+// time frames are marked to be committed with the metadata of the new key format
+func (s *LendingTradeService) rebuildCache(lastTime int64, resumeToken *bson.Raw) error {
+	logger.Info("Rebuild lending trade cache by lending token and term")
+	if err := s.clearCache(); err != nil {
 		return err
 	}
-	for _, t := range cache.RelayerUserTrades {
-		s.addRelayerUserTrade(t)
+	pageOffset := 0
+	size := 1000
+	for {
+		trades, err := s.lendingTradeDao.GetLendingTradeByTime(0, lastTime+1, pageOffset*size, size)
+		if err != nil {
+			return err
+		}
+		if len(trades) == 0 {
+			break
+		}
+		for _, trade := range trades {
+			s.countTrade(trade)
+		}
+		pageOffset = pageOffset + 1
 	}
-	s.lendingTradeCache.lastTime = cache.LastTime
+	s.lendingTradeCache.lastTime = lastTime
+	s.lendingTradeCache.resumeToken = resumeToken
 	return nil
 }
 
+// clearCache remove time frames and trade hashes from cache store, metadata is kept until next commit
+func (s *LendingTradeService) clearCache() error {
+	batch := NewCacheBatch()
+	for _, prefix := range []string{lendingStoreRelayerUserTrade, lendingStoreMarketTrade, lendingStoreTrade} {
+		err := s.store.Iterate(prefix, "", func(key string, value []byte) error {
+			batch.Delete(key)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return s.store.Commit(batch)
+}
+
+func (s *LendingTradeService) getRelayerUserTradeStoreKey(modTime int64, relayerAddress, lendingToken common.Address, term uint64, userAddress common.Address) string {
+	return fmt.Sprintf("%s%s/%s/%s/%s", lendingStoreRelayerUserTrade, utils.UintToPaddedString(modTime), relayerAddress.Hex(), s.getCacheKeyString(term, lendingToken), userAddress.Hex())
+}
+
+func (s *LendingTradeService) getMarketTradeStoreKey(modTime int64, relayerAddress, lendingToken common.Address, term uint64) string {
+	return fmt.Sprintf("%s%s/%s/%s", lendingStoreMarketTrade, utils.UintToPaddedString(modTime), relayerAddress.Hex(), s.getCacheKeyString(term, lendingToken))
+}
+
 func (s *LendingTradeService) getCacheKeyString(term uint64, lendingToken common.Address) string {
-	return fmt.Sprintf("%d::%s", term, lendingToken)
+	return fmt.Sprintf("%d::%s", term, lendingToken.Hex())
 }
 
-func (s *LendingTradeService) parsePairString(key string) (common.Address, common.Address, error) {
+func (s *LendingTradeService) parseCacheKeyString(key string) (uint64, common.Address, error) {
 	tokens := strings.Split(key, "::")
 	if len(tokens) == 2 {
-		return common.HexToAddress(tokens[0]), common.HexToAddress(tokens[1]), nil
+		term, err := strconv.ParseUint(tokens[0], 10, 64)
+		if err != nil {
+			return 0, common.Address{}, err
+		}
+		return term, common.HexToAddress(tokens[1]), nil
 	}
-	return common.Address{}, common.Address{}, errors.New("Invalid Key")
+	return 0, common.Address{}, errors.New("Invalid Key")
 }
 
-// updateRelayerTick update lastest tick, need to be lock
+// addTrade count trade once by trade hash, need to be lock
+// status updates of a trade do not change its volume, the close of a loan updates its interest
+func (s *LendingTradeService) addTrade(trade *types.LendingTrade) bool {
+	if record, ok := s.lendingTradeCache.trades[trade.Hash]; ok {
+		s.closeTrade(record, trade)
+		return false
+	}
+	return s.countTrade(trade)
+}
+
+// countTrade count trade if its hash is not known, need to be lock
+func (s *LendingTradeService) countTrade(trade *types.LendingTrade) bool {
+	if _, ok := s.lendingTradeCache.trades[trade.Hash]; ok {
+		return false
+	}
+	s.lendingTradeCache.trades[trade.Hash] = &lendingTradeRecord{
+		Time:     trade.CreatedAt.Unix(),
+		Maturity: int64(trade.LiquidationTime),
+		Closed:   isClosedLoan(trade),
+	}
+	s.lendingTradeCache.dirtyTrades[trade.Hash] = true
+	s.updateRelayerUserTrade(trade)
+	s.updateMarketTrade(trade)
+	return true
+}
+
+// updateRelayerUserTrade count trade for borrower and investor in their relayer, need to be lock
+// a user is counted once when both sides of the trade are the same user in the same relayer
 func (s *LendingTradeService) updateRelayerUserTrade(trade *types.LendingTrade) error {
-	tradeTime := trade.CreatedAt.Unix()
-	modTime, _ := utils.GetModTime(tradeTime, duration, unit)
-	if trade.BorrowingRelayer.Hex() == trade.InvestingRelayer.Hex() {
-		// BorrowingRelayer == InvestingRelayer
-		if _, ok := s.lendingTradeCache.relayerUserTrades[trade.BorrowingRelayer]; !ok {
-			s.lendingTradeCache.relayerUserTrades[trade.BorrowingRelayer] = make(map[common.Address]map[int64]*types.LendingUserTrade)
-		}
-		if trade.Investor.Hex() == trade.Borrower.Hex() {
-			// BorrowingRelayer == InvestingRelayer, Investor == Borrower
-			if _, ok := s.lendingTradeCache.relayerUserTrades[trade.BorrowingRelayer][trade.Borrower]; !ok {
-				s.lendingTradeCache.relayerUserTrades[trade.BorrowingRelayer][trade.Borrower] = make(map[int64]*types.LendingUserTrade)
-			}
-			if last, ok := s.lendingTradeCache.relayerUserTrades[trade.BorrowingRelayer][trade.Borrower][modTime]; !ok {
-				userTrade := &types.LendingUserTrade{
-					UserAddress:    trade.Investor,
-					Count:          big.NewInt(1),
-					RelayerAddress: trade.BorrowingRelayer,
-				}
-				s.lendingTradeCache.relayerUserTrades[trade.BorrowingRelayer][trade.Borrower][modTime] = userTrade
-			} else {
-				last.Count = new(big.Int).Add(last.Count, big.NewInt(1))
+	modTime, _ := utils.GetModTime(trade.CreatedAt.Unix(), duration, unit)
+	key := s.getCacheKeyString(trade.Term, trade.LendingToken)
+	users := make(map[common.Address]map[common.Address]bool)
+	users[trade.BorrowingRelayer] = map[common.Address]bool{trade.Borrower: true}
+	if _, ok := users[trade.InvestingRelayer]; !ok {
+		users[trade.InvestingRelayer] = make(map[common.Address]bool)
+	}
+	users[trade.InvestingRelayer][trade.Investor] = true
+
+	amount := big.NewInt(0)
+	if trade.Amount != nil {
+		amount = trade.Amount
+	}
+	for relayerAddress, userAddresses := range users {
+		for userAddress := range userAddresses {
+			last := s.getRelayerUserTrade(relayerAddress, key, userAddress, modTime, trade)
+			last.Count = new(big.Int).Add(last.Count, big.NewInt(1))
+			last.Volume = addBigInt(last.Volume, amount)
+		}
+	}
+
+	interest := getInterestAmount(trade)
+	borrower := s.getRelayerUserTrade(trade.BorrowingRelayer, key, trade.Borrower, modTime, trade)
+	borrower.BorrowingCount = addBigInt(borrower.BorrowingCount, big.NewInt(1))
+	borrower.BorrowingVolume = addBigInt(borrower.BorrowingVolume, amount)
+	borrower.InterestPaid = addBigInt(borrower.InterestPaid, interest)
+	investor := s.getRelayerUserTrade(trade.InvestingRelayer, key, trade.Investor, modTime, trade)
+	investor.InvestingCount = addBigInt(investor.InvestingCount, big.NewInt(1))
+	investor.InvestingVolume = addBigInt(investor.InvestingVolume, amount)
+	investor.InterestEarned = addBigInt(investor.InterestEarned, interest)
+	return nil
+}
+
+// getRelayerUserTrade get time frame of user for trade and mark it to be committed, need to be lock
+func (s *LendingTradeService) getRelayerUserTrade(relayerAddress common.Address, key string, userAddress common.Address, modTime int64, trade *types.LendingTrade) *types.LendingUserTrade {
+	userTrades := s.getRelayerUserTrades(relayerAddress, key, userAddress)
+	last, ok := userTrades[modTime]
+	if !ok {
+		last = &types.LendingUserTrade{
+			UserAddress:    userAddress,
+			Count:          big.NewInt(0),
+			Volume:         big.NewInt(0),
+			RelayerAddress: relayerAddress,
+			LendingToken:   trade.LendingToken,
+			Term:           trade.Term,
+			TimeStamp:      modTime,
+		}
+		userTrades[modTime] = last
+	}
+	s.lendingTradeCache.dirtyUserTrades[s.getRelayerUserTradeStoreKey(modTime, relayerAddress, trade.LendingToken, trade.Term, userAddress)] = last
+	return last
+}
+
+// closeTrade update interest of a loan counted while open when it is repaid or liquidated, need to be lock
+// the interest is moved to the time frame of the loan creation like its volume
+func (s *LendingTradeService) closeTrade(record *lendingTradeRecord, trade *types.LendingTrade) {
+	if record.Closed || !isClosedLoan(trade) {
+		return
+	}
+	record.Closed = true
+	s.lendingTradeCache.dirtyTrades[trade.Hash] = true
+	delta := new(big.Int).Sub(getInterestAmount(trade), getLoanInterest(trade, trade.Term))
+	if delta.Sign() == 0 {
+		return
+	}
+	modTime, _ := utils.GetModTime(trade.CreatedAt.Unix(), duration, unit)
+	key := s.getCacheKeyString(trade.Term, trade.LendingToken)
+	borrower := s.getRelayerUserTrade(trade.BorrowingRelayer, key, trade.Borrower, modTime, trade)
+	borrower.InterestPaid = addBigInt(borrower.InterestPaid, delta)
+	investor := s.getRelayerUserTrade(trade.InvestingRelayer, key, trade.Investor, modTime, trade)
+	investor.InterestEarned = addBigInt(investor.InterestEarned, delta)
+}
+
+// isClosedLoan check if loan is repaid or liquidated
+func isClosedLoan(trade *types.LendingTrade) bool {
+	return trade.Status == types.TradeStatusClosed || trade.Status == types.TradeStatusLiquidated
+}
+
+// getInterestAmount get interest of trade in lending token, due at maturity for an open loan
+// the interest of a loan repaid or liquidated before maturity accrues until it is closed
+func getInterestAmount(trade *types.LendingTrade) *big.Int {
+	if !isClosedLoan(trade) {
+		return getLoanInterest(trade, trade.Term)
+	}
+	elapsed := trade.UpdatedAt.Unix() - trade.CreatedAt.Unix()
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	if uint64(elapsed) > trade.Term {
+		return getLoanInterest(trade, trade.Term)
+	}
+	return getLoanInterest(trade, uint64(elapsed))
+}
+
+// getLoanInterest get interest of trade over seconds, in lending token
+// interest is an annual rate in percent with lendingInterestDecimals decimals
+func getLoanInterest(trade *types.LendingTrade, seconds uint64) *big.Int {
+	if trade.Amount == nil {
+		return big.NewInt(0)
+	}
+	interest := new(big.Int).Mul(trade.Amount, new(big.Int).SetUint64(trade.Interest))
+	interest = interest.Mul(interest, new(big.Int).SetUint64(seconds))
+	return interest.Div(interest, new(big.Int).Mul(big.NewInt(lendingOneYear*100), big.NewInt(lendingInterestDecimals)))
+}
+
+// addBigInt add b to a, a is nil in time frames stored before the field existed
+func addBigInt(a, b *big.Int) *big.Int {
+	if a == nil {
+		return new(big.Int).Set(b)
+	}
+	return new(big.Int).Add(a, b)
+}
+
+// addOptionalBigInt add b to a, b is nil in time frames stored before the field existed
+func addOptionalBigInt(a, b *big.Int) *big.Int {
+	if b == nil {
+		return a
+	}
+	return new(big.Int).Add(a, b)
+}
+
+// updateMarketTrade add trade to lending token and term statistics of both relayers and all relayers, need to be lock
+// borrowing fee is the revenue of the borrowing relayer, investing fee of the investing relayer
+func (s *LendingTradeService) updateMarketTrade(trade *types.LendingTrade) {
+	modTime, _ := utils.GetModTime(trade.CreatedAt.Unix(), duration, unit)
+	key := s.getCacheKeyString(trade.Term, trade.LendingToken)
+	amount := big.NewInt(0)
+	if trade.Amount != nil {
+		amount = trade.Amount
+	}
+	borrowingFee := big.NewInt(0)
+	if trade.BorrowingFee != nil {
+		borrowingFee = trade.BorrowingFee
+	}
+	investingFee := big.NewInt(0)
+	if trade.InvestingFee != nil {
+		investingFee = trade.InvestingFee
+	}
+	interest := new(big.Int).SetUint64(trade.Interest)
+
+	relayers := []common.Address{common.Address{}, trade.BorrowingRelayer}
+	if trade.InvestingRelayer != trade.BorrowingRelayer {
+		relayers = append(relayers, trade.InvestingRelayer)
+	}
+	for _, relayerAddress := range relayers {
+		if _, ok := s.lendingTradeCache.marketTrades[relayerAddress]; !ok {
+			s.lendingTradeCache.marketTrades[relayerAddress] = make(map[string]map[int64]*types.LendingMarketTrade)
+		}
+		if _, ok := s.lendingTradeCache.marketTrades[relayerAddress][key]; !ok {
+			s.lendingTradeCache.marketTrades[relayerAddress][key] = make(map[int64]*types.LendingMarketTrade)
+		}
+		last, ok := s.lendingTradeCache.marketTrades[relayerAddress][key][modTime]
+		if !ok {
+			last = &types.LendingMarketTrade{
+				RelayerAddress:      relayerAddress,
+				LendingToken:        trade.LendingToken,
+				Term:                trade.Term,
+				Count:               big.NewInt(0),
+				Volume:              big.NewInt(0),
+				InterestSum:         big.NewInt(0),
+				WeightedInterestSum: big.NewInt(0),
+				BorrowingFee:        big.NewInt(0),
+				InvestingFee:        big.NewInt(0),
+				Borrowing:           types.NewLendingSideTrade(),
+				Investing:           types.NewLendingSideTrade(),
+				TimeStamp:           modTime,
 			}
-		} else {
-			// BorrowingRelayer == InvestingRelayer, Investor # Borrower
-			if _, ok := s.lendingTradeCache.relayerUserTrades[trade.BorrowingRelayer][trade.Borrower]; !ok {
-				s.lendingTradeCache.relayerUserTrades[trade.BorrowingRelayer][trade.Borrower] = make(map[int64]*types.LendingUserTrade)
+			s.lendingTradeCache.marketTrades[relayerAddress][key][modTime] = last
+		}
+		last.Count = new(big.Int).Add(last.Count, big.NewInt(1))
+		last.Volume = new(big.Int).Add(last.Volume, amount)
+		last.InterestSum = new(big.Int).Add(last.InterestSum, interest)
+		last.WeightedInterestSum = new(big.Int).Add(last.WeightedInterestSum, new(big.Int).Mul(interest, amount))
+		if (relayerAddress == common.Address{}) || relayerAddress == trade.BorrowingRelayer {
+			last.BorrowingFee = new(big.Int).Add(last.BorrowingFee, borrowingFee)
+			if last.Borrowing == nil {
+				last.Borrowing = types.NewLendingSideTrade()
 			}
-			if _, ok := s.lendingTradeCache.relayerUserTrades[trade.InvestingRelayer][trade.Investor]; !ok {
-				s.lendingTradeCache.relayerUserTrades[trade.InvestingRelayer][trade.Investor] = make(map[int64]*types.LendingUserTrade)
+			addSideTrade(last.Borrowing, amount, interest)
+		}
+		if (relayerAddress == common.Address{}) || relayerAddress == trade.InvestingRelayer {
+			last.InvestingFee = new(big.Int).Add(last.InvestingFee, investingFee)
+			if last.Investing == nil {
+				last.Investing = types.NewLendingSideTrade()
 			}
+			addSideTrade(last.Investing, amount, interest)
+		}
+		s.lendingTradeCache.dirtyMarketTrades[s.getMarketTradeStoreKey(modTime, relayerAddress, trade.LendingToken, trade.Term)] = last
+	}
+}
 
-			if last, ok := s.lendingTradeCache.relayerUserTrades[trade.BorrowingRelayer][trade.Borrower][modTime]; !ok {
-				userTrade := &types.LendingUserTrade{
-					UserAddress:    trade.Borrower,
-					Count:          big.NewInt(1),
-					RelayerAddress: trade.BorrowingRelayer,
-				}
-				s.lendingTradeCache.relayerUserTrades[trade.BorrowingRelayer][trade.Borrower][modTime] = userTrade
-			} else {
-				last.Count = new(big.Int).Add(last.Count, big.NewInt(1))
-			}
+func addSideTrade(side *types.LendingSideTrade, amount, interest *big.Int) {
+	side.Count = new(big.Int).Add(side.Count, big.NewInt(1))
+	side.Volume = new(big.Int).Add(side.Volume, amount)
+	side.InterestSum = new(big.Int).Add(side.InterestSum, interest)
+	side.WeightedInterestSum = new(big.Int).Add(side.WeightedInterestSum, new(big.Int).Mul(interest, amount))
+}
 
-			if last, ok := s.lendingTradeCache.relayerUserTrades[trade.InvestingRelayer][trade.Investor][modTime]; !ok {
-				userTrade := &types.LendingUserTrade{
-					UserAddress:    trade.Investor,
-					Count:          big.NewInt(1),
-					RelayerAddress: trade.InvestingRelayer,
+// getRelayerUserTrades get time frames of user, need to be lock
+func (s *LendingTradeService) getRelayerUserTrades(relayerAddress common.Address, key string, userAddress common.Address) map[int64]*types.LendingUserTrade {
+	if _, ok := s.lendingTradeCache.relayerUserTrades[relayerAddress]; !ok {
+		s.lendingTradeCache.relayerUserTrades[relayerAddress] = make(map[string]map[common.Address]map[int64]*types.LendingUserTrade)
+	}
+	if _, ok := s.lendingTradeCache.relayerUserTrades[relayerAddress][key]; !ok {
+		s.lendingTradeCache.relayerUserTrades[relayerAddress][key] = make(map[common.Address]map[int64]*types.LendingUserTrade)
+	}
+	if _, ok := s.lendingTradeCache.relayerUserTrades[relayerAddress][key][userAddress]; !ok {
+		s.lendingTradeCache.relayerUserTrades[relayerAddress][key][userAddress] = make(map[int64]*types.LendingUserTrade)
+	}
+	return s.lendingTradeCache.relayerUserTrades[relayerAddress][key][userAddress]
+}
+
+func (s *LendingTradeService) addRelayerUserTrade(userTrade *types.LendingUserTrade) {
+	key := s.getCacheKeyString(userTrade.Term, userTrade.LendingToken)
+	s.getRelayerUserTrades(userTrade.RelayerAddress, key, userTrade.UserAddress)[userTrade.TimeStamp] = userTrade
+}
+
+func (s *LendingTradeService) addMarketTrade(marketTrade *types.LendingMarketTrade) {
+	key := s.getCacheKeyString(marketTrade.Term, marketTrade.LendingToken)
+	if _, ok := s.lendingTradeCache.marketTrades[marketTrade.RelayerAddress]; !ok {
+		s.lendingTradeCache.marketTrades[marketTrade.RelayerAddress] = make(map[string]map[int64]*types.LendingMarketTrade)
+	}
+	if _, ok := s.lendingTradeCache.marketTrades[marketTrade.RelayerAddress][key]; !ok {
+		s.lendingTradeCache.marketTrades[marketTrade.RelayerAddress][key] = make(map[int64]*types.LendingMarketTrade)
+	}
+	s.lendingTradeCache.marketTrades[marketTrade.RelayerAddress][key][marketTrade.TimeStamp] = marketTrade
+}
+
+// GetNumberTraderByTime get number trader bytime
+// empty relayer address counts distinct users of all relayers, role is borrower, investor or any
+func (s *LendingTradeService) GetNumberTraderByTime(relayerAddress common.Address, role string, dateFrom, dateTo int64) int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	users := make(map[common.Address]bool)
+	for relayer, tradebykey := range s.lendingTradeCache.relayerUserTrades {
+		if (relayerAddress != common.Address{}) && relayer != relayerAddress {
+			continue
+		}
+		for _, tradebyuseraddress := range tradebykey {
+			for address, tradeuserbytime := range tradebyuseraddress {
+				for t, trade := range tradeuserbytime {
+					if (t >= dateFrom || dateFrom == 0) && (t <= dateTo || dateTo == 0) && hasLendingRole(trade, role) {
+						users[address] = true
+					}
 				}
-				s.lendingTradeCache.relayerUserTrades[trade.InvestingRelayer][trade.Investor][modTime] = userTrade
-			} else {
-				last.Count = new(big.Int).Add(last.Count, big.NewInt(1))
 			}
+		}
+	}
+	return len(users)
+}
 
+// hasLendingRole check user borrowed or lent in time frame
+func hasLendingRole(trade *types.LendingUserTrade, role string) bool {
+	switch role {
+	case LendingRoleBorrower:
+		return trade.BorrowingCount != nil && trade.BorrowingCount.Sign() > 0
+	case LendingRoleInvestor:
+		return trade.InvestingCount != nil && trade.InvestingCount.Sign() > 0
+	}
+	return true
+}
+
+// IsValidRole check role param of lending endpoints, empty for any
+func (s *LendingTradeService) IsValidRole(role string) bool {
+	return role == "" || role == LendingRoleAny || role == LendingRoleBorrower || role == LendingRoleInvestor
+}
+
+// GetLendingVolume get lending statistics by lending token, term is 0 for all terms
+// empty relayer address for all relayers, empty lending token for all lending tokens
+// role borrower or investor keeps the trades where the relayer matched this side
+// VolumeUSD is set if price service is not nil and lending token has a USD price, markets are then sorted by it
+func (s *LendingTradeService) GetLendingVolume(relayerAddress, lendingToken common.Address, term uint64, role string, from, to int64, priceService *PriceService) []*types.LendingMarket {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.getLendingMarkets(relayerAddress, lendingToken, term, role, from, to, false, priceService)
+}
+
+// GetLendingMarkets get lending statistics by lending token and term
+// empty relayer address for all relayers, empty lending token for all lending tokens, term is 0 for all terms
+func (s *LendingTradeService) GetLendingMarkets(relayerAddress, lendingToken common.Address, term uint64, role string, from, to int64, priceService *PriceService) []*types.LendingMarket {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.getLendingMarkets(relayerAddress, lendingToken, term, role, from, to, true, priceService)
+}
+
+func (s *LendingTradeService) getLendingMarkets(relayerAddress, lendingToken common.Address, term uint64, role string, from, to int64, byTerm bool, priceService *PriceService) []*types.LendingMarket {
+	markets := make(map[string]*types.LendingMarket)
+	interestSums := make(map[string]*big.Int)
+	weightedInterestSums := make(map[string]*big.Int)
+	for key, tradebytime := range s.lendingTradeCache.marketTrades[relayerAddress] {
+		t, token, err := s.parseCacheKeyString(key)
+		if err != nil || (lendingToken != common.Address{}) && lendingToken != token || term != 0 && term != t {
+			continue
 		}
-	} else {
-		// BorrowingRelayer # InvestingRelayer
-		if _, ok := s.lendingTradeCache.relayerUserTrades[trade.BorrowingRelayer]; !ok {
-			s.lendingTradeCache.relayerUserTrades[trade.BorrowingRelayer] = make(map[common.Address]map[int64]*types.LendingUserTrade)
+		if !byTerm {
+			t = 0
 		}
-		if _, ok := s.lendingTradeCache.relayerUserTrades[trade.InvestingRelayer]; !ok {
-			s.lendingTradeCache.relayerUserTrades[trade.InvestingRelayer] = make(map[common.Address]map[int64]*types.LendingUserTrade)
+		marketKey := s.getCacheKeyString(t, token)
+		for timestamp, trade := range tradebytime {
+			if (from != 0 && timestamp < from) || (to != 0 && timestamp > to) {
+				continue
+			}
+			side := &types.LendingSideTrade{
+				Count:               trade.Count,
+				Volume:              trade.Volume,
+				InterestSum:         trade.InterestSum,
+				WeightedInterestSum: trade.WeightedInterestSum,
+			}
+			borrowingFee, investingFee := trade.BorrowingFee, trade.InvestingFee
+			switch role {
+			case LendingRoleBorrower:
+				side, investingFee = trade.Borrowing, big.NewInt(0)
+			case LendingRoleInvestor:
+				side, borrowingFee = trade.Investing, big.NewInt(0)
+			}
+			// time frames stored before sides were tracked
+			if side == nil {
+				continue
+			}
+			market, ok := markets[marketKey]
+			if !ok {
+				market = &types.LendingMarket{
+					RelayerAddress: relayerAddress,
+					LendingToken:   token,
+					Term:           t,
+					Count:          big.NewInt(0),
+					Volume:         big.NewInt(0),
+					BorrowingFee:   big.NewInt(0),
+					InvestingFee:   big.NewInt(0),
+				}
+				markets[marketKey] = market
+				interestSums[marketKey] = big.NewInt(0)
+				weightedInterestSums[marketKey] = big.NewInt(0)
+			}
+			market.Count = new(big.Int).Add(market.Count, side.Count)
+			market.Volume = new(big.Int).Add(market.Volume, side.Volume)
+			market.BorrowingFee = new(big.Int).Add(market.BorrowingFee, borrowingFee)
+			market.InvestingFee = new(big.Int).Add(market.InvestingFee, investingFee)
+			interestSums[marketKey] = new(big.Int).Add(interestSums[marketKey], side.InterestSum)
+			weightedInterestSums[marketKey] = new(big.Int).Add(weightedInterestSums[marketKey], side.WeightedInterestSum)
 		}
-		if _, ok := s.lendingTradeCache.relayerUserTrades[trade.BorrowingRelayer][trade.Borrower]; !ok {
-			s.lendingTradeCache.relayerUserTrades[trade.BorrowingRelayer][trade.Borrower] = make(map[int64]*types.LendingUserTrade)
+	}
+	res := []*types.LendingMarket{}
+	volumesUSD := make(map[*types.LendingMarket]*big.Float)
+	for key, market := range markets {
+		if market.Count.Sign() > 0 {
+			market.AverageInterest = math.DivideToFloat(interestSums[key], market.Count)
 		}
-		if _, ok := s.lendingTradeCache.relayerUserTrades[trade.InvestingRelayer][trade.Investor]; !ok {
-			s.lendingTradeCache.relayerUserTrades[trade.InvestingRelayer][trade.Investor] = make(map[int64]*types.LendingUserTrade)
+		if market.Volume.Sign() > 0 {
+			market.WeightedInterest = math.DivideToFloat(weightedInterestSums[key], market.Volume)
 		}
-		if last, ok := s.lendingTradeCache.relayerUserTrades[trade.InvestingRelayer][trade.Investor][modTime]; !ok {
-			userTrade := &types.LendingUserTrade{
-				UserAddress:    trade.Investor,
-				Count:          big.NewInt(1),
-				RelayerAddress: trade.InvestingRelayer,
+		if priceService != nil {
+			if usd, ok := priceService.ToUSD(market.LendingToken, market.Volume); ok {
+				volumesUSD[market] = usd
+				market.VolumeUSD = FormatUSD(usd)
 			}
-			s.lendingTradeCache.relayerUserTrades[trade.InvestingRelayer][trade.Investor][modTime] = userTrade
-		} else {
-			last.Count = new(big.Int).Add(last.Count, big.NewInt(1))
 		}
+		res = append(res, market)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if cmp := compareUSD(volumesUSD[res[i]], volumesUSD[res[j]]); cmp != 0 {
+			return cmp > 0
+		}
+		if cmp := res[i].Volume.Cmp(res[j].Volume); cmp != 0 {
+			return cmp > 0
+		}
+		if res[i].LendingToken != res[j].LendingToken {
+			return strings.ToLower(res[i].LendingToken.Hex()) < strings.ToLower(res[j].LendingToken.Hex())
+		}
+		return res[i].Term < res[j].Term
+	})
+	return res
+}
 
-		if last, ok := s.lendingTradeCache.relayerUserTrades[trade.BorrowingRelayer][trade.Borrower][modTime]; !ok {
-			userTrade := &types.LendingUserTrade{
-				UserAddress:    trade.Borrower,
-				Count:          big.NewInt(1),
-				RelayerAddress: trade.BorrowingRelayer,
+// GetTopLendingUsers rank borrowers or investors of lending token by principal, interest or number of loans
+// amounts of different lending tokens can not be summed, an empty lending token has no ranking
+// empty relayer address for all relayers, term is 0 for all terms
+// empty user address for top users, the rank of the user otherwise
+func (s *LendingTradeService) GetTopLendingUsers(relayerAddress, userAddress, lendingToken common.Address, term uint64, from, to int64, role, sortBy string, top int) []*types.LendingUserVolume {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if top == 0 {
+		top = 10
+	}
+	if (lendingToken == common.Address{}) {
+		return []*types.LendingUserVolume{}
+	}
+	borrower := role == LendingRoleBorrower
+	users := make(map[common.Address]*types.LendingUserVolume)
+	for relayer, tradebykey := range s.lendingTradeCache.relayerUserTrades {
+		if (relayerAddress != common.Address{}) && relayer != relayerAddress {
+			continue
+		}
+		for key, tradebyuseraddress := range tradebykey {
+			t, token, err := s.parseCacheKeyString(key)
+			if err != nil || lendingToken != token || term != 0 && term != t {
+				continue
+			}
+			for address, tradeuserbytime := range tradebyuseraddress {
+				if s.addressListService.GetIndex().IsBotAddress(address) {
+					continue
+				}
+				for timestamp, trade := range tradeuserbytime {
+					if (from != 0 && timestamp < from) || (to != 0 && timestamp > to) {
+						continue
+					}
+					count, volume, interest := trade.InvestingCount, trade.InvestingVolume, trade.InterestEarned
+					if borrower {
+						count, volume, interest = trade.BorrowingCount, trade.BorrowingVolume, trade.InterestPaid
+					}
+					if count == nil || count.Sign() == 0 {
+						continue
+					}
+					u, ok := users[address]
+					if !ok {
+						u = &types.LendingUserVolume{
+							UserAddress: address,
+							Count:       big.NewInt(0),
+							Volume:      big.NewInt(0),
+							Interest:    big.NewInt(0),
+						}
+						users[address] = u
+					}
+					u.Count = addBigInt(u.Count, count)
+					u.Volume = addBigInt(u.Volume, volume)
+					u.Interest = addBigInt(u.Interest, interest)
+				}
 			}
-			s.lendingTradeCache.relayerUserTrades[trade.BorrowingRelayer][trade.Borrower][modTime] = userTrade
-		} else {
-			last.Count = new(big.Int).Add(last.Count, big.NewInt(1))
 		}
 	}
 
-	return nil
+	var ranks []*types.LendingUserVolume
+	for _, u := range users {
+		ranks = append(ranks, u)
+	}
+	sort.Slice(ranks, func(i, j int) bool {
+		score1, score2 := ranks[i].Volume, ranks[j].Volume
+		switch sortBy {
+		case LendingSortInterest:
+			score1, score2 = ranks[i].Interest, ranks[j].Interest
+		case LendingSortCount:
+			score1, score2 = ranks[i].Count, ranks[j].Count
+		}
+		if cmp := score1.Cmp(score2); cmp != 0 {
+			return cmp > 0
+		}
+		return strings.ToLower(ranks[i].UserAddress.Hex()) < strings.ToLower(ranks[j].UserAddress.Hex())
+	})
+	res := []*types.LendingUserVolume{}
+	for i, u := range ranks {
+		if (userAddress == common.Address{} || u.UserAddress == userAddress) {
+			u.Rank = i + 1
+			res = append(res, u)
+		}
+	}
+	if top >= len(res) {
+		top = len(res)
+	}
+	return res[0:top]
 }
 
-func (s *LendingTradeService) addRelayerUserTrade(userTrade *types.LendingUserTrade) {
-	if _, ok := s.lendingTradeCache.relayerUserTrades[userTrade.RelayerAddress]; !ok {
-		s.lendingTradeCache.relayerUserTrades[userTrade.RelayerAddress] = make(map[common.Address]map[int64]*types.LendingUserTrade)
-	}
-	if _, ok := s.lendingTradeCache.relayerUserTrades[userTrade.RelayerAddress][userTrade.UserAddress]; !ok {
-		s.lendingTradeCache.relayerUserTrades[userTrade.RelayerAddress][userTrade.UserAddress] = make(map[int64]*types.LendingUserTrade)
+// IsValidRanking check role and sort params of lending leaderboard
+func (s *LendingTradeService) IsValidRanking(role, sortBy string) bool {
+	return (role == LendingRoleBorrower || role == LendingRoleInvestor) &&
+		(sortBy == LendingSortVolume || sortBy == LendingSortInterest || sortBy == LendingSortCount)
+}
+
+// GetRelayerLendingFees get lending trades of relayer by lending token, term and time frame, sorted by time
+func (s *LendingTradeService) GetRelayerLendingFees(relayerAddress common.Address, from, to int64) []*types.LendingMarketTrade {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	res := []*types.LendingMarketTrade{}
+	for _, tradebytime := range s.lendingTradeCache.marketTrades[relayerAddress] {
+		for timestamp, trade := range tradebytime {
+			if (from != 0 && timestamp < from) || (to != 0 && timestamp > to) {
+				continue
+			}
+			t := *trade
+			res = append(res, &t)
+		}
 	}
-	s.lendingTradeCache.relayerUserTrades[userTrade.RelayerAddress][userTrade.UserAddress][userTrade.TimeStamp] = userTrade
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].TimeStamp < res[j].TimeStamp
+	})
+	return res
 }
 
-// GetNumberTraderByTime get number trader bytime
-func (s *LendingTradeService) GetNumberTraderByTime(relayerAddress common.Address, dateFrom, dateTo int64) int {
-	users := make(map[common.Address]bool)
-	if tradebyrelayerAddress, ok := s.lendingTradeCache.relayerUserTrades[relayerAddress]; ok {
-		for address, tradeuserbyaddress := range tradebyrelayerAddress {
-			for t := range tradeuserbyaddress {
-				if (t >= dateFrom || dateFrom == 0) && (t <= dateTo || dateTo == 0) {
-					users[address] = true
+// GetUserLendingProfile get loans of user by lending token, over all relayers and terms
+func (s *LendingTradeService) GetUserLendingProfile(userAddress common.Address) []*types.UserLendingProfile {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	tokens := make(map[common.Address]*types.UserLendingProfile)
+	for _, tradebykey := range s.lendingTradeCache.relayerUserTrades {
+		for key, tradebyuseraddress := range tradebykey {
+			_, lendingToken, err := s.parseCacheKeyString(key)
+			if err != nil {
+				continue
+			}
+			for _, trade := range tradebyuseraddress[userAddress] {
+				profile, ok := tokens[lendingToken]
+				if !ok {
+					profile = &types.UserLendingProfile{
+						LendingToken:    lendingToken,
+						BorrowingCount:  big.NewInt(0),
+						BorrowingVolume: big.NewInt(0),
+						InterestPaid:    big.NewInt(0),
+						InvestingCount:  big.NewInt(0),
+						InvestingVolume: big.NewInt(0),
+						InterestEarned:  big.NewInt(0),
+					}
+					tokens[lendingToken] = profile
 				}
+				profile.BorrowingCount = addOptionalBigInt(profile.BorrowingCount, trade.BorrowingCount)
+				profile.BorrowingVolume = addOptionalBigInt(profile.BorrowingVolume, trade.BorrowingVolume)
+				profile.InterestPaid = addOptionalBigInt(profile.InterestPaid, trade.InterestPaid)
+				profile.InvestingCount = addOptionalBigInt(profile.InvestingCount, trade.InvestingCount)
+				profile.InvestingVolume = addOptionalBigInt(profile.InvestingVolume, trade.InvestingVolume)
+				profile.InterestEarned = addOptionalBigInt(profile.InterestEarned, trade.InterestEarned)
 			}
 		}
 	}
-	return len(users)
+	res := []*types.UserLendingProfile{}
+	for _, profile := range tokens {
+		res = append(res, profile)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return strings.ToLower(res[i].LendingToken.Hex()) < strings.ToLower(res[j].LendingToken.Hex())
+	})
+	return res
 }
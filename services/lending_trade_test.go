@@ -0,0 +1,195 @@
+package services
+
+import (
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/tomochain/tomox-stats/types"
+	"github.com/tomochain/tomox-stats/utils"
+)
+
+var (
+	testLendingToken = common.HexToAddress("0x0000000000000000000000000000000000000d01")
+	testBorrower     = common.HexToAddress("0x0000000000000000000000000000000000000a11")
+	testInvestor     = common.HexToAddress("0x0000000000000000000000000000000000000a12")
+	testOtherRelayer = common.HexToAddress("0x0000000000000000000000000000000000000e02")
+)
+
+func newTestLendingTrade(hash int64, createdAt int64, amount int64) *types.LendingTrade {
+	return &types.LendingTrade{
+		Hash:             common.BigToHash(big.NewInt(hash)),
+		Borrower:         testBorrower,
+		Investor:         testInvestor,
+		LendingToken:     testLendingToken,
+		BorrowingRelayer: testRelayer,
+		InvestingRelayer: testRelayer,
+		Term:             86400,
+		Interest:         10 * lendingInterestDecimals,
+		Amount:           big.NewInt(amount),
+		Status:           types.TradeStatusSuccess,
+		CreatedAt:        time.Unix(createdAt, 0),
+	}
+}
+
+func TestLendingTradeReplay(t *testing.T) {
+	s := NewLendingTradeService(nil, nil, nil)
+	now := time.Now().Unix()
+	trade := newTestLendingTrade(1, now, 100)
+	// the backfill after a failed resume overlaps the events of the new stream
+	s.NotifyTrade(trade)
+	s.NotifyTrade(trade)
+	// status updates are notified with the same hash
+	repaid := *trade
+	repaid.Status = "REPAID"
+	s.NotifyTrade(&repaid)
+	s.NotifyTrade(newTestLendingTrade(2, now, 50))
+
+	markets := s.GetLendingVolume(common.Address{}, testLendingToken, 0, "", 0, 0, nil)
+	assert.Len(t, markets, 1)
+	assert.Equal(t, int64(2), markets[0].Count.Int64())
+	assert.Equal(t, int64(150), markets[0].Volume.Int64())
+	assert.Equal(t, 2, s.GetNumberTraderByTime(testRelayer, LendingRoleAny, 0, 0))
+}
+
+func TestLendingTradeRetention(t *testing.T) {
+	s := NewLendingTradeService(nil, nil, nil)
+	// update of a trade whose hash was pruned is not counted again
+	s.NotifyTrade(newTestLendingTrade(1, time.Now().Unix()-tradeHashRetention-60, 100))
+	assert.Empty(t, s.GetLendingVolume(common.Address{}, testLendingToken, 0, "", 0, 0, nil))
+}
+
+func TestLendingTradeBackfill(t *testing.T) {
+	s := NewLendingTradeService(nil, nil, nil)
+	// the backfill of fetch counts trades older than the hash retention
+	old := newTestLendingTrade(1, time.Now().Unix()-tradeHashRetention-24*60*60, 100)
+	s.mutex.Lock()
+	assert.True(t, s.addTrade(old))
+	s.mutex.Unlock()
+	markets := s.GetLendingVolume(common.Address{}, testLendingToken, 0, "", 0, 0, nil)
+	if assert.Len(t, markets, 1) {
+		assert.Equal(t, int64(100), markets[0].Volume.Int64())
+	}
+	assert.Equal(t, 2, s.GetNumberTraderByTime(testRelayer, LendingRoleAny, 0, 0))
+
+	// a later update of the backfilled trade is known and not counted again
+	s.NotifyTrade(old)
+	markets = s.GetLendingVolume(common.Address{}, testLendingToken, 0, "", 0, 0, nil)
+	assert.Equal(t, int64(100), markets[0].Volume.Int64())
+}
+
+func TestLendingTradeRelayers(t *testing.T) {
+	s := NewLendingTradeService(nil, nil, nil)
+	trade := newTestLendingTrade(1, time.Now().Unix(), 100)
+	trade.InvestingRelayer = testOtherRelayer
+	s.NotifyTrade(trade)
+	s.NotifyTrade(trade)
+
+	// the trade is counted once for each relayer and once for all relayers
+	for _, relayerAddress := range []common.Address{common.Address{}, testRelayer, testOtherRelayer} {
+		markets := s.GetLendingVolume(relayerAddress, testLendingToken, 0, "", 0, 0, nil)
+		if !assert.Len(t, markets, 1) {
+			return
+		}
+		assert.Equal(t, int64(1), markets[0].Count.Int64())
+		assert.Equal(t, int64(100), markets[0].Volume.Int64())
+	}
+	assert.Equal(t, 1, s.GetNumberTraderByTime(testRelayer, LendingRoleAny, 0, 0))
+	// users of all relayers are counted once
+	assert.Equal(t, 2, s.GetNumberTraderByTime(common.Address{}, LendingRoleAny, 0, 0))
+	assert.Equal(t, 1, s.GetNumberTraderByTime(common.Address{}, LendingRoleInvestor, 0, 0))
+}
+
+func TestLendingTradeInterest(t *testing.T) {
+	s := NewLendingTradeService(nil, NewAddressListService(nil, nil, nil), nil)
+	now := time.Now().Unix()
+	// 10% a year of a full year of seconds per term second, 864000 for the whole term
+	trade := newTestLendingTrade(1, now-86400, lendingOneYear*100)
+	trade.UpdatedAt = trade.CreatedAt
+	s.NotifyTrade(trade)
+	// the open loan pays the interest due at maturity
+	top := s.GetTopLendingUsers(common.Address{}, common.Address{}, testLendingToken, 0, 0, 0, LendingRoleBorrower, LendingSortInterest, 10)
+	if !assert.Len(t, top, 1) {
+		return
+	}
+	assert.Equal(t, int64(864000), top[0].Interest.Int64())
+
+	// the loan repaid at half of its term pays the interest accrued until repay, once
+	repaid := *trade
+	repaid.Status = types.TradeStatusClosed
+	repaid.UpdatedAt = time.Unix(now-86400/2, 0)
+	s.NotifyTrade(&repaid)
+	s.NotifyTrade(&repaid)
+	for _, role := range []string{LendingRoleBorrower, LendingRoleInvestor} {
+		top = s.GetTopLendingUsers(common.Address{}, common.Address{}, testLendingToken, 0, 0, 0, role, LendingSortInterest, 10)
+		if !assert.Len(t, top, 1) {
+			return
+		}
+		assert.Equal(t, int64(432000), top[0].Interest.Int64())
+	}
+
+	// amounts of different lending tokens are not ranked together
+	assert.Empty(t, s.GetTopLendingUsers(common.Address{}, common.Address{}, common.Address{}, 0, 0, 0, LendingRoleBorrower, LendingSortVolume, 10))
+}
+
+func TestLendingStoreRoundTrip(t *testing.T) {
+	dir := newTestCacheStoreDir(t)
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "lending.trade")
+	now := time.Now().Unix()
+
+	store, err := NewCacheStore(CacheStoreFile, name)
+	assert.NoError(t, err)
+	s := NewLendingTradeService(nil, nil, store)
+	trade := newTestLendingTrade(1, now, 100)
+	s.NotifyTrade(trade)
+	assert.NoError(t, s.commitCache())
+	store.Close()
+
+	store, err = NewCacheStore(CacheStoreFile, name)
+	assert.NoError(t, err)
+	loaded := NewLendingTradeService(nil, nil, store)
+	assert.NoError(t, loaded.loadCache())
+	// the hash of the trade is stored, its replay after restart is not counted again
+	loaded.NotifyTrade(trade)
+	markets := loaded.GetLendingVolume(common.Address{}, testLendingToken, 0, "", 0, 0, nil)
+	if !assert.Len(t, markets, 1) {
+		return
+	}
+	assert.Equal(t, int64(1), markets[0].Count.Int64())
+	assert.Equal(t, uint64(0), markets[0].Term)
+	assert.Equal(t, s.GetLendingMarkets(common.Address{}, testLendingToken, 0, "", 0, 0, nil), loaded.GetLendingMarkets(common.Address{}, testLendingToken, 0, "", 0, 0, nil))
+}
+
+func TestLendingStoreClear(t *testing.T) {
+	dir := newTestCacheStoreDir(t)
+	defer os.RemoveAll(dir)
+	store, err := NewCacheStore(CacheStoreFile, filepath.Join(dir, "lending.trade"))
+	assert.NoError(t, err)
+	// time frames of previous versions have no lending token and term in their keys
+	legacy := lendingStoreRelayerUserTrade + utils.UintToPaddedString(time.Now().Unix()) + "/" + testRelayer.Hex() + "/" + testBorrower.Hex()
+	batch := NewCacheBatch()
+	batch.Put(legacy, &types.LendingUserTrade{UserAddress: testBorrower, RelayerAddress: testRelayer, Count: big.NewInt(1)})
+	batch.Put(lendingStoreMeta, &lendingStoreMetadata{LastTime: time.Now().Unix()})
+	assert.NoError(t, store.Commit(batch))
+
+	s := NewLendingTradeService(nil, nil, store)
+	assert.NoError(t, s.clearCache())
+	value, err := store.Get(legacy)
+	assert.NoError(t, err)
+	assert.Nil(t, value)
+
+	// the metadata of the rebuilt time frames marks the new key format
+	s.NotifyTrade(newTestLendingTrade(1, time.Now().Unix(), 100))
+	assert.NoError(t, s.commitCache())
+	value, err = store.Get(lendingStoreMeta)
+	assert.NoError(t, err)
+	var meta lendingStoreMetadata
+	assert.NoError(t, json.Unmarshal(value, &meta))
+	assert.True(t, meta.Markets)
+}
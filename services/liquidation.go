@@ -0,0 +1,218 @@
+package services
+
+import (
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/tomochain/tomox-stats/daos"
+	"github.com/tomochain/tomox-stats/types"
+	"github.com/tomochain/tomox-stats/utils"
+)
+
+// LiquidationService logs liquidated lending trades and ranks open loans by liquidation risk
+// liquidations are queued by the lending trade stream and logged by a writer, a failed write is retried
+type LiquidationService struct {
+	liquidationDao  *daos.LiquidationDao
+	lendingTradeDao *daos.LendingTradeDao
+	tradeDao        *daos.TradeDao
+	loanBookService *LoanBookService
+	tradeService    *TradeService
+	priceService    *PriceService
+	// tradeHash => liquidated trade waiting to be logged
+	pending map[common.Hash]*types.LendingTrade
+	// queued wakes the writer up when a liquidation is queued
+	queued chan struct{}
+	mutex  sync.Mutex
+}
+
+// NewLiquidationService init new instance
+func NewLiquidationService(
+	liquidationDao *daos.LiquidationDao,
+	lendingTradeDao *daos.LendingTradeDao,
+	tradeDao *daos.TradeDao,
+	loanBookService *LoanBookService,
+	tradeService *TradeService,
+	priceService *PriceService,
+) *LiquidationService {
+	return &LiquidationService{
+		liquidationDao:  liquidationDao,
+		lendingTradeDao: lendingTradeDao,
+		tradeDao:        tradeDao,
+		loanBookService: loanBookService,
+		tradeService:    tradeService,
+		priceService:    priceService,
+		pending:         make(map[common.Hash]*types.LendingTrade),
+		queued:          make(chan struct{}, 1),
+	}
+}
+
+// Init log liquidated trades updated since the latest logged liquidation, then start the writer
+func (s *LiquidationService) Init() {
+	last, err := s.liquidationDao.GetLastLiquidatedAt()
+	if err != nil {
+		logger.Error(err)
+	}
+	var from int64
+	if !last.IsZero() {
+		from = last.Unix()
+	}
+	pageOffset := 0
+	size := 1000
+	for {
+		trades, err := s.lendingTradeDao.GetLendingTradeByStatusSince(types.TradeStatusLiquidated, from, pageOffset*size, size)
+		logger.Debug("FETCH LIQUIDATIONS", pageOffset*size)
+		if err != nil || len(trades) == 0 {
+			break
+		}
+		for _, trade := range trades {
+			if err := s.addLiquidation(trade); err != nil {
+				logger.Error(err)
+			}
+		}
+		pageOffset = pageOffset + 1
+	}
+	go s.writeLiquidations()
+}
+
+// NotifyLendingTrade queue trade when its status becomes liquidated
+// a liquidation is logged once by trade hash
+func (s *LiquidationService) NotifyLendingTrade(trade *types.LendingTrade) error {
+	if trade == nil || trade.Status != types.TradeStatusLiquidated {
+		return nil
+	}
+	s.mutex.Lock()
+	s.pending[trade.Hash] = trade
+	s.mutex.Unlock()
+	select {
+	case s.queued <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// writeLiquidations log queued liquidations when notified, failed ones are retried every minute
+func (s *LiquidationService) writeLiquidations() {
+	ticker := time.NewTicker(60 * time.Second)
+	for {
+		select {
+		case <-s.queued:
+		case <-ticker.C:
+		}
+		s.mutex.Lock()
+		pending := s.pending
+		s.pending = make(map[common.Hash]*types.LendingTrade)
+		s.mutex.Unlock()
+
+		for hash, trade := range pending {
+			if err := s.addLiquidation(trade); err != nil {
+				logger.Error(err)
+				s.mutex.Lock()
+				if _, ok := s.pending[hash]; !ok {
+					s.pending[hash] = trade
+				}
+				s.mutex.Unlock()
+			}
+		}
+	}
+}
+
+// addLiquidation log liquidation of trade with the collateral price at liquidation time
+func (s *LiquidationService) addLiquidation(trade *types.LendingTrade) error {
+	l := types.NewLiquidation(trade)
+	price, err := s.getLiquidationPrice(trade)
+	if err != nil {
+		return err
+	}
+	l.CollateralPrice = price
+	return s.liquidationDao.Add(l)
+}
+
+// getLiquidationPrice get price of the last trade of collateral in lending token before the loan was liquidated
+// the liquidation price is the closest known price if the pair has no trade
+func (s *LiquidationService) getLiquidationPrice(trade *types.LendingTrade) (*big.Int, error) {
+	spec := &types.TradeSpec{
+		BaseToken:  trade.CollateralToken.Hex(),
+		QuoteToken: trade.LendingToken.Hex(),
+		Status:     types.TradeStatusSuccess,
+		DateTo:     trade.UpdatedAt.Unix() + 1,
+	}
+	trades, err := s.tradeDao.GetTradesByCursor(spec, nil, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(trades) > 0 && trades[0].PricePoint != nil {
+		return trades[0].PricePoint, nil
+	}
+	return trade.LiquidationPrice, nil
+}
+
+// GetLiquidations get page of liquidations, latest first
+func (s *LiquidationService) GetLiquidations(spec *types.LiquidationSpec, offset int, size int) (*types.LiquidationRes, error) {
+	return s.liquidationDao.Query(spec, offset, size)
+}
+
+// getCollateralPrice get latest price of collateral in lending token
+// from the last trade of the pair, then from USD prices, then from the price of the loan
+func (s *LiquidationService) getCollateralPrice(loan *types.LendingTrade, pairPrices map[string]*big.Int) *big.Int {
+	if price, ok := pairPrices[utils.GetPairKey(loan.CollateralToken, loan.LendingToken)]; ok {
+		return price
+	}
+	if price, ok := s.priceService.GetPairPrice(loan.CollateralToken, loan.LendingToken); ok && price.Sign() > 0 {
+		return price
+	}
+	return loan.CollateralPrice
+}
+
+// GetLoansAtRisk get open loans closest to liquidation, empty relayer address for all relayers
+func (s *LiquidationService) GetLoansAtRisk(relayerAddress common.Address, top int) []*types.LoanRisk {
+	if top <= 0 {
+		top = 10
+	}
+	pairPrices := make(map[string]*big.Int)
+	for _, p := range s.tradeService.GetLastPairPrices() {
+		pairPrices[utils.GetPairKey(p.BaseToken, p.QuoteToken)] = p.Price
+	}
+
+	res := []*types.LoanRisk{}
+	for _, loan := range s.loanBookService.GetOpenLoans(relayerAddress) {
+		if loan.LiquidationPrice == nil || loan.LiquidationPrice.Sign() <= 0 {
+			continue
+		}
+		price := s.getCollateralPrice(loan, pairPrices)
+		if price == nil || price.Sign() <= 0 {
+			continue
+		}
+		distance, _ := new(big.Float).Quo(
+			new(big.Float).SetInt(new(big.Int).Sub(price, loan.LiquidationPrice)),
+			new(big.Float).SetInt(price),
+		).Float64()
+		res = append(res, &types.LoanRisk{
+			Hash:                   loan.Hash,
+			Borrower:               loan.Borrower,
+			BorrowingRelayer:       loan.BorrowingRelayer,
+			InvestingRelayer:       loan.InvestingRelayer,
+			LendingToken:           loan.LendingToken,
+			CollateralToken:        loan.CollateralToken,
+			Term:                   loan.Term,
+			Amount:                 loan.Amount,
+			CollateralLockedAmount: loan.CollateralLockedAmount,
+			LiquidationPrice:       loan.LiquidationPrice,
+			CollateralPrice:        price,
+			Distance:               distance,
+			LiquidationTime:        loan.LiquidationTime,
+		})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Distance != res[j].Distance {
+			return res[i].Distance < res[j].Distance
+		}
+		return res[i].Hash.Hex() < res[j].Hash.Hex()
+	})
+	if top >= len(res) {
+		top = len(res)
+	}
+	return res[0:top]
+}
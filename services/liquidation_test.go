@@ -0,0 +1,59 @@
+package services
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/tomochain/tomox-stats/types"
+)
+
+func newTestLiquidationService() *LiquidationService {
+	tradeService, _ := newTestTradeService()
+	return NewLiquidationService(nil, nil, nil, NewLoanBookService(nil, nil), tradeService, NewPriceService(nil, nil, nil))
+}
+
+func TestLiquidationQueue(t *testing.T) {
+	s := newTestLiquidationService()
+	loan := newTestLoan(1, time.Now().Unix(), 100)
+	s.NotifyLendingTrade(loan)
+	liquidated := *loan
+	liquidated.Status = types.TradeStatusLiquidated
+	// the liquidation is queued for the writer, the lending trade stream does not wait for the database
+	s.NotifyLendingTrade(&liquidated)
+	s.NotifyLendingTrade(&liquidated)
+	assert.Len(t, s.pending, 1)
+	assert.Len(t, s.queued, 1)
+}
+
+func TestLoansAtRisk(t *testing.T) {
+	s := newTestLiquidationService()
+	now := time.Now().Unix()
+	s.tradeService.NotifyTrade(&types.Trade{
+		Hash:       common.BigToHash(big.NewInt(1)),
+		BaseToken:  testBaseToken,
+		QuoteToken: testLendingToken,
+		PricePoint: big.NewInt(100),
+		Amount:     big.NewInt(1),
+		Status:     types.TradeStatusSuccess,
+		CreatedAt:  time.Unix(now, 0),
+	})
+	for i, liquidationPrice := range []int64{50, 90, 80} {
+		loan := newTestLoan(int64(i+1), now, 100)
+		loan.CollateralToken = testBaseToken
+		loan.LiquidationPrice = big.NewInt(liquidationPrice)
+		s.loanBookService.NotifyLendingTrade(loan)
+	}
+
+	risks := s.GetLoansAtRisk(common.Address{}, 2)
+	if !assert.Len(t, risks, 2) {
+		return
+	}
+	assert.Equal(t, int64(90), risks[0].LiquidationPrice.Int64())
+	assert.Equal(t, 0.1, risks[0].Distance)
+	assert.Equal(t, int64(80), risks[1].LiquidationPrice.Int64())
+	// a top which is not positive falls back to the default
+	assert.Len(t, s.GetLoansAtRisk(common.Address{}, -1), 3)
+}
@@ -0,0 +1,275 @@
+package services
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/tomochain/tomox-stats/daos"
+	"github.com/tomochain/tomox-stats/types"
+	"github.com/tomochain/tomox-stats/utils"
+	"github.com/tomochain/tomox-stats/utils/math"
+)
+
+const (
+	// loanExpiryGrace is the time after maturity an open loan is kept, waiting for its repay or liquidation event
+	loanExpiryGrace = 24 * 60 * 60
+)
+
+// LoanBookService keeps the book of open loans from the lending trade change stream
+// loans still open after their maturity and grace time are expired
+type LoanBookService struct {
+	lendingTradeDao *daos.LendingTradeDao
+	priceService    *PriceService
+	// tradeHash => open loan
+	loans map[common.Hash]*types.LendingTrade
+	// tradeHash => update time of loans which are not open anymore
+	// replayed events older than the last update of a loan are ignored
+	closedLoans map[common.Hash]int64
+	mutex       sync.RWMutex
+}
+
+// NewLoanBookService init new instance
+func NewLoanBookService(lendingTradeDao *daos.LendingTradeDao, priceService *PriceService) *LoanBookService {
+	return &LoanBookService{
+		lendingTradeDao: lendingTradeDao,
+		priceService:    priceService,
+		loans:           make(map[common.Hash]*types.LendingTrade),
+		closedLoans:     make(map[common.Hash]int64),
+	}
+}
+
+// Init load open loans from lending trade collection
+func (s *LoanBookService) Init() {
+	pageOffset := 0
+	size := 1000
+	for {
+		trades, err := s.lendingTradeDao.GetLendingTradeByStatus(types.TradeStatusOpen, pageOffset*size, size)
+		logger.Debug("FETCH OPEN LOANS", pageOffset*size)
+		if err != nil || len(trades) == 0 {
+			break
+		}
+		s.mutex.Lock()
+		for _, trade := range trades {
+			s.updateLoan(trade)
+		}
+		s.mutex.Unlock()
+		pageOffset = pageOffset + 1
+	}
+	s.prune()
+	ticker := time.NewTicker(60 * time.Second)
+	go func() {
+		for range ticker.C {
+			s.prune()
+		}
+	}()
+}
+
+// NotifyLendingTrade update loan with its last state
+func (s *LoanBookService) NotifyLendingTrade(trade *types.LendingTrade) error {
+	if trade == nil {
+		return nil
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.updateLoan(trade)
+	return nil
+}
+
+// updateLoan need to be lock
+func (s *LoanBookService) updateLoan(trade *types.LendingTrade) {
+	if last, ok := s.loans[trade.Hash]; ok && trade.UpdatedAt.Before(last.UpdatedAt) {
+		return
+	}
+	if t, ok := s.closedLoans[trade.Hash]; ok && trade.UpdatedAt.Unix() <= t {
+		return
+	}
+	if trade.Status == types.TradeStatusOpen {
+		s.loans[trade.Hash] = trade
+		return
+	}
+	delete(s.loans, trade.Hash)
+	s.closedLoans[trade.Hash] = trade.UpdatedAt.Unix()
+}
+
+// prune expire loans past their maturity and grace time, forget loans closed before their events can be replayed
+// an expired loan is closed at its last update, so that its replayed events are ignored
+func (s *LoanBookService) prune() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := time.Now().Unix()
+	for hash, loan := range s.loans {
+		if loan.LiquidationTime > 0 && int64(loan.LiquidationTime)+loanExpiryGrace < now {
+			delete(s.loans, hash)
+			s.closedLoans[hash] = loan.UpdatedAt.Unix()
+		}
+	}
+	horizon := now - tradeHashRetention
+	for hash, t := range s.closedLoans {
+		if t < horizon {
+			delete(s.closedLoans, hash)
+		}
+	}
+}
+
+// getLoans get open loans of relayer, on borrowing or investing side, need to be lock
+func (s *LoanBookService) getLoans(relayerAddress common.Address) []*types.LendingTrade {
+	var loans []*types.LendingTrade
+	for _, loan := range s.loans {
+		if (relayerAddress == common.Address{}) || loan.BorrowingRelayer == relayerAddress || loan.InvestingRelayer == relayerAddress {
+			loans = append(loans, loan)
+		}
+	}
+	return loans
+}
+
+// GetOpenLoans get open loans of relayer, empty relayer address for all relayers
+func (s *LoanBookService) GetOpenLoans(relayerAddress common.Address) []*types.LendingTrade {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.getLoans(relayerAddress)
+}
+
+// GetLoanBook get outstanding principal, locked collateral and terms of open loans
+// empty relayer address for all relayers
+func (s *LoanBookService) GetLoanBook(relayerAddress common.Address) *types.LoanBook {
+	s.mutex.RLock()
+	loans := s.getLoans(relayerAddress)
+	s.mutex.RUnlock()
+
+	principals := make(map[common.Address]*types.LoanTokenAmount)
+	collaterals := make(map[common.Address]*types.LoanTokenAmount)
+	terms := make(map[string]*types.LoanTerm)
+	for _, loan := range loans {
+		addLoanTokenAmount(principals, loan.LendingToken, loan.Amount)
+		addLoanTokenAmount(collaterals, loan.CollateralToken, loan.CollateralLockedAmount)
+		key := fmt.Sprintf("%d::%s", loan.Term, loan.LendingToken.Hex())
+		term, ok := terms[key]
+		if !ok {
+			term = &types.LoanTerm{
+				LendingToken: loan.LendingToken,
+				Term:         loan.Term,
+				Principal:    big.NewInt(0),
+			}
+			terms[key] = term
+		}
+		if loan.Amount != nil {
+			term.Principal = new(big.Int).Add(term.Principal, loan.Amount)
+		}
+		term.Count++
+	}
+
+	book := &types.LoanBook{
+		RelayerAddress: relayerAddress,
+		Count:          len(loans),
+		Principal:      []*types.LoanTokenAmount{},
+		Collateral:     []*types.LoanTokenAmount{},
+		Terms:          []*types.LoanTerm{},
+	}
+	principalUSD, collateralUSD := NewUSD(), NewUSD()
+	for _, p := range principals {
+		if usd, ok := s.priceService.ToUSD(p.Token, p.Amount); ok {
+			p.AmountUSD = FormatUSD(usd)
+			principalUSD.Add(principalUSD, usd)
+		}
+		book.Principal = append(book.Principal, p)
+	}
+	for _, c := range collaterals {
+		if usd, ok := s.priceService.ToUSD(c.Token, c.Amount); ok {
+			c.AmountUSD = FormatUSD(usd)
+			collateralUSD.Add(collateralUSD, usd)
+		}
+		book.Collateral = append(book.Collateral, c)
+	}
+	book.PrincipalUSD = FormatUSD(principalUSD)
+	book.TotalValueLockedUSD = FormatUSD(collateralUSD)
+	for _, t := range terms {
+		if total := principals[t.LendingToken].Amount; total.Sign() > 0 {
+			t.PrincipalShare = math.DivideToFloat(t.Principal, total)
+		}
+		book.Terms = append(book.Terms, t)
+	}
+	sortLoanTokenAmounts(book.Principal)
+	sortLoanTokenAmounts(book.Collateral)
+	sort.Slice(book.Terms, func(i, j int) bool {
+		if book.Terms[i].LendingToken != book.Terms[j].LendingToken {
+			return strings.ToLower(book.Terms[i].LendingToken.Hex()) < strings.ToLower(book.Terms[j].LendingToken.Hex())
+		}
+		return book.Terms[i].Term < book.Terms[j].Term
+	})
+	return book
+}
+
+// GetMaturities get principal of open loans due between from and to, by day and lending token
+// empty relayer address for all relayers, empty lending token for all lending tokens
+func (s *LoanBookService) GetMaturities(relayerAddress, lendingToken common.Address, from, to int64) []*types.LoanMaturity {
+	s.mutex.RLock()
+	loans := s.getLoans(relayerAddress)
+	s.mutex.RUnlock()
+
+	maturities := make(map[int64]map[common.Address]*types.LoanMaturity)
+	for _, loan := range loans {
+		if (lendingToken != common.Address{}) && loan.LendingToken != lendingToken {
+			continue
+		}
+		t := int64(loan.LiquidationTime)
+		if t < from || t > to {
+			continue
+		}
+		day, _ := utils.GetModTime(t, 1, "day")
+		if _, ok := maturities[day]; !ok {
+			maturities[day] = make(map[common.Address]*types.LoanMaturity)
+		}
+		m, ok := maturities[day][loan.LendingToken]
+		if !ok {
+			m = &types.LoanMaturity{
+				Date:         day,
+				LendingToken: loan.LendingToken,
+				Principal:    big.NewInt(0),
+			}
+			maturities[day][loan.LendingToken] = m
+		}
+		if loan.Amount != nil {
+			m.Principal = new(big.Int).Add(m.Principal, loan.Amount)
+		}
+		m.Count++
+	}
+	res := []*types.LoanMaturity{}
+	for _, byToken := range maturities {
+		for _, m := range byToken {
+			res = append(res, m)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Date != res[j].Date {
+			return res[i].Date < res[j].Date
+		}
+		return strings.ToLower(res[i].LendingToken.Hex()) < strings.ToLower(res[j].LendingToken.Hex())
+	})
+	return res
+}
+
+func addLoanTokenAmount(amounts map[common.Address]*types.LoanTokenAmount, token common.Address, amount *big.Int) {
+	a, ok := amounts[token]
+	if !ok {
+		a = &types.LoanTokenAmount{
+			Token:  token,
+			Amount: big.NewInt(0),
+		}
+		amounts[token] = a
+	}
+	if amount != nil {
+		a.Amount = new(big.Int).Add(a.Amount, amount)
+	}
+	a.Count++
+}
+
+func sortLoanTokenAmounts(amounts []*types.LoanTokenAmount) {
+	sort.Slice(amounts, func(i, j int) bool {
+		return strings.ToLower(amounts[i].Token.Hex()) < strings.ToLower(amounts[j].Token.Hex())
+	})
+}
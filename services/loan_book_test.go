@@ -0,0 +1,52 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/tomochain/tomox-stats/types"
+)
+
+func newTestLoan(hash int64, createdAt int64, amount int64) *types.LendingTrade {
+	loan := newTestLendingTrade(hash, createdAt, amount)
+	loan.Status = types.TradeStatusOpen
+	loan.LiquidationTime = uint64(createdAt) + loan.Term
+	loan.UpdatedAt = loan.CreatedAt
+	return loan
+}
+
+func TestLoanBookExpiry(t *testing.T) {
+	s := NewLoanBookService(nil, nil)
+	now := time.Now().Unix()
+	expired := newTestLoan(1, now-int64(86400)-loanExpiryGrace-60, 100)
+	s.NotifyLendingTrade(expired)
+	s.NotifyLendingTrade(newTestLoan(2, now-int64(86400)+60, 50))
+	assert.Len(t, s.GetOpenLoans(common.Address{}), 2)
+
+	// a loan whose repay or liquidation event is missed is expired after maturity and grace time
+	s.prune()
+	loans := s.GetOpenLoans(common.Address{})
+	if !assert.Len(t, loans, 1) {
+		return
+	}
+	assert.Equal(t, int64(50), loans[0].Amount.Int64())
+}
+
+func TestLoanBookPrincipalShare(t *testing.T) {
+	s := NewLoanBookService(nil, NewPriceService(nil, nil, nil))
+	now := time.Now().Unix()
+	s.NotifyLendingTrade(newTestLoan(1, now, 300))
+	weekly := newTestLoan(2, now, 100)
+	weekly.Term = 7 * 86400
+	s.NotifyLendingTrade(weekly)
+
+	book := s.GetLoanBook(common.Address{})
+	if !assert.Len(t, book.Terms, 2) {
+		return
+	}
+	assert.Equal(t, 0.75, book.Terms[0].PrincipalShare)
+	assert.Equal(t, 0.25, book.Terms[1].PrincipalShare)
+	assert.Equal(t, "", book.Principal[0].AmountUSD)
+}
@@ -17,3 +17,4 @@ var ErrTokenExists = errors.New("Token already exists")
 var ErrAccountNotFound = errors.New("Account not found")
 var ErrAccountExists = errors.New("Account already Exists")
 var ErrNoContractCode = errors.New("Contract not found at given address")
+var ErrRelayerDomainUsed = errors.New("Domain is used by another relayer")
@@ -0,0 +1,37 @@
+package services
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/globalsign/mgo/bson"
+	"github.com/tomochain/tomox-stats/types"
+)
+
+var (
+	testBaseToken  = common.HexToAddress("0x0000000000000000000000000000000000000b01")
+	testQuoteToken = common.HexToAddress("0x0000000000000000000000000000000000000c01")
+	testRelayer    = common.HexToAddress("0x0000000000000000000000000000000000000e01")
+	testMaker      = common.HexToAddress("0x0000000000000000000000000000000000000a01")
+	testTaker      = common.HexToAddress("0x0000000000000000000000000000000000000a02")
+)
+
+// newTestTrade create a successful trade of testMaker and testTaker on the test pair and relayer
+func newTestTrade(hash int64, createdAt int64, price, amount int64, takerSide string) *types.Trade {
+	return &types.Trade{
+		ID:             bson.NewObjectId(),
+		Hash:           common.BigToHash(big.NewInt(hash)),
+		Maker:          testMaker,
+		Taker:          testTaker,
+		BaseToken:      testBaseToken,
+		QuoteToken:     testQuoteToken,
+		PricePoint:     big.NewInt(price),
+		Amount:         big.NewInt(amount),
+		Status:         types.TradeStatusSuccess,
+		CreatedAt:      time.Unix(createdAt, 0),
+		TakerOrderSide: takerSide,
+		MakerExchange:  testRelayer,
+		TakerExchange:  testRelayer,
+	}
+}
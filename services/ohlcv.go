@@ -0,0 +1,330 @@
+package services
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/tomochain/tomox-stats/app"
+	"github.com/tomochain/tomox-stats/types"
+	"github.com/tomochain/tomox-stats/utils"
+)
+
+const (
+	// ohlcvMinTicks is the least number of candles kept in memory for each series
+	// series of short ticks keep more candles, so that they cover the backfill period
+	ohlcvMinTicks = 1000
+	// ohlcvIndexSeconds is the time frame of the trade index, candles of longer ticks cover whole time frames
+	ohlcvIndexSeconds = 60 * 60
+)
+
+// OHLCVService builds candles for every configured tick duration
+// from the trade change stream
+type OHLCVService struct {
+	tradeService *TradeService
+	// channel => time => Tick
+	ticks     map[string]map[int64]*types.Tick
+	durations map[string][]int64
+	// pairAddress => index time frame => tradeHash => trade in candles, for trades which can still be reverted
+	trades map[string]map[int64]map[common.Hash]*types.Trade
+	mutex  sync.RWMutex
+}
+
+// NewOHLCVService init new instance
+func NewOHLCVService(tradeService *TradeService) *OHLCVService {
+	return &OHLCVService{
+		tradeService: tradeService,
+		ticks:        make(map[string]map[int64]*types.Tick),
+		durations:    app.Config.TickDuration,
+		trades:       make(map[string]map[int64]map[common.Hash]*types.Trade),
+	}
+}
+
+// Init backfill candles with the trades counted by TradeService
+// newer trades are notified once the service is added to TradeService notifiers
+func (s *OHLCVService) Init() {
+	s.tradeService.BackfillNotifier(s, time.Now().Unix()-intervalCrawl)
+	s.prune()
+	ticker := time.NewTicker(60 * time.Second)
+	go func() {
+		for range ticker.C {
+			s.prune()
+		}
+	}()
+}
+
+// NotifyTrade update candles of the trade pair
+// TradeService only notifies trades with counted status
+func (s *OHLCVService) NotifyTrade(trade *types.Trade) error {
+	if trade == nil {
+		return nil
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.addTrade(trade)
+	return nil
+}
+
+// NotifyRevertTrade remove trade from candles of the trade pair
+func (s *OHLCVService) NotifyRevertTrade(trade *types.Trade) error {
+	if trade == nil {
+		return nil
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	key := s.getPairString(trade.BaseToken, trade.QuoteToken)
+	indexTime := s.getIndexTime(trade.CreatedAt.Unix())
+	if _, ok := s.trades[key][indexTime][trade.Hash]; !ok {
+		return nil
+	}
+	delete(s.trades[key][indexTime], trade.Hash)
+	if len(s.trades[key][indexTime]) == 0 {
+		delete(s.trades[key], indexTime)
+	}
+	s.revertTick(trade)
+	return nil
+}
+
+// addTrade add trade to candles once by trade hash, need to be lock
+// a trade older than tradeHashRetention is not notified again, its hash is not kept
+func (s *OHLCVService) addTrade(trade *types.Trade) {
+	key := s.getPairString(trade.BaseToken, trade.QuoteToken)
+	indexTime := s.getIndexTime(trade.CreatedAt.Unix())
+	if _, ok := s.trades[key][indexTime][trade.Hash]; ok {
+		return
+	}
+	if trade.CreatedAt.Unix() >= time.Now().Unix()-tradeHashRetention {
+		if _, ok := s.trades[key]; !ok {
+			s.trades[key] = make(map[int64]map[common.Hash]*types.Trade)
+		}
+		if _, ok := s.trades[key][indexTime]; !ok {
+			s.trades[key][indexTime] = make(map[common.Hash]*types.Trade)
+		}
+		s.trades[key][indexTime][trade.Hash] = trade
+	}
+	s.updateTick(trade)
+}
+
+func (s *OHLCVService) getPairString(baseToken, quoteToken common.Address) string {
+	return fmt.Sprintf("%s::%s", baseToken.Hex(), quoteToken.Hex())
+}
+
+// getIndexTime get the time frame of the trade index
+func (s *OHLCVService) getIndexTime(tradeTime int64) int64 {
+	return tradeTime - tradeTime%ohlcvIndexSeconds
+}
+
+// getPairTrades get trades in candles of pair created from fromdate until todate, need to be lock
+func (s *OHLCVService) getPairTrades(baseToken, quoteToken common.Address, fromdate int64, todate int64) []*types.Trade {
+	var res []*types.Trade
+	trades := s.trades[s.getPairString(baseToken, quoteToken)]
+	for t := s.getIndexTime(fromdate); t < todate; t += ohlcvIndexSeconds {
+		for _, trade := range trades[t] {
+			if tradeTime := trade.CreatedAt.Unix(); tradeTime >= fromdate && tradeTime < todate {
+				res = append(res, trade)
+			}
+		}
+	}
+	return res
+}
+
+func (s *OHLCVService) getChannelID(relayerAddress, baseToken, quoteToken common.Address, unit string, duration int64) string {
+	return fmt.Sprintf("%s::%s", strings.ToLower(relayerAddress.Hex()), utils.GetOHLCVChannelID(baseToken, quoteToken, unit, duration))
+}
+
+// updateTick add trade to candles of both relayers and all relayers, need to be lock
+func (s *OHLCVService) updateTick(trade *types.Trade) {
+	if trade.PricePoint == nil || trade.Amount == nil {
+		return
+	}
+	relayers := make(map[common.Address]bool)
+	relayers[common.Address{}] = true
+	relayers[trade.MakerExchange] = true
+	relayers[trade.TakerExchange] = true
+
+	tradeTime := trade.CreatedAt.Unix()
+	for unit, durations := range s.durations {
+		for _, duration := range durations {
+			modTime, _ := utils.GetModTime(tradeTime, duration, unit)
+			for relayer := range relayers {
+				id := s.getChannelID(relayer, trade.BaseToken, trade.QuoteToken, unit, duration)
+				if _, ok := s.ticks[id]; !ok {
+					s.ticks[id] = make(map[int64]*types.Tick)
+				}
+				last, ok := s.ticks[id][modTime]
+				if !ok {
+					s.ticks[id][modTime] = &types.Tick{
+						RelayerAddress: relayer,
+						BaseToken:      trade.BaseToken,
+						QuoteToken:     trade.QuoteToken,
+						Open:           utils.CloneBigInt(trade.PricePoint),
+						High:           utils.CloneBigInt(trade.PricePoint),
+						Low:            utils.CloneBigInt(trade.PricePoint),
+						Close:          utils.CloneBigInt(trade.PricePoint),
+						Volume:         utils.CloneBigInt(trade.Amount),
+						Count:          big.NewInt(1),
+						Timestamp:      modTime,
+						OpenTime:       tradeTime,
+						CloseTime:      tradeTime,
+						Duration:       duration,
+						Unit:           unit,
+					}
+					continue
+				}
+				if trade.PricePoint.Cmp(last.High) > 0 {
+					last.High = utils.CloneBigInt(trade.PricePoint)
+				}
+				if trade.PricePoint.Cmp(last.Low) < 0 {
+					last.Low = utils.CloneBigInt(trade.PricePoint)
+				}
+				if tradeTime < last.OpenTime {
+					last.Open = utils.CloneBigInt(trade.PricePoint)
+					last.OpenTime = tradeTime
+				}
+				if tradeTime >= last.CloseTime {
+					last.Close = utils.CloneBigInt(trade.PricePoint)
+					last.CloseTime = tradeTime
+				}
+				last.Volume = new(big.Int).Add(last.Volume, trade.Amount)
+				last.Count = new(big.Int).Add(last.Count, big.NewInt(1))
+			}
+		}
+	}
+}
+
+// revertTick remove trade from candles of both relayers and all relayers, need to be lock
+// prices are rebuilt from the trades in the candle, they are kept for candles starting
+// before tradeHashRetention whose trades are not all known
+func (s *OHLCVService) revertTick(trade *types.Trade) {
+	if trade.PricePoint == nil || trade.Amount == nil {
+		return
+	}
+	horizon := time.Now().Unix() - tradeHashRetention
+	relayers := []common.Address{common.Address{}, trade.MakerExchange}
+	if trade.TakerExchange != trade.MakerExchange {
+		relayers = append(relayers, trade.TakerExchange)
+	}
+	for unit, durations := range s.durations {
+		for _, duration := range durations {
+			modTime, seconds := utils.GetModTime(trade.CreatedAt.Unix(), duration, unit)
+			for _, relayer := range relayers {
+				id := s.getChannelID(relayer, trade.BaseToken, trade.QuoteToken, unit, duration)
+				tick, ok := s.ticks[id][modTime]
+				if !ok {
+					continue
+				}
+				tick.Volume = new(big.Int).Sub(tick.Volume, trade.Amount)
+				tick.Count = new(big.Int).Sub(tick.Count, big.NewInt(1))
+				if tick.Count.Sign() <= 0 {
+					delete(s.ticks[id], modTime)
+					continue
+				}
+				if modTime < horizon {
+					continue
+				}
+				tick.Open, tick.High, tick.Low, tick.Close = nil, nil, nil, nil
+				for _, t := range s.getPairTrades(trade.BaseToken, trade.QuoteToken, modTime, modTime+seconds) {
+					tradeTime := t.CreatedAt.Unix()
+					if (relayer != common.Address{}) && t.MakerExchange != relayer && t.TakerExchange != relayer {
+						continue
+					}
+					if tick.High == nil || t.PricePoint.Cmp(tick.High) > 0 {
+						tick.High = utils.CloneBigInt(t.PricePoint)
+					}
+					if tick.Low == nil || t.PricePoint.Cmp(tick.Low) < 0 {
+						tick.Low = utils.CloneBigInt(t.PricePoint)
+					}
+					if tick.Open == nil || tradeTime < tick.OpenTime {
+						tick.Open = utils.CloneBigInt(t.PricePoint)
+						tick.OpenTime = tradeTime
+					}
+					if tick.Close == nil || tradeTime >= tick.CloseTime {
+						tick.Close = utils.CloneBigInt(t.PricePoint)
+						tick.CloseTime = tradeTime
+					}
+				}
+			}
+		}
+	}
+}
+
+// getMaxTicks get the number of candles kept for a series of tick duration
+// at least ohlcvMinTicks, or enough candles to cover intervalCrawl
+func getMaxTicks(duration int64, unit string) int {
+	maxTicks := ohlcvMinTicks
+	if seconds := utils.UnitToSecond(duration, unit); seconds > 0 && intervalCrawl/seconds > int64(maxTicks) {
+		maxTicks = int(intervalCrawl / seconds)
+	}
+	return maxTicks
+}
+
+// prune keep the lastest candles of each series, up to the max ticks of its duration
+// and forget trades which can not be reverted anymore
+func (s *OHLCVService) prune() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	horizon := time.Now().Unix() - tradeHashRetention
+	for key, trades := range s.trades {
+		for t, tradebyhash := range trades {
+			for hash, trade := range tradebyhash {
+				if trade.CreatedAt.Unix() < horizon {
+					delete(tradebyhash, hash)
+				}
+			}
+			if len(tradebyhash) == 0 {
+				delete(trades, t)
+			}
+		}
+		if len(trades) == 0 {
+			delete(s.trades, key)
+		}
+	}
+	for _, ticks := range s.ticks {
+		var times []int64
+		maxTicks := 0
+		for t, tick := range ticks {
+			times = append(times, t)
+			maxTicks = getMaxTicks(tick.Duration, tick.Unit)
+		}
+		if len(times) <= maxTicks {
+			continue
+		}
+		sort.Slice(times, func(i, j int) bool {
+			return times[i] < times[j]
+		})
+		for _, t := range times[:len(times)-maxTicks] {
+			delete(ticks, t)
+		}
+	}
+}
+
+// GetOHLCV get candles of pair by relayer, empty relayer address for all relayers
+func (s *OHLCVService) GetOHLCV(relayerAddress, baseToken, quoteToken common.Address, duration int64, unit string, from, to int64) []*types.Tick {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	res := []*types.Tick{}
+	id := s.getChannelID(relayerAddress, baseToken, quoteToken, unit, duration)
+	for t, tick := range s.ticks[id] {
+		if (from == 0 || t >= from) && (to == 0 || t <= to) {
+			res = append(res, tick)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Timestamp < res[j].Timestamp
+	})
+	return res
+}
+
+// IsValidDuration check duration and unit are in tick_duration config
+func (s *OHLCVService) IsValidDuration(duration int64, unit string) bool {
+	for _, d := range s.durations[unit] {
+		if d == duration {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestOHLCVService() *OHLCVService {
+	s := NewOHLCVService(nil)
+	s.durations = map[string][]int64{
+		"min":  {1},
+		"hour": {1},
+	}
+	return s
+}
+
+func TestOHLCVCandle(t *testing.T) {
+	s := newTestOHLCVService()
+	start := int64(1600000020)
+	s.NotifyTrade(newTestTrade(1, start+10, 12, 3, sideBuy))
+	s.NotifyTrade(newTestTrade(2, start+30, 9, 2, sideSell))
+	// trades of the stream are not always in time order
+	s.NotifyTrade(newTestTrade(3, start, 10, 1, sideBuy))
+
+	ticks := s.GetOHLCV(testRelayer, testBaseToken, testQuoteToken, 1, "min", 0, 0)
+	assert.Len(t, ticks, 1)
+	tick := ticks[0]
+	assert.Equal(t, int64(1600000020), tick.Timestamp)
+	assert.Equal(t, int64(10), tick.Open.Int64())
+	assert.Equal(t, int64(12), tick.High.Int64())
+	assert.Equal(t, int64(9), tick.Low.Int64())
+	assert.Equal(t, int64(9), tick.Close.Int64())
+	assert.Equal(t, int64(6), tick.Volume.Int64())
+	assert.Equal(t, int64(3), tick.Count.Int64())
+
+	all := s.GetOHLCV(common.Address{}, testBaseToken, testQuoteToken, 1, "hour", 0, 0)
+	assert.Len(t, all, 1)
+	assert.Equal(t, int64(6), all[0].Volume.Int64())
+}
+
+func TestGetMaxTicks(t *testing.T) {
+	assert.Equal(t, 60*24*60, getMaxTicks(1, "min"))
+	assert.Equal(t, 60*24, getMaxTicks(1, "hour"))
+	assert.Equal(t, ohlcvMinTicks, getMaxTicks(1, "day"))
+}
+
+func TestOHLCVPrune(t *testing.T) {
+	s := newTestOHLCVService()
+	start := int64(444444 * 60 * 60)
+	for i := 0; i < 1500; i++ {
+		s.NotifyTrade(newTestTrade(int64(i), start+int64(i)*60*60, 10, 1, sideBuy))
+	}
+	s.prune()
+
+	hours := s.GetOHLCV(testRelayer, testBaseToken, testQuoteToken, 1, "hour", 0, 0)
+	assert.Len(t, hours, getMaxTicks(1, "hour"))
+	// the oldest candles are pruned
+	assert.Equal(t, start+int64(1500-getMaxTicks(1, "hour"))*60*60, hours[0].Timestamp)
+
+	minutes := s.GetOHLCV(testRelayer, testBaseToken, testQuoteToken, 1, "min", 0, 0)
+	assert.Len(t, minutes, 1500)
+}
+
+func TestOHLCVRevert(t *testing.T) {
+	s := newTestOHLCVService()
+	start := time.Now().Unix() / 60 * 60
+	first := newTestTrade(1, start, 10, 1, sideBuy)
+	high := newTestTrade(2, start+10, 15, 2, sideBuy)
+	s.NotifyTrade(first)
+	s.NotifyTrade(high)
+	s.NotifyTrade(high)
+	s.NotifyTrade(newTestTrade(3, start+20, 11, 3, sideSell))
+
+	s.NotifyRevertTrade(high)
+	ticks := s.GetOHLCV(testRelayer, testBaseToken, testQuoteToken, 1, "min", 0, 0)
+	assert.Len(t, ticks, 1)
+	assert.Equal(t, int64(10), ticks[0].Open.Int64())
+	assert.Equal(t, int64(11), ticks[0].High.Int64())
+	assert.Equal(t, int64(11), ticks[0].Close.Int64())
+	assert.Equal(t, int64(4), ticks[0].Volume.Int64())
+	assert.Equal(t, int64(2), ticks[0].Count.Int64())
+
+	// a trade is reverted once
+	s.NotifyRevertTrade(high)
+	s.NotifyRevertTrade(first)
+	ticks = s.GetOHLCV(testRelayer, testBaseToken, testQuoteToken, 1, "min", 0, 0)
+	assert.Equal(t, int64(3), ticks[0].Volume.Int64())
+	assert.Equal(t, int64(11), ticks[0].Open.Int64())
+}
+
+func TestOHLCVRevertLongTick(t *testing.T) {
+	s := newTestOHLCVService()
+	s.durations["day"] = []int64{1}
+	day := time.Now().Unix()/(24*60*60)*24*60*60 - 24*60*60
+	high := newTestTrade(1, day+60*60, 15, 1, sideBuy)
+	s.NotifyTrade(high)
+	s.NotifyTrade(newTestTrade(2, day+5*60*60, 12, 1, sideBuy))
+	s.NotifyTrade(newTestTrade(3, day+3*60*60, 11, 1, sideSell))
+
+	// prices of the day are rebuilt from the trades of all its hours
+	s.NotifyRevertTrade(high)
+	ticks := s.GetOHLCV(testRelayer, testBaseToken, testQuoteToken, 1, "day", 0, 0)
+	if assert.Len(t, ticks, 1) {
+		assert.Equal(t, int64(11), ticks[0].Open.Int64())
+		assert.Equal(t, int64(12), ticks[0].High.Int64())
+		assert.Equal(t, int64(12), ticks[0].Close.Int64())
+		assert.Equal(t, int64(2), ticks[0].Count.Int64())
+	}
+	assert.Len(t, s.trades[s.getPairString(testBaseToken, testQuoteToken)], 2)
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/tomochain/tomox-stats/daos"
+	"github.com/tomochain/tomox-stats/types"
+	"github.com/tomochain/tomox-stats/utils"
+)
+
+const (
+	// pairTickerWindow is the period of pair tickers
+	pairTickerWindow = 24 * 60 * 60
+)
+
+// PairService builds 24h tickers of the pairs of pair collection from the trades of TradeService
+type PairService struct {
+	pairDao      *daos.PairDao
+	tradeService *TradeService
+	// relayerAddress => pairs listed by relayer, empty relayer address for all pairs
+	pairCache map[common.Address]*pairListCache
+	mutex     sync.Mutex
+}
+
+type pairListCache struct {
+	pairs    []types.Pair
+	timelife int64
+}
+
+// NewPairService init new instance
+func NewPairService(pairDao *daos.PairDao, tradeService *TradeService) *PairService {
+	return &PairService{
+		pairDao:      pairDao,
+		tradeService: tradeService,
+		pairCache:    make(map[common.Address]*pairListCache),
+	}
+}
+
+// getPairs get pairs listed by relayer, all pairs for empty relayer address
+// pair lists are cached for cacheTimeLifeMax seconds
+func (s *PairService) getPairs(relayerAddress common.Address) ([]types.Pair, error) {
+	now := time.Now().Unix()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if c, ok := s.pairCache[relayerAddress]; ok && now-c.timelife < cacheTimeLifeMax {
+		return c.pairs, nil
+	}
+	var pairs []types.Pair
+	var err error
+	if (relayerAddress == common.Address{}) {
+		pairs, err = s.pairDao.GetAll()
+	} else {
+		pairs, err = s.pairDao.GetAllByCoinbase(relayerAddress)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.pairCache[relayerAddress] = &pairListCache{
+		pairs:    pairs,
+		timelife: now,
+	}
+	return pairs, nil
+}
+
+// GetPairTickers get 24h tickers of pairs, empty relayer address for all pairs and all relayers
+// pairs of a relayer are the pairs it lists in pair collection
+// QuoteVolumeUSD is set if price service is not nil and quote token has a USD price, tickers are then sorted by it
+// LastPrice is nil for pairs without trade kept by TradeService, which keeps them for tradeHashRetention
+func (s *PairService) GetPairTickers(relayerAddress common.Address, priceService *PriceService) ([]*types.PairTicker, error) {
+	pairs, err := s.getPairs(relayerAddress)
+	if err != nil {
+		return nil, err
+	}
+	trades, lastTrades := s.tradeService.getPairTrades(relayerAddress, time.Now().Unix()-pairTickerWindow)
+	res := []*types.PairTicker{}
+	volumesUSD := make(map[*types.PairTicker]*big.Float)
+	for i := range pairs {
+		key := s.tradeService.getPairString(pairs[i].BaseTokenAddress, pairs[i].QuoteTokenAddress)
+		ticker := getPairTicker(&pairs[i], relayerAddress, trades[key], lastTrades[key])
+		if priceService != nil {
+			if usd, ok := priceService.ToUSD(ticker.QuoteTokenAddress, ticker.QuoteVolume); ok {
+				volumesUSD[ticker] = usd
+				ticker.QuoteVolumeUSD = FormatUSD(usd)
+			}
+		}
+		res = append(res, ticker)
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		if cmp := compareUSD(volumesUSD[res[i]], volumesUSD[res[j]]); cmp != 0 {
+			return cmp > 0
+		}
+		return res[i].QuoteVolume.Cmp(res[j].QuoteVolume) > 0
+	})
+	return res, nil
+}
+
+// getPairTicker build ticker of pair from its trades of the ticker window and its last trade
+func getPairTicker(pair *types.Pair, relayerAddress common.Address, trades []*types.Trade, last *types.Trade) *types.PairTicker {
+	ticker := &types.PairTicker{
+		RelayerAddress:     relayerAddress,
+		BaseTokenSymbol:    pair.BaseTokenSymbol,
+		BaseTokenAddress:   pair.BaseTokenAddress,
+		BaseTokenDecimals:  pair.BaseTokenDecimals,
+		QuoteTokenSymbol:   pair.QuoteTokenSymbol,
+		QuoteTokenAddress:  pair.QuoteTokenAddress,
+		QuoteTokenDecimals: pair.QuoteTokenDecimals,
+		Volume:             big.NewInt(0),
+		QuoteVolume:        big.NewInt(0),
+	}
+	if last != nil {
+		ticker.LastPrice = utils.CloneBigInt(last.PricePoint)
+	}
+	baseMultiplier := pair.BaseTokenMultiplier()
+	if pair.BaseTokenDecimals == 0 {
+		baseMultiplier = pow10Big(defaultTokenDecimals)
+	}
+
+	var openTime int64
+	users := make(map[common.Address]bool)
+	for _, trade := range trades {
+		tradeTime := trade.CreatedAt.Unix()
+		if ticker.Open == nil || tradeTime < openTime {
+			ticker.Open = utils.CloneBigInt(trade.PricePoint)
+			openTime = tradeTime
+		}
+		if ticker.High == nil || trade.PricePoint.Cmp(ticker.High) > 0 {
+			ticker.High = utils.CloneBigInt(trade.PricePoint)
+		}
+		if ticker.Low == nil || trade.PricePoint.Cmp(ticker.Low) < 0 {
+			ticker.Low = utils.CloneBigInt(trade.PricePoint)
+		}
+		ticker.Volume = new(big.Int).Add(ticker.Volume, trade.Amount)
+		quoteVolume := new(big.Int).Mul(trade.Amount, trade.PricePoint)
+		ticker.QuoteVolume = new(big.Int).Add(ticker.QuoteVolume, quoteVolume.Div(quoteVolume, baseMultiplier))
+		ticker.Count++
+		users[trade.Maker] = true
+		users[trade.Taker] = true
+	}
+	ticker.ActiveTraders = len(users)
+	if ticker.Open != nil && ticker.Open.Sign() > 0 && ticker.LastPrice != nil {
+		change := new(big.Int).Sub(ticker.LastPrice, ticker.Open)
+		ticker.Change, _ = new(big.Rat).SetFrac(new(big.Int).Mul(change, big.NewInt(100)), ticker.Open).Float64()
+	}
+	return ticker
+}
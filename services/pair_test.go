@@ -0,0 +1,53 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/tomochain/tomox-stats/types"
+)
+
+func TestPairTickers(t *testing.T) {
+	tradeService, _ := newTestTradeService()
+	s := NewPairService(nil, tradeService)
+	s.pairCache[common.Address{}] = &pairListCache{
+		pairs: []types.Pair{{
+			BaseTokenAddress:  testBaseToken,
+			QuoteTokenAddress: testQuoteToken,
+			BaseTokenDecimals: 1,
+		}},
+		timelife: time.Now().Unix(),
+	}
+	now := time.Now().Unix()
+	// the trade older than the ticker window only sets the last price
+	tradeService.NotifyTrade(newTestTrade(1, now-pairTickerWindow-60, 50, 10, sideBuy))
+	tradeService.NotifyTrade(newTestTrade(2, now-20, 100, 10, sideBuy))
+	tradeService.NotifyTrade(newTestTrade(3, now-10, 120, 20, sideSell))
+
+	tickers, err := s.GetPairTickers(common.Address{}, nil)
+	assert.NoError(t, err)
+	if !assert.Len(t, tickers, 1) {
+		return
+	}
+	ticker := tickers[0]
+	assert.Equal(t, int64(100), ticker.Open.Int64())
+	assert.Equal(t, int64(120), ticker.High.Int64())
+	assert.Equal(t, int64(100), ticker.Low.Int64())
+	assert.Equal(t, int64(120), ticker.LastPrice.Int64())
+	assert.Equal(t, float64(20), ticker.Change)
+	assert.Equal(t, int64(30), ticker.Volume.Int64())
+	assert.Equal(t, int64((10*100+20*120)/10), ticker.QuoteVolume.Int64())
+	assert.Equal(t, 2, ticker.Count)
+	assert.Equal(t, 2, ticker.ActiveTraders)
+
+	// a trade reverted by TradeService is no longer in the ticker
+	failed := newTestTrade(3, now-10, 120, 20, sideSell)
+	failed.Status = types.TradeStatusError
+	tradeService.NotifyTrade(failed)
+	tickers, err = s.GetPairTickers(common.Address{}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, tickers[0].Count)
+	assert.Equal(t, int64(100), tickers[0].LastPrice.Int64())
+}
@@ -0,0 +1,746 @@
+package services
+
+import (
+	"encoding/json"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/globalsign/mgo/bson"
+	"github.com/tomochain/tomox-stats/daos"
+	"github.com/tomochain/tomox-stats/types"
+	"github.com/tomochain/tomox-stats/utils"
+)
+
+const (
+	// PnLMethodFIFO match sells with the oldest buys, and buys with the oldest sells of a short position
+	PnLMethodFIFO = "fifo"
+	// PnLMethodAverage match trades with the average entry price of the position
+	PnLMethodAverage = "average"
+
+	defaultTokenDecimals = 18
+
+	// cache store keys of settled ledgers
+	pnlStoreMeta     = "meta"
+	pnlStorePosition = "pp/"
+)
+
+// pnlLot is an open part of a position, amount is always positive
+type pnlLot struct {
+	amount *big.Int
+	price  *big.Int
+}
+
+// pnlTrade is a buy or a sell of a user on a pair
+type pnlTrade struct {
+	hash   common.Hash
+	id     bson.ObjectId
+	time   int64
+	amount *big.Int
+	price  *big.Int
+	buy    bool
+}
+
+// pnlLedger is the state of a position after a sequence of trades
+// realized PnL are kept as amount * price, they are divided by base token decimals when queried
+type pnlLedger struct {
+	// signed size, negative for a short position
+	size *big.Int
+	// open lots of fifo method, all of them are on the side of size
+	lots         []*pnlLot
+	realizedFIFO *big.Int
+	// entry price of average method
+	averagePrice    *big.Int
+	realizedAverage *big.Int
+	volume          *big.Int
+	count           int
+	lastTrade       int64
+}
+
+// pnlPosition is the position of a user on a pair
+// trades which can still be reverted are kept, so that the ledger is replayed without a reverted trade
+type pnlPosition struct {
+	baseToken  common.Address
+	quoteToken common.Address
+	// ledger of trades older than tradeHashRetention
+	settled *pnlLedger
+	// trades which can still be reverted, in time order
+	trades []*pnlTrade
+	// ledger of settled trades and trades
+	ledger *pnlLedger
+}
+
+// pnlLotRecord is an open lot of a stored ledger
+type pnlLotRecord struct {
+	Amount *big.Int `json:"amount"`
+	Price  *big.Int `json:"price"`
+}
+
+// pnlPositionRecord is the settled ledger of a position stored in cache store
+type pnlPositionRecord struct {
+	UserAddress     common.Address  `json:"userAddress"`
+	BaseToken       common.Address  `json:"baseToken"`
+	QuoteToken      common.Address  `json:"quoteToken"`
+	Size            *big.Int        `json:"size"`
+	Lots            []*pnlLotRecord `json:"lots"`
+	RealizedFIFO    *big.Int        `json:"realizedFifo"`
+	AveragePrice    *big.Int        `json:"averagePrice"`
+	RealizedAverage *big.Int        `json:"realizedAverage"`
+	Volume          *big.Int        `json:"volume"`
+	Count           int             `json:"count"`
+	LastTrade       int64           `json:"lastTrade"`
+}
+
+// pnlStoreMetadata is committed with every batch, so that it matches the stored ledgers
+type pnlStoreMetadata struct {
+	SettledTime int64 `json:"settledTime"`
+	// pairAddress => last trade price and time
+	LastPrices map[string]*big.Int `json:"lastPrices"`
+	LastTimes  map[string]int64    `json:"lastTimes"`
+}
+
+// PnLService keeps a position ledger of every user by pair from the trade stream
+// settled ledgers are kept in cache store, trades since they were settled are replayed at startup
+// all trades are replayed from the first one if the store is empty
+type PnLService struct {
+	tradeDao *daos.TradeDao
+	tokenDao *daos.TokenDao
+	store    CacheStore
+	// userAddress => pairAddress => position
+	positions map[common.Address]map[string]*pnlPosition
+	// pairAddress => last trade price
+	lastPrices map[string]*big.Int
+	// pairAddress => last trade time
+	lastTimes map[string]int64
+	// tradeHash => trade in ledger, for trades which can still be reverted
+	trades map[common.Hash]*types.Trade
+	// trades before settled time are in settled ledgers
+	settledTime int64
+	// userAddress => pairAddress => true for settled ledgers changed since last commit
+	dirty map[common.Address]map[string]bool
+	// token => decimals
+	decimals map[common.Address]int
+	// trade statuses counted in volume
+	tradeStatuses map[string]bool
+	mutex         sync.RWMutex
+}
+
+// NewPnLService init new instance
+func NewPnLService(tradeDao *daos.TradeDao, tokenDao *daos.TokenDao, store CacheStore) *PnLService {
+	return &PnLService{
+		tradeDao:      tradeDao,
+		tokenDao:      tokenDao,
+		store:         store,
+		dirty:         make(map[common.Address]map[string]bool),
+		positions:     make(map[common.Address]map[string]*pnlPosition),
+		lastPrices:    make(map[string]*big.Int),
+		lastTimes:     make(map[string]int64),
+		trades:        make(map[common.Hash]*types.Trade),
+		decimals:      make(map[common.Address]int),
+		tradeStatuses: newTradeStatuses(),
+	}
+}
+
+// Init load settled ledgers and replay trades of trade collection since they were settled
+func (s *PnLService) Init() {
+	if err := s.loadCache(); err != nil {
+		logger.Error("Failed to load PnL cache:", err)
+	}
+	s.fetch(s.settledTime, time.Now().Unix()+1)
+	s.prune()
+	ticker := time.NewTicker(60 * time.Second)
+	go func() {
+		for range ticker.C {
+			s.prune()
+			if err := s.commitCache(); err != nil {
+				logger.Error(err)
+			}
+		}
+	}()
+}
+
+// loadCache load settled ledgers and settled time from cache store
+func (s *PnLService) loadCache() error {
+	data, err := s.store.Get(pnlStoreMeta)
+	if err != nil || data == nil {
+		return err
+	}
+	var meta pnlStoreMetadata
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	err = s.store.Iterate(pnlStorePosition, "", func(key string, value []byte) error {
+		var record pnlPositionRecord
+		if err := json.Unmarshal(value, &record); err != nil {
+			return err
+		}
+		s.restorePosition(&record)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.settledTime = meta.SettledTime
+	for key, price := range meta.LastPrices {
+		s.lastPrices[key] = price
+		s.lastTimes[key] = meta.LastTimes[key]
+	}
+	return nil
+}
+
+// restorePosition add stored settled ledger, need to be lock
+func (s *PnLService) restorePosition(record *pnlPositionRecord) {
+	settled := &pnlLedger{
+		size:            record.Size,
+		realizedFIFO:    record.RealizedFIFO,
+		averagePrice:    record.AveragePrice,
+		realizedAverage: record.RealizedAverage,
+		volume:          record.Volume,
+		count:           record.Count,
+		lastTrade:       record.LastTrade,
+	}
+	for _, lot := range record.Lots {
+		settled.lots = append(settled.lots, &pnlLot{amount: lot.Amount, price: lot.Price})
+	}
+	if _, ok := s.positions[record.UserAddress]; !ok {
+		s.positions[record.UserAddress] = make(map[string]*pnlPosition)
+	}
+	s.positions[record.UserAddress][utils.GetPairKey(record.BaseToken, record.QuoteToken)] = &pnlPosition{
+		baseToken:  record.BaseToken,
+		quoteToken: record.QuoteToken,
+		settled:    settled,
+		ledger:     settled.clone(),
+	}
+}
+
+// commitCache write settled ledgers changed since last commit to cache store
+func (s *PnLService) commitCache() error {
+	s.mutex.Lock()
+	dirty := s.dirty
+	s.dirty = make(map[common.Address]map[string]bool)
+	batch, err := s.newCommitBatch(dirty)
+	s.mutex.Unlock()
+
+	if err == nil {
+		err = s.store.Commit(batch)
+	}
+	if err != nil {
+		// keep changes for next commit
+		s.mutex.Lock()
+		for userAddress, keys := range dirty {
+			for key := range keys {
+				s.setDirty(userAddress, key)
+			}
+		}
+		s.mutex.Unlock()
+		return err
+	}
+	return nil
+}
+
+// newCommitBatch need to be lock
+func (s *PnLService) newCommitBatch(dirty map[common.Address]map[string]bool) (*CacheBatch, error) {
+	batch := NewCacheBatch()
+	for userAddress, keys := range dirty {
+		for key := range keys {
+			storeKey := pnlStorePosition + userAddress.Hex() + "/" + key
+			p, ok := s.positions[userAddress][key]
+			if !ok {
+				batch.Delete(storeKey)
+				continue
+			}
+			record := &pnlPositionRecord{
+				UserAddress:     userAddress,
+				BaseToken:       p.baseToken,
+				QuoteToken:      p.quoteToken,
+				Size:            p.settled.size,
+				Lots:            []*pnlLotRecord{},
+				RealizedFIFO:    p.settled.realizedFIFO,
+				AveragePrice:    p.settled.averagePrice,
+				RealizedAverage: p.settled.realizedAverage,
+				Volume:          p.settled.volume,
+				Count:           p.settled.count,
+				LastTrade:       p.settled.lastTrade,
+			}
+			for _, lot := range p.settled.lots {
+				record.Lots = append(record.Lots, &pnlLotRecord{Amount: lot.amount, Price: lot.price})
+			}
+			if err := batch.Put(storeKey, record); err != nil {
+				return nil, err
+			}
+		}
+	}
+	meta := &pnlStoreMetadata{
+		SettledTime: s.settledTime,
+		LastPrices:  s.lastPrices,
+		LastTimes:   s.lastTimes,
+	}
+	if err := batch.Put(pnlStoreMeta, meta); err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+// setDirty mark settled ledger of position to be committed, need to be lock
+func (s *PnLService) setDirty(userAddress common.Address, key string) {
+	if _, ok := s.dirty[userAddress]; !ok {
+		s.dirty[userAddress] = make(map[string]bool)
+	}
+	s.dirty[userAddress][key] = true
+}
+
+func (s *PnLService) fetch(fromdate int64, todate int64) {
+	pageOffset := 0
+	size := 1000
+	for {
+		trades, err := s.tradeDao.GetTradeByTime(fromdate, todate, pageOffset*size, size)
+		logger.Debug("FETCH PNL DATA", pageOffset*size)
+		if err != nil || len(trades) == 0 {
+			break
+		}
+		s.mutex.Lock()
+		for _, trade := range trades {
+			if s.tradeStatuses[trade.Status] {
+				s.addTrade(trade)
+			}
+		}
+		s.mutex.Unlock()
+		pageOffset = pageOffset + 1
+	}
+}
+
+// NotifyTrade add trade to the positions of its maker and taker
+// TradeService only notifies trades with counted status
+func (s *PnLService) NotifyTrade(trade *types.Trade) error {
+	if trade == nil {
+		return nil
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.addTrade(trade)
+	return nil
+}
+
+// NotifyRevertTrade remove trade from the positions of its maker and taker
+func (s *PnLService) NotifyRevertTrade(trade *types.Trade) error {
+	if trade == nil {
+		return nil
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.revertTrade(trade)
+	return nil
+}
+
+// prune settle trades which can not be reverted anymore
+func (s *PnLService) prune() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	horizon := time.Now().Unix() - tradeHashRetention
+	for userAddress, positions := range s.positions {
+		for key, p := range positions {
+			if p.settle(horizon) > 0 {
+				s.setDirty(userAddress, key)
+			}
+		}
+	}
+	for hash, trade := range s.trades {
+		if trade.CreatedAt.Unix() < horizon {
+			delete(s.trades, hash)
+		}
+	}
+	s.settledTime = horizon
+}
+
+// addTrade add trade once by trade hash to the positions of its maker and taker, need to be lock
+func (s *PnLService) addTrade(trade *types.Trade) {
+	if trade.Amount == nil || trade.PricePoint == nil {
+		return
+	}
+	if _, ok := s.trades[trade.Hash]; ok {
+		return
+	}
+	tradeTime := trade.CreatedAt.Unix()
+	if tradeTime < s.settledTime && tradeTime < time.Now().Unix()-tradeHashRetention {
+		// the trade is not notified again once settled, its hash is not kept
+		logger.Debug("Settle PnL of trade older than hash retention", trade.Hash.Hex())
+	} else {
+		s.trades[trade.Hash] = trade
+	}
+	key := utils.GetPairKey(trade.BaseToken, trade.QuoteToken)
+	if last, ok := s.lastTimes[key]; !ok || tradeTime >= last {
+		s.lastPrices[key] = trade.PricePoint
+		s.lastTimes[key] = tradeTime
+	}
+	if trade.Maker == trade.Taker {
+		// self trade does not change position
+		return
+	}
+	takerBuy := trade.TakerOrderSide == sideBuy
+	s.getPosition(trade.Taker, key, trade).add(newPnLTrade(trade, takerBuy), s.settledTime)
+	s.getPosition(trade.Maker, key, trade).add(newPnLTrade(trade, !takerBuy), s.settledTime)
+	if tradeTime < s.settledTime {
+		s.setDirty(trade.Taker, key)
+		s.setDirty(trade.Maker, key)
+	}
+}
+
+// revertTrade remove trade from the positions of its maker and taker, need to be lock
+func (s *PnLService) revertTrade(trade *types.Trade) {
+	if _, ok := s.trades[trade.Hash]; !ok {
+		return
+	}
+	delete(s.trades, trade.Hash)
+	key := utils.GetPairKey(trade.BaseToken, trade.QuoteToken)
+	for _, userAddress := range []common.Address{trade.Taker, trade.Maker} {
+		p, ok := s.positions[userAddress][key]
+		if !ok {
+			continue
+		}
+		p.remove(trade.Hash)
+		if len(p.trades) == 0 && p.settled.count == 0 {
+			delete(s.positions[userAddress], key)
+		}
+	}
+	if s.lastTimes[key] != trade.CreatedAt.Unix() {
+		return
+	}
+	// the last price falls back to the latest other trade which can still be reverted
+	var latest *types.Trade
+	for _, t := range s.trades {
+		if t.BaseToken == trade.BaseToken && t.QuoteToken == trade.QuoteToken && (latest == nil || t.CreatedAt.After(latest.CreatedAt)) {
+			latest = t
+		}
+	}
+	if latest != nil {
+		s.lastPrices[key] = latest.PricePoint
+		s.lastTimes[key] = latest.CreatedAt.Unix()
+	}
+}
+
+// getPosition get position of user on pair of trade, need to be lock
+func (s *PnLService) getPosition(userAddress common.Address, key string, trade *types.Trade) *pnlPosition {
+	if _, ok := s.positions[userAddress]; !ok {
+		s.positions[userAddress] = make(map[string]*pnlPosition)
+	}
+	p, ok := s.positions[userAddress][key]
+	if !ok {
+		p = &pnlPosition{
+			baseToken:  trade.BaseToken,
+			quoteToken: trade.QuoteToken,
+			settled:    newPnLLedger(),
+			ledger:     newPnLLedger(),
+		}
+		s.positions[userAddress][key] = p
+	}
+	return p
+}
+
+func newPnLTrade(trade *types.Trade, buy bool) *pnlTrade {
+	return &pnlTrade{
+		hash:   trade.Hash,
+		id:     trade.ID,
+		time:   trade.CreatedAt.Unix(),
+		amount: trade.Amount,
+		price:  trade.PricePoint,
+		buy:    buy,
+	}
+}
+
+// isBefore order trades by time, then by id for trades of the same second
+func (t *pnlTrade) isBefore(other *pnlTrade) bool {
+	if t.time != other.time {
+		return t.time < other.time
+	}
+	return t.id < other.id
+}
+
+// add insert trade in time order, the ledger is replayed if it is not the latest trade
+// a trade older than settled time is added to the settled ledger
+func (p *pnlPosition) add(t *pnlTrade, settledTime int64) {
+	if t.time < settledTime {
+		p.settled.apply(t)
+		p.replay()
+		return
+	}
+	i := sort.Search(len(p.trades), func(i int) bool {
+		return t.isBefore(p.trades[i])
+	})
+	p.trades = append(p.trades, nil)
+	copy(p.trades[i+1:], p.trades[i:])
+	p.trades[i] = t
+	if i == len(p.trades)-1 {
+		p.ledger.apply(t)
+		return
+	}
+	p.replay()
+}
+
+// remove trade by hash and replay the ledger without it
+func (p *pnlPosition) remove(hash common.Hash) {
+	for i, t := range p.trades {
+		if t.hash == hash {
+			p.trades = append(p.trades[:i], p.trades[i+1:]...)
+			p.replay()
+			return
+		}
+	}
+}
+
+// settle move trades older than horizon to the settled ledger, return the number of settled trades
+func (p *pnlPosition) settle(horizon int64) int {
+	n := 0
+	for n < len(p.trades) && p.trades[n].time < horizon {
+		p.settled.apply(p.trades[n])
+		n++
+	}
+	p.trades = p.trades[n:]
+	return n
+}
+
+// replay rebuild the ledger from settled ledger and trades
+func (p *pnlPosition) replay() {
+	p.ledger = p.settled.clone()
+	for _, t := range p.trades {
+		p.ledger.apply(t)
+	}
+}
+
+func newPnLLedger() *pnlLedger {
+	return &pnlLedger{
+		size:            big.NewInt(0),
+		realizedFIFO:    big.NewInt(0),
+		averagePrice:    big.NewInt(0),
+		realizedAverage: big.NewInt(0),
+		volume:          big.NewInt(0),
+	}
+}
+
+// clone copy ledger, lots are modified when they are closed
+func (l *pnlLedger) clone() *pnlLedger {
+	c := *l
+	c.lots = make([]*pnlLot, len(l.lots))
+	for i, lot := range l.lots {
+		c.lots[i] = &pnlLot{amount: lot.amount, price: lot.price}
+	}
+	return &c
+}
+
+// apply add a buy or a sell to the ledger
+func (l *pnlLedger) apply(t *pnlTrade) {
+	side := 1
+	if !t.buy {
+		side = -1
+	}
+	amount := t.amount
+	price := t.price
+
+	// fifo: close lots of the opposite side first, from the oldest
+	remaining := new(big.Int).Set(amount)
+	for remaining.Sign() > 0 && len(l.lots) > 0 && l.size.Sign() == -side {
+		lot := l.lots[0]
+		closed := remaining
+		if lot.amount.Cmp(remaining) < 0 {
+			closed = lot.amount
+		}
+		// selling a long lot earns price - entry, buying back a short lot earns entry - price
+		diff := new(big.Int).Sub(price, lot.price)
+		if t.buy {
+			diff = diff.Neg(diff)
+		}
+		l.realizedFIFO = new(big.Int).Add(l.realizedFIFO, new(big.Int).Mul(diff, closed))
+		lot.amount = new(big.Int).Sub(lot.amount, closed)
+		remaining = new(big.Int).Sub(remaining, closed)
+		if lot.amount.Sign() == 0 {
+			l.lots = l.lots[1:]
+		}
+	}
+	if remaining.Sign() > 0 {
+		l.lots = append(l.lots, &pnlLot{amount: remaining, price: price})
+	}
+
+	// average: closing part is matched with the average entry price, opening part updates it
+	absSize := new(big.Int).Abs(l.size)
+	if l.size.Sign() == 0 || l.size.Sign() == side {
+		total := new(big.Int).Add(new(big.Int).Mul(l.averagePrice, absSize), new(big.Int).Mul(price, amount))
+		l.averagePrice = total.Div(total, new(big.Int).Add(absSize, amount))
+	} else {
+		closed := amount
+		if absSize.Cmp(amount) < 0 {
+			closed = absSize
+		}
+		diff := new(big.Int).Sub(price, l.averagePrice)
+		if t.buy {
+			diff = diff.Neg(diff)
+		}
+		l.realizedAverage = new(big.Int).Add(l.realizedAverage, new(big.Int).Mul(diff, closed))
+		switch absSize.Cmp(amount) {
+		case 0:
+			l.averagePrice = big.NewInt(0)
+		case -1:
+			// position is flipped to the other side at trade price
+			l.averagePrice = new(big.Int).Set(price)
+		}
+	}
+
+	l.size = new(big.Int).Add(l.size, new(big.Int).Mul(amount, big.NewInt(int64(side))))
+	l.volume = new(big.Int).Add(l.volume, amount)
+	l.count++
+	if t.time > l.lastTrade {
+		l.lastTrade = t.time
+	}
+}
+
+// getFIFOUnrealized value open lots of fifo method at last price
+func (l *pnlLedger) getFIFOUnrealized(lastPrice *big.Int) *big.Int {
+	unrealized := big.NewInt(0)
+	for _, lot := range l.lots {
+		unrealized = unrealized.Add(unrealized, new(big.Int).Mul(lot.amount, new(big.Int).Sub(lastPrice, lot.price)))
+	}
+	if l.size.Sign() < 0 {
+		unrealized = unrealized.Neg(unrealized)
+	}
+	return unrealized
+}
+
+// GetTradesPnL replay trades in time order in new fifo ledgers and get the total PnL of every user
+// positions only have the given trades, open lots are valued at the last price of the trades of their pair
+func (s *PnLService) GetTradesPnL(trades []*types.Trade) map[common.Address]*big.Int {
+	sorted := make([]*pnlTrade, 0, len(trades))
+	for _, trade := range trades {
+		if trade.Amount != nil && trade.PricePoint != nil {
+			sorted = append(sorted, newPnLTrade(trade, trade.TakerOrderSide == sideBuy))
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].isBefore(sorted[j])
+	})
+	tradesByHash := make(map[common.Hash]*types.Trade)
+	for _, trade := range trades {
+		tradesByHash[trade.Hash] = trade
+	}
+
+	positions := make(map[common.Address]map[string]*pnlPosition)
+	lastPrices := make(map[string]*big.Int)
+	for _, t := range sorted {
+		trade := tradesByHash[t.hash]
+		key := utils.GetPairKey(trade.BaseToken, trade.QuoteToken)
+		lastPrices[key] = t.price
+		if trade.Maker == trade.Taker {
+			continue
+		}
+		for _, userAddress := range []common.Address{trade.Taker, trade.Maker} {
+			if _, ok := positions[userAddress]; !ok {
+				positions[userAddress] = make(map[string]*pnlPosition)
+			}
+			p, ok := positions[userAddress][key]
+			if !ok {
+				p = &pnlPosition{baseToken: trade.BaseToken, quoteToken: trade.QuoteToken, ledger: newPnLLedger()}
+				positions[userAddress][key] = p
+			}
+			buy := t.buy
+			if userAddress == trade.Maker {
+				buy = !buy
+			}
+			p.ledger.apply(&pnlTrade{hash: t.hash, id: t.id, time: t.time, amount: t.amount, price: t.price, buy: buy})
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	res := make(map[common.Address]*big.Int)
+	for userAddress, userPositions := range positions {
+		total := big.NewInt(0)
+		for key, p := range userPositions {
+			pnl := new(big.Int).Add(p.ledger.realizedFIFO, p.ledger.getFIFOUnrealized(lastPrices[key]))
+			total = total.Add(total, pnl.Quo(pnl, s.getDecimalsBig(p.baseToken)))
+		}
+		res[userAddress] = total
+	}
+	return res
+}
+
+// getDecimalsBig get 10^decimals of token, need to be lock
+func (s *PnLService) getDecimalsBig(token common.Address) *big.Int {
+	decimals, ok := s.decimals[token]
+	if !ok {
+		decimals = defaultTokenDecimals
+		t, err := s.tokenDao.GetByAddress(token)
+		if err == nil && t != nil {
+			decimals = t.Decimals
+			s.decimals[token] = decimals
+		}
+	}
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+}
+
+// getUserPosition compute PnL of position with method, need to be lock
+func (s *PnLService) getUserPosition(userAddress common.Address, key string, p *pnlPosition, method string) *types.UserPosition {
+	decimalsBig := s.getDecimalsBig(p.baseToken)
+	l := p.ledger
+	lastPrice := big.NewInt(0)
+	if price, ok := s.lastPrices[key]; ok {
+		lastPrice = price
+	}
+	var realized, unrealized, entry *big.Int
+	if method == PnLMethodAverage {
+		realized = l.realizedAverage
+		entry = l.averagePrice
+		unrealized = new(big.Int).Mul(l.size, new(big.Int).Sub(lastPrice, entry))
+	} else {
+		realized = l.realizedFIFO
+		unrealized = l.getFIFOUnrealized(lastPrice)
+		cost := big.NewInt(0)
+		for _, lot := range l.lots {
+			cost = cost.Add(cost, new(big.Int).Mul(lot.amount, lot.price))
+		}
+		entry = big.NewInt(0)
+		if l.size.Sign() != 0 {
+			entry = cost.Div(cost, new(big.Int).Abs(l.size))
+		}
+	}
+	realized = new(big.Int).Quo(realized, decimalsBig)
+	unrealized = new(big.Int).Quo(unrealized, decimalsBig)
+	return &types.UserPosition{
+		UserAddress:       userAddress,
+		BaseToken:         p.baseToken,
+		QuoteToken:        p.quoteToken,
+		Method:            method,
+		Size:              new(big.Int).Set(l.size),
+		AverageEntryPrice: entry,
+		LastPrice:         lastPrice,
+		RealizedPnL:       realized,
+		UnrealizedPnL:     unrealized,
+		TotalPnL:          new(big.Int).Add(realized, unrealized),
+		Volume:            new(big.Int).Set(l.volume),
+		Count:             l.count,
+		LastTrade:         l.lastTrade,
+	}
+}
+
+// GetUserPositions get positions of user, empty token address for all tokens
+func (s *PnLService) GetUserPositions(userAddress common.Address, baseToken, quoteToken common.Address, method string) []*types.UserPosition {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	res := []*types.UserPosition{}
+	for key, p := range s.positions[userAddress] {
+		if ((baseToken != common.Address{}) && baseToken != p.baseToken) || ((quoteToken != common.Address{}) && quoteToken != p.quoteToken) {
+			continue
+		}
+		res = append(res, s.getUserPosition(userAddress, key, p, method))
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return strings.ToLower(utils.GetPairKey(res[i].BaseToken, res[i].QuoteToken)) < strings.ToLower(utils.GetPairKey(res[j].BaseToken, res[j].QuoteToken))
+	})
+	return res
+}
+
+// IsValidMethod check PnL method is supported
+func (s *PnLService) IsValidMethod(method string) bool {
+	return method == PnLMethodFIFO || method == PnLMethodAverage
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/tomochain/tomox-stats/types"
+)
+
+func newTestPnLService(store CacheStore) *PnLService {
+	s := NewPnLService(nil, nil, store)
+	s.decimals[testBaseToken] = 0
+	return s
+}
+
+func getTestPosition(s *PnLService, userAddress common.Address, method string) *types.UserPosition {
+	positions := s.GetUserPositions(userAddress, testBaseToken, testQuoteToken, method)
+	if len(positions) != 1 {
+		return nil
+	}
+	return positions[0]
+}
+
+func TestPnLFIFO(t *testing.T) {
+	s := newTestPnLService(nil)
+	now := time.Now().Unix()
+	// taker buys 10 at 100 and 10 at 110, then sells 15 at 120
+	s.NotifyTrade(newTestTrade(1, now-30, 100, 10, sideBuy))
+	s.NotifyTrade(newTestTrade(2, now-20, 110, 10, sideBuy))
+	s.NotifyTrade(newTestTrade(3, now-10, 120, 15, sideSell))
+
+	p := getTestPosition(s, testTaker, PnLMethodFIFO)
+	// the first lot is closed, the second one partially
+	assert.Equal(t, int64(5), p.Size.Int64())
+	assert.Equal(t, int64(10*20+5*10), p.RealizedPnL.Int64())
+	assert.Equal(t, int64(110), p.AverageEntryPrice.Int64())
+	assert.Equal(t, int64(5*10), p.UnrealizedPnL.Int64())
+	assert.Equal(t, int64(35), p.Volume.Int64())
+	assert.Equal(t, 3, p.Count)
+
+	// the maker has the opposite short position
+	m := getTestPosition(s, testMaker, PnLMethodFIFO)
+	assert.Equal(t, int64(-5), m.Size.Int64())
+	assert.Equal(t, int64(-250), m.RealizedPnL.Int64())
+	assert.Equal(t, int64(-50), m.UnrealizedPnL.Int64())
+
+	a := getTestPosition(s, testTaker, PnLMethodAverage)
+	assert.Equal(t, int64(105), a.AverageEntryPrice.Int64())
+	assert.Equal(t, int64(15*15), a.RealizedPnL.Int64())
+	assert.Equal(t, int64(5*15), a.UnrealizedPnL.Int64())
+}
+
+func TestPnLFlip(t *testing.T) {
+	s := newTestPnLService(nil)
+	now := time.Now().Unix()
+	s.NotifyTrade(newTestTrade(1, now-20, 100, 10, sideBuy))
+	// selling more than the position opens a short lot at trade price
+	s.NotifyTrade(newTestTrade(2, now-10, 90, 15, sideSell))
+	s.NotifyTrade(newTestTrade(3, now, 80, 1, sideBuy))
+
+	p := getTestPosition(s, testTaker, PnLMethodFIFO)
+	assert.Equal(t, int64(-4), p.Size.Int64())
+	assert.Equal(t, int64(-100+10), p.RealizedPnL.Int64())
+	assert.Equal(t, int64(90), p.AverageEntryPrice.Int64())
+	assert.Equal(t, int64(4*10), p.UnrealizedPnL.Int64())
+}
+
+func TestPnLRevert(t *testing.T) {
+	s := newTestPnLService(nil)
+	now := time.Now().Unix()
+	second := newTestTrade(2, now-20, 110, 10, sideBuy)
+	s.NotifyTrade(newTestTrade(1, now-30, 100, 10, sideBuy))
+	s.NotifyTrade(second)
+	s.NotifyTrade(newTestTrade(3, now-10, 120, 15, sideSell))
+	// a replayed trade is counted once
+	s.NotifyTrade(second)
+
+	s.NotifyRevertTrade(second)
+	p := getTestPosition(s, testTaker, PnLMethodFIFO)
+	// the ledger is replayed without the reverted lot, the sell closes the first lot and opens a short one
+	assert.Equal(t, int64(-5), p.Size.Int64())
+	assert.Equal(t, int64(10*20), p.RealizedPnL.Int64())
+	assert.Equal(t, int64(120), p.AverageEntryPrice.Int64())
+	assert.Equal(t, int64(0), p.UnrealizedPnL.Int64())
+	assert.Equal(t, 2, p.Count)
+
+	// a trade is reverted once
+	s.NotifyRevertTrade(second)
+	assert.Equal(t, 2, getTestPosition(s, testTaker, PnLMethodFIFO).Count)
+}
+
+func TestPnLOutOfOrder(t *testing.T) {
+	s := newTestPnLService(nil)
+	now := time.Now().Unix()
+	// trades of the stream and of the backfill are not always in time order
+	s.NotifyTrade(newTestTrade(3, now-10, 120, 15, sideSell))
+	s.NotifyTrade(newTestTrade(1, now-30, 100, 10, sideBuy))
+	s.NotifyTrade(newTestTrade(2, now-20, 110, 10, sideBuy))
+
+	p := getTestPosition(s, testTaker, PnLMethodFIFO)
+	assert.Equal(t, int64(5), p.Size.Int64())
+	assert.Equal(t, int64(250), p.RealizedPnL.Int64())
+	assert.Equal(t, int64(120), p.LastPrice.Int64())
+}
+
+func TestPnLStoreRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pnlstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "pnl")
+
+	store, err := NewCacheStore(CacheStoreFile, name)
+	assert.NoError(t, err)
+	s := newTestPnLService(store)
+	old := time.Now().Unix() - tradeHashRetention - 60
+	s.NotifyTrade(newTestTrade(1, old-20, 100, 10, sideBuy))
+	s.NotifyTrade(newTestTrade(2, old-10, 110, 10, sideBuy))
+	s.NotifyTrade(newTestTrade(3, time.Now().Unix(), 120, 15, sideSell))
+	// trades older than hash retention are settled
+	s.prune()
+	assert.NoError(t, s.commitCache())
+	store.Close()
+
+	store, err = NewCacheStore(CacheStoreFile, name)
+	assert.NoError(t, err)
+	loaded := newTestPnLService(store)
+	assert.NoError(t, loaded.loadCache())
+	assert.Equal(t, s.settledTime, loaded.settledTime)
+	// trades since settled time are replayed from trade collection
+	loaded.NotifyTrade(newTestTrade(3, time.Now().Unix(), 120, 15, sideSell))
+	assert.Equal(t, getTestPosition(s, testTaker, PnLMethodFIFO), getTestPosition(loaded, testTaker, PnLMethodFIFO))
+	assert.Equal(t, getTestPosition(s, testMaker, PnLMethodAverage), getTestPosition(loaded, testMaker, PnLMethodAverage))
+}
+
+func TestGetTradesPnL(t *testing.T) {
+	s := newTestPnLService(nil)
+	now := time.Now().Unix()
+	pnls := s.GetTradesPnL([]*types.Trade{
+		newTestTrade(3, now-10, 120, 15, sideSell),
+		newTestTrade(1, now-30, 100, 10, sideBuy),
+		newTestTrade(2, now-20, 110, 10, sideBuy),
+	})
+	assert.Equal(t, int64(250+50), pnls[testTaker].Int64())
+	assert.Equal(t, int64(-300), pnls[testMaker].Int64())
+	// trades of the service are not changed
+	assert.Empty(t, s.GetUserPositions(testTaker, testBaseToken, testQuoteToken, PnLMethodFIFO))
+}
@@ -0,0 +1,399 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/tomochain/tomox-stats/daos"
+	"github.com/tomochain/tomox-stats/types"
+)
+
+const (
+	// PriceSourceFile read fiat prices from a json file
+	PriceSourceFile = "file"
+	// PriceSourceHTTP read fiat prices from a json http endpoint
+	PriceSourceHTTP = "http"
+
+	// CurrencyUSD is the currency param of USD valued endpoints
+	CurrencyUSD = "USD"
+
+	priceHTTPTimeout = 10 * time.Second
+	// usdPrecision is the mantissa precision of USD values, sums of token amounts stay exact to the cent
+	usdPrecision = 256
+	// usdDecimals is the number of decimals of USD values in responses
+	usdDecimals = 6
+)
+
+// PriceSource provides USD prices of one whole token
+// keys are token symbols or token addresses
+type PriceSource interface {
+	GetPrices() (map[string]float64, error)
+}
+
+// NewPriceSource init price source of kind, url is the file path or the http url
+// no source is configured if kind is empty
+func NewPriceSource(kind string, url string) (PriceSource, error) {
+	switch kind {
+	case "":
+		return nil, nil
+	case PriceSourceFile:
+		return &filePriceSource{path: url}, nil
+	case PriceSourceHTTP:
+		return &httpPriceSource{url: url, client: &http.Client{Timeout: priceHTTPTimeout}}, nil
+	}
+	return nil, fmt.Errorf("Unknown price source %s", kind)
+}
+
+// filePriceSource is a static json file, {"TOMO": 0.5, "USDT": 1}
+type filePriceSource struct {
+	path string
+}
+
+func (s *filePriceSource) GetPrices() (map[string]float64, error) {
+	data, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+	prices := make(map[string]float64)
+	if err := json.Unmarshal(data, &prices); err != nil {
+		return nil, err
+	}
+	return prices, nil
+}
+
+// httpPriceSource is an endpoint returning the same json as filePriceSource
+type httpPriceSource struct {
+	url    string
+	client *http.Client
+}
+
+func (s *httpPriceSource) GetPrices() (map[string]float64, error) {
+	resp, err := s.client.Get(s.url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Price source returned status %d", resp.StatusCode)
+	}
+	prices := make(map[string]float64)
+	if err := json.NewDecoder(resp.Body).Decode(&prices); err != nil {
+		return nil, err
+	}
+	return prices, nil
+}
+
+type priceToken struct {
+	symbol   string
+	decimals int
+	// usd is the price stored in token collection, 0 if unknown
+	usd float64
+}
+
+// PriceService values tokens in USD
+// prices come from the price source, tokens not listed by the source are priced
+// through the last trade prices of their pairs, then from the last price stored in token collection
+type PriceService struct {
+	tokenDao     *daos.TokenDao
+	tradeService *TradeService
+	source       PriceSource
+	// token => USD price of one whole token
+	prices map[common.Address]float64
+	// token => USD price of one smallest unit of token
+	unitPrices map[common.Address]*big.Float
+	// token => decimals of tokens of token collection
+	decimals map[common.Address]int
+	mutex    sync.RWMutex
+}
+
+// NewPriceService init new instance, source can be nil
+func NewPriceService(tokenDao *daos.TokenDao, tradeService *TradeService, source PriceSource) *PriceService {
+	return &PriceService{
+		tokenDao:     tokenDao,
+		tradeService: tradeService,
+		source:       source,
+		prices:       make(map[common.Address]float64),
+		unitPrices:   make(map[common.Address]*big.Float),
+		decimals:     make(map[common.Address]int),
+	}
+}
+
+// Init update prices every minute
+func (s *PriceService) Init() {
+	if err := s.update(); err != nil {
+		logger.Error(err)
+	}
+	ticker := time.NewTicker(60 * time.Second)
+	go func() {
+		for range ticker.C {
+			if err := s.update(); err != nil {
+				logger.Error(err)
+			}
+		}
+	}()
+}
+
+func (s *PriceService) getTokens() (map[common.Address]*priceToken, error) {
+	tokens, err := s.tokenDao.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[common.Address]*priceToken)
+	native := types.GetNativeCurrency()
+	res[native.Address] = &priceToken{symbol: native.Symbol, decimals: native.Decimals}
+	for _, t := range tokens {
+		p := &priceToken{symbol: t.Symbol, decimals: t.Decimals}
+		if t.USD != "" {
+			p.usd, _ = strconv.ParseFloat(t.USD, 64)
+		}
+		if last, ok := res[t.ContractAddress]; ok && p.usd == 0 {
+			p.usd = last.usd
+		}
+		res[t.ContractAddress] = p
+	}
+	return res, nil
+}
+
+// update refresh prices and store the new ones in token collection
+func (s *PriceService) update() error {
+	tokens, err := s.getTokens()
+	if err != nil {
+		return err
+	}
+
+	// anchors are the prices of the source, or the stored prices if the source is not available
+	// only prices of the source are stored, so that a derived price never becomes an anchor
+	anchors := make(map[common.Address]float64)
+	if s.source != nil {
+		sourcePrices, err := s.source.GetPrices()
+		if err != nil {
+			logger.Error("Failed to get prices from source:", err)
+		}
+		for address, t := range tokens {
+			if price, ok := sourcePrices[t.symbol]; ok {
+				anchors[address] = price
+			}
+		}
+		for key, price := range sourcePrices {
+			if common.IsHexAddress(key) {
+				anchors[common.HexToAddress(key)] = price
+			}
+		}
+	}
+	sourced := len(anchors) > 0
+	if !sourced {
+		for address, t := range tokens {
+			if t.usd > 0 {
+				anchors[address] = t.usd
+			}
+		}
+	}
+
+	prices := s.derivePrices(anchors, tokens, s.tradeService.GetLastPairPrices())
+	for address, t := range tokens {
+		if _, ok := prices[address]; !ok && t.usd > 0 {
+			prices[address] = t.usd
+		}
+	}
+
+	decimals := make(map[common.Address]int)
+	for address, t := range tokens {
+		decimals[address] = t.decimals
+	}
+	unitPrices := make(map[common.Address]*big.Float)
+	for address, price := range prices {
+		decimals := defaultTokenDecimals
+		if t, ok := tokens[address]; ok {
+			decimals = t.decimals
+		}
+		unitPrices[address] = new(big.Float).Quo(big.NewFloat(price), new(big.Float).SetInt(pow10Big(decimals)))
+	}
+
+	s.mutex.Lock()
+	s.prices = prices
+	s.unitPrices = unitPrices
+	s.decimals = decimals
+	s.mutex.Unlock()
+
+	if !sourced {
+		return nil
+	}
+	updated := make(map[string]bool)
+	for address, price := range anchors {
+		t, ok := tokens[address]
+		// stored prices have 6 decimals
+		if !ok || updated[t.symbol] || fmt.Sprintf("%f", t.usd) == fmt.Sprintf("%f", price) {
+			continue
+		}
+		updated[t.symbol] = true
+		if err := s.tokenDao.UpdateFiatPriceBySymbol(t.symbol, price); err != nil {
+			logger.Error(err)
+		}
+	}
+	return nil
+}
+
+// derivedPrice is a price of token derived from a pair, volume is the volume of the pair in the token
+type derivedPrice struct {
+	price  float64
+	volume *big.Int
+}
+
+// derivePrices price tokens from anchors through the trade graph
+// a pair with a priced quote token prices its base token and conversely
+// tokens are priced by their shortest path to an anchor, through the pair with the highest volume
+func (s *PriceService) derivePrices(anchors map[common.Address]float64, tokens map[common.Address]*priceToken, pairPrices []*types.PairPrice) map[common.Address]float64 {
+	prices := make(map[common.Address]float64)
+	for address, price := range anchors {
+		prices[address] = price
+	}
+	for {
+		// each round only derives from the prices of previous rounds
+		derived := make(map[common.Address]*derivedPrice)
+		derive := func(token common.Address, price float64, volume *big.Int) {
+			if volume == nil {
+				volume = big.NewInt(0)
+			}
+			if last, ok := derived[token]; !ok || volume.Cmp(last.volume) > 0 {
+				derived[token] = &derivedPrice{price: price, volume: volume}
+			}
+		}
+		for _, p := range pairPrices {
+			if p.Price == nil || p.Price.Sign() <= 0 {
+				continue
+			}
+			quoteDecimals := defaultTokenDecimals
+			if t, ok := tokens[p.QuoteToken]; ok {
+				quoteDecimals = t.decimals
+			}
+			// price of one whole base token in whole quote token
+			rate, _ := new(big.Float).Quo(new(big.Float).SetInt(p.Price), new(big.Float).SetInt(pow10Big(quoteDecimals))).Float64()
+			if rate <= 0 {
+				continue
+			}
+			basePrice, baseOk := prices[p.BaseToken]
+			quotePrice, quoteOk := prices[p.QuoteToken]
+			if quoteOk && !baseOk {
+				derive(p.BaseToken, rate*quotePrice, p.Volume)
+			} else if baseOk && !quoteOk {
+				derive(p.QuoteToken, basePrice/rate, p.VolumeByQuote)
+			}
+		}
+		if len(derived) == 0 {
+			return prices
+		}
+		for token, d := range derived {
+			prices[token] = d.price
+		}
+	}
+}
+
+// GetPrices get USD price of one whole token, by token address
+func (s *PriceService) GetPrices() map[string]float64 {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	res := make(map[string]float64)
+	for address, price := range s.prices {
+		res[strings.ToLower(address.Hex())] = price
+	}
+	return res
+}
+
+// GetTokenDecimals get decimals of token, false if token is not in token collection
+func (s *PriceService) GetTokenDecimals(token common.Address) (int, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	decimals, ok := s.decimals[token]
+	return decimals, ok
+}
+
+// ToUSD value amount of token, in its smallest unit, false if token price is unknown
+func (s *PriceService) ToUSD(token common.Address, amount *big.Int) (*big.Float, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	unitPrice, ok := s.unitPrices[token]
+	if !ok || amount == nil {
+		return nil, false
+	}
+	return NewUSD().Mul(new(big.Float).SetInt(amount), unitPrice), true
+}
+
+// FormatUSD value amount of token as a decimal string, empty if token price is unknown
+func (s *PriceService) FormatUSD(token common.Address, amount *big.Int) string {
+	usd, ok := s.ToUSD(token, amount)
+	if !ok {
+		return ""
+	}
+	return FormatUSD(usd)
+}
+
+// NewUSD init a zero USD value to sum USD values
+func NewUSD() *big.Float {
+	return new(big.Float).SetPrec(usdPrecision)
+}
+
+// FormatUSD format USD value as a decimal string, USD values of responses are strings like token amounts
+func FormatUSD(usd *big.Float) string {
+	return usd.Text('f', usdDecimals)
+}
+
+// compareUSD compare USD values, a missing value is the lowest
+func compareUSD(usd1, usd2 *big.Float) int {
+	if usd1 == nil || usd2 == nil {
+		switch {
+		case usd1 != nil:
+			return 1
+		case usd2 != nil:
+			return -1
+		}
+		return 0
+	}
+	return usd1.Cmp(usd2)
+}
+
+// ParseUSD parse USD value formatted by FormatUSD, 0 if empty or invalid
+func ParseUSD(usd string) *big.Float {
+	v, ok := NewUSD().SetString(usd)
+	if !ok {
+		return NewUSD()
+	}
+	return v
+}
+
+// GetPairPrice get price of one whole base token in quote token smallest unit, from their USD prices
+func (s *PriceService) GetPairPrice(baseToken, quoteToken common.Address) (*big.Int, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	basePrice, ok := s.prices[baseToken]
+	if !ok {
+		return nil, false
+	}
+	quoteUnitPrice, ok := s.unitPrices[quoteToken]
+	if !ok || quoteUnitPrice.Sign() <= 0 {
+		return nil, false
+	}
+	price, _ := new(big.Float).Quo(big.NewFloat(basePrice), quoteUnitPrice).Int(nil)
+	return price, true
+}
+
+// IsValidCurrency check currency param of volume endpoints, empty for quote token
+func (s *PriceService) IsValidCurrency(currency string) bool {
+	return currency == "" || s.IsUSD(currency)
+}
+
+// IsUSD check if volumes are valued in USD for currency param
+func (s *PriceService) IsUSD(currency string) bool {
+	return strings.ToUpper(currency) == CurrencyUSD
+}
+
+func pow10Big(decimals int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+}
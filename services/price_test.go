@@ -0,0 +1,55 @@
+package services
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/tomochain/tomox-stats/types"
+)
+
+func TestFormatUSD(t *testing.T) {
+	s := NewPriceService(nil, nil, nil)
+	s.unitPrices[testQuoteToken] = new(big.Float).Quo(big.NewFloat(0.5), new(big.Float).SetInt(pow10Big(18)))
+
+	// 123456789012.345678901234567891 tokens of 18 decimals, beyond the precision of a float64
+	amount, _ := new(big.Int).SetString("123456789012345678901234567891", 10)
+	assert.Equal(t, "61728394506.172839", s.FormatUSD(testQuoteToken, amount))
+	assert.Equal(t, "", s.FormatUSD(testBaseToken, amount))
+
+	total := NewUSD()
+	for i := 0; i < 3; i++ {
+		usd, ok := s.ToUSD(testQuoteToken, pow10Big(17))
+		assert.True(t, ok)
+		total.Add(total, usd)
+	}
+	assert.Equal(t, "0.150000", FormatUSD(total))
+	assert.Equal(t, 0, ParseUSD(FormatUSD(total)).Cmp(ParseUSD("0.15")))
+	assert.Equal(t, 1, compareUSD(total, nil))
+}
+
+func TestDerivePrices(t *testing.T) {
+	s := NewPriceService(nil, nil, nil)
+	anchor := common.HexToAddress("0x0000000000000000000000000000000000000f01")
+	other := common.HexToAddress("0x0000000000000000000000000000000000000f02")
+	near := common.HexToAddress("0x0000000000000000000000000000000000000f03")
+	far := common.HexToAddress("0x0000000000000000000000000000000000000f04")
+	tokens := map[common.Address]*priceToken{
+		anchor: {decimals: 0},
+		other:  {decimals: 0},
+		near:   {decimals: 0},
+		far:    {decimals: 0},
+	}
+	pairPrices := []*types.PairPrice{
+		// a longer path to an anchor is not taken whatever its volume
+		{BaseToken: far, QuoteToken: near, Price: big.NewInt(3), Volume: big.NewInt(1000)},
+		{BaseToken: near, QuoteToken: anchor, Price: big.NewInt(2), Volume: big.NewInt(10)},
+		// near is priced through the pair with the highest volume of near
+		{BaseToken: other, QuoteToken: near, Price: big.NewInt(1), VolumeByQuote: big.NewInt(100)},
+		{BaseToken: far, QuoteToken: anchor, Price: big.NewInt(5), Volume: big.NewInt(1)},
+	}
+	prices := s.derivePrices(map[common.Address]float64{anchor: 1, other: 3}, tokens, pairPrices)
+	assert.Equal(t, float64(3), prices[near])
+	assert.Equal(t, float64(5), prices[far])
+}
@@ -4,21 +4,27 @@ import (
 	"fmt"
 	"math/big"
 	"net/http"
+	"sort"
 
 	"github.com/tomochain/tomox-stats/daos"
 	"github.com/tomochain/tomox-stats/relayer"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/globalsign/mgo"
 	"github.com/tomochain/tomox-stats/app"
 	"github.com/tomochain/tomox-stats/types"
 )
 
+// relayerDomainMigration is the marker of the removal of domains shared by several active relayers
+const relayerDomainMigration = "relayer-unique-domain"
+
 // RelayerService struct
 type RelayerService struct {
-	relayer    *relayer.Relayer
-	tokenDao   *daos.TokenDao
-	pairDao    *daos.PairDao
-	relayerDao *daos.RelayerDao
+	relayer      *relayer.Relayer
+	tokenDao     *daos.TokenDao
+	pairDao      *daos.PairDao
+	relayerDao   *daos.RelayerDao
+	migrationDao *daos.MigrationDao
 }
 
 // NewRelayerService returns a new instance of orderservice
@@ -27,15 +33,71 @@ func NewRelayerService(
 	tokenDao *daos.TokenDao,
 	pairDao *daos.PairDao,
 	relayerDao *daos.RelayerDao,
+	migrationDao *daos.MigrationDao,
 ) *RelayerService {
 	return &RelayerService{
 		relaye,
 		tokenDao,
 		pairDao,
 		relayerDao,
+		migrationDao,
+	}
+}
+
+// Init build the unique index of relayer domains once duplicated domains are removed
+// the api keeps running without the index, updates of metadata still check that a domain is not used
+func (s *RelayerService) Init() {
+	if err := s.clearDuplicateDomains(); err != nil {
+		logger.Error("Failed to clear duplicated relayer domains:", err)
+	}
+	if err := s.relayerDao.EnsureDomainIndex(); err != nil {
+		logger.Error("Failed to build relayer domain index:", err)
 	}
 }
 
+// clearDuplicateDomains report domains shared by several active relayers, edited by hand before they were checked
+// the domain is kept by the relayer updated last and cleared from the others, once
+func (s *RelayerService) clearDuplicateDomains() error {
+	applied, err := s.migrationDao.IsApplied(relayerDomainMigration)
+	if err != nil || applied {
+		return err
+	}
+	relayers, err := s.relayerDao.GetAll()
+	if err != nil {
+		return err
+	}
+	for _, address := range getDuplicateDomainRelayers(relayers) {
+		logger.Warning("Clear domain shared with another relayer of relayer", address.Hex())
+		if err := s.relayerDao.ClearDomainByAddress(address); err != nil {
+			return err
+		}
+	}
+	return s.migrationDao.MarkApplied(relayerDomainMigration)
+}
+
+// getDuplicateDomainRelayers get active relayers whose domain is also the domain of an active relayer updated later
+func getDuplicateDomainRelayers(relayers []types.Relayer) []common.Address {
+	last := make(map[string]types.Relayer)
+	for _, r := range relayers {
+		if r.Domain == "" || r.Resign {
+			continue
+		}
+		if l, ok := last[r.Domain]; !ok || r.UpdatedAt.After(l.UpdatedAt) {
+			last[r.Domain] = r
+		}
+	}
+	res := []common.Address{}
+	for _, r := range relayers {
+		if r.Domain == "" || r.Resign {
+			continue
+		}
+		if last[r.Domain].Address != r.Address {
+			res = append(res, r.Address)
+		}
+	}
+	return res
+}
+
 func (s *RelayerService) GetByAddress(addr common.Address) (*types.Relayer, error) {
 	return s.relayerDao.GetByAddress(addr)
 }
@@ -44,6 +106,37 @@ func (s *RelayerService) UpdateNameByAddress(addr common.Address, name string, u
 	return s.relayerDao.UpdateNameByAddress(addr, name, url)
 }
 
+// GetAll get relayers with their admin edited information
+func (s *RelayerService) GetAll() ([]types.Relayer, error) {
+	return s.relayerDao.GetAll()
+}
+
+// UpdateMetadata update the name, domain, logo and description of relayer set in m, nil if relayer is not found
+// the domain resolves the relayer of requests from their host, it can not be used by another relayer
+// the unique domain index rejects concurrent updates setting the same domain
+func (s *RelayerService) UpdateMetadata(addr common.Address, m *types.RelayerMetadata) (*types.Relayer, error) {
+	relayer, err := s.relayerDao.GetByAddress(addr)
+	if err != nil || relayer == nil {
+		return nil, err
+	}
+	if m.Domain != nil && *m.Domain != "" {
+		other, err := s.relayerDao.GetByHost(*m.Domain)
+		if err != nil {
+			return nil, err
+		}
+		if other != nil && other.Address.Hex() != addr.Hex() {
+			return nil, ErrRelayerDomainUsed
+		}
+	}
+	if err := s.relayerDao.UpdateMetadataByAddress(addr, m); err != nil {
+		if mgo.IsDup(err) {
+			return nil, ErrRelayerDomainUsed
+		}
+		return nil, err
+	}
+	return s.relayerDao.GetByAddress(addr)
+}
+
 func (s *RelayerService) GetRelayerAddress(r *http.Request) common.Address {
 	v := r.URL.Query()
 	relayerAddress := v.Get("relayerAddress")
@@ -133,22 +226,27 @@ func (s *RelayerService) updateRelayers(relayerInfos []*relayer.RInfo, lendingRe
 			}
 		}
 		lendingFee := uint16(0)
+		var lendingInfo *relayer.LendingRInfo
 		for _, l := range lendingRelayerInfos {
 			if l.Address.Hex() == r.Address.Hex() {
 				lendingFee = l.Fee
+				lendingInfo = l
 				break
 			}
 		}
+		lendingPairs, collateralTokens := getRelayerLendingInfo(lendingInfo)
 		relayer := &types.Relayer{
-			RID:        r.RID,
-			Owner:      r.Owner,
-			Deposit:    r.Deposit,
-			Address:    r.Address,
-			Resign:     r.Resign,
-			LockTime:   r.LockTime,
-			MakeFee:    big.NewInt(int64(r.MakeFee)),
-			TakeFee:    big.NewInt(int64(r.TakeFee)),
-			LendingFee: big.NewInt(int64(lendingFee)),
+			RID:              r.RID,
+			Owner:            r.Owner,
+			Deposit:          r.Deposit,
+			Address:          r.Address,
+			Resign:           r.Resign,
+			LockTime:         r.LockTime,
+			MakeFee:          big.NewInt(int64(r.MakeFee)),
+			TakeFee:          big.NewInt(int64(r.TakeFee)),
+			LendingFee:       big.NewInt(int64(lendingFee)),
+			LendingPairs:     lendingPairs,
+			CollateralTokens: collateralTokens,
 		}
 		if !found {
 			fmt.Println("Create relayer:", r.Address.Hex())
@@ -174,7 +272,7 @@ func (s *RelayerService) updateRelayers(relayerInfos []*relayer.RInfo, lendingRe
 			}
 		}
 		if !found {
-			fmt.Println("Delete relayer:", r.Address.Hex)
+			fmt.Println("Delete relayer:", r.Address.Hex())
 			err = s.relayerDao.DeleteByAddress(r.Address)
 			if err != nil {
 
@@ -196,16 +294,19 @@ func (s *RelayerService) updateRelayer(relayerInfo *relayer.RInfo, lendingRelaye
 	}
 
 	lendingFee := lendingRelayerInfo.Fee
+	lendingPairs, collateralTokens := getRelayerLendingInfo(lendingRelayerInfo)
 	relayer := &types.Relayer{
-		RID:        relayerInfo.RID,
-		Owner:      relayerInfo.Owner,
-		Deposit:    relayerInfo.Deposit,
-		Address:    relayerInfo.Address,
-		Resign:     relayerInfo.Resign,
-		LockTime:   relayerInfo.LockTime,
-		MakeFee:    big.NewInt(int64(relayerInfo.MakeFee)),
-		TakeFee:    big.NewInt(int64(relayerInfo.TakeFee)),
-		LendingFee: big.NewInt(int64(lendingFee)),
+		RID:              relayerInfo.RID,
+		Owner:            relayerInfo.Owner,
+		Deposit:          relayerInfo.Deposit,
+		Address:          relayerInfo.Address,
+		Resign:           relayerInfo.Resign,
+		LockTime:         relayerInfo.LockTime,
+		MakeFee:          big.NewInt(int64(relayerInfo.MakeFee)),
+		TakeFee:          big.NewInt(int64(relayerInfo.TakeFee)),
+		LendingFee:       big.NewInt(int64(lendingFee)),
+		LendingPairs:     lendingPairs,
+		CollateralTokens: collateralTokens,
 	}
 
 	if !found {
@@ -225,6 +326,36 @@ func (s *RelayerService) updateRelayer(relayerInfo *relayer.RInfo, lendingRelaye
 	return nil
 }
 
+// getRelayerLendingInfo get lending pairs and collateral tokens of lending relayer info, nil if relayer does not lend
+func getRelayerLendingInfo(info *relayer.LendingRInfo) ([]*types.RelayerLendingPair, []*types.RelayerToken) {
+	if info == nil {
+		return nil, nil
+	}
+	var pairs []*types.RelayerLendingPair
+	for _, p := range info.LendingPairs {
+		pair := &types.RelayerLendingPair{
+			Term:         p.Term,
+			LendingToken: p.LendingToken,
+		}
+		if t, ok := info.LendingTokens[p.LendingToken]; ok {
+			pair.LendingTokenSymbol = t.Symbol
+		}
+		pairs = append(pairs, pair)
+	}
+	var collaterals []*types.RelayerToken
+	for address, t := range info.ColateralTokens {
+		collaterals = append(collaterals, &types.RelayerToken{
+			Address:  address,
+			Symbol:   t.Symbol,
+			Decimals: int(t.Decimals),
+		})
+	}
+	sort.Slice(collaterals, func(i, j int) bool {
+		return collaterals[i].Symbol < collaterals[j].Symbol
+	})
+	return pairs, collaterals
+}
+
 func (s *RelayerService) updateTokenRelayer(relayerInfo *relayer.RInfo) error {
 	currentTokens, err := s.tokenDao.GetAllByCoinbase(relayerInfo.Address)
 	if err != nil {
@@ -265,7 +396,7 @@ func (s *RelayerService) updateTokenRelayer(relayerInfo *relayer.RInfo) error {
 				}
 			}
 			if !found {
-				fmt.Println("Delete Token:", ctoken.ContractAddress.Hex)
+				fmt.Println("Delete Token:", ctoken.ContractAddress.Hex())
 				err = s.tokenDao.DeleteByTokenAndCoinbase(ctoken.ContractAddress, relayerInfo.Address)
 				if err != nil {
 
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/tomochain/tomox-stats/daos"
+	"github.com/tomochain/tomox-stats/types"
+	"github.com/tomochain/tomox-stats/utils"
+)
+
+const (
+	// RelayerSortVolume sort relayers by 24h volume
+	RelayerSortVolume = "volume"
+	// RelayerSortDeposit sort relayers by deposit
+	RelayerSortDeposit = "deposit"
+
+	// relayerDirectoryTimeLife is the number of seconds the relayer directory is cached
+	relayerDirectoryTimeLife = 60
+)
+
+// RelayerDirectoryService lists registered relayers with their listings and trading activity
+// the directory is built with one query by collection and one walk of time frames, then cached
+type RelayerDirectoryService struct {
+	relayerDao   *daos.RelayerDao
+	pairDao      *daos.PairDao
+	tokenDao     *daos.TokenDao
+	tradeService *TradeService
+	priceService *PriceService
+	relayers     []*types.RelayerSummary
+	timelife     int64
+	mutex        sync.Mutex
+}
+
+// NewRelayerDirectoryService init new instance
+func NewRelayerDirectoryService(relayerDao *daos.RelayerDao, pairDao *daos.PairDao, tokenDao *daos.TokenDao, tradeService *TradeService, priceService *PriceService) *RelayerDirectoryService {
+	return &RelayerDirectoryService{
+		relayerDao:   relayerDao,
+		pairDao:      pairDao,
+		tokenDao:     tokenDao,
+		tradeService: tradeService,
+		priceService: priceService,
+	}
+}
+
+// IsValidSort check sortBy param of relayer directory, empty for volume
+func (s *RelayerDirectoryService) IsValidSort(sortBy string) bool {
+	return sortBy == "" || sortBy == RelayerSortVolume || sortBy == RelayerSortDeposit
+}
+
+// GetRelayers get every registered relayer sorted by 24h volume or deposit, from the highest
+func (s *RelayerDirectoryService) GetRelayers(sortBy string) ([]*types.RelayerSummary, error) {
+	relayers, err := s.getRelayers()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*types.RelayerSummary, len(relayers))
+	copy(res, relayers)
+	sort.SliceStable(res, func(i, j int) bool {
+		if sortBy == RelayerSortDeposit {
+			if cmp := compareDeposit(res[i].Deposit, res[j].Deposit); cmp != 0 {
+				return cmp > 0
+			}
+		} else if cmp := ParseUSD(res[i].Volume24h.TotalVolumeUSD).Cmp(ParseUSD(res[j].Volume24h.TotalVolumeUSD)); cmp != 0 {
+			return cmp > 0
+		}
+		return res[i].RID < res[j].RID
+	})
+	return res, nil
+}
+
+// getRelayers get cached relayer summaries, rebuilt when they are older than relayerDirectoryTimeLife
+func (s *RelayerDirectoryService) getRelayers() ([]*types.RelayerSummary, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := time.Now().Unix()
+	if s.relayers != nil && now-s.timelife <= relayerDirectoryTimeLife {
+		return s.relayers, nil
+	}
+	relayers, err := s.relayerDao.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	addresses := []common.Address{}
+	for _, r := range relayers {
+		addresses = append(addresses, r.Address)
+	}
+	pairs, err := s.pairDao.GetAllByCoinbases(addresses)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := s.tokenDao.GetAllByCoinbases(addresses)
+	if err != nil {
+		return nil, err
+	}
+	res := buildRelayerSummaries(relayers, pairs, tokens)
+
+	from24h, _ := utils.GetModTime(now-24*60*60, 1, unit)
+	from7d, _ := utils.GetModTime(now-7*24*60*60, 1, unit)
+	from30d, _ := utils.GetModTime(now-30*24*60*60, 1, unit)
+	volumes := s.tradeService.getRelayerWindowVolumes([]int64{from24h, from7d, from30d})
+	for _, summary := range res {
+		windows := volumes[summary.Address]
+		summary.Volume24h = s.getWindowVolume(windows, 0)
+		summary.Volume7d = s.getWindowVolume(windows, 1)
+		summary.Volume30d = s.getWindowVolume(windows, 2)
+	}
+	s.relayers = res
+	s.timelife = now
+	return res, nil
+}
+
+// getWindowVolume value volume of window i in USD, relayers without trade have no window
+func (s *RelayerDirectoryService) getWindowVolume(windows []*relayerWindowVolume, i int) *types.TradeVolume {
+	if i >= len(windows) {
+		return newTradeVolumeUSD(nil, 0, s.priceService)
+	}
+	return newTradeVolumeUSD(windows[i].quoteVolumes, len(windows[i].users), s.priceService)
+}
+
+// buildRelayerSummaries get summaries of relayers with their listed pairs and tokens
+func buildRelayerSummaries(relayers []types.Relayer, pairs []types.Pair, tokens []types.Token) []*types.RelayerSummary {
+	res := []*types.RelayerSummary{}
+	summaries := make(map[common.Address]*types.RelayerSummary)
+	for _, r := range relayers {
+		summary := &types.RelayerSummary{
+			Address:          r.Address,
+			RID:              r.RID,
+			Owner:            r.Owner,
+			Name:             r.Name,
+			Domain:           r.Domain,
+			Logo:             r.Logo,
+			Description:      r.Description,
+			Deposit:          r.Deposit,
+			Resign:           r.Resign,
+			LockTime:         r.LockTime,
+			MakeFee:          r.MakeFee,
+			TakeFee:          r.TakeFee,
+			LendingFee:       r.LendingFee,
+			Pairs:            []*types.RelayerPair{},
+			Tokens:           []*types.RelayerToken{},
+			LendingPairs:     r.LendingPairs,
+			CollateralTokens: r.CollateralTokens,
+		}
+		if summary.LendingPairs == nil {
+			summary.LendingPairs = []*types.RelayerLendingPair{}
+		}
+		if summary.CollateralTokens == nil {
+			summary.CollateralTokens = []*types.RelayerToken{}
+		}
+		summaries[r.Address] = summary
+		res = append(res, summary)
+	}
+	for _, p := range pairs {
+		if summary, ok := summaries[p.RelayerAddress]; ok {
+			summary.Pairs = append(summary.Pairs, &types.RelayerPair{
+				BaseToken:        p.BaseTokenAddress,
+				BaseTokenSymbol:  p.BaseTokenSymbol,
+				QuoteToken:       p.QuoteTokenAddress,
+				QuoteTokenSymbol: p.QuoteTokenSymbol,
+				Active:           p.Active,
+			})
+		}
+	}
+	for _, t := range tokens {
+		if summary, ok := summaries[t.RelayerAddress]; ok {
+			summary.Tokens = append(summary.Tokens, &types.RelayerToken{
+				Address:  t.ContractAddress,
+				Symbol:   t.Symbol,
+				Decimals: t.Decimals,
+			})
+		}
+	}
+	return res
+}
+
+// compareDeposit compare deposits, a missing deposit is the lowest
+func compareDeposit(d1, d2 *big.Int) int {
+	if d1 == nil || d2 == nil {
+		switch {
+		case d1 != nil:
+			return 1
+		case d2 != nil:
+			return -1
+		}
+		return 0
+	}
+	return d1.Cmp(d2)
+}
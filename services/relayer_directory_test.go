@@ -0,0 +1,73 @@
+package services
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/tomochain/tomox-stats/types"
+)
+
+func TestRelayerDirectoryVolumes(t *testing.T) {
+	tradeService, _ := newTestTradeService()
+	priceService := NewPriceService(nil, nil, nil)
+	priceService.unitPrices[testQuoteToken] = big.NewFloat(0.01)
+	s := NewRelayerDirectoryService(nil, nil, nil, tradeService, priceService)
+
+	now := time.Now().Unix()
+	unpricedToken := common.HexToAddress("0x0000000000000000000000000000000000000c02")
+	tradeService.NotifyTrade(newTestTrade(1, now-2*24*60*60, 100, 10, sideBuy))
+	tradeService.NotifyTrade(newTestTrade(2, now-60, 100, 5, sideBuy))
+	unpriced := newTestTrade(3, now-60, 10, 3, sideSell)
+	unpriced.QuoteToken = unpricedToken
+	tradeService.NotifyTrade(unpriced)
+
+	volumes := tradeService.getRelayerWindowVolumes([]int64{now - 24*60*60, now - 7*24*60*60})
+	windows := volumes[testRelayer]
+	if !assert.Len(t, windows, 2) {
+		return
+	}
+	// maker and taker sides of every trade are counted
+	day := s.getWindowVolume(windows, 0)
+	assert.Equal(t, "10.000000", day.TotalVolumeUSD)
+	assert.Equal(t, int64(2), day.Trader.Int64())
+	if assert.Len(t, day.UnpricedVolumes, 1) {
+		assert.Equal(t, unpricedToken, day.UnpricedVolumes[0].QuoteToken)
+		assert.Equal(t, int64(60), day.UnpricedVolumes[0].Volume.Int64())
+	}
+	week := s.getWindowVolume(windows, 1)
+	assert.Equal(t, "30.000000", week.TotalVolumeUSD)
+	// a relayer without trade has empty volumes
+	none := s.getWindowVolume(volumes[common.Address{}], 2)
+	assert.Equal(t, "0.000000", none.TotalVolumeUSD)
+	assert.Equal(t, int64(0), none.Trader.Int64())
+
+	other := common.HexToAddress("0x0000000000000000000000000000000000000e02")
+	summaries := buildRelayerSummaries(
+		[]types.Relayer{{Address: testRelayer, RID: 1}, {Address: other, RID: 2}},
+		[]types.Pair{{RelayerAddress: other, BaseTokenAddress: testBaseToken, QuoteTokenAddress: testQuoteToken}},
+		[]types.Token{{RelayerAddress: testRelayer, ContractAddress: testQuoteToken}},
+	)
+	if assert.Len(t, summaries, 2) {
+		assert.Len(t, summaries[0].Pairs, 0)
+		assert.Len(t, summaries[0].Tokens, 1)
+		assert.Len(t, summaries[1].Pairs, 1)
+		assert.Len(t, summaries[1].Tokens, 0)
+	}
+
+	// cached summaries are sorted without being rebuilt
+	summaries[0].Volume24h = day
+	summaries[1].Volume24h = none
+	summaries[1].Deposit = big.NewInt(1)
+	s.relayers = summaries
+	s.timelife = now
+	res, err := s.GetRelayers(RelayerSortVolume)
+	assert.NoError(t, err)
+	assert.Equal(t, testRelayer, res[0].Address)
+	res, err = s.GetRelayers(RelayerSortDeposit)
+	assert.NoError(t, err)
+	assert.Equal(t, other, res[0].Address)
+	assert.Equal(t, testRelayer, s.relayers[0].Address)
+}
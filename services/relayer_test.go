@@ -0,0 +1,29 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/tomochain/tomox-stats/types"
+)
+
+func TestDuplicateDomainRelayers(t *testing.T) {
+	now := time.Now()
+	first := common.HexToAddress("0x0000000000000000000000000000000000000d01")
+	last := common.HexToAddress("0x0000000000000000000000000000000000000d02")
+	resigned := common.HexToAddress("0x0000000000000000000000000000000000000d03")
+	other := common.HexToAddress("0x0000000000000000000000000000000000000d04")
+	relayers := []types.Relayer{
+		{Address: first, Domain: "dex.io", UpdatedAt: now.Add(-time.Hour)},
+		{Address: last, Domain: "dex.io", UpdatedAt: now},
+		{Address: resigned, Domain: "dex.io", Resign: true, UpdatedAt: now.Add(time.Hour)},
+		{Address: other, Domain: "other.io", UpdatedAt: now},
+		{Address: testRelayer, UpdatedAt: now},
+		{Address: testOtherRelayer, UpdatedAt: now},
+	}
+	// the relayer updated last keeps the domain
+	assert.Equal(t, []common.Address{first}, getDuplicateDomainRelayers(relayers))
+	assert.Len(t, getDuplicateDomainRelayers(relayers[1:]), 0)
+}
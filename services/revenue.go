@@ -0,0 +1,177 @@
+package services
+
+import (
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/tomochain/tomox-stats/types"
+	"github.com/tomochain/tomox-stats/utils"
+)
+
+const (
+	// RevenueIntervalHour group revenue by hour
+	RevenueIntervalHour = "hour"
+	// RevenueIntervalDay group revenue by day
+	RevenueIntervalDay = "day"
+	// RevenueIntervalMonth group revenue by calendar month
+	RevenueIntervalMonth = "month"
+)
+
+// RevenueService computes relayer fee revenue from trading and lending fees
+type RevenueService struct {
+	tradeService        *TradeService
+	lendingTradeService *LendingTradeService
+	priceService        *PriceService
+}
+
+// NewRevenueService init new instance
+func NewRevenueService(tradeService *TradeService, lendingTradeService *LendingTradeService, priceService *PriceService) *RevenueService {
+	return &RevenueService{
+		tradeService:        tradeService,
+		lendingTradeService: lendingTradeService,
+		priceService:        priceService,
+	}
+}
+
+// IsValidInterval check interval param of revenue endpoint
+func (s *RevenueService) IsValidInterval(interval string) bool {
+	return isValidInterval(interval)
+}
+
+// isValidInterval check interval param of time series endpoints, revenue intervals are shared with relayer flows
+func isValidInterval(interval string) bool {
+	return interval == RevenueIntervalHour || interval == RevenueIntervalDay || interval == RevenueIntervalMonth
+}
+
+// getFrameTime get start of the time frame of interval, in UTC
+func getFrameTime(ts int64, interval string) int64 {
+	if interval == RevenueIntervalMonth {
+		t := time.Unix(ts, 0).UTC()
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC).Unix()
+	}
+	modTime, _ := utils.GetModTime(ts, 1, interval)
+	return modTime
+}
+
+// GetRevenue get fees collected by relayer from from to to, by time frame of interval
+func (s *RevenueService) GetRevenue(relayerAddress common.Address, from, to int64, interval string) *types.RelayerRevenue {
+	res := &types.RelayerRevenue{
+		RelayerAddress: relayerAddress,
+		Interval:       interval,
+		From:           from,
+		To:             to,
+		Frames:         []*types.RevenueFrame{},
+	}
+	frames := make(map[int64]*types.RevenueFrame)
+	pairs := make(map[int64]map[string]*types.PairRevenue)
+	quoteTokens := make(map[int64]map[common.Address]*types.TokenRevenue)
+	lendingTokens := make(map[int64]map[common.Address]*types.LendingRevenue)
+	getFrame := func(ts int64) *types.RevenueFrame {
+		frameTime := getFrameTime(ts, interval)
+		frame, ok := frames[frameTime]
+		if !ok {
+			frame = &types.RevenueFrame{
+				TimeStamp:   frameTime,
+				Pairs:       []*types.PairRevenue{},
+				QuoteTokens: []*types.TokenRevenue{},
+				Lending:     []*types.LendingRevenue{},
+			}
+			frames[frameTime] = frame
+			pairs[frameTime] = make(map[string]*types.PairRevenue)
+			quoteTokens[frameTime] = make(map[common.Address]*types.TokenRevenue)
+			lendingTokens[frameTime] = make(map[common.Address]*types.LendingRevenue)
+		}
+		return frame
+	}
+
+	for _, fee := range s.tradeService.GetRelayerFees(relayerAddress, from, to) {
+		frame := getFrame(fee.TimeStamp)
+		key := s.tradeService.getPairString(fee.BaseToken, fee.QuoteToken)
+		pair, ok := pairs[frame.TimeStamp][key]
+		if !ok {
+			pair = &types.PairRevenue{
+				BaseToken:  fee.BaseToken,
+				QuoteToken: fee.QuoteToken,
+				Count:      big.NewInt(0),
+				MakeFee:    big.NewInt(0),
+				TakeFee:    big.NewInt(0),
+			}
+			pairs[frame.TimeStamp][key] = pair
+			frame.Pairs = append(frame.Pairs, pair)
+		}
+		pair.Count = new(big.Int).Add(pair.Count, fee.Count)
+		pair.MakeFee = new(big.Int).Add(pair.MakeFee, fee.MakeFee)
+		pair.TakeFee = new(big.Int).Add(pair.TakeFee, fee.TakeFee)
+
+		quote, ok := quoteTokens[frame.TimeStamp][fee.QuoteToken]
+		if !ok {
+			quote = &types.TokenRevenue{
+				Token:   fee.QuoteToken,
+				MakeFee: big.NewInt(0),
+				TakeFee: big.NewInt(0),
+			}
+			quoteTokens[frame.TimeStamp][fee.QuoteToken] = quote
+			frame.QuoteTokens = append(frame.QuoteTokens, quote)
+		}
+		quote.MakeFee = new(big.Int).Add(quote.MakeFee, fee.MakeFee)
+		quote.TakeFee = new(big.Int).Add(quote.TakeFee, fee.TakeFee)
+	}
+
+	for _, trade := range s.lendingTradeService.GetRelayerLendingFees(relayerAddress, from, to) {
+		frame := getFrame(trade.TimeStamp)
+		lending, ok := lendingTokens[frame.TimeStamp][trade.LendingToken]
+		if !ok {
+			lending = &types.LendingRevenue{
+				LendingToken: trade.LendingToken,
+				Count:        big.NewInt(0),
+				BorrowingFee: big.NewInt(0),
+				InvestingFee: big.NewInt(0),
+			}
+			lendingTokens[frame.TimeStamp][trade.LendingToken] = lending
+			frame.Lending = append(frame.Lending, lending)
+		}
+		lending.Count = new(big.Int).Add(lending.Count, trade.Count)
+		lending.BorrowingFee = new(big.Int).Add(lending.BorrowingFee, trade.BorrowingFee)
+		lending.InvestingFee = new(big.Int).Add(lending.InvestingFee, trade.InvestingFee)
+	}
+
+	totalUSD := NewUSD()
+	for _, frame := range frames {
+		frameUSD := NewUSD()
+		for _, quote := range frame.QuoteTokens {
+			if usd, ok := s.priceService.ToUSD(quote.Token, new(big.Int).Add(quote.MakeFee, quote.TakeFee)); ok {
+				quote.FeeUSD = FormatUSD(usd)
+				frameUSD.Add(frameUSD, usd)
+			}
+		}
+		for _, lending := range frame.Lending {
+			if usd, ok := s.priceService.ToUSD(lending.LendingToken, new(big.Int).Add(lending.BorrowingFee, lending.InvestingFee)); ok {
+				lending.FeeUSD = FormatUSD(usd)
+				frameUSD.Add(frameUSD, usd)
+			}
+		}
+		frame.TotalUSD = FormatUSD(frameUSD)
+		sort.Slice(frame.Pairs, func(i, j int) bool {
+			if frame.Pairs[i].QuoteToken != frame.Pairs[j].QuoteToken {
+				return strings.ToLower(frame.Pairs[i].QuoteToken.Hex()) < strings.ToLower(frame.Pairs[j].QuoteToken.Hex())
+			}
+			return strings.ToLower(frame.Pairs[i].BaseToken.Hex()) < strings.ToLower(frame.Pairs[j].BaseToken.Hex())
+		})
+		sort.Slice(frame.QuoteTokens, func(i, j int) bool {
+			return strings.ToLower(frame.QuoteTokens[i].Token.Hex()) < strings.ToLower(frame.QuoteTokens[j].Token.Hex())
+		})
+		sort.Slice(frame.Lending, func(i, j int) bool {
+			return strings.ToLower(frame.Lending[i].LendingToken.Hex()) < strings.ToLower(frame.Lending[j].LendingToken.Hex())
+		})
+		res.Frames = append(res.Frames, frame)
+		totalUSD.Add(totalUSD, frameUSD)
+	}
+	res.TotalUSD = FormatUSD(totalUSD)
+	sort.Slice(res.Frames, func(i, j int) bool {
+		return res.Frames[i].TimeStamp < res.Frames[j].TimeStamp
+	})
+	return res
+}
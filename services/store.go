@@ -0,0 +1,273 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+	"github.com/tomochain/tomox-stats/app"
+	"github.com/tomochain/tomox-stats/utils"
+)
+
+const (
+	// CacheStoreLevelDB keep cache in an embedded leveldb database
+	CacheStoreLevelDB = "leveldb"
+	// CacheStoreFile keep cache in a json file rewritten on every commit
+	CacheStoreFile = "file"
+
+	// defaultCacheLoadDays is the number of days of time frames loaded at startup if it is not configured
+	defaultCacheLoadDays = 90
+)
+
+// CacheStore persists service caches as key value records
+type CacheStore interface {
+	// Get return nil value if key is not found
+	Get(key string) ([]byte, error)
+	// Iterate call fn in key order on records having prefix, starting from start key
+	Iterate(prefix string, start string, fn func(key string, value []byte) error) error
+	// Commit write all changes of batch atomically
+	Commit(batch *CacheBatch) error
+	Close() error
+}
+
+// CacheBatch changes written to a CacheStore in one commit
+type CacheBatch struct {
+	puts    map[string][]byte
+	deletes map[string]bool
+}
+
+// NewCacheBatch init new instance
+func NewCacheBatch() *CacheBatch {
+	return &CacheBatch{
+		puts:    make(map[string][]byte),
+		deletes: make(map[string]bool),
+	}
+}
+
+// Put set json encoded value of key
+func (b *CacheBatch) Put(key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	delete(b.deletes, key)
+	b.puts[key] = data
+	return nil
+}
+
+// Delete remove key
+func (b *CacheBatch) Delete(key string) {
+	delete(b.puts, key)
+	b.deletes[key] = true
+}
+
+// Len number of changes in batch
+func (b *CacheBatch) Len() int {
+	return len(b.puts) + len(b.deletes)
+}
+
+// NewCacheStore open cache store of kind, name is the base name of the store files
+// leveldb store is the directory <name>.db, file store is the json file <name>.store
+// the json file store is the fallback of a leveldb store which can not be opened
+func NewCacheStore(kind string, name string) (CacheStore, error) {
+	switch kind {
+	case CacheStoreLevelDB, "":
+		store, err := newLevelDBCacheStore(name + ".db")
+		if err != nil {
+			logger.Error("Open leveldb cache store failed, fallback to file store", err)
+			return newFileCacheStore(name + ".store")
+		}
+		return store, nil
+	case CacheStoreFile:
+		return newFileCacheStore(name + ".store")
+	}
+	return nil, fmt.Errorf("Unknown cache store: %s", kind)
+}
+
+// getCacheLoadHorizon get the oldest time frame loaded from cache store at startup
+// defaultCacheLoadDays are loaded unless CacheLoadDays is set, the full history if it is negative
+// buckets more recent than tradeHashRetention are always loaded, an update could revert them
+func getCacheLoadHorizon() int64 {
+	days := app.Config.CacheLoadDays
+	if days < 0 {
+		return 0
+	}
+	if days == 0 {
+		days = defaultCacheLoadDays
+	}
+	period := days * 24 * 60 * 60
+	if period < tradeHashRetention {
+		period = tradeHashRetention
+	}
+	horizon, _ := utils.GetModTime(time.Now().Unix()-period, duration, unit)
+	return horizon
+}
+
+// iterateCacheFrames call fn on time frame records having prefix from cache load horizon
+// records of time frame 0, imported from cache files without time frame, are always loaded
+func iterateCacheFrames(store CacheStore, prefix string, fn func(key string, value []byte) error) error {
+	horizon := getCacheLoadHorizon()
+	if horizon > 0 {
+		legacy := prefix + utils.UintToPaddedString(0)
+		if err := store.Iterate(legacy, legacy, fn); err != nil {
+			return err
+		}
+	}
+	return store.Iterate(prefix, prefix+utils.UintToPaddedString(horizon), fn)
+}
+
+// readCacheFile read json cache file written by previous versions, return false if it does not exist
+func readCacheFile(path string, v interface{}) (bool, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, json.Unmarshal(data, v)
+}
+
+type levelDBCacheStore struct {
+	db *leveldb.DB
+}
+
+func newLevelDBCacheStore(path string) (*levelDBCacheStore, error) {
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &levelDBCacheStore{db}, nil
+}
+
+func (s *levelDBCacheStore) Get(key string) ([]byte, error) {
+	value, err := s.db.Get([]byte(key), nil)
+	if err == leveldb.ErrNotFound {
+		return nil, nil
+	}
+	return value, err
+}
+
+func (s *levelDBCacheStore) Iterate(prefix string, start string, fn func(key string, value []byte) error) error {
+	r := util.BytesPrefix([]byte(prefix))
+	if start > prefix {
+		r.Start = []byte(start)
+	}
+	it := s.db.NewIterator(r, nil)
+	defer it.Release()
+	for it.Next() {
+		if err := fn(string(it.Key()), it.Value()); err != nil {
+			return err
+		}
+	}
+	return it.Error()
+}
+
+func (s *levelDBCacheStore) Commit(batch *CacheBatch) error {
+	b := new(leveldb.Batch)
+	for key := range batch.deletes {
+		b.Delete([]byte(key))
+	}
+	for key, value := range batch.puts {
+		b.Put([]byte(key), value)
+	}
+	return s.db.Write(b, &opt.WriteOptions{Sync: true})
+}
+
+func (s *levelDBCacheStore) Close() error {
+	return s.db.Close()
+}
+
+// fileCacheStore keep all records in memory, each commit rewrites the whole file
+type fileCacheStore struct {
+	path    string
+	records map[string]json.RawMessage
+	mutex   sync.RWMutex
+}
+
+func newFileCacheStore(path string) (*fileCacheStore, error) {
+	s := &fileCacheStore{
+		path:    path,
+		records: make(map[string]json.RawMessage),
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.records); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *fileCacheStore) Get(key string) ([]byte, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.records[key], nil
+}
+
+func (s *fileCacheStore) Iterate(prefix string, start string, fn func(key string, value []byte) error) error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	var keys []string
+	for key := range s.records {
+		if strings.HasPrefix(key, prefix) && key >= start {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := fn(key, s.records[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Commit write to a temporary file then rename it, so a crash never leaves a partial file
+func (s *fileCacheStore) Commit(batch *CacheBatch) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for key := range batch.deletes {
+		delete(s.records, key)
+	}
+	for key, value := range batch.puts {
+		s.records[key] = value
+	}
+	data, err := json.Marshal(s.records)
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+func (s *fileCacheStore) Close() error {
+	return nil
+}
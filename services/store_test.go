@@ -0,0 +1,134 @@
+package services
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tomochain/tomox-stats/app"
+	"github.com/tomochain/tomox-stats/utils"
+)
+
+func newTestCacheStoreDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "cachestore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestCacheStoreRoundTrip(t *testing.T) {
+	for _, kind := range []string{CacheStoreLevelDB, CacheStoreFile} {
+		dir := newTestCacheStoreDir(t)
+		defer os.RemoveAll(dir)
+		name := filepath.Join(dir, "trade")
+
+		store, err := NewCacheStore(kind, name)
+		assert.NoError(t, err, kind)
+		batch := NewCacheBatch()
+		batch.Put("ut/1", 1)
+		batch.Put("ut/2", 2)
+		batch.Put("ut/3", 3)
+		batch.Put("tr/1", 4)
+		assert.NoError(t, store.Commit(batch), kind)
+		batch = NewCacheBatch()
+		batch.Delete("ut/2")
+		batch.Put("ut/3", 5)
+		assert.NoError(t, store.Commit(batch), kind)
+		assert.NoError(t, store.Close(), kind)
+
+		store, err = NewCacheStore(kind, name)
+		assert.NoError(t, err, kind)
+		value, err := store.Get("ut/3")
+		assert.NoError(t, err, kind)
+		assert.Equal(t, "5", string(value), kind)
+		value, err = store.Get("ut/2")
+		assert.NoError(t, err, kind)
+		assert.Nil(t, value, kind)
+
+		var keys []string
+		err = store.Iterate("ut/", "ut/", func(key string, value []byte) error {
+			keys = append(keys, key)
+			return nil
+		})
+		assert.NoError(t, err, kind)
+		assert.Equal(t, []string{"ut/1", "ut/3"}, keys, kind)
+		keys = nil
+		store.Iterate("ut/", "ut/2", func(key string, value []byte) error {
+			keys = append(keys, key)
+			return nil
+		})
+		assert.Equal(t, []string{"ut/3"}, keys, kind)
+		store.Close()
+	}
+}
+
+func TestTradeCacheRoundTrip(t *testing.T) {
+	dir := newTestCacheStoreDir(t)
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "trade")
+	now := time.Now().Unix()
+
+	store, err := NewCacheStore(CacheStoreFile, name)
+	assert.NoError(t, err)
+	s, _ := newTestTradeService()
+	s.store = store
+	// a time frame older than the default 60 days of previous versions, added by the backfill
+	s.addTrade(newTestTrade(1, now-90*24*60*60, 10, 2, sideBuy))
+	s.NotifyTrade(newTestTrade(2, now, 12, 1, sideSell))
+	assert.NoError(t, s.commitCache())
+	store.Close()
+
+	store, err = NewCacheStore(CacheStoreFile, name)
+	assert.NoError(t, err)
+	loaded, _ := newTestTradeService()
+	loaded.store = store
+	assert.NoError(t, loaded.loadCache())
+	assert.Equal(t, getTestTotalVolume(s), getTestTotalVolume(loaded))
+	assert.Equal(t, int64(64), getTestTotalVolume(loaded))
+	assert.Equal(t, now, loaded.tradeCache.lastTime)
+	// the trade still in hash retention is known, its replay is not counted again
+	loaded.NotifyTrade(newTestTrade(2, now, 12, 1, sideSell))
+	assert.Equal(t, int64(64), getTestTotalVolume(loaded))
+}
+
+func TestIterateCacheFrames(t *testing.T) {
+	dir := newTestCacheStoreDir(t)
+	defer os.RemoveAll(dir)
+	store, err := NewCacheStore(CacheStoreFile, filepath.Join(dir, "lending.trade"))
+	assert.NoError(t, err)
+	now := time.Now().Unix()
+	legacy := "lut/" + utils.UintToPaddedString(0) + "/a"
+	ancient := "lut/" + utils.UintToPaddedString(now-365*24*60*60) + "/a"
+	old := "lut/" + utils.UintToPaddedString(now-30*24*60*60) + "/a"
+	recent := "lut/" + utils.UintToPaddedString(now) + "/a"
+	batch := NewCacheBatch()
+	for _, key := range []string{legacy, ancient, old, recent} {
+		batch.Put(key, 1)
+	}
+	assert.NoError(t, store.Commit(batch))
+
+	getKeys := func() []string {
+		var keys []string
+		iterateCacheFrames(store, "lut/", func(key string, value []byte) error {
+			keys = append(keys, key)
+			return nil
+		})
+		return keys
+	}
+	// the default horizon is bounded
+	assert.Equal(t, []string{legacy, old, recent}, getKeys())
+
+	app.Config.CacheLoadDays = -1
+	assert.Equal(t, []string{legacy, ancient, old, recent}, getKeys())
+
+	app.Config.CacheLoadDays = 10
+	defer func() {
+		app.Config.CacheLoadDays = 0
+	}()
+	// time frames without time stamp are loaded whatever the horizon
+	assert.Equal(t, []string{legacy, recent}, getKeys())
+}
@@ -1,20 +1,20 @@
 package services
 
 import (
-	"bufio"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"math"
 	"math/big"
-	"os"
 	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/globalsign/mgo/bson"
+	"github.com/tomochain/tomox-stats/app"
 	"github.com/tomochain/tomox-stats/daos"
 	"github.com/tomochain/tomox-stats/types"
 	"github.com/tomochain/tomox-stats/utils"
@@ -27,109 +27,135 @@ const (
 	sideSell         = "SELL"
 	cacheTimeLifeMax = 15 * 50
 	intervalCrawl    = 60 * 24 * 60 * 60
-)
 
-var e1 = []string{
-	"0xbfc6e92daae38d49a978245e04acc98178770a36",
-	"0x9d62a70c8e3587f0051ad56a111feb738a43103e",
-	"0x0798ca0782fddf1a57d09587cc2dea4c139294f7",
-	"0xab45d2804945a5f443189a435d61808d4659447c",
-	"0xc9c75e98d79a8987d5a0b1ed9e2226e637570587",
-	"0xf17278d65fd53ae68661ab0361fcefb0919b64cf",
-	"0x881e1caae2f97601701c762d73f1c83fd186cf0c",
-	"0x92875d7345d8447eb9cba401caa4959343772336",
-	"0x2a941348945811697d669802d7abaaa4fc544af2",
-	"0xc5352eff365260a640fc237d2c22ccfeb68c44e6",
-	"0x95a05686f861be57823bbb308e2f71fbaab05cc6",
-	"0xb76dbf54d84954f0a5da6f9ea00047541853688c",
-	"0xa1f9950b0673b3df6aecec0e040c835c6d78b51a",
-	"0xe681eeb30bad216e1d679cdfdb7eda5d9c362f9b",
-	"0xd081777a391b9bbb15b0ae1e4103fd18689bdb3c",
-	"0xf17278d65fd53ae68661ab0361fcefb0919b64cf",
-}
-
-var e2 = []string{
-	"0x9d62A70c8E3587f0051AD56A111fEb738a43103E",
-	"0xbfc6e92daae38d49a978245e04acc98178770a36",
-	"0x90cBE91913075dD48ceC8528bD82e47BA15ffab4",
-	"0x62bb16DC0aED004EF877acE866E8f558E6e351A2",
-	"0xab45d2804945a5f443189a435d61808d4659447c",
-	"0xe36dEd7Bc36f60D0Cd6D1A0dEBda6dD999BBf199",
-	"0xf996aE46adad51f2Bb0C0879670CbAe466202E4D",
-	"0x02e45aDF6025553d6df357415FF624d375Abf2B8",
-	"0x717f876aD79773AB3290A2bcf9244B1C8F9602e1",
-	"0xf742536b95B2E3bCfC48543AE93d4Ebf93f3c537",
-	"0xB76dbf54d84954F0A5DA6F9EA00047541853688c",
-	"0x95a05686f861be57823bbb308e2f71fbaab05cc6",
-	"0x0798ca0782fddF1a57d09587CC2Dea4C139294f7",
-	"0x0798ca0782fddF1a57d09587CC2Dea4C139294f7",
-	"0xe04AEc262fBbc434cF1781c49d4236914765a33b",
-	"0xab45d2804945a5f443189a435d61808d4659447c",
-}
-
-var bot = []string{
-	"0x9E7c130D6EA105450dD8DdD51dd6fAB4b5c955d7",
-	"0xF8ec1939AF7F37F53156d554fe8187E2Cdf4A060",
-	"0x0F8469Ead31Ffd4b6b504dba35B0Cf3C8DFf4e14",
-	"0x569874387d94F9efF87Bbe1e94f2308681F64223",
-	"0x01F294DBdD3207fE86Af1b68a697dA4d1296B75B",
-
-	"0x0cd9d70a38b71ed056e5d20fef6e3c9d2f6bc253",
-	"0xc923f5f834b45674bac07c85f0328fa713de3fe2",
-	"0xf742536b95b2e3bcfc48543ae93d4ebf93f3c537",
-	"0xf742536b95b2e3bcfc48543ae93d4ebf93f3c537",
-	"0xf996ae46adad51f2bb0c0879670cbae466202e4d",
-
-	"0x988cE4471422Af884Ef42c7d6dd6B6986F1Eaf80",
-	"0xA13948aD209FD8967da18B7c2E4841133f725F01",
-	"0xa9E590c7B76be6f473a308669B9373199219581C",
-	"0xa2191f5aeA9aCAF5875E5b42A6DbA378C5f0fbed",
-	"0xC65Ac99fC0c96330504B733EB01e9d58dAD93FA6",
-
-	"0x90CA4896779C8BC4cAf9085Cfc73F54B17Bc0e09",
-	"0x02e45aDF6025553d6df357415FF624d375Abf2B8",
-	"0x42224E7404D0c037B6D7aB7027A74b22f3190302",
-	"0x0BDbC4E0e19CE1C8129612CcD1EB4eBa94FE064B",
-	"0xF8032b6ef6843cE4938EB742742F4cB5C91236A0",
-
-	"0x548bA11486a2bC522Ab864773a5fC6Ffd9777A1f",
-	"0xb10010Bf1C3AA108170e1C3fE2662B8a462E6040",
-	"0x859695a2C648014a31d307e73CF688e009317F9E",
-	"0x717f876aD79773AB3290A2bcf9244B1C8F9602e1",
-	"0x628Cd98302aBD2Cf30a8e54539410D726Bb0e5F6",
-
-	"0xc683608c1125717F0C459442d5830DDCd321704C",
-	"0x95A47f00F14AEbE3C893F6978957af036Aae4627",
-	"0xe92a0B625E8Be02AD88918B67d752274737Db09b",
-	"0x0bF9BDEE7d4f572033187D001dEc079331D833Ed",
-	"0xa46fbe3Bf444ffFb8BDAd3F682bda5cBec7F0ebC",
-}
+	watchRetryInterval = 5 * time.Second
+	operationDelete    = "delete"
+	// tradeHashRetention is how long added trades are remembered to detect updates
+	tradeHashRetention = 7 * 24 * 60 * 60
+
+	tradeStateIgnored = 0
+	tradeStatePending = 1
+	tradeStateCounted = 2
+
+	// tradeCacheFile is the json cache of previous versions, imported once into the cache store
+	tradeCacheFile = "trade.cache"
+
+	// cache store keys, time frames are padded so that buckets are sorted by time
+	tradeStoreMeta             = "meta"
+	tradeStoreUserTrade        = "ut/"
+	tradeStoreRelayerUserTrade = "rut/"
+	tradeStoreRelayerFee       = "rfe/"
+	tradeStoreRelayerFlow      = "rfl/"
+	tradeStoreTrade            = "tr/"
+)
 
 // TradeService struct with daos required, responsible for communicating with daos.
 // TradeService functions are responsible for interacting with daos and implements business logics.
 type TradeService struct {
-	tradeDao      *daos.TradeDao
-	tokenDao      *daos.TokenDao
-	tradeCache    *tradeCache
-	tokenCache    map[common.Address]*tokenCache
+	tradeDao   *daos.TradeDao
+	tokenDao   *daos.TokenDao
+	tradeCache *tradeCache
+	store      CacheStore
+	// wash groups and bot addresses the volume is computed with
+	addressIndex *AddressIndex
+	tokenCache   map[common.Address]*tokenCache
+	// pairAddress => price of the latest counted trade
 	lastPairPrice map[string]*big.Int
-	mutex         sync.RWMutex
+	// pairAddress => time of the latest counted trade
+	lastPairTime map[string]int64
+	notifiers    []TradeNotifier
+	// trade statuses counted in volume
+	tradeStatuses map[string]bool
+	// rebuild of relayer statistics running, nil if none
+	rebuild *relayerStatsRebuild
+	mutex   sync.RWMutex
+}
+
+// TradeNotifier is implemented by services consuming the trade change stream
+// notifiers are called under TradeService lock, a trade may be notified again after a backfill
+type TradeNotifier interface {
+	// NotifyTrade is called when a trade is counted in volume
+	NotifyTrade(trade *types.Trade) error
+	// NotifyRevertTrade is called with the counted version of a trade when it is updated or deleted
+	// the updated trade is notified again if it is still counted
+	NotifyRevertTrade(trade *types.Trade) error
 }
 
 type tradeCache struct {
 	lastTime int64
+	// resumeToken of the last trade change event applied to cache
+	resumeToken *bson.Raw
+	// relayerFeesFetched is false until relayer fees of trades counted before they were tracked are fetched
+	relayerFeesFetched bool
+	// relayerFlowsFetched is false until relayer flows of trades counted before they were tracked are fetched
+	relayerFlowsFetched bool
 	// pairAddress => userAddress =>  time => UserTrade
 	userTrades map[string]map[common.Address]map[int64]*types.UserTrade
 	// relayerAddress => pairAddress => time => RelayerTrade
 	relayerTrades map[common.Address]map[string]map[int64]*types.RelayerTrade
 	// relayerAddress => pairAddress => userAddress => time => UserTrade
 	relayerUserTrades map[common.Address]map[string]map[common.Address]map[int64]*types.UserTrade
+	// tradeHash => trade added to cache
+	trades map[common.Hash]*types.Trade
+	// tradeID => tradeHash, delete change event only has document id
+	tradeIDs map[bson.ObjectId]common.Hash
+	// relayerAddress => pairAddress => PendingTrade, empty relayer address for all relayers
+	pendingTrades map[common.Address]map[string]*types.PendingTrade
+	// relayerAddress => pairAddress => time => RelayerFee
+	relayerFees map[common.Address]map[string]map[int64]*types.RelayerFee
+	// pairAddress => makerRelayer::takerRelayer => time => RelayerFlow
+	relayerFlows map[string]map[string]map[int64]*types.RelayerFlow
+	// store key => user trade changed since last commit, nil if removed
+	dirtyUserTrades map[string]*types.UserTrade
+	// store key => relayer fee changed since last commit, nil if removed
+	dirtyRelayerFees map[string]*types.RelayerFee
+	// store key => relayer flow changed since last commit, nil if removed
+	dirtyRelayerFlows map[string]*types.RelayerFlow
+	// tradeHash => true if trade is changed since last commit
+	dirtyTrades map[common.Hash]bool
+}
+
+// tradeRecord is the part of a trade needed to revert it from cache
+type tradeRecord struct {
+	ID             bson.ObjectId  `json:"id"`
+	Hash           common.Hash    `json:"hash"`
+	Maker          common.Address `json:"maker"`
+	Taker          common.Address `json:"taker"`
+	BaseToken      common.Address `json:"baseToken"`
+	QuoteToken     common.Address `json:"quoteToken"`
+	PricePoint     *big.Int       `json:"pricepoint"`
+	Amount         *big.Int       `json:"amount"`
+	Status         string         `json:"status"`
+	TakerOrderSide string         `json:"takerOrderSide"`
+	MakerExchange  common.Address `json:"makerExchange"`
+	TakerExchange  common.Address `json:"takerExchange"`
+	CreatedAt      time.Time      `json:"createdAt"`
+}
+
+type tradeSide struct {
+	userAddress common.Address
+	bid         bool
+	ask         bool
+	maker       bool
+	taker       bool
+}
+
+// tradeStoreMetadata is committed with every batch, so that it matches the stored buckets
+type tradeStoreMetadata struct {
+	LastTime    int64     `json:"lastTime"`
+	ResumeToken *bson.Raw `json:"resumeToken,omitempty"`
+	// RelayerFees is false for stores written before relayer fees were tracked
+	RelayerFees bool `json:"relayerFees"`
+	// RelayerFlows is false for stores written before relayer flows were tracked
+	RelayerFlows bool `json:"relayerFlows"`
 }
 
 type cachetradefile struct {
 	LastTime          int64              `json:"lastTime"`
+	ResumeToken       *bson.Raw          `json:"resumeToken,omitempty"`
 	UserTrades        []*types.UserTrade `json:"userTrades"`
 	RelayerUserTrades []*types.UserTrade `json:"relayerUserTrades"`
+	Trades            []*tradeRecord     `json:"trades"`
 }
 type tokenCache struct {
 	token    *types.Token
@@ -137,22 +163,50 @@ type tokenCache struct {
 }
 
 // NewTradeService init new instance
-func NewTradeService(tokenDao *daos.TokenDao, tradeDao *daos.TradeDao) *TradeService {
+func NewTradeService(tokenDao *daos.TokenDao, tradeDao *daos.TradeDao, addressListService *AddressListService, store CacheStore) *TradeService {
 
 	cache := &tradeCache{
 		userTrades:        make(map[string]map[common.Address]map[int64]*types.UserTrade),
 		relayerTrades:     make(map[common.Address]map[string]map[int64]*types.RelayerTrade),
 		relayerUserTrades: make(map[common.Address]map[string]map[common.Address]map[int64]*types.UserTrade),
+		trades:            make(map[common.Hash]*types.Trade),
+		tradeIDs:          make(map[bson.ObjectId]common.Hash),
+		pendingTrades:     make(map[common.Address]map[string]*types.PendingTrade),
+		relayerFees:       make(map[common.Address]map[string]map[int64]*types.RelayerFee),
+		dirtyUserTrades:   make(map[string]*types.UserTrade),
+		dirtyRelayerFees:  make(map[string]*types.RelayerFee),
+		relayerFlows:      make(map[string]map[string]map[int64]*types.RelayerFlow),
+		dirtyRelayerFlows: make(map[string]*types.RelayerFlow),
+		dirtyTrades:       make(map[common.Hash]bool),
+		// an empty cache counts relayer fees and flows of all its trades
+		relayerFeesFetched:  true,
+		relayerFlowsFetched: true,
 	}
 	return &TradeService{
 		tokenDao:      tokenDao,
 		tradeDao:      tradeDao,
 		tradeCache:    cache,
+		store:         store,
+		addressIndex:  addressListService.GetIndex(),
 		tokenCache:    make(map[common.Address]*tokenCache),
 		lastPairPrice: make(map[string]*big.Int),
+		lastPairTime:  make(map[string]int64),
+		tradeStatuses: newTradeStatuses(),
 	}
 }
 
+// newTradeStatuses get trade statuses counted in volume from config
+func newTradeStatuses() map[string]bool {
+	tradeStatuses := make(map[string]bool)
+	for _, status := range app.Config.TradeStatuses {
+		tradeStatuses[strings.ToUpper(status)] = true
+	}
+	if len(tradeStatuses) == 0 {
+		tradeStatuses[types.TradeStatusSuccess] = true
+	}
+	return tradeStatuses
+}
+
 func (s *TradeService) getTokenByAddress(token common.Address) (*types.Token, error) {
 	now := time.Now().Unix()
 	if tokenCache, ok := s.tokenCache[token]; ok {
@@ -172,9 +226,26 @@ func (s *TradeService) getTokenByAddress(token common.Address) (*types.Token, er
 }
 
 // WatchChanges watch trade record insert/update
+// the stream is resumed from the last processed event after restart or stream failure
 func (s *TradeService) WatchChanges() {
+	for {
+		s.watchChanges()
+		time.Sleep(watchRetryInterval)
+	}
+}
 
-	ct, sc, err := s.tradeDao.Watch()
+func (s *TradeService) watchChanges() {
+	s.mutex.RLock()
+	resumeToken := s.tradeCache.resumeToken
+	s.mutex.RUnlock()
+
+	ct, sc, err := s.tradeDao.Watch(resumeToken)
+	if err != nil && resumeToken != nil {
+		logger.Warning("Failed to resume change stream, backfill from last trade time:", err)
+		sc.Close()
+		resumeToken = nil
+		ct, sc, err = s.tradeDao.Watch(nil)
+	}
 
 	defer sc.Close()
 	if err != nil {
@@ -182,6 +253,14 @@ func (s *TradeService) WatchChanges() {
 		return
 	}
 	defer ct.Close()
+
+	// the stream is opened before backfill so that no trade is missed in between
+	if resumeToken == nil {
+		s.mutex.RLock()
+		lastTime := s.tradeCache.lastTime
+		s.mutex.RUnlock()
+		s.fetch(lastTime, time.Now().Unix()+1)
+	}
 	ctx := context.Background()
 
 	//Handling change stream in a cycle
@@ -199,12 +278,28 @@ func (s *TradeService) WatchChanges() {
 			//if item from the stream un-marshaled successfully, do something with it
 			if ok {
 				logger.Debugf("Operation Type: %s", ev.OperationType)
-				s.NotifyTrade(ev.FullDocument)
+				if ev.OperationType == operationDelete {
+					if id, ok := ev.DocumentKey["_id"].(bson.ObjectId); ok {
+						s.NotifyDeleteTrade(id)
+					}
+				} else {
+					s.NotifyTrade(ev.FullDocument)
+				}
+				s.setResumeToken(ct.ResumeToken())
+			} else if err := ct.Err(); err != nil {
+				logger.Error("Trade change stream failed:", err)
+				return
 			}
 		}
 	}
 }
 
+func (s *TradeService) setResumeToken(resumeToken *bson.Raw) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.tradeCache.resumeToken = resumeToken
+}
+
 // NotifyTrade handle trade insert/update db trigger
 func (s *TradeService) NotifyTrade(trade *types.Trade) error {
 	if trade == nil {
@@ -213,24 +308,242 @@ func (s *TradeService) NotifyTrade(trade *types.Trade) error {
 	}
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
+	if _, ok := s.tradeCache.trades[trade.Hash]; !ok && trade.CreatedAt.Unix() < time.Now().Unix()-tradeHashRetention {
+		// update of a trade added before its hash was pruned
+		return nil
+	}
+	s.addTrade(trade)
+	if trade.CreatedAt.Unix() > s.tradeCache.lastTime {
+		s.tradeCache.lastTime = trade.CreatedAt.Unix()
+	}
+	return nil
+}
+
+// NotifyDeleteTrade revert deleted trade from cache
+func (s *TradeService) NotifyDeleteTrade(id bson.ObjectId) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if hash, ok := s.tradeCache.tradeIDs[id]; ok {
+		s.removeTrade(hash)
+	}
+	return nil
+}
+
+// addTrade add trade to cache once by trade hash, need to be lock
+// an update of a known trade replaces its previous volume if its volume or status has changed
+// notifiers are told about the counted trades reverted and added
+func (s *TradeService) addTrade(trade *types.Trade) {
+	state := s.getTradeState(trade)
+	last, ok := s.tradeCache.trades[trade.Hash]
+	if ok {
+		if s.getTradeState(last) == state && s.isSameTradeVolume(last, trade) {
+			s.tradeCache.trades[trade.Hash] = trade
+			return
+		}
+		s.revertTrade(last)
+	}
+	s.applyTrade(trade, 1)
+	s.tradeCache.trades[trade.Hash] = trade
+	s.tradeCache.tradeIDs[trade.ID] = trade.Hash
+	s.tradeCache.dirtyTrades[trade.Hash] = true
+	if state == tradeStateCounted {
+		s.setLastPairPrice(trade)
+		for _, n := range s.notifiers {
+			if err := n.NotifyTrade(trade); err != nil {
+				logger.Error(err)
+			}
+		}
+	}
+}
+
+// removeTrade revert trade from cache, need to be lock
+func (s *TradeService) removeTrade(hash common.Hash) {
+	trade, ok := s.tradeCache.trades[hash]
+	if !ok {
+		return
+	}
+	s.revertTrade(trade)
+	delete(s.tradeCache.trades, hash)
+	delete(s.tradeCache.tradeIDs, trade.ID)
+	s.tradeCache.dirtyTrades[hash] = true
+}
+
+// revertTrade remove volume of trade added to cache and notify it if it was counted, need to be lock
+func (s *TradeService) revertTrade(trade *types.Trade) {
+	s.applyTrade(trade, -1)
+	if s.getTradeState(trade) != tradeStateCounted {
+		return
+	}
+	for _, n := range s.notifiers {
+		if err := n.NotifyRevertTrade(trade); err != nil {
+			logger.Error(err)
+		}
+	}
 	key := s.getPairString(trade.BaseToken, trade.QuoteToken)
+	if price, ok := s.lastPairPrice[key]; !ok || trade.PricePoint == nil || s.lastPairTime[key] != trade.CreatedAt.Unix() || price.Cmp(trade.PricePoint) != 0 {
+		return
+	}
+	// the last price falls back to the latest other counted trade still in cache
+	var latest *types.Trade
+	for _, t := range s.tradeCache.trades {
+		if t.Hash == trade.Hash || t.BaseToken != trade.BaseToken || t.QuoteToken != trade.QuoteToken || s.getTradeState(t) != tradeStateCounted {
+			continue
+		}
+		if latest == nil || t.CreatedAt.After(latest.CreatedAt) {
+			latest = t
+		}
+	}
+	if latest != nil {
+		s.lastPairPrice[key] = latest.PricePoint
+		s.lastPairTime[key] = latest.CreatedAt.Unix()
+	}
+}
+
+// setLastPairPrice keep the price of the latest counted trade of pair, need to be lock
+func (s *TradeService) setLastPairPrice(trade *types.Trade) {
+	if trade.PricePoint == nil {
+		return
+	}
+	key := s.getPairString(trade.BaseToken, trade.QuoteToken)
+	if _, ok := s.lastPairPrice[key]; ok && trade.CreatedAt.Unix() < s.lastPairTime[key] {
+		return
+	}
 	s.lastPairPrice[key] = trade.PricePoint
-	s.updateRelayerUserTrade(trade)
-	s.updateUserTrade(trade)
-	return nil
+	s.lastPairTime[key] = trade.CreatedAt.Unix()
+}
+
+// applyTrade add signed trade to volume or pending volume depend on its status, need to be lock
+func (s *TradeService) applyTrade(trade *types.Trade, sign int64) {
+	switch s.getTradeState(trade) {
+	case tradeStateCounted:
+		s.updateUserTrade(trade, sign)
+		s.updateRelayerUserTrade(trade, sign)
+		s.updateRelayerFee(s.tradeCache, trade, sign)
+	case tradeStatePending:
+		s.updatePendingTrade(trade, sign)
+	}
+}
+
+func (s *TradeService) getTradeState(trade *types.Trade) int {
+	if s.tradeStatuses[trade.Status] {
+		return tradeStateCounted
+	}
+	if trade.Status == types.TradeStatusPending {
+		return tradeStatePending
+	}
+	return tradeStateIgnored
+}
+
+// updatePendingTrade add signed trade volume to pending volume of maker, taker and all relayers, need to be lock
+func (s *TradeService) updatePendingTrade(trade *types.Trade, sign int64) {
+	key := s.getPairString(trade.BaseToken, trade.QuoteToken)
+	bigSign := big.NewInt(sign)
+	amount := new(big.Int).Mul(trade.Amount, bigSign)
+	volumeByQuote := new(big.Int).Mul(s.getVolumeByQuote(trade.BaseToken, trade.QuoteToken, trade.Amount, trade.PricePoint), bigSign)
+	exchange := make(map[common.Address]bool)
+	exchange[common.Address{}] = true
+	exchange[trade.MakerExchange] = true
+	exchange[trade.TakerExchange] = true
+	for addr := range exchange {
+		if _, ok := s.tradeCache.pendingTrades[addr]; !ok {
+			s.tradeCache.pendingTrades[addr] = make(map[string]*types.PendingTrade)
+		}
+		last, ok := s.tradeCache.pendingTrades[addr][key]
+		if !ok {
+			last = &types.PendingTrade{
+				RelayerAddress: addr,
+				BaseToken:      trade.BaseToken,
+				QuoteToken:     trade.QuoteToken,
+				Count:          big.NewInt(0),
+				Volume:         big.NewInt(0),
+				VolumeByQuote:  big.NewInt(0),
+			}
+			s.tradeCache.pendingTrades[addr][key] = last
+		}
+		last.Count = new(big.Int).Add(last.Count, bigSign)
+		last.Volume = new(big.Int).Add(last.Volume, amount)
+		last.VolumeByQuote = new(big.Int).Add(last.VolumeByQuote, volumeByQuote)
+		if last.Count.Sign() <= 0 {
+			delete(s.tradeCache.pendingTrades[addr], key)
+		}
+	}
+}
+
+func (s *TradeService) isSameTradeVolume(t1, t2 *types.Trade) bool {
+	return t1.Maker == t2.Maker && t1.Taker == t2.Taker &&
+		t1.BaseToken == t2.BaseToken && t1.QuoteToken == t2.QuoteToken &&
+		t1.MakerExchange == t2.MakerExchange && t1.TakerExchange == t2.TakerExchange &&
+		t1.TakerOrderSide == t2.TakerOrderSide && t1.CreatedAt.Unix() == t2.CreatedAt.Unix() &&
+		t1.Amount.Cmp(t2.Amount) == 0 && t1.PricePoint.Cmp(t2.PricePoint) == 0
+}
+
+// pruneTrades forget trades older than tradeHashRetention, need to be lock
+func (s *TradeService) pruneTrades() {
+	horizon := time.Now().Unix() - tradeHashRetention
+	for hash, trade := range s.tradeCache.trades {
+		if trade.CreatedAt.Unix() < horizon {
+			if s.getTradeState(trade) == tradeStatePending {
+				s.updatePendingTrade(trade, -1)
+			}
+			delete(s.tradeCache.trades, hash)
+			delete(s.tradeCache.tradeIDs, trade.ID)
+			s.tradeCache.dirtyTrades[hash] = true
+		}
+	}
+}
+
+// AddNotifier register service to be notified of every new trade
+func (s *TradeService) AddNotifier(n TradeNotifier) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.notifiers = append(s.notifiers, n)
+}
+
+// BackfillNotifier notify n of the counted trades created from fromdate until the last trade of the cache
+// newer trades are notified by the change stream once n is added to notifiers
+// trades known by the cache are notified with their version in cache, the one which will be reverted on update
+func (s *TradeService) BackfillNotifier(n TradeNotifier, fromdate int64) {
+	s.mutex.RLock()
+	todate := s.tradeCache.lastTime + 1
+	s.mutex.RUnlock()
+	pageOffset := 0
+	size := 1000
+	for {
+		trades, err := s.tradeDao.GetTradeByTime(fromdate, todate, pageOffset*size, size)
+		if err != nil || len(trades) == 0 {
+			break
+		}
+		s.mutex.Lock()
+		for _, trade := range trades {
+			if t, ok := s.tradeCache.trades[trade.Hash]; ok {
+				trade = t
+			}
+			if s.getTradeState(trade) != tradeStateCounted {
+				continue
+			}
+			if err := n.NotifyTrade(trade); err != nil {
+				logger.Error(err)
+			}
+		}
+		s.mutex.Unlock()
+		pageOffset = pageOffset + 1
+	}
 }
 
 // Init init cache
 // ensure add current time frame before trade notify come
 func (s *TradeService) Init() {
 	logger.Info("OHLCV init starting...")
-	//now := time.Now().Unix()
-	s.loadCache()
+	if err := s.loadCache(); err != nil {
+		logger.Error("Failed to load trade cache:", err)
+	}
 	if s.tradeCache.lastTime == 0 {
 		s.tradeCache.lastTime = time.Now().Unix() - intervalCrawl
 	}
-	//s.fetch(s.tradeCache.lastTime, now)
-	//s.commitCache()
+	// relayer fees and flows missing from caches written before they were tracked are counted in background
+	if !s.tradeCache.relayerFeesFetched || !s.tradeCache.relayerFlowsFetched {
+		s.rebuildRelayerStats(!s.tradeCache.relayerFeesFetched, !s.tradeCache.relayerFlowsFetched)
+	}
 	ticker := time.NewTicker(60 * time.Second)
 	quit := make(chan struct{})
 	go func() {
@@ -262,9 +575,10 @@ func (s *TradeService) fetch(fromdate int64, todate int64) {
 		}
 		s.mutex.Lock()
 		for _, trade := range trades {
-			s.updateUserTrade(trade)
-			s.updateRelayerUserTrade(trade)
-			s.tradeCache.lastTime = trade.CreatedAt.Unix()
+			s.addTrade(trade)
+			if trade.CreatedAt.Unix() > s.tradeCache.lastTime {
+				s.tradeCache.lastTime = trade.CreatedAt.Unix()
+			}
 
 		}
 		s.mutex.Unlock()
@@ -272,88 +586,448 @@ func (s *TradeService) fetch(fromdate int64, todate int64) {
 	}
 }
 
-func (s *TradeService) flattenUserTrades() []*types.UserTrade {
-	var userTrades []*types.UserTrade
-	for _, tradebyUserAddress := range s.tradeCache.userTrades {
-		for _, tradebyTime := range tradebyUserAddress {
-			for _, trade := range tradebyTime {
-				userTrades = append(userTrades, trade)
-			}
-		}
+func newTradeRecord(trade *types.Trade) *tradeRecord {
+	return &tradeRecord{
+		ID:             trade.ID,
+		Hash:           trade.Hash,
+		Maker:          trade.Maker,
+		Taker:          trade.Taker,
+		BaseToken:      trade.BaseToken,
+		QuoteToken:     trade.QuoteToken,
+		PricePoint:     trade.PricePoint,
+		Amount:         trade.Amount,
+		Status:         trade.Status,
+		TakerOrderSide: trade.TakerOrderSide,
+		MakerExchange:  trade.MakerExchange,
+		TakerExchange:  trade.TakerExchange,
+		CreatedAt:      trade.CreatedAt,
 	}
-	return userTrades
 }
 
-func (s *TradeService) flattenRelayerUserTrades() []*types.UserTrade {
-	var relayerUserTrades []*types.UserTrade
-	for _, tradebyRelayer := range s.tradeCache.relayerUserTrades {
-		for _, tradebyUserAddess := range tradebyRelayer {
-			for _, tradeBytime := range tradebyUserAddess {
-				for _, trade := range tradeBytime {
-					relayerUserTrades = append(relayerUserTrades, trade)
-				}
-			}
-		}
+func (t *tradeRecord) toTrade() *types.Trade {
+	return &types.Trade{
+		ID:             t.ID,
+		Hash:           t.Hash,
+		Maker:          t.Maker,
+		Taker:          t.Taker,
+		BaseToken:      t.BaseToken,
+		QuoteToken:     t.QuoteToken,
+		PricePoint:     t.PricePoint,
+		Amount:         t.Amount,
+		Status:         t.Status,
+		TakerOrderSide: t.TakerOrderSide,
+		MakerExchange:  t.MakerExchange,
+		TakerExchange:  t.TakerExchange,
+		CreatedAt:      t.CreatedAt,
 	}
-	return relayerUserTrades
 }
 
+// commitCache write time frames and trades changed since last commit to cache store
+// the batch is built under lock and written outside of it
 func (s *TradeService) commitCache() error {
 	s.mutex.Lock()
-	defer s.mutex.Unlock()
 	logger.Info("commit trade cache")
-	userTrades := s.flattenUserTrades()
-	relayerUserTrades := s.flattenRelayerUserTrades()
-	cachefile := &cachetradefile{
-		LastTime:          s.tradeCache.lastTime,
-		UserTrades:        userTrades,
-		RelayerUserTrades: relayerUserTrades,
-	}
-	cacheData, err := json.Marshal(cachefile)
-	if err != nil {
-		return err
+	if s.rebuild == nil {
+		s.pruneTrades()
 	}
-	file, err := os.Create("trade.cache")
-	defer file.Close()
+	dirtyUserTrades := s.tradeCache.dirtyUserTrades
+	dirtyRelayerFees := s.tradeCache.dirtyRelayerFees
+	dirtyRelayerFlows := s.tradeCache.dirtyRelayerFlows
+	dirtyTrades := s.tradeCache.dirtyTrades
+	s.tradeCache.dirtyUserTrades = make(map[string]*types.UserTrade)
+	s.tradeCache.dirtyRelayerFees = make(map[string]*types.RelayerFee)
+	s.tradeCache.dirtyRelayerFlows = make(map[string]*types.RelayerFlow)
+	s.tradeCache.dirtyTrades = make(map[common.Hash]bool)
+	batch, err := s.newCommitBatch(dirtyUserTrades, dirtyRelayerFees, dirtyRelayerFlows, dirtyTrades)
+	s.mutex.Unlock()
+
 	if err == nil {
-		_, err = file.Write(cacheData)
-		if err != nil {
-			return err
+		err = s.store.Commit(batch)
+	}
+	if err != nil {
+		// keep changes for next commit, unless they are changed again since
+		s.mutex.Lock()
+		for key, userTrade := range dirtyUserTrades {
+			if _, ok := s.tradeCache.dirtyUserTrades[key]; !ok {
+				s.tradeCache.dirtyUserTrades[key] = userTrade
+			}
+		}
+		for key, fee := range dirtyRelayerFees {
+			if _, ok := s.tradeCache.dirtyRelayerFees[key]; !ok {
+				s.tradeCache.dirtyRelayerFees[key] = fee
+			}
 		}
+		for key, flow := range dirtyRelayerFlows {
+			if _, ok := s.tradeCache.dirtyRelayerFlows[key]; !ok {
+				s.tradeCache.dirtyRelayerFlows[key] = flow
+			}
+		}
+		for hash := range dirtyTrades {
+			s.tradeCache.dirtyTrades[hash] = true
+		}
+		s.mutex.Unlock()
+		return err
 	}
 	return nil
 }
 
+// newCommitBatch need to be lock
+func (s *TradeService) newCommitBatch(dirtyUserTrades map[string]*types.UserTrade, dirtyRelayerFees map[string]*types.RelayerFee, dirtyRelayerFlows map[string]*types.RelayerFlow, dirtyTrades map[common.Hash]bool) (*CacheBatch, error) {
+	batch := NewCacheBatch()
+	for key, userTrade := range dirtyUserTrades {
+		if userTrade == nil {
+			batch.Delete(key)
+		} else if err := batch.Put(key, userTrade); err != nil {
+			return nil, err
+		}
+	}
+	for key, fee := range dirtyRelayerFees {
+		if fee == nil {
+			batch.Delete(key)
+		} else if err := batch.Put(key, fee); err != nil {
+			return nil, err
+		}
+	}
+	for key, flow := range dirtyRelayerFlows {
+		if flow == nil {
+			batch.Delete(key)
+		} else if err := batch.Put(key, flow); err != nil {
+			return nil, err
+		}
+	}
+	for hash := range dirtyTrades {
+		key := tradeStoreTrade + hash.Hex()
+		if trade, ok := s.tradeCache.trades[hash]; ok {
+			if err := batch.Put(key, newTradeRecord(trade)); err != nil {
+				return nil, err
+			}
+		} else {
+			batch.Delete(key)
+		}
+	}
+	meta := &tradeStoreMetadata{
+		LastTime:     s.tradeCache.lastTime,
+		ResumeToken:  s.tradeCache.resumeToken,
+		RelayerFees:  s.tradeCache.relayerFeesFetched,
+		RelayerFlows: s.tradeCache.relayerFlowsFetched,
+	}
+	if err := batch.Put(tradeStoreMeta, meta); err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+// loadCache load time frames from cache load horizon and trades from cache store
+// the json cache file of previous versions is imported if the store is empty
 func (s *TradeService) loadCache() error {
-	file, err := os.Open("trade.cache")
-	defer file.Close()
+	data, err := s.store.Get(tradeStoreMeta)
 	if err != nil {
 		return err
 	}
-	stats, statsErr := file.Stat()
-	if statsErr != nil {
-		return statsErr
+	if data == nil {
+		return s.importCacheFile()
 	}
+	var meta tradeStoreMetadata
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return err
+	}
+	err = iterateCacheFrames(s.store, tradeStoreUserTrade, func(key string, value []byte) error {
+		var userTrade types.UserTrade
+		if err := json.Unmarshal(value, &userTrade); err != nil {
+			return err
+		}
+		s.addUserTrade(&userTrade)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	err = iterateCacheFrames(s.store, tradeStoreRelayerUserTrade, func(key string, value []byte) error {
+		var userTrade types.UserTrade
+		if err := json.Unmarshal(value, &userTrade); err != nil {
+			return err
+		}
+		s.addRelayerUserTrade(&userTrade)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	err = iterateCacheFrames(s.store, tradeStoreRelayerFee, func(key string, value []byte) error {
+		var fee types.RelayerFee
+		if err := json.Unmarshal(value, &fee); err != nil {
+			return err
+		}
+		s.getRelayerFees(s.tradeCache, fee.RelayerAddress, s.getPairString(fee.BaseToken, fee.QuoteToken))[fee.TimeStamp] = &fee
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	err = iterateCacheFrames(s.store, tradeStoreRelayerFlow, func(key string, value []byte) error {
+		var flow types.RelayerFlow
+		if err := json.Unmarshal(value, &flow); err != nil {
+			return err
+		}
+		s.getRelayerFlows(s.tradeCache, flow.BaseToken, flow.QuoteToken, flow.MakerRelayer, flow.TakerRelayer)[flow.TimeStamp] = &flow
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	err = s.store.Iterate(tradeStoreTrade, "", func(key string, value []byte) error {
+		var record tradeRecord
+		if err := json.Unmarshal(value, &record); err != nil {
+			return err
+		}
+		s.restoreTrade(record.toTrade())
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.tradeCache.lastTime = meta.LastTime
+	s.tradeCache.resumeToken = meta.ResumeToken
+	s.tradeCache.relayerFeesFetched = meta.RelayerFees
+	s.tradeCache.relayerFlowsFetched = meta.RelayerFlows
+	return nil
+}
+
+// relayerStatsRebuild relayer fees and/or flows counted again apart from the cache, until they replace its ones
+type relayerStatsRebuild struct {
+	fees  bool
+	flows bool
+	// from is the first time frame counted again, older time frames of the cache are kept
+	from int64
+	// trades created before split are fetched from trade collection, the others are the trades of the cache when the rebuild is applied
+	// the change stream does not update trades older than tradeHashRetention, so their fetched version is the last one
+	split int64
+	stats *tradeCache
+}
+
+func newRelayerStatsRebuild(fees bool, flows bool, from int64, split int64) *relayerStatsRebuild {
+	return &relayerStatsRebuild{
+		fees:  fees,
+		flows: flows,
+		from:  from,
+		split: split,
+		stats: &tradeCache{
+			relayerFees:       make(map[common.Address]map[string]map[int64]*types.RelayerFee),
+			relayerFlows:      make(map[string]map[string]map[int64]*types.RelayerFlow),
+			dirtyRelayerFees:  make(map[string]*types.RelayerFee),
+			dirtyRelayerFlows: make(map[string]*types.RelayerFlow),
+		},
+	}
+}
+
+// RebuildRelayerStats count relayer fees and flows again from the trades of trade collection, in background
+// return false if a rebuild is already running
+func (s *TradeService) RebuildRelayerStats() bool {
+	return s.rebuildRelayerStats(true, true)
+}
+
+// rebuildRelayerStats count relayer fees and/or flows again in background, from the cache load horizon
+// the cache keeps its relayer statistics up to date with the change stream until the rebuilt ones replace them
+// the store metadata is marked unfetched until the rebuild succeeded, so that a failed rebuild is run again on next start
+func (s *TradeService) rebuildRelayerStats(fees bool, flows bool) bool {
+	s.mutex.Lock()
+	if s.rebuild != nil {
+		s.mutex.Unlock()
+		return false
+	}
+	// trades are not pruned until the rebuild is applied, the ones after split must all be in cache
+	s.pruneTrades()
+	split := time.Now().Unix() - tradeHashRetention
+	// newer trades are not fetched yet, they are added to cache by the change stream backfill
+	if s.tradeCache.lastTime+1 < split {
+		split = s.tradeCache.lastTime + 1
+	}
+	rebuild := newRelayerStatsRebuild(fees, flows, getCacheLoadHorizon(), split)
+	s.rebuild = rebuild
+	if fees {
+		s.tradeCache.relayerFeesFetched = false
+	}
+	if flows {
+		s.tradeCache.relayerFlowsFetched = false
+	}
+	s.mutex.Unlock()
+
+	go func() {
+		logger.Info("Rebuild relayer statistics")
+		err := s.fetchRelayerStats(rebuild)
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		s.rebuild = nil
+		if err != nil {
+			logger.Error("Failed to rebuild relayer statistics:", err)
+			return
+		}
+		s.applyRelayerStats(rebuild)
+	}()
+	return true
+}
 
-	size := stats.Size()
-	bytes := make([]byte, size)
-	bufr := bufio.NewReader(file)
-	_, err = bufr.Read(bytes)
+// fetchRelayerStats count relayer statistics of the trades of trade collection created before split of rebuild
+func (s *TradeService) fetchRelayerStats(rebuild *relayerStatsRebuild) error {
+	pageOffset := 0
+	size := 1000
+	for {
+		trades, err := s.tradeDao.GetTradeByTime(rebuild.from, rebuild.split, pageOffset*size, size)
+		if err != nil {
+			return err
+		}
+		if len(trades) == 0 {
+			return nil
+		}
+		s.mutex.Lock()
+		s.countRelayerStats(rebuild, trades)
+		s.mutex.Unlock()
+		pageOffset = pageOffset + 1
+	}
+}
+
+// countRelayerStats add counted trades to rebuilt relayer statistics, need to be lock
+func (s *TradeService) countRelayerStats(rebuild *relayerStatsRebuild, trades []*types.Trade) {
+	for _, trade := range trades {
+		if s.getTradeState(trade) != tradeStateCounted {
+			continue
+		}
+		if rebuild.fees {
+			s.updateRelayerFee(rebuild.stats, trade, 1)
+		}
+		if rebuild.flows && !s.isWashTrade(trade.Maker, trade.Taker) {
+			s.updateRelayerFlow(rebuild.stats, trade, 1)
+		}
+	}
+}
+
+// applyRelayerStats add the trades of cache created since split to rebuilt relayer statistics and replace the ones of cache
+// stored time frames which are not counted again are deleted on next commit, need to be lock
+func (s *TradeService) applyRelayerStats(rebuild *relayerStatsRebuild) {
+	var trades []*types.Trade
+	for _, trade := range s.tradeCache.trades {
+		if trade.CreatedAt.Unix() >= rebuild.split {
+			trades = append(trades, trade)
+		}
+	}
+	s.countRelayerStats(rebuild, trades)
+	if rebuild.fees {
+		for _, feebypair := range s.tradeCache.relayerFees {
+			for _, feebytime := range feebypair {
+				for modTime, fee := range feebytime {
+					key := s.getRelayerFeeStoreKey(modTime, fee.RelayerAddress, fee.BaseToken, fee.QuoteToken)
+					if modTime < rebuild.from {
+						s.getRelayerFees(rebuild.stats, fee.RelayerAddress, s.getPairString(fee.BaseToken, fee.QuoteToken))[modTime] = fee
+					} else if _, ok := rebuild.stats.dirtyRelayerFees[key]; !ok {
+						s.tradeCache.dirtyRelayerFees[key] = nil
+					}
+				}
+			}
+		}
+		for key, fee := range rebuild.stats.dirtyRelayerFees {
+			s.tradeCache.dirtyRelayerFees[key] = fee
+		}
+		s.tradeCache.relayerFees = rebuild.stats.relayerFees
+		s.tradeCache.relayerFeesFetched = true
+	}
+	if rebuild.flows {
+		for _, flowbyrelayer := range s.tradeCache.relayerFlows {
+			for _, flowbytime := range flowbyrelayer {
+				for modTime, flow := range flowbytime {
+					key := s.getRelayerFlowStoreKey(modTime, flow.MakerRelayer, flow.TakerRelayer, flow.BaseToken, flow.QuoteToken)
+					if modTime < rebuild.from {
+						s.getRelayerFlows(rebuild.stats, flow.BaseToken, flow.QuoteToken, flow.MakerRelayer, flow.TakerRelayer)[modTime] = flow
+					} else if _, ok := rebuild.stats.dirtyRelayerFlows[key]; !ok {
+						s.tradeCache.dirtyRelayerFlows[key] = nil
+					}
+				}
+			}
+		}
+		for key, flow := range rebuild.stats.dirtyRelayerFlows {
+			s.tradeCache.dirtyRelayerFlows[key] = flow
+		}
+		s.tradeCache.relayerFlows = rebuild.stats.relayerFlows
+		s.tradeCache.relayerFlowsFetched = true
+	}
+}
+
+// importCacheFile load json cache file and mark all of it to be committed to cache store
+func (s *TradeService) importCacheFile() error {
 	var cache cachetradefile
-	err = json.Unmarshal(bytes, &cache)
-	if err != nil {
+	ok, err := readCacheFile(tradeCacheFile, &cache)
+	if !ok || err != nil {
 		return err
 	}
+	logger.Info("Import trade cache file to cache store")
 	for _, t := range cache.UserTrades {
 		s.addUserTrade(t)
+		s.tradeCache.dirtyUserTrades[s.getUserTradeStoreKey(t.TimeStamp, t.BaseToken, t.QuoteToken, t.UserAddress)] = t
 	}
 	for _, t := range cache.RelayerUserTrades {
 		s.addRelayerUserTrade(t)
+		s.tradeCache.dirtyUserTrades[s.getRelayerUserTradeStoreKey(t.TimeStamp, t.RelayerAddress, t.BaseToken, t.QuoteToken, t.UserAddress)] = t
+	}
+	for _, t := range cache.Trades {
+		trade := t.toTrade()
+		s.restoreTrade(trade)
+		s.tradeCache.dirtyTrades[trade.Hash] = true
 	}
 	s.tradeCache.lastTime = cache.LastTime
+	s.tradeCache.resumeToken = cache.ResumeToken
+	s.seedTrades(cache.LastTime)
+	// cache files have no relayer fees and flows, they are fetched by Init
+	s.tradeCache.relayerFeesFetched = false
+	s.tradeCache.relayerFlowsFetched = false
 	return nil
 }
 
+// seedTrades restore trades counted in the imported cache file and missing from it
+// cache files of previous versions have no trades, without them the backfill from last time
+// and updates of these trades would count them again
+func (s *TradeService) seedTrades(lastTime int64) {
+	pageOffset := 0
+	size := 1000
+	for {
+		trades, err := s.tradeDao.GetTradeByTime(lastTime-tradeHashRetention, lastTime+1, pageOffset*size, size)
+		if err != nil || len(trades) == 0 {
+			break
+		}
+		for _, trade := range trades {
+			if _, ok := s.tradeCache.trades[trade.Hash]; !ok {
+				s.restoreTrade(trade)
+				s.tradeCache.dirtyTrades[trade.Hash] = true
+			}
+		}
+		pageOffset = pageOffset + 1
+	}
+}
+
+// restoreTrade add stored trade, its volume is already in stored time frames
+func (s *TradeService) restoreTrade(trade *types.Trade) {
+	s.tradeCache.trades[trade.Hash] = trade
+	s.tradeCache.tradeIDs[trade.ID] = trade.Hash
+	// pending volume is not stored, rebuild it from trade status
+	if s.getTradeState(trade) == tradeStatePending {
+		s.updatePendingTrade(trade, 1)
+	}
+}
+
+func (s *TradeService) getUserTradeStoreKey(modTime int64, baseToken, quoteToken, userAddress common.Address) string {
+	return fmt.Sprintf("%s%s/%s/%s", tradeStoreUserTrade, utils.UintToPaddedString(modTime), s.getPairString(baseToken, quoteToken), userAddress.Hex())
+}
+
+func (s *TradeService) getRelayerUserTradeStoreKey(modTime int64, relayerAddress, baseToken, quoteToken, userAddress common.Address) string {
+	return fmt.Sprintf("%s%s/%s/%s/%s", tradeStoreRelayerUserTrade, utils.UintToPaddedString(modTime), relayerAddress.Hex(), s.getPairString(baseToken, quoteToken), userAddress.Hex())
+}
+
+func (s *TradeService) getRelayerFlowStoreKey(modTime int64, makerRelayer, takerRelayer, baseToken, quoteToken common.Address) string {
+	return fmt.Sprintf("%s%s/%s/%s/%s", tradeStoreRelayerFlow, utils.UintToPaddedString(modTime), makerRelayer.Hex(), takerRelayer.Hex(), s.getPairString(baseToken, quoteToken))
+}
+
+func (s *TradeService) getRelayerFeeStoreKey(modTime int64, relayerAddress, baseToken, quoteToken common.Address) string {
+	return fmt.Sprintf("%s%s/%s/%s", tradeStoreRelayerFee, utils.UintToPaddedString(modTime), relayerAddress.Hex(), s.getPairString(baseToken, quoteToken))
+}
+
 func (s *TradeService) getPairString(baseToken, quoteToken common.Address) string {
 	return fmt.Sprintf("%s::%s", baseToken.Hex(), quoteToken.Hex())
 }
@@ -376,161 +1050,123 @@ func (s *TradeService) getVolumeByQuote(baseToken, quoteToken common.Address, am
 	return big.NewInt(0)
 }
 
-// updateUserTrade user trade in all relayer
-func (s *TradeService) updateUserTrade(trade *types.Trade) error {
-	tradeTime := trade.CreatedAt.Unix()
+// updateUserTrade add signed trade volume to user trade in all relayer, need to be lock
+// sign is -1 to revert a trade previously added
+func (s *TradeService) updateUserTrade(trade *types.Trade, sign int64) error {
 	key := s.getPairString(trade.BaseToken, trade.QuoteToken)
 	if _, ok := s.tradeCache.userTrades[key]; !ok {
 		s.tradeCache.userTrades[key] = make(map[common.Address]map[int64]*types.UserTrade)
 	}
-
-	if _, ok := s.tradeCache.userTrades[key][trade.Maker]; !ok {
-		s.tradeCache.userTrades[key][trade.Maker] = make(map[int64]*types.UserTrade)
-	}
-	if _, ok := s.tradeCache.userTrades[key][trade.Taker]; !ok {
-		s.tradeCache.userTrades[key][trade.Taker] = make(map[int64]*types.UserTrade)
-	}
-
-	modTime, _ := utils.GetModTime(tradeTime, duration, unit)
-	volumeByQuote := s.getVolumeByQuote(trade.BaseToken, trade.QuoteToken, trade.Amount, trade.PricePoint)
-
-	if trade.Taker.Hex() == trade.Maker.Hex() {
-		if last, ok2 := s.tradeCache.userTrades[key][trade.Taker][modTime]; ok2 {
-			last.Count = last.Count.Add(last.Count, big.NewInt(1))
-			last.Volume = big.NewInt(0).Add(last.Volume, trade.Amount)
-			last.VolumeByQuote = big.NewInt(0).Add(last.VolumeByQuote, volumeByQuote)
-			last.VolumeAsk = big.NewInt(0).Add(last.VolumeAsk, trade.Amount)
-			last.VolumeBid = big.NewInt(0).Add(last.VolumeBid, trade.Amount)
-			last.VolumeAskByQuote = big.NewInt(0).Add(last.VolumeAskByQuote, volumeByQuote)
-			last.VolumeBidByQuote = big.NewInt(0).Add(last.VolumeBidByQuote, volumeByQuote)
-
-		} else {
-			userTrade := &types.UserTrade{
-				UserAddress:      trade.Maker,
-				Count:            big.NewInt(1),
-				Volume:           trade.Amount,
-				VolumeByQuote:    volumeByQuote,
-				VolumeAskByQuote: volumeByQuote,
-				VolumeAsk:        trade.Amount,
-				VolumeBid:        trade.Amount,
-				VolumeBidByQuote: volumeByQuote,
-				BaseToken:        trade.BaseToken,
-				QuoteToken:       trade.QuoteToken,
-				TimeStamp:        modTime,
-			}
-			s.tradeCache.userTrades[key][trade.Taker][modTime] = userTrade
+	for _, side := range s.getTradeSides(trade) {
+		if _, ok := s.tradeCache.userTrades[key][side.userAddress]; !ok {
+			s.tradeCache.userTrades[key][side.userAddress] = make(map[int64]*types.UserTrade)
 		}
-	} else {
-
-		if last, ok2 := s.tradeCache.userTrades[key][trade.Taker][modTime]; ok2 {
-			last.Count = last.Count.Add(last.Count, big.NewInt(1))
-			last.Volume = big.NewInt(0).Add(last.Volume, trade.Amount)
-			last.VolumeByQuote = big.NewInt(0).Add(last.VolumeByQuote, volumeByQuote)
-			if trade.TakerOrderSide == sideBuy {
-				last.VolumeAsk = big.NewInt(0).Add(last.VolumeAsk, trade.Amount)
-				last.VolumeAskByQuote = big.NewInt(0).Add(last.VolumeAskByQuote, volumeByQuote)
-			} else {
-				last.VolumeBid = big.NewInt(0).Add(last.VolumeBid, trade.Amount)
-				last.VolumeBidByQuote = big.NewInt(0).Add(last.VolumeBidByQuote, volumeByQuote)
-			}
+		modTime, userTrade := s.addUserTradeVolume(s.tradeCache.userTrades[key][side.userAddress], trade, side, common.Address{}, sign)
+		s.tradeCache.dirtyUserTrades[s.getUserTradeStoreKey(modTime, trade.BaseToken, trade.QuoteToken, side.userAddress)] = userTrade
+	}
+	return nil
+}
 
-		} else {
-			userTrade := &types.UserTrade{
-				UserAddress:   trade.Taker,
-				Count:         big.NewInt(1),
-				Volume:        trade.Amount,
-				VolumeByQuote: volumeByQuote,
-				BaseToken:     trade.BaseToken,
-				QuoteToken:    trade.QuoteToken,
-				TimeStamp:     modTime,
-			}
-			if trade.TakerOrderSide == sideBuy {
-				userTrade.VolumeBid = trade.Amount
-				userTrade.VolumeBidByQuote = volumeByQuote
-				userTrade.VolumeAsk = big.NewInt(0)
-				userTrade.VolumeAskByQuote = big.NewInt(0)
-			} else {
-				userTrade.VolumeBid = big.NewInt(0)
-				userTrade.VolumeBidByQuote = big.NewInt(0)
-				userTrade.VolumeAsk = trade.Amount
-				userTrade.VolumeAskByQuote = volumeByQuote
-			}
-			s.tradeCache.userTrades[key][trade.Taker][modTime] = userTrade
+// getCountedVolumes get volume by quote of trades counted in relayer volume by trade hash
+// trades with a status which is not counted and wash trades are skipped
+func (s *TradeService) getCountedVolumes(trades []*types.Trade) map[common.Hash]*big.Int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	res := make(map[common.Hash]*big.Int)
+	for _, trade := range trades {
+		if trade.Amount == nil || trade.PricePoint == nil || s.getTradeState(trade) != tradeStateCounted || s.isWashTrade(trade.Maker, trade.Taker) {
+			continue
 		}
+		res[trade.Hash] = s.getVolumeByQuote(trade.BaseToken, trade.QuoteToken, trade.Amount, trade.PricePoint)
+	}
+	return res
+}
 
-		if last, ok2 := s.tradeCache.userTrades[key][trade.Maker][modTime]; ok2 {
-			last.Count = last.Count.Add(last.Count, big.NewInt(1))
-			last.Volume = big.NewInt(0).Add(last.Volume, trade.Amount)
-			last.VolumeByQuote = big.NewInt(0).Add(last.VolumeByQuote, volumeByQuote)
-			if trade.TakerOrderSide == sideSell {
-				last.VolumeAsk = big.NewInt(0).Add(last.VolumeAsk, trade.Amount)
-				last.VolumeAskByQuote = big.NewInt(0).Add(last.VolumeAskByQuote, volumeByQuote)
-			} else {
-				last.VolumeBid = big.NewInt(0).Add(last.VolumeBid, trade.Amount)
-				last.VolumeBidByQuote = big.NewInt(0).Add(last.VolumeBidByQuote, volumeByQuote)
-			}
+// getTradeSides return users of trade with their order side
+func (s *TradeService) getTradeSides(trade *types.Trade) []tradeSide {
+	if trade.Taker.Hex() == trade.Maker.Hex() {
+		return []tradeSide{{userAddress: trade.Taker, bid: true, ask: true, maker: true, taker: true}}
+	}
+	takerBid := trade.TakerOrderSide == sideBuy
+	return []tradeSide{
+		{userAddress: trade.Taker, bid: takerBid, ask: !takerBid, taker: true},
+		{userAddress: trade.Maker, bid: !takerBid, ask: takerBid, maker: true},
+	}
+}
 
-		} else {
-			userTrade := &types.UserTrade{
-				UserAddress:   trade.Maker,
-				Count:         big.NewInt(1),
-				Volume:        trade.Amount,
-				VolumeByQuote: volumeByQuote,
-				BaseToken:     trade.BaseToken,
-				QuoteToken:    trade.QuoteToken,
-				TimeStamp:     modTime,
-			}
-			if trade.TakerOrderSide == sideSell {
-				userTrade.VolumeBid = trade.Amount
-				userTrade.VolumeBidByQuote = volumeByQuote
-				userTrade.VolumeAsk = big.NewInt(0)
-				userTrade.VolumeAskByQuote = big.NewInt(0)
-			} else {
-				userTrade.VolumeBid = big.NewInt(0)
-				userTrade.VolumeBidByQuote = big.NewInt(0)
-				userTrade.VolumeAsk = trade.Amount
-				userTrade.VolumeAskByQuote = volumeByQuote
-			}
-			s.tradeCache.userTrades[key][trade.Maker][modTime] = userTrade
+// addUserTradeVolume add signed trade volume to the time frame of user trades
+// the time frame is removed once all its trades are reverted
+// return the time frame and its user trade, nil if removed
+func (s *TradeService) addUserTradeVolume(userTrades map[int64]*types.UserTrade, trade *types.Trade, side tradeSide, relayerAddress common.Address, sign int64) (int64, *types.UserTrade) {
+	modTime, _ := utils.GetModTime(trade.CreatedAt.Unix(), duration, unit)
+	bigSign := big.NewInt(sign)
+	amount := new(big.Int).Mul(trade.Amount, bigSign)
+	volumeByQuote := new(big.Int).Mul(s.getVolumeByQuote(trade.BaseToken, trade.QuoteToken, trade.Amount, trade.PricePoint), bigSign)
+
+	last, ok := userTrades[modTime]
+	if !ok {
+		last = &types.UserTrade{
+			UserAddress:      side.userAddress,
+			Count:            big.NewInt(0),
+			Volume:           big.NewInt(0),
+			VolumeByQuote:    big.NewInt(0),
+			VolumeAsk:        big.NewInt(0),
+			VolumeBid:        big.NewInt(0),
+			VolumeAskByQuote: big.NewInt(0),
+			VolumeBidByQuote: big.NewInt(0),
+			TimeStamp:        modTime,
+			RelayerAddress:   relayerAddress,
+			BaseToken:        trade.BaseToken,
+			QuoteToken:       trade.QuoteToken,
 		}
-
+		userTrades[modTime] = last
 	}
-	return nil
+	last.Count = new(big.Int).Add(last.Count, bigSign)
+	last.Volume = new(big.Int).Add(last.Volume, amount)
+	last.VolumeByQuote = new(big.Int).Add(last.VolumeByQuote, volumeByQuote)
+	if side.bid {
+		last.VolumeBid = new(big.Int).Add(last.VolumeBid, amount)
+		last.VolumeBidByQuote = new(big.Int).Add(last.VolumeBidByQuote, volumeByQuote)
+	}
+	if side.ask {
+		last.VolumeAsk = new(big.Int).Add(last.VolumeAsk, amount)
+		last.VolumeAskByQuote = new(big.Int).Add(last.VolumeAskByQuote, volumeByQuote)
+	}
+	if side.maker {
+		last.VolumeMaker = addBigInt(last.VolumeMaker, amount)
+	}
+	if side.taker {
+		last.VolumeTaker = addBigInt(last.VolumeTaker, amount)
+	}
+	if last.Count.Sign() <= 0 {
+		delete(userTrades, modTime)
+		return modTime, nil
+	}
+	return modTime, last
 }
+
 func (s *TradeService) isBotAddress(t common.Address) bool {
-	for _, v := range bot {
-		if strings.ToLower(v) == strings.ToLower(t.Hex()) {
-			return true
-		}
-	}
-	return false
+	return s.addressIndex.IsBotAddress(t)
 }
-func (s *TradeService) isWashTrade(t1, t2 common.Address) bool {
-	if t1.Hex() == t2.Hex() {
-		return true
-	}
-	for i, v := range e1 {
-		if strings.ToLower(v) == strings.ToLower(t1.Hex()) {
-			if strings.ToLower(e2[i]) == strings.ToLower(t2.Hex()) {
-				return true
-			}
-		}
-		if strings.ToLower(e2[i]) == strings.ToLower(t1.Hex()) {
-			if strings.ToLower(v) == strings.ToLower(t2.Hex()) {
-				return true
-			}
-		}
 
-	}
-	return false
+func (s *TradeService) isWashTrade(t1, t2 common.Address) bool {
+	return s.addressIndex.IsWashTrade(t1, t2)
 }
 
-// updateRelayerTick update lastest tick, need to be lock
-func (s *TradeService) updateRelayerUserTrade(trade *types.Trade) error {
+// updateRelayerUserTrade add signed trade volume to user trade of maker and taker relayer, need to be lock
+// sign is -1 to revert a trade previously added
+func (s *TradeService) updateRelayerUserTrade(trade *types.Trade, sign int64) error {
 	if s.isWashTrade(trade.Maker, trade.Taker) {
 		return nil
 	}
-	tradeTime := trade.CreatedAt.Unix()
+	s.updateRelayerUserTradeVolume(trade, sign)
+	return nil
+}
+
+// updateRelayerUserTradeVolume add signed trade volume to user trade of maker and taker relayer and to the flow between them
+// without wash trade check, need to be lock
+func (s *TradeService) updateRelayerUserTradeVolume(trade *types.Trade, sign int64) {
+	s.updateRelayerFlow(s.tradeCache, trade, sign)
 	key := s.getPairString(trade.BaseToken, trade.QuoteToken)
 	exchange := make(map[common.Address]bool)
 	exchange[trade.MakerExchange] = true
@@ -542,125 +1178,364 @@ func (s *TradeService) updateRelayerUserTrade(trade *types.Trade) error {
 		if _, ok := s.tradeCache.relayerUserTrades[addr][key]; !ok {
 			s.tradeCache.relayerUserTrades[addr][key] = make(map[common.Address]map[int64]*types.UserTrade)
 		}
-		if _, ok := s.tradeCache.relayerUserTrades[addr][key][trade.Maker]; !ok {
-			s.tradeCache.relayerUserTrades[addr][key][trade.Maker] = make(map[int64]*types.UserTrade)
-		}
-		if _, ok := s.tradeCache.relayerUserTrades[addr][key][trade.Taker]; !ok {
-			s.tradeCache.relayerUserTrades[addr][key][trade.Taker] = make(map[int64]*types.UserTrade)
+		for _, side := range s.getTradeSides(trade) {
+			if _, ok := s.tradeCache.relayerUserTrades[addr][key][side.userAddress]; !ok {
+				s.tradeCache.relayerUserTrades[addr][key][side.userAddress] = make(map[int64]*types.UserTrade)
+			}
+			modTime, userTrade := s.addUserTradeVolume(s.tradeCache.relayerUserTrades[addr][key][side.userAddress], trade, side, addr, sign)
+			s.tradeCache.dirtyUserTrades[s.getRelayerUserTradeStoreKey(modTime, addr, trade.BaseToken, trade.QuoteToken, side.userAddress)] = userTrade
 		}
 	}
+}
 
-	modTime, _ := utils.GetModTime(tradeTime, duration, unit)
+// updateRelayerFlow add signed trade volume to the flow from maker relayer to taker relayer, need to be lock
+func (s *TradeService) updateRelayerFlow(cache *tradeCache, trade *types.Trade, sign int64) {
+	modTime, _ := utils.GetModTime(trade.CreatedAt.Unix(), duration, unit)
+	bigSign := big.NewInt(sign)
+	flows := s.getRelayerFlows(cache, trade.BaseToken, trade.QuoteToken, trade.MakerExchange, trade.TakerExchange)
+	last, ok := flows[modTime]
+	if !ok {
+		last = &types.RelayerFlow{
+			MakerRelayer:  trade.MakerExchange,
+			TakerRelayer:  trade.TakerExchange,
+			BaseToken:     trade.BaseToken,
+			QuoteToken:    trade.QuoteToken,
+			Count:         big.NewInt(0),
+			Volume:        big.NewInt(0),
+			VolumeByQuote: big.NewInt(0),
+			TimeStamp:     modTime,
+		}
+		flows[modTime] = last
+	}
+	last.Count = new(big.Int).Add(last.Count, bigSign)
+	last.Volume = new(big.Int).Add(last.Volume, new(big.Int).Mul(trade.Amount, bigSign))
 	volumeByQuote := s.getVolumeByQuote(trade.BaseToken, trade.QuoteToken, trade.Amount, trade.PricePoint)
+	last.VolumeByQuote = new(big.Int).Add(last.VolumeByQuote, volumeByQuote.Mul(volumeByQuote, bigSign))
+	storeKey := s.getRelayerFlowStoreKey(modTime, trade.MakerExchange, trade.TakerExchange, trade.BaseToken, trade.QuoteToken)
+	if last.Count.Sign() <= 0 {
+		delete(flows, modTime)
+		cache.dirtyRelayerFlows[storeKey] = nil
+		return
+	}
+	cache.dirtyRelayerFlows[storeKey] = last
+}
 
-	for addr := range exchange {
-		if trade.Taker.Hex() == trade.Maker.Hex() {
-			if last, ok2 := s.tradeCache.relayerUserTrades[addr][key][trade.Taker][modTime]; ok2 {
-				last.Count = last.Count.Add(last.Count, big.NewInt(1))
-				last.Volume = big.NewInt(0).Add(last.Volume, trade.Amount)
-				last.VolumeByQuote = big.NewInt(0).Add(last.VolumeByQuote, volumeByQuote)
-				last.VolumeAsk = big.NewInt(0).Add(last.VolumeAsk, trade.Amount)
-				last.VolumeBid = big.NewInt(0).Add(last.VolumeBid, trade.Amount)
-				last.VolumeAskByQuote = big.NewInt(0).Add(last.VolumeAskByQuote, volumeByQuote)
-				last.VolumeBidByQuote = big.NewInt(0).Add(last.VolumeBidByQuote, volumeByQuote)
+// getRelayerFlows get time frames of flow from maker relayer to taker relayer on pair, need to be lock
+func (s *TradeService) getRelayerFlows(cache *tradeCache, baseToken, quoteToken, makerRelayer, takerRelayer common.Address) map[int64]*types.RelayerFlow {
+	key := s.getPairString(baseToken, quoteToken)
+	if _, ok := cache.relayerFlows[key]; !ok {
+		cache.relayerFlows[key] = make(map[string]map[int64]*types.RelayerFlow)
+	}
+	flowKey := s.getPairString(makerRelayer, takerRelayer)
+	if _, ok := cache.relayerFlows[key][flowKey]; !ok {
+		cache.relayerFlows[key][flowKey] = make(map[int64]*types.RelayerFlow)
+	}
+	return cache.relayerFlows[key][flowKey]
+}
 
-			} else {
-				userTrade := &types.UserTrade{
-					UserAddress:      trade.Maker,
-					Count:            big.NewInt(1),
-					Volume:           utils.CloneBigInt(trade.Amount),
-					VolumeByQuote:    utils.CloneBigInt(volumeByQuote),
-					VolumeAskByQuote: utils.CloneBigInt(volumeByQuote),
-					VolumeAsk:        utils.CloneBigInt(trade.Amount),
-					VolumeBid:        utils.CloneBigInt(trade.Amount),
-					VolumeBidByQuote: utils.CloneBigInt(volumeByQuote),
-					TimeStamp:        modTime,
-					RelayerAddress:   addr,
-					BaseToken:        trade.BaseToken,
-					QuoteToken:       trade.QuoteToken,
+// GetRelayerFlows get volume and count of trades from maker relayer to taker relayer
+// empty base token or quote token for all pairs of the other token, flows are summed by quote token
+// VolumeUSD is set if price service is not nil and quote token has a USD price
+func (s *TradeService) GetRelayerFlows(baseToken, quoteToken common.Address, from, to int64, priceService *PriceService) []*types.RelayerFlow {
+	s.mutex.RLock()
+	flows := make(map[string]*types.RelayerFlow)
+	for key, flowbyrelayer := range s.tradeCache.relayerFlows {
+		b, q, err := s.parsePairString(key)
+		if err != nil || (baseToken != common.Address{}) && baseToken != b || (quoteToken != common.Address{}) && quoteToken != q {
+			continue
+		}
+		for _, flowbytime := range flowbyrelayer {
+			for timestamp, flow := range flowbytime {
+				if (from != 0 && timestamp < from) || (to != 0 && timestamp > to) {
+					continue
+				}
+				flowKey := fmt.Sprintf("%s::%s::%s", flow.MakerRelayer.Hex(), flow.TakerRelayer.Hex(), q.Hex())
+				f, ok := flows[flowKey]
+				if !ok {
+					f = &types.RelayerFlow{
+						MakerRelayer:  flow.MakerRelayer,
+						TakerRelayer:  flow.TakerRelayer,
+						QuoteToken:    q,
+						Count:         big.NewInt(0),
+						Volume:        big.NewInt(0),
+						VolumeByQuote: big.NewInt(0),
+					}
+					// base token volumes can only be summed on one pair
+					if (baseToken != common.Address{}) {
+						f.BaseToken = b
+					}
+					flows[flowKey] = f
 				}
-				s.tradeCache.relayerUserTrades[addr][key][trade.Taker][modTime] = userTrade
+				f.Count = new(big.Int).Add(f.Count, flow.Count)
+				f.Volume = new(big.Int).Add(f.Volume, flow.Volume)
+				f.VolumeByQuote = new(big.Int).Add(f.VolumeByQuote, flow.VolumeByQuote)
+			}
+		}
+	}
+	s.mutex.RUnlock()
+
+	res := []*types.RelayerFlow{}
+	volumesUSD := make(map[*types.RelayerFlow]*big.Float)
+	for _, f := range flows {
+		if (baseToken == common.Address{}) {
+			f.Volume = nil
+		}
+		if priceService != nil {
+			if usd, ok := priceService.ToUSD(f.QuoteToken, f.VolumeByQuote); ok {
+				volumesUSD[f] = usd
+				f.VolumeUSD = FormatUSD(usd)
+			}
+		}
+		res = append(res, f)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if cmp := compareUSD(volumesUSD[res[i]], volumesUSD[res[j]]); cmp != 0 {
+			return cmp > 0
+		}
+		if cmp := res[i].VolumeByQuote.Cmp(res[j].VolumeByQuote); cmp != 0 {
+			return cmp > 0
+		}
+		return s.getPairString(res[i].MakerRelayer, res[i].TakerRelayer) < s.getPairString(res[j].MakerRelayer, res[j].TakerRelayer)
+	})
+	return res
+}
+
+// GetMarketShares get market share of relayers on pairs by time frame of interval
+// empty base token or quote token for all pairs of the other token
+func (s *TradeService) GetMarketShares(baseToken, quoteToken common.Address, from, to int64, interval string) []*types.PairMarketShare {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	res := []*types.PairMarketShare{}
+	for key, flowbyrelayer := range s.tradeCache.relayerFlows {
+		b, q, err := s.parsePairString(key)
+		if err != nil || (baseToken != common.Address{}) && baseToken != b || (quoteToken != common.Address{}) && quoteToken != q {
+			continue
+		}
+		frames := make(map[int64]*types.MarketShareFrame)
+		shares := make(map[int64]map[common.Address]*types.RelayerShare)
+		getShare := func(frame *types.MarketShareFrame, relayerAddress common.Address) *types.RelayerShare {
+			share, ok := shares[frame.TimeStamp][relayerAddress]
+			if !ok {
+				share = &types.RelayerShare{
+					RelayerAddress: relayerAddress,
+					MakerVolume:    big.NewInt(0),
+					TakerVolume:    big.NewInt(0),
+				}
+				shares[frame.TimeStamp][relayerAddress] = share
+				frame.Relayers = append(frame.Relayers, share)
+			}
+			return share
+		}
+		for _, flowbytime := range flowbyrelayer {
+			for timestamp, flow := range flowbytime {
+				if (from != 0 && timestamp < from) || (to != 0 && timestamp > to) {
+					continue
+				}
+				frameTime := getFrameTime(timestamp, interval)
+				frame, ok := frames[frameTime]
+				if !ok {
+					frame = &types.MarketShareFrame{
+						TimeStamp: frameTime,
+						Volume:    big.NewInt(0),
+						Relayers:  []*types.RelayerShare{},
+					}
+					frames[frameTime] = frame
+					shares[frameTime] = make(map[common.Address]*types.RelayerShare)
+				}
+				frame.Volume = new(big.Int).Add(frame.Volume, flow.Volume)
+				maker := getShare(frame, flow.MakerRelayer)
+				maker.MakerVolume = new(big.Int).Add(maker.MakerVolume, flow.Volume)
+				taker := getShare(frame, flow.TakerRelayer)
+				taker.TakerVolume = new(big.Int).Add(taker.TakerVolume, flow.Volume)
+			}
+		}
+		if len(frames) == 0 {
+			continue
+		}
+		pair := &types.PairMarketShare{
+			BaseToken:  b,
+			QuoteToken: q,
+			Frames:     []*types.MarketShareFrame{},
+		}
+		for _, frame := range frames {
+			// each trade has a maker side and a taker side
+			sides := new(big.Int).Mul(frame.Volume, big.NewInt(2))
+			for _, share := range frame.Relayers {
+				if sides.Sign() > 0 {
+					share.Share, _ = new(big.Rat).SetFrac(new(big.Int).Add(share.MakerVolume, share.TakerVolume), sides).Float64()
+				}
+			}
+			sort.Slice(frame.Relayers, func(i, j int) bool {
+				if frame.Relayers[i].Share != frame.Relayers[j].Share {
+					return frame.Relayers[i].Share > frame.Relayers[j].Share
+				}
+				return strings.ToLower(frame.Relayers[i].RelayerAddress.Hex()) < strings.ToLower(frame.Relayers[j].RelayerAddress.Hex())
+			})
+			pair.Frames = append(pair.Frames, frame)
+		}
+		sort.Slice(pair.Frames, func(i, j int) bool {
+			return pair.Frames[i].TimeStamp < pair.Frames[j].TimeStamp
+		})
+		res = append(res, pair)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return s.getPairString(res[i].BaseToken, res[i].QuoteToken) < s.getPairString(res[j].BaseToken, res[j].QuoteToken)
+	})
+	return res
+}
+
+// IsValidInterval check interval param of time series endpoints
+func (s *TradeService) IsValidInterval(interval string) bool {
+	return isValidInterval(interval)
+}
+
+// updateRelayerFee add signed trade fees to the relayer of maker and taker, need to be lock
+// fees are revenue of the relayer even for wash trades
+func (s *TradeService) updateRelayerFee(cache *tradeCache, trade *types.Trade, sign int64) {
+	key := s.getPairString(trade.BaseToken, trade.QuoteToken)
+	modTime, _ := utils.GetModTime(trade.CreatedAt.Unix(), duration, unit)
+	bigSign := big.NewInt(sign)
+	fees := map[common.Address][2]*big.Int{
+		trade.MakerExchange: {big.NewInt(0), big.NewInt(0)},
+		trade.TakerExchange: {big.NewInt(0), big.NewInt(0)},
+	}
+	if trade.MakeFee != nil {
+		fees[trade.MakerExchange][0].Mul(trade.MakeFee, bigSign)
+	}
+	if trade.TakeFee != nil {
+		fees[trade.TakerExchange][1].Mul(trade.TakeFee, bigSign)
+	}
+	for relayerAddress, fee := range fees {
+		relayerFees := s.getRelayerFees(cache, relayerAddress, key)
+		last, ok := relayerFees[modTime]
+		if !ok {
+			last = &types.RelayerFee{
+				RelayerAddress: relayerAddress,
+				BaseToken:      trade.BaseToken,
+				QuoteToken:     trade.QuoteToken,
+				Count:          big.NewInt(0),
+				MakeFee:        big.NewInt(0),
+				TakeFee:        big.NewInt(0),
+				TimeStamp:      modTime,
 			}
-		} else {
+			relayerFees[modTime] = last
+		}
+		last.Count = new(big.Int).Add(last.Count, bigSign)
+		last.MakeFee = new(big.Int).Add(last.MakeFee, fee[0])
+		last.TakeFee = new(big.Int).Add(last.TakeFee, fee[1])
+		storeKey := s.getRelayerFeeStoreKey(modTime, relayerAddress, trade.BaseToken, trade.QuoteToken)
+		if last.Count.Sign() <= 0 {
+			delete(relayerFees, modTime)
+			cache.dirtyRelayerFees[storeKey] = nil
+			continue
+		}
+		cache.dirtyRelayerFees[storeKey] = last
+	}
+}
 
-			if last, ok2 := s.tradeCache.relayerUserTrades[addr][key][trade.Taker][modTime]; ok2 {
-				last.Count = last.Count.Add(last.Count, big.NewInt(1))
-				last.Volume = big.NewInt(0).Add(last.Volume, trade.Amount)
-				last.VolumeByQuote = big.NewInt(0).Add(last.VolumeByQuote, volumeByQuote)
-				if trade.TakerOrderSide == sideBuy {
-					last.VolumeAsk = big.NewInt(0).Add(last.VolumeAsk, trade.Amount)
-					last.VolumeAskByQuote = big.NewInt(0).Add(last.VolumeAskByQuote, volumeByQuote)
-				} else {
-					last.VolumeBid = big.NewInt(0).Add(last.VolumeBid, trade.Amount)
-					last.VolumeBidByQuote = big.NewInt(0).Add(last.VolumeBidByQuote, volumeByQuote)
-				}
+// getRelayerFees get time frames of relayer fees on pair, need to be lock
+func (s *TradeService) getRelayerFees(cache *tradeCache, relayerAddress common.Address, key string) map[int64]*types.RelayerFee {
+	if _, ok := cache.relayerFees[relayerAddress]; !ok {
+		cache.relayerFees[relayerAddress] = make(map[string]map[int64]*types.RelayerFee)
+	}
+	if _, ok := cache.relayerFees[relayerAddress][key]; !ok {
+		cache.relayerFees[relayerAddress][key] = make(map[int64]*types.RelayerFee)
+	}
+	return cache.relayerFees[relayerAddress][key]
+}
 
-			} else {
-				userTrade := &types.UserTrade{
-					UserAddress:    trade.Taker,
-					Count:          big.NewInt(1),
-					Volume:         utils.CloneBigInt(trade.Amount),
-					VolumeByQuote:  utils.CloneBigInt(volumeByQuote),
-					BaseToken:      trade.BaseToken,
-					QuoteToken:     trade.QuoteToken,
-					TimeStamp:      modTime,
-					RelayerAddress: addr,
-				}
-				if trade.TakerOrderSide == sideBuy {
-					userTrade.VolumeBid = utils.CloneBigInt(trade.Amount)
-					userTrade.VolumeBidByQuote = utils.CloneBigInt(volumeByQuote)
-					userTrade.VolumeAsk = big.NewInt(0)
-					userTrade.VolumeAskByQuote = big.NewInt(0)
-				} else {
-					userTrade.VolumeBid = big.NewInt(0)
-					userTrade.VolumeBidByQuote = big.NewInt(0)
-					userTrade.VolumeAsk = utils.CloneBigInt(trade.Amount)
-					userTrade.VolumeAskByQuote = utils.CloneBigInt(volumeByQuote)
-				}
-				s.tradeCache.relayerUserTrades[addr][key][trade.Taker][modTime] = userTrade
+// GetRelayerFees get fees of relayer by pair and time frame, sorted by time
+func (s *TradeService) GetRelayerFees(relayerAddress common.Address, from, to int64) []*types.RelayerFee {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	res := []*types.RelayerFee{}
+	for _, feebytime := range s.tradeCache.relayerFees[relayerAddress] {
+		for timestamp, fee := range feebytime {
+			if (from != 0 && timestamp < from) || (to != 0 && timestamp > to) {
+				continue
 			}
+			f := *fee
+			res = append(res, &f)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].TimeStamp < res[j].TimeStamp
+	})
+	return res
+}
 
-			if last, ok2 := s.tradeCache.relayerUserTrades[addr][key][trade.Maker][modTime]; ok2 {
-				last.Count = last.Count.Add(last.Count, big.NewInt(1))
-				last.Volume = big.NewInt(0).Add(last.Volume, trade.Amount)
-				last.VolumeByQuote = big.NewInt(0).Add(last.VolumeByQuote, volumeByQuote)
-				if trade.TakerOrderSide == sideSell {
-					last.VolumeAsk = big.NewInt(0).Add(last.VolumeAsk, trade.Amount)
-					last.VolumeAskByQuote = big.NewInt(0).Add(last.VolumeAskByQuote, volumeByQuote)
-				} else {
-					last.VolumeBid = big.NewInt(0).Add(last.VolumeBid, trade.Amount)
-					last.VolumeBidByQuote = big.NewInt(0).Add(last.VolumeBidByQuote, volumeByQuote)
-				}
+// NotifyAddressListChange recompute relayer volume of trades of addresses whose wash groups have changed
+// trades of cache are recomputed with the index change, as the change stream may update them
+// older trades are not updated anymore, they are fetched and recomputed page by page, only for time frames loaded in cache
+func (s *TradeService) NotifyAddressListChange(index *AddressIndex, addresses []common.Address) error {
+	s.mutex.Lock()
+	previous := s.addressIndex
+	s.addressIndex = index
+	if len(addresses) == 0 {
+		s.mutex.Unlock()
+		return nil
+	}
+	changed := make(map[common.Address]bool)
+	for _, a := range addresses {
+		changed[a] = true
+	}
+	recomputed := make(map[common.Hash]bool)
+	for hash, trade := range s.tradeCache.trades {
+		if changed[trade.Maker] || changed[trade.Taker] {
+			recomputed[hash] = true
+			s.recomputeWashTrade(trade, previous)
+		}
+	}
+	// newer trades missing from cache are not applied by the change stream or the backfill yet,
+	// they are counted with the new index when they are
+	todate := time.Now().Unix() - tradeHashRetention
+	if s.tradeCache.lastTime+1 < todate {
+		todate = s.tradeCache.lastTime + 1
+	}
+	s.mutex.Unlock()
 
-			} else {
-				userTrade := &types.UserTrade{
-					UserAddress:    trade.Maker,
-					Count:          big.NewInt(1),
-					Volume:         utils.CloneBigInt(trade.Amount),
-					VolumeByQuote:  utils.CloneBigInt(volumeByQuote),
-					BaseToken:      trade.BaseToken,
-					QuoteToken:     trade.QuoteToken,
-					TimeStamp:      modTime,
-					RelayerAddress: addr,
-				}
-				if trade.TakerOrderSide == sideSell {
-					userTrade.VolumeBid = utils.CloneBigInt(trade.Amount)
-					userTrade.VolumeBidByQuote = utils.CloneBigInt(volumeByQuote)
-					userTrade.VolumeAsk = big.NewInt(0)
-					userTrade.VolumeAskByQuote = big.NewInt(0)
-				} else {
-					userTrade.VolumeBid = big.NewInt(0)
-					userTrade.VolumeBidByQuote = big.NewInt(0)
-					userTrade.VolumeAsk = utils.CloneBigInt(trade.Amount)
-					userTrade.VolumeAskByQuote = utils.CloneBigInt(volumeByQuote)
-				}
-				s.tradeCache.relayerUserTrades[addr][key][trade.Maker][modTime] = userTrade
+	pageOffset := 0
+	size := 1000
+	for {
+		trades, err := s.tradeDao.GetTradeByUsers(addresses, getCacheLoadHorizon(), todate, pageOffset*size, size)
+		if err != nil {
+			return err
+		}
+		if len(trades) == 0 {
+			break
+		}
+		s.mutex.Lock()
+		for _, trade := range trades {
+			if _, ok := s.tradeCache.trades[trade.Hash]; ok || recomputed[trade.Hash] {
+				continue
 			}
-
+			recomputed[trade.Hash] = true
+			s.recomputeWashTrade(trade, previous)
 		}
+		s.mutex.Unlock()
+		pageOffset = pageOffset + 1
 	}
+	logger.Infof("Recomputed relayer volume of %d trades", len(recomputed))
 	return nil
 }
+
+// recomputeWashTrade add or revert counted trade from relayer volume if its wash trade status has changed, need to be lock
+func (s *TradeService) recomputeWashTrade(trade *types.Trade, previous *AddressIndex) {
+	if s.getTradeState(trade) != tradeStateCounted {
+		return
+	}
+	wasWashTrade := previous.IsWashTrade(trade.Maker, trade.Taker)
+	isWashTrade := s.isWashTrade(trade.Maker, trade.Taker)
+	if wasWashTrade == isWashTrade {
+		return
+	}
+	if isWashTrade {
+		s.updateRelayerUserTradeVolume(trade, -1)
+	} else {
+		s.updateRelayerUserTradeVolume(trade, 1)
+	}
+}
+
 func (s *TradeService) addUserTrade(userTrade *types.UserTrade) {
 	key := s.getPairString(userTrade.BaseToken, userTrade.QuoteToken)
 	if _, ok := s.tradeCache.userTrades[key]; !ok {
@@ -776,12 +1651,245 @@ func (s *TradeService) QueryVolume(relayerAddress common.Address, userAddress co
 	return s.queryVolume(relayerAddress, userAddress, baseTokens, quoteToken, from, to, top)
 }
 
-func (s *TradeService) queryVolume(relayerAddress common.Address, userAddress common.Address, baseTokens []common.Address, quoteToken common.Address, from, to int64, top int) []*types.UserVolume {
+// QueryTotalUSD get total infomation with volume of all quote tokens valued in USD
+// empty quote token for all quote tokens, volume of tokens without USD price is not counted
+func (s *TradeService) QueryTotalUSD(relayerAddress common.Address, baseTokens []common.Address, quoteToken common.Address, from, to int64, priceService *PriceService) *types.TradeVolume {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	users := make(map[common.Address]bool)
+	quoteVolumes := make(map[common.Address]*big.Int)
+	for qt, userVolumes := range s.getUserVolumesByQuote(relayerAddress, baseTokens, quoteToken, from, to) {
+		quoteVolume := big.NewInt(0)
+		for address, volume := range userVolumes {
+			users[address] = true
+			quoteVolume = new(big.Int).Add(quoteVolume, volume)
+		}
+		quoteVolumes[qt] = quoteVolume
+	}
+	return newTradeVolumeUSD(quoteVolumes, len(users), priceService)
+}
+
+// newTradeVolumeUSD value volumes by quote token in USD, volumes of quote tokens without price are kept apart
+func newTradeVolumeUSD(quoteVolumes map[common.Address]*big.Int, traders int, priceService *PriceService) *types.TradeVolume {
+	totalVolume := NewUSD()
+	unpriced := []*types.QuoteVolume{}
+	for qt, volume := range quoteVolumes {
+		if usd, ok := priceService.ToUSD(qt, volume); ok {
+			totalVolume.Add(totalVolume, usd)
+		} else if volume.Sign() > 0 {
+			unpriced = append(unpriced, &types.QuoteVolume{QuoteToken: qt, Volume: volume})
+		}
+	}
+	sort.Slice(unpriced, func(i, j int) bool {
+		return unpriced[i].QuoteToken.Hex() < unpriced[j].QuoteToken.Hex()
+	})
+	return &types.TradeVolume{
+		TotalVolumeUSD:  FormatUSD(totalVolume),
+		Trader:          big.NewInt(int64(traders)),
+		UnpricedVolumes: unpriced,
+	}
+}
+
+// relayerWindowVolume volumes by quote token and traders of a relayer in a time window
+type relayerWindowVolume struct {
+	quoteVolumes map[common.Address]*big.Int
+	users        map[common.Address]bool
+}
+
+// getRelayerWindowVolumes get volumes of every relayer in the time windows starting at froms
+// time frames are walked once for all windows, users are counted in a window if they traded in it
+func (s *TradeService) getRelayerWindowVolumes(froms []int64) map[common.Address][]*relayerWindowVolume {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	res := make(map[common.Address][]*relayerWindowVolume)
+	for relayer, tradebyRelayer := range s.tradeCache.relayerUserTrades {
+		windows := make([]*relayerWindowVolume, len(froms))
+		for i := range windows {
+			windows[i] = &relayerWindowVolume{
+				quoteVolumes: make(map[common.Address]*big.Int),
+				users:        make(map[common.Address]bool),
+			}
+		}
+		for key, tradebyUserAddess := range tradebyRelayer {
+			_, qToken, err := s.parsePairString(key)
+			if err != nil {
+				continue
+			}
+			for address, tradeBytime := range tradebyUserAddess {
+				for t, trade := range tradeBytime {
+					for i, from := range froms {
+						if t < from {
+							continue
+						}
+						w := windows[i]
+						if v, ok := w.quoteVolumes[qToken]; ok {
+							v.Add(v, trade.VolumeByQuote)
+						} else {
+							w.quoteVolumes[qToken] = new(big.Int).Set(trade.VolumeByQuote)
+						}
+						w.users[address] = true
+					}
+				}
+			}
+		}
+		res[relayer] = windows
+	}
+	return res
+}
+
+// Query24hVolumeUSD get user 24h volume of all quote tokens valued in USD
+func (s *TradeService) Query24hVolumeUSD(relayerAddress common.Address, userAddress common.Address, baseTokens []common.Address, quoteToken common.Address, top int, priceService *PriceService) []*types.UserVolume {
+	now := time.Now().Unix() - 24*60*60
+	day, _ := utils.GetModTime(now, 1, unit)
+	return s.QueryVolumeUSD(relayerAddress, userAddress, baseTokens, quoteToken, day, now, top, priceService)
+}
+
+// QueryVolumeUSD get user volume of all quote tokens valued in USD
+// empty quote token for all quote tokens, volume of tokens without USD price is not counted
+func (s *TradeService) QueryVolumeUSD(relayerAddress common.Address, userAddress common.Address, baseTokens []common.Address, quoteToken common.Address, from, to int64, top int, priceService *PriceService) []*types.UserVolume {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
 	if top == 0 {
 		top = 10
 	}
 
+	userVolumes := make(map[common.Address]*big.Float)
+	for qt, volumes := range s.getUserVolumesByQuote(relayerAddress, baseTokens, quoteToken, from, to) {
+		for address, volume := range volumes {
+			if s.isBotAddress(address) {
+				continue
+			}
+			usd, ok := priceService.ToUSD(qt, volume)
+			if !ok {
+				continue
+			}
+			if v, ok := userVolumes[address]; ok {
+				v.Add(v, usd)
+			} else {
+				userVolumes[address] = usd
+			}
+		}
+	}
 	var users []*types.UserVolume
+	for a := range userVolumes {
+		users = append(users, &types.UserVolume{
+			UserAddress: a,
+		})
+	}
+	sort.Slice(users, func(i, j int) bool {
+		if cmp := userVolumes[users[i].UserAddress].Cmp(userVolumes[users[j].UserAddress]); cmp != 0 {
+			return cmp > 0
+		}
+		return strings.ToLower(users[i].UserAddress.Hex()) < strings.ToLower(users[j].UserAddress.Hex())
+	})
+	for _, u := range users {
+		u.VolumeUSD = FormatUSD(userVolumes[u.UserAddress])
+	}
+	var res []*types.UserVolume
+	for i, u := range users {
+		if (userAddress == common.Address{} || u.UserAddress.Hex() == userAddress.Hex()) {
+			u.Rank = i + 1
+			res = append(res, u)
+		}
+	}
+
+	if top >= len(res) {
+		top = len(res)
+	}
+	return res[0:top]
+}
+
+// getUserVolumesByQuote get volume of every user by quote token, empty quote token for all quote tokens
+func (s *TradeService) getUserVolumesByQuote(relayerAddress common.Address, baseTokens []common.Address, quoteToken common.Address, from, to int64) map[common.Address]map[common.Address]*big.Int {
+	quoteTokens := make(map[common.Address]bool)
+	for _, tradebyRelayer := range s.tradeCache.relayerUserTrades {
+		for key := range tradebyRelayer {
+			_, qToken, err := s.parsePairString(key)
+			if err == nil && ((quoteToken == common.Address{}) || quoteToken.Hex() == qToken.Hex()) {
+				quoteTokens[qToken] = true
+			}
+		}
+	}
+	res := make(map[common.Address]map[common.Address]*big.Int)
+	for qt := range quoteTokens {
+		res[qt] = s.getUserVolumes(relayerAddress, baseTokens, qt, from, to)
+	}
+	return res
+}
+
+// getPairTrades get counted trades of relayer since from and latest counted trade of relayer by pair
+// empty relayer address for all relayers, trades are kept in cache for tradeHashRetention
+func (s *TradeService) getPairTrades(relayerAddress common.Address, from int64) (map[string][]*types.Trade, map[string]*types.Trade) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	trades := make(map[string][]*types.Trade)
+	lastTrades := make(map[string]*types.Trade)
+	for _, trade := range s.tradeCache.trades {
+		if trade.PricePoint == nil || trade.Amount == nil || s.getTradeState(trade) != tradeStateCounted {
+			continue
+		}
+		if (relayerAddress != common.Address{}) && trade.MakerExchange != relayerAddress && trade.TakerExchange != relayerAddress {
+			continue
+		}
+		key := s.getPairString(trade.BaseToken, trade.QuoteToken)
+		if last, ok := lastTrades[key]; !ok || trade.CreatedAt.After(last.CreatedAt) {
+			lastTrades[key] = trade
+		}
+		if trade.CreatedAt.Unix() >= from {
+			trades[key] = append(trades[key], trade)
+		}
+	}
+	return trades, lastTrades
+}
+
+// GetLastPairPrices get last trade price of every pair
+func (s *TradeService) GetLastPairPrices() []*types.PairPrice {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	var res []*types.PairPrice
+	from, _ := utils.GetModTime(time.Now().Unix()-24*60*60, duration, unit)
+	for key, price := range s.lastPairPrice {
+		bToken, qToken, err := s.parsePairString(key)
+		if err != nil || price == nil {
+			continue
+		}
+		p := &types.PairPrice{
+			BaseToken:     bToken,
+			QuoteToken:    qToken,
+			Price:         new(big.Int).Set(price),
+			Volume:        big.NewInt(0),
+			VolumeByQuote: big.NewInt(0),
+		}
+		for _, userTrades := range s.tradeCache.userTrades[key] {
+			for t, userTrade := range userTrades {
+				if t >= from {
+					p.Volume.Add(p.Volume, userTrade.Volume)
+					p.VolumeByQuote.Add(p.VolumeByQuote, userTrade.VolumeByQuote)
+				}
+			}
+		}
+		res = append(res, p)
+	}
+	return res
+}
+
+// GetUserVolumes get volume by quote token of every user, wash trades excluded
+// empty relayer address for all relayers
+func (s *TradeService) GetUserVolumes(relayerAddress common.Address, baseTokens []common.Address, quoteToken common.Address, from, to int64) map[common.Address]*big.Int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.getUserVolumes(relayerAddress, baseTokens, quoteToken, from, to)
+}
+
+// readUserVolumes call fn with the user volumes of GetUserVolumes under read lock
+// every trade is either included in the volumes or notified to notifiers after fn returns
+func (s *TradeService) readUserVolumes(relayerAddress common.Address, baseTokens []common.Address, quoteToken common.Address, from, to int64, fn func(map[common.Address]*big.Int)) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	fn(s.getUserVolumes(relayerAddress, baseTokens, quoteToken, from, to))
+}
+
+func (s *TradeService) getUserVolumes(relayerAddress common.Address, baseTokens []common.Address, quoteToken common.Address, from, to int64) map[common.Address]*big.Int {
 	userVolumes := make(map[common.Address]*big.Int)
 
 	for relayer, tradebyRelayer := range s.tradeCache.relayerUserTrades {
@@ -807,6 +1915,16 @@ func (s *TradeService) queryVolume(relayerAddress common.Address, userAddress co
 			}
 		}
 	}
+	return userVolumes
+}
+
+func (s *TradeService) queryVolume(relayerAddress common.Address, userAddress common.Address, baseTokens []common.Address, quoteToken common.Address, from, to int64, top int) []*types.UserVolume {
+	if top == 0 {
+		top = 10
+	}
+
+	var users []*types.UserVolume
+	userVolumes := s.getUserVolumes(relayerAddress, baseTokens, quoteToken, from, to)
 	for a, v := range userVolumes {
 		if !s.isBotAddress(a) {
 			users = append(users, &types.UserVolume{
@@ -840,6 +1958,175 @@ func (s *TradeService) queryVolume(relayerAddress common.Address, userAddress co
 	return res[0:top]
 }
 
+// GetTradeHistory get a page of trades from newest to oldest, starting after cursor if not nil
+func (s *TradeService) GetTradeHistory(spec *types.TradeSpec, cursor *types.TradeCursor, size int) (*types.TradeHistory, error) {
+	trades, err := s.tradeDao.GetTradesByCursor(spec, cursor, size+1)
+	if err != nil {
+		return nil, err
+	}
+	res := &types.TradeHistory{Trades: trades}
+	if len(trades) > size {
+		res.Trades = trades[:size]
+		last := res.Trades[size-1]
+		res.Next = (&types.TradeCursor{CreatedAt: last.CreatedAt, ID: last.ID}).String()
+	}
+	return res, nil
+}
+
+// GetUserTradeProfile get volumes of user by pair and by quote token with the rank of user in each market
+// ranks are computed like QueryVolume, over all relayers and all time frames in cache, in a single pass
+// users with the same volume share the best rank
+func (s *TradeService) GetUserTradeProfile(userAddress common.Address) ([]*types.UserPairProfile, []*types.UserQuoteProfile) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	pairs := make(map[string]*types.UserPairProfile)
+	quotes := make(map[common.Address]*types.UserQuoteProfile)
+	for key, tradebyuser := range s.tradeCache.userTrades {
+		tradebytime, ok := tradebyuser[userAddress]
+		if !ok || len(tradebytime) == 0 {
+			continue
+		}
+		baseToken, quoteToken, err := s.parsePairString(key)
+		if err != nil {
+			continue
+		}
+		pair := &types.UserPairProfile{
+			BaseToken:     baseToken,
+			QuoteToken:    quoteToken,
+			Count:         big.NewInt(0),
+			Volume:        big.NewInt(0),
+			VolumeBid:     big.NewInt(0),
+			VolumeAsk:     big.NewInt(0),
+			VolumeMaker:   big.NewInt(0),
+			VolumeTaker:   big.NewInt(0),
+			VolumeUnsplit: big.NewInt(0),
+		}
+		quote, ok := quotes[quoteToken]
+		if !ok {
+			quote = &types.UserQuoteProfile{
+				QuoteToken:       quoteToken,
+				Count:            big.NewInt(0),
+				VolumeByQuote:    big.NewInt(0),
+				VolumeBidByQuote: big.NewInt(0),
+				VolumeAskByQuote: big.NewInt(0),
+			}
+			quotes[quoteToken] = quote
+		}
+		for _, trade := range tradebytime {
+			pair.Count = new(big.Int).Add(pair.Count, trade.Count)
+			pair.Volume = new(big.Int).Add(pair.Volume, trade.Volume)
+			pair.VolumeBid = new(big.Int).Add(pair.VolumeBid, trade.VolumeBid)
+			pair.VolumeAsk = new(big.Int).Add(pair.VolumeAsk, trade.VolumeAsk)
+			if trade.VolumeMaker == nil && trade.VolumeTaker == nil {
+				pair.VolumeUnsplit = new(big.Int).Add(pair.VolumeUnsplit, trade.Volume)
+			}
+			pair.VolumeMaker = addOptionalBigInt(pair.VolumeMaker, trade.VolumeMaker)
+			pair.VolumeTaker = addOptionalBigInt(pair.VolumeTaker, trade.VolumeTaker)
+			quote.Count = new(big.Int).Add(quote.Count, trade.Count)
+			quote.VolumeByQuote = new(big.Int).Add(quote.VolumeByQuote, trade.VolumeByQuote)
+			quote.VolumeBidByQuote = new(big.Int).Add(quote.VolumeBidByQuote, trade.VolumeBidByQuote)
+			quote.VolumeAskByQuote = new(big.Int).Add(quote.VolumeAskByQuote, trade.VolumeAskByQuote)
+		}
+		if sides := new(big.Int).Add(pair.VolumeMaker, pair.VolumeTaker); sides.Sign() > 0 {
+			pair.MakerShare, _ = new(big.Rat).SetFrac(pair.VolumeMaker, sides).Float64()
+		}
+		pairs[key] = pair
+	}
+
+	if !s.isBotAddress(userAddress) {
+		s.setUserTradeRanks(userAddress, pairs, quotes)
+	}
+	resPairs := []*types.UserPairProfile{}
+	for _, pair := range pairs {
+		resPairs = append(resPairs, pair)
+	}
+	res := []*types.UserQuoteProfile{}
+	for _, quote := range quotes {
+		res = append(res, quote)
+	}
+	sort.Slice(resPairs, func(i, j int) bool {
+		return s.getPairString(resPairs[i].BaseToken, resPairs[i].QuoteToken) < s.getPairString(resPairs[j].BaseToken, resPairs[j].QuoteToken)
+	})
+	sort.Slice(res, func(i, j int) bool {
+		return strings.ToLower(res[i].QuoteToken.Hex()) < strings.ToLower(res[j].QuoteToken.Hex())
+	})
+	return resPairs, res
+}
+
+// setUserTradeRanks set the rank of user by relayer volume of its pairs and quote tokens, need to be lock
+// volumes of users of all relayers are summed in one walk of relayer user trades
+func (s *TradeService) setUserTradeRanks(userAddress common.Address, pairs map[string]*types.UserPairProfile, quotes map[common.Address]*types.UserQuoteProfile) {
+	// pairAddress or quote token => userAddress => volume by quote
+	pairVolumes := make(map[string]map[common.Address]*big.Int)
+	quoteVolumes := make(map[common.Address]map[common.Address]*big.Int)
+	for _, tradebyRelayer := range s.tradeCache.relayerUserTrades {
+		for key, tradebyUserAddress := range tradebyRelayer {
+			_, quoteToken, err := s.parsePairString(key)
+			if err != nil || quotes[quoteToken] == nil {
+				continue
+			}
+			if _, ok := quoteVolumes[quoteToken]; !ok {
+				quoteVolumes[quoteToken] = make(map[common.Address]*big.Int)
+			}
+			if _, ok := pairs[key]; ok {
+				if _, ok := pairVolumes[key]; !ok {
+					pairVolumes[key] = make(map[common.Address]*big.Int)
+				}
+			}
+			for address, tradeBytime := range tradebyUserAddress {
+				volume := big.NewInt(0)
+				for _, trade := range tradeBytime {
+					volume.Add(volume, trade.VolumeByQuote)
+				}
+				quoteVolumes[quoteToken][address] = addBigInt(quoteVolumes[quoteToken][address], volume)
+				if volumes, ok := pairVolumes[key]; ok {
+					volumes[address] = addBigInt(volumes[address], volume)
+				}
+			}
+		}
+	}
+	for key, pair := range pairs {
+		pair.Rank = s.getVolumeRank(userAddress, pairVolumes[key])
+	}
+	for quoteToken, quote := range quotes {
+		quote.Rank = s.getVolumeRank(userAddress, quoteVolumes[quoteToken])
+	}
+}
+
+// getVolumeRank get rank of user among users of volumes which are not bots, 0 if user has no volume
+func (s *TradeService) getVolumeRank(userAddress common.Address, volumes map[common.Address]*big.Int) int {
+	volume, ok := volumes[userAddress]
+	if !ok {
+		return 0
+	}
+	rank := 1
+	for address, v := range volumes {
+		if v.Cmp(volume) > 0 && !s.isBotAddress(address) {
+			rank++
+		}
+	}
+	return rank
+}
+
+// GetUserRelayers get relayers user traded with in time frames in cache, wash trades excluded
+func (s *TradeService) GetUserRelayers(userAddress common.Address) []common.Address {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	res := []common.Address{}
+	for relayerAddress, tradebypair := range s.tradeCache.relayerUserTrades {
+		for _, tradebyuser := range tradebypair {
+			if len(tradebyuser[userAddress]) > 0 {
+				res = append(res, relayerAddress)
+				break
+			}
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return strings.ToLower(res[i].Hex()) < strings.ToLower(res[j].Hex())
+	})
+	return res
+}
+
 // GetTopRelayerUserPnL get top PnL user trade
 func (s *TradeService) GetTopRelayerUserPnL(relayerAddress common.Address, baseToken, quoteToken common.Address, top int) []*types.UserPnL {
 	s.mutex.RLock()
@@ -848,6 +2135,27 @@ func (s *TradeService) GetTopRelayerUserPnL(relayerAddress common.Address, baseT
 	if top == 0 {
 		top = 10
 	}
+	users := s.getRelayerUserPnL(relayerAddress, baseToken, quoteToken, 0, 0)
+	sort.Slice(users, func(i, j int) bool {
+		if users[i].PnL.Cmp(users[j].PnL) > 0 {
+			return true
+		}
+		return false
+	})
+	if top >= len(users) {
+		top = len(users)
+	}
+	return users[0:top]
+}
+
+// GetRelayerUserPnL get PnL of every user of relayer on pair, between from and to time frames
+func (s *TradeService) GetRelayerUserPnL(relayerAddress common.Address, baseToken, quoteToken common.Address, from, to int64) []*types.UserPnL {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.getRelayerUserPnL(relayerAddress, baseToken, quoteToken, from, to)
+}
+
+func (s *TradeService) getRelayerUserPnL(relayerAddress common.Address, baseToken, quoteToken common.Address, from, to int64) []*types.UserPnL {
 	var users []*types.UserPnL
 	key := s.getPairString(baseToken, quoteToken)
 	var lastPrice *big.Int
@@ -862,7 +2170,10 @@ func (s *TradeService) GetTopRelayerUserPnL(relayerAddress common.Address, baseT
 				volumeBid := big.NewInt(0)
 				pnl := big.NewInt(0)
 
-				for _, trade := range tradeBytime {
+				for t, trade := range tradeBytime {
+					if (from != 0 && t < from) || (to != 0 && t > to) {
+						continue
+					}
 					volumeAskByQuote = volumeAskByQuote.Add(volumeAskByQuote, trade.VolumeAskByQuote)
 					volumeBidByQuote = volumeBidByQuote.Add(volumeBidByQuote, trade.VolumeBidByQuote)
 					volumeAsk = volumeAsk.Add(volumeAsk, trade.VolumeAsk)
@@ -891,16 +2202,38 @@ func (s *TradeService) GetTopRelayerUserPnL(relayerAddress common.Address, baseT
 			}
 		}
 	}
-	sort.Slice(users, func(i, j int) bool {
-		if users[i].PnL.Cmp(users[j].PnL) > 0 {
-			return true
+	return users
+}
+
+// GetPendingVolume get volume of trades waiting for settlement by pair
+// empty relayer address for all relayers
+// VolumeUSD is set if price service is not nil and quote token has a USD price, pairs are then sorted by it
+func (s *TradeService) GetPendingVolume(relayerAddress common.Address, baseTokens []common.Address, quoteToken common.Address, priceService *PriceService) []*types.PendingTrade {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	res := []*types.PendingTrade{}
+	volumesUSD := make(map[*types.PendingTrade]*big.Float)
+	for key, pending := range s.tradeCache.pendingTrades[relayerAddress] {
+		bToken, qToken, err := s.parsePairString(key)
+		if err == nil && ((quoteToken == common.Address{}) || quoteToken.Hex() == qToken.Hex()) && (utils.ContainsAddress(baseTokens, bToken) || len(baseTokens) == 0) {
+			if priceService != nil {
+				p := *pending
+				pending = &p
+				if usd, ok := priceService.ToUSD(pending.QuoteToken, pending.VolumeByQuote); ok {
+					volumesUSD[pending] = usd
+					pending.VolumeUSD = FormatUSD(usd)
+				}
+			}
+			res = append(res, pending)
 		}
-		return false
-	})
-	if top >= len(users) {
-		top = len(users)
 	}
-	return users[0:top]
+	sort.Slice(res, func(i, j int) bool {
+		if cmp := compareUSD(volumesUSD[res[i]], volumesUSD[res[j]]); cmp != 0 {
+			return cmp > 0
+		}
+		return res[i].VolumeByQuote.Cmp(res[j].VolumeByQuote) > 0
+	})
+	return res
 }
 
 // GetNumberUsers get total trader
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/tomochain/tomox-stats/types"
+	"github.com/tomochain/tomox-stats/utils"
+)
+
+// recordNotifier records the hashes of notified trades
+type recordNotifier struct {
+	trades  []common.Hash
+	reverts []common.Hash
+}
+
+func (n *recordNotifier) NotifyTrade(trade *types.Trade) error {
+	n.trades = append(n.trades, trade.Hash)
+	return nil
+}
+
+func (n *recordNotifier) NotifyRevertTrade(trade *types.Trade) error {
+	n.reverts = append(n.reverts, trade.Hash)
+	return nil
+}
+
+func newTestTradeService() (*TradeService, *recordNotifier) {
+	s := NewTradeService(nil, nil, NewAddressListService(nil, nil, nil), nil)
+	s.tokenCache[testBaseToken] = &tokenCache{
+		token:    &types.Token{Decimals: 0},
+		timelife: time.Now().Unix(),
+	}
+	n := &recordNotifier{}
+	s.AddNotifier(n)
+	return s, n
+}
+
+// getTestTotalVolume get volume of test relayer, counted for maker and taker
+func getTestTotalVolume(s *TradeService) int64 {
+	return s.QueryTotal(testRelayer, nil, testQuoteToken, 0, 0).TotalVolume.Int64()
+}
+
+func getTestLastPrice(s *TradeService) int64 {
+	for _, p := range s.GetLastPairPrices() {
+		if p.BaseToken == testBaseToken && p.QuoteToken == testQuoteToken {
+			return p.Price.Int64()
+		}
+	}
+	return 0
+}
+
+func TestTradeReplay(t *testing.T) {
+	s, n := newTestTradeService()
+	now := time.Now().Unix()
+	trade := newTestTrade(1, now, 10, 2, sideBuy)
+	// the backfill after a failed resume overlaps the events of the new stream
+	s.NotifyTrade(trade)
+	s.NotifyTrade(trade)
+	replayed := *trade
+	s.NotifyTrade(&replayed)
+
+	assert.Equal(t, int64(40), getTestTotalVolume(s))
+	assert.Len(t, n.trades, 1)
+	assert.Empty(t, n.reverts)
+}
+
+func TestTradeRevert(t *testing.T) {
+	s, n := newTestTradeService()
+	now := time.Now().Unix()
+	s.NotifyTrade(newTestTrade(1, now-60, 10, 2, sideBuy))
+	trade := newTestTrade(2, now, 12, 1, sideSell)
+	s.NotifyTrade(trade)
+	assert.Equal(t, int64(64), getTestTotalVolume(s))
+	assert.Equal(t, int64(12), getTestLastPrice(s))
+
+	failed := *trade
+	failed.Status = types.TradeStatusError
+	s.NotifyTrade(&failed)
+	assert.Equal(t, int64(40), getTestTotalVolume(s))
+	assert.Equal(t, []common.Hash{trade.Hash}, n.reverts)
+	// the last price falls back to the latest counted trade
+	assert.Equal(t, int64(10), getTestLastPrice(s))
+
+	s.NotifyDeleteTrade(failed.ID)
+	assert.Equal(t, int64(40), getTestTotalVolume(s))
+	// an ignored trade is not notified again when deleted
+	assert.Len(t, n.reverts, 1)
+}
+
+func TestTradeDelete(t *testing.T) {
+	s, n := newTestTradeService()
+	trade := newTestTrade(1, time.Now().Unix(), 10, 2, sideBuy)
+	s.NotifyTrade(trade)
+	s.NotifyDeleteTrade(trade.ID)
+	assert.Equal(t, int64(0), getTestTotalVolume(s))
+	assert.Equal(t, []common.Hash{trade.Hash}, n.reverts)
+}
+
+func TestRebuildRelayerStats(t *testing.T) {
+	s, _ := newTestTradeService()
+	now := time.Now().Unix()
+	// volume of a trade missing from trade collection is not counted again
+	stale := newTestTrade(1, now-10*24*60*60, 10, 1, sideBuy)
+	s.addTrade(stale)
+	s.NotifyTrade(newTestTrade(2, now-60, 10, 1, sideBuy))
+
+	s.mutex.Lock()
+	s.pruneTrades()
+	rebuild := newRelayerStatsRebuild(true, true, 0, now-tradeHashRetention)
+	s.rebuild = rebuild
+	s.countRelayerStats(rebuild, []*types.Trade{newTestTrade(3, now-9*24*60*60, 10, 1, sideBuy)})
+	s.mutex.Unlock()
+
+	// trades changed during the rebuild are counted with their last version
+	s.NotifyTrade(newTestTrade(4, now-30, 10, 1, sideBuy))
+	s.NotifyTrade(newTestTrade(2, now-60, 10, 5, sideBuy))
+	s.mutex.Lock()
+	s.applyRelayerStats(rebuild)
+	s.rebuild = nil
+	s.mutex.Unlock()
+
+	count := int64(0)
+	for _, fee := range s.GetRelayerFees(testRelayer, 0, 0) {
+		count += fee.Count.Int64()
+	}
+	assert.Equal(t, int64(3), count)
+	volume := int64(0)
+	for _, flow := range s.GetRelayerFlows(testBaseToken, testQuoteToken, 0, 0, nil) {
+		volume += flow.Volume.Int64()
+	}
+	assert.Equal(t, int64(7), volume)
+	modTime, _ := utils.GetModTime(stale.CreatedAt.Unix(), duration, unit)
+	staleKey := s.getRelayerFeeStoreKey(modTime, testRelayer, testBaseToken, testQuoteToken)
+	if fee, ok := s.tradeCache.dirtyRelayerFees[staleKey]; assert.True(t, ok) {
+		assert.Nil(t, fee)
+	}
+	assert.True(t, s.tradeCache.relayerFeesFetched)
+}
+
+func TestTradeLastPrice(t *testing.T) {
+	s, _ := newTestTradeService()
+	now := time.Now().Unix()
+	s.NotifyTrade(newTestTrade(1, now, 10, 1, sideBuy))
+	// an older trade of the backfill does not change the last price
+	s.NotifyTrade(newTestTrade(2, now-60, 9, 1, sideBuy))
+	assert.Equal(t, int64(10), getTestLastPrice(s))
+	// a trade which is not counted does not change the last price
+	failed := newTestTrade(3, now+1, 8, 1, sideBuy)
+	failed.Status = types.TradeStatusError
+	s.NotifyTrade(failed)
+	assert.Equal(t, int64(10), getTestLastPrice(s))
+}
+
+func TestUserTradeProfile(t *testing.T) {
+	s, _ := newTestTradeService()
+	now := time.Now().Unix()
+	other := common.HexToAddress("0x0000000000000000000000000000000000000a03")
+	s.NotifyTrade(newTestTrade(1, now-20, 10, 2, sideBuy))
+	trade := newTestTrade(2, now-10, 10, 5, sideBuy)
+	trade.Taker = other
+	s.NotifyTrade(trade)
+
+	// the maker ranks first on the pair and on the quote token, the taker last
+	for userAddress, rank := range map[common.Address]int{testMaker: 1, other: 2, testTaker: 3} {
+		pairs, quotes := s.GetUserTradeProfile(userAddress)
+		if !assert.Len(t, pairs, 1) || !assert.Len(t, quotes, 1) {
+			return
+		}
+		assert.Equal(t, rank, pairs[0].Rank)
+		assert.Equal(t, rank, quotes[0].Rank)
+		assert.Equal(t, int64(0), pairs[0].VolumeUnsplit.Int64())
+	}
+	pairs, _ := s.GetUserTradeProfile(testMaker)
+	assert.Equal(t, int64(7), pairs[0].Volume.Int64())
+	assert.Equal(t, float64(1), pairs[0].MakerShare)
+
+	// a user without trade has no profile
+	pairs, quotes := s.GetUserTradeProfile(common.HexToAddress("0x0000000000000000000000000000000000000a04"))
+	assert.Empty(t, pairs)
+	assert.Empty(t, quotes)
+}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/tomochain/tomox-stats/daos"
+	"github.com/tomochain/tomox-stats/types"
+)
+
+// UserProfileService gathers trading and lending activity of an address
+type UserProfileService struct {
+	tradeDao            *daos.TradeDao
+	tradeService        *TradeService
+	lendingTradeService *LendingTradeService
+}
+
+// NewUserProfileService init new instance
+func NewUserProfileService(tradeDao *daos.TradeDao, tradeService *TradeService, lendingTradeService *LendingTradeService) *UserProfileService {
+	return &UserProfileService{
+		tradeDao:            tradeDao,
+		tradeService:        tradeService,
+		lendingTradeService: lendingTradeService,
+	}
+}
+
+// GetUserProfile get profile of user, first and last trade times are 0 if user has no trade
+func (s *UserProfileService) GetUserProfile(userAddress common.Address) (*types.UserProfile, error) {
+	first, err := s.tradeDao.GetUserTrade(userAddress, []string{"+createdAt"})
+	if err != nil {
+		return nil, err
+	}
+	last, err := s.tradeDao.GetUserTrade(userAddress, []string{"-createdAt"})
+	if err != nil {
+		return nil, err
+	}
+	profile := &types.UserProfile{
+		UserAddress: userAddress,
+		Relayers:    s.tradeService.GetUserRelayers(userAddress),
+		Lending:     s.lendingTradeService.GetUserLendingProfile(userAddress),
+	}
+	if first != nil {
+		profile.FirstTradeTime = first.CreatedAt.Unix()
+	}
+	if last != nil {
+		profile.LastTradeTime = last.CreatedAt.Unix()
+	}
+	profile.Pairs, profile.QuoteTokens = s.tradeService.GetUserTradeProfile(userAddress)
+	return profile, nil
+}
@@ -0,0 +1,478 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/tomochain/tomox-stats/types"
+	"github.com/tomochain/tomox-stats/utils"
+)
+
+const (
+	// VolumeEventSnapshot is the event of the top users of a channel
+	VolumeEventSnapshot = "snapshot"
+	// VolumeEventDelta is the event of the volume changes of a trade
+	VolumeEventDelta = "delta"
+
+	// VolumeWindowAll counts volumes of all time
+	VolumeWindowAll = "all"
+	// VolumeWindowDay counts volumes of the last 24 hours
+	VolumeWindowDay = "24h"
+	// VolumeWindowWeek counts volumes of the last 7 days
+	VolumeWindowWeek = "7d"
+	// VolumeWindowMonth counts volumes of the last 30 days
+	VolumeWindowMonth = "30d"
+
+	// volumeStreamBuffer is the number of events a subscriber can lag behind before being dropped
+	volumeStreamBuffer = 256
+	// maxVolumeChannels is the number of channels with subscribers the service keeps up to date
+	maxVolumeChannels = 500
+)
+
+// ErrTooManyVolumeChannels is returned when a new channel would exceed maxVolumeChannels
+var ErrTooManyVolumeChannels = errors.New("Too many volume streams")
+
+// volumeWindows length of volume windows in seconds, 0 for no limit
+var volumeWindows = map[string]int64{
+	VolumeWindowAll:   0,
+	VolumeWindowDay:   24 * 60 * 60,
+	VolumeWindowWeek:  7 * 24 * 60 * 60,
+	VolumeWindowMonth: 30 * 24 * 60 * 60,
+}
+
+// VolumeStreamService pushes user volume and rank changes to subscribers of a relayer/pair/quote channel
+// channels are updated from the trade change stream and resynced with TradeService every minute
+// the resync moves the start of windowed channels, a channel is removed with its last subscriber
+type VolumeStreamService struct {
+	tradeService *TradeService
+	// channelID => channel with subscribers
+	channels map[string]*volumeChannel
+	mutex    sync.Mutex
+}
+
+// VolumeSubscription receives the events of a channel until it is unsubscribed
+// Events is closed if the subscriber does not read its events fast enough
+type VolumeSubscription struct {
+	Events  chan *types.VolumeStreamEvent
+	channel *volumeChannel
+	top     int
+}
+
+type volumeChannel struct {
+	id             string
+	relayerAddress common.Address
+	// empty base token for all pairs of quote token
+	baseToken  common.Address
+	quoteToken common.Address
+	window     string
+	// start of window, time frames before it are not counted
+	from        int64
+	volumes     map[common.Address]*big.Int
+	ranking     []common.Address
+	subscribers map[*VolumeSubscription]bool
+}
+
+// NewVolumeStreamService init new instance
+func NewVolumeStreamService(tradeService *TradeService) *VolumeStreamService {
+	return &VolumeStreamService{
+		tradeService: tradeService,
+		channels:     make(map[string]*volumeChannel),
+	}
+}
+
+// Init resync channels every minute, address list changes are not notified
+func (s *VolumeStreamService) Init() {
+	ticker := time.NewTicker(60 * time.Second)
+	go func() {
+		for range ticker.C {
+			s.resync()
+		}
+	}()
+}
+
+// IsValidVolumeWindow check volume window, empty is VolumeWindowAll
+func (s *VolumeStreamService) IsValidVolumeWindow(window string) bool {
+	if window == "" {
+		return true
+	}
+	_, ok := volumeWindows[window]
+	return ok
+}
+
+// getWindowFrom get start of volume window at time now, 0 for no limit
+func getWindowFrom(window string, now int64) int64 {
+	if volumeWindows[window] == 0 {
+		return 0
+	}
+	return now - volumeWindows[window]
+}
+
+// GetVolumeChannelID get channel of relayer, pair or quote token volumes in window
+// empty relayer address for all relayers, empty base token for all pairs of quote token
+func GetVolumeChannelID(relayerAddress, baseToken, quoteToken common.Address, window string) string {
+	var id string
+	if (baseToken == common.Address{}) {
+		id = utils.GetMarketsChannelID(quoteToken.Hex())
+	} else {
+		id = utils.GetTradeChannelID(baseToken, quoteToken)
+	}
+	if (relayerAddress != common.Address{}) {
+		id = fmt.Sprintf("%s::%s", strings.ToLower(relayerAddress.Hex()), id)
+	}
+	if window != "" && window != VolumeWindowAll {
+		id = fmt.Sprintf("%s::%s", id, window)
+	}
+	return id
+}
+
+// Subscribe subscribe to volumes of channel, the first event is the snapshot of top users
+// volumes are counted in the time frames of window, ErrTooManyVolumeChannels if the channel can not be created
+func (s *VolumeStreamService) Subscribe(relayerAddress, baseToken, quoteToken common.Address, window string, top int) (*VolumeSubscription, error) {
+	if top == 0 {
+		top = 10
+	}
+	if window == "" {
+		window = VolumeWindowAll
+	}
+	id := GetVolumeChannelID(relayerAddress, baseToken, quoteToken, window)
+	sub := &VolumeSubscription{
+		Events: make(chan *types.VolumeStreamEvent, volumeStreamBuffer),
+		top:    top,
+	}
+
+	s.mutex.Lock()
+	if c, ok := s.channels[id]; ok {
+		s.addSubscriber(c, sub)
+		s.mutex.Unlock()
+		return sub, nil
+	}
+	full := len(s.channels) >= maxVolumeChannels
+	s.mutex.Unlock()
+	if full {
+		return nil, ErrTooManyVolumeChannels
+	}
+
+	// the channel is seeded under TradeService lock so no trade is missed or counted twice
+	c := &volumeChannel{
+		id:             id,
+		relayerAddress: relayerAddress,
+		baseToken:      baseToken,
+		quoteToken:     quoteToken,
+		window:         window,
+		from:           getWindowFrom(window, time.Now().Unix()),
+		subscribers:    make(map[*VolumeSubscription]bool),
+	}
+	var err error
+	s.tradeService.readUserVolumes(relayerAddress, c.getBaseTokens(), quoteToken, c.from, 0, func(volumes map[common.Address]*big.Int) {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		if last, ok := s.channels[id]; ok {
+			c = last
+		} else if len(s.channels) >= maxVolumeChannels {
+			err = ErrTooManyVolumeChannels
+			return
+		} else {
+			s.setVolumes(c, volumes)
+			s.channels[id] = c
+		}
+		s.addSubscriber(c, sub)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// Unsubscribe stop events of subscription, channel is removed with its last subscriber
+func (s *VolumeStreamService) Unsubscribe(sub *VolumeSubscription) {
+	if sub == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.removeSubscriber(sub)
+}
+
+// addSubscriber register subscriber and send it the channel snapshot, need to be lock
+func (s *VolumeStreamService) addSubscriber(c *volumeChannel, sub *VolumeSubscription) {
+	sub.channel = c
+	c.subscribers[sub] = true
+	sub.Events <- c.getSnapshot(sub.top)
+}
+
+// removeSubscriber need to be lock
+func (s *VolumeStreamService) removeSubscriber(sub *VolumeSubscription) {
+	c := sub.channel
+	if !c.subscribers[sub] {
+		return
+	}
+	delete(c.subscribers, sub)
+	close(sub.Events)
+	if len(c.subscribers) == 0 && s.channels[c.id] == c {
+		delete(s.channels, c.id)
+	}
+}
+
+// send event to subscriber without blocking, a lagging subscriber is dropped, need to be lock
+func (s *VolumeStreamService) send(sub *VolumeSubscription, event *types.VolumeStreamEvent) {
+	select {
+	case sub.Events <- event:
+	default:
+		s.removeSubscriber(sub)
+	}
+}
+
+// NotifyTrade push the volume changes of trade to its channels
+// it is called under TradeService lock with counted trades only
+func (s *VolumeStreamService) NotifyTrade(trade *types.Trade) error {
+	s.pushTrade(trade, 1)
+	return nil
+}
+
+// NotifyRevertTrade push the volume changes of a reverted trade to its channels
+// it is called under TradeService lock with trades which were counted
+func (s *VolumeStreamService) NotifyRevertTrade(trade *types.Trade) error {
+	s.pushTrade(trade, -1)
+	return nil
+}
+
+// pushTrade add volume of trade to its channels with sign 1, or remove it with sign -1
+func (s *VolumeStreamService) pushTrade(trade *types.Trade, sign int64) {
+	if trade == nil || s.tradeService.isWashTrade(trade.Maker, trade.Taker) {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.channels) == 0 {
+		return
+	}
+	modTime, _ := utils.GetModTime(trade.CreatedAt.Unix(), duration, unit)
+	volumeByQuote := s.tradeService.getVolumeByQuote(trade.BaseToken, trade.QuoteToken, trade.Amount, trade.PricePoint)
+	for _, c := range s.channels {
+		if !c.isTradeChannel(trade, modTime) {
+			continue
+		}
+		// the volume of all relayers counts the trade once by relayer, like getUserVolumes
+		relayers := c.getTradeRelayers(trade)
+		if relayers == 0 {
+			continue
+		}
+		amount := new(big.Int).Mul(volumeByQuote, big.NewInt(relayers))
+		if amount.Sign() <= 0 {
+			continue
+		}
+		amount = amount.Mul(amount, big.NewInt(sign))
+		var deltas []*types.VolumeDelta
+		for _, side := range s.tradeService.getTradeSides(trade) {
+			if s.tradeService.isBotAddress(side.userAddress) {
+				continue
+			}
+			if d := c.addVolume(side.userAddress, amount); d != nil {
+				deltas = append(deltas, d)
+			}
+		}
+		s.sendDeltas(c, deltas)
+	}
+}
+
+// sendDeltas send deltas to subscribers showing their users, need to be lock
+func (s *VolumeStreamService) sendDeltas(c *volumeChannel, deltas []*types.VolumeDelta) {
+	if len(deltas) == 0 {
+		return
+	}
+	now := time.Now().Unix()
+	for sub := range c.subscribers {
+		var res []*types.VolumeDelta
+		for _, d := range deltas {
+			if (d.Rank > 0 && d.Rank <= sub.top) || (d.PreviousRank > 0 && d.PreviousRank <= sub.top) {
+				res = append(res, d)
+			}
+		}
+		if len(res) == 0 {
+			continue
+		}
+		s.send(sub, &types.VolumeStreamEvent{
+			Event:     VolumeEventDelta,
+			Channel:   c.id,
+			Deltas:    res,
+			TimeStamp: now,
+		})
+	}
+}
+
+// resync reload volumes of channels from TradeService at the current start of their window
+// subscribers get a new snapshot if volumes have changed, channels without subscribers are removed
+func (s *VolumeStreamService) resync() {
+	s.mutex.Lock()
+	var channels []*volumeChannel
+	for id, c := range s.channels {
+		if len(c.subscribers) == 0 {
+			delete(s.channels, id)
+			continue
+		}
+		channels = append(channels, c)
+	}
+	s.mutex.Unlock()
+
+	now := time.Now().Unix()
+	for _, c := range channels {
+		from := getWindowFrom(c.window, now)
+		s.tradeService.readUserVolumes(c.relayerAddress, c.getBaseTokens(), c.quoteToken, from, 0, func(volumes map[common.Address]*big.Int) {
+			s.mutex.Lock()
+			defer s.mutex.Unlock()
+			if s.channels[c.id] != c {
+				return
+			}
+			c.from = from
+			if !s.setVolumes(c, volumes) {
+				return
+			}
+			for sub := range c.subscribers {
+				s.send(sub, c.getSnapshot(sub.top))
+			}
+		})
+	}
+}
+
+// setVolumes replace volumes of channel, bots are excluded
+// return true if volumes have changed, need to be lock
+func (s *VolumeStreamService) setVolumes(c *volumeChannel, volumes map[common.Address]*big.Int) bool {
+	res := make(map[common.Address]*big.Int)
+	for a, v := range volumes {
+		if v.Sign() > 0 && !s.tradeService.isBotAddress(a) {
+			res[a] = new(big.Int).Set(v)
+		}
+	}
+	changed := len(res) != len(c.volumes)
+	for a, v := range res {
+		if last, ok := c.volumes[a]; !ok || last.Cmp(v) != 0 {
+			changed = true
+		}
+	}
+	if !changed {
+		return false
+	}
+	c.volumes = res
+	c.ranking = make([]common.Address, 0, len(res))
+	for a := range res {
+		c.ranking = append(c.ranking, a)
+	}
+	sort.Slice(c.ranking, func(i, j int) bool {
+		return c.isAhead(c.ranking[i], c.ranking[j])
+	})
+	return true
+}
+
+func (c *volumeChannel) getBaseTokens() []common.Address {
+	if (c.baseToken == common.Address{}) {
+		return nil
+	}
+	return []common.Address{c.baseToken}
+}
+
+func (c *volumeChannel) isTradeChannel(trade *types.Trade, modTime int64) bool {
+	if trade.QuoteToken.Hex() != c.quoteToken.Hex() {
+		return false
+	}
+	if (c.baseToken != common.Address{}) && trade.BaseToken.Hex() != c.baseToken.Hex() {
+		return false
+	}
+	return c.from == 0 || modTime >= c.from
+}
+
+// getTradeRelayers get number of times the trade is counted in channel
+func (c *volumeChannel) getTradeRelayers(trade *types.Trade) int64 {
+	if (c.relayerAddress == common.Address{}) {
+		if trade.MakerExchange.Hex() == trade.TakerExchange.Hex() {
+			return 1
+		}
+		return 2
+	}
+	if trade.MakerExchange.Hex() == c.relayerAddress.Hex() || trade.TakerExchange.Hex() == c.relayerAddress.Hex() {
+		return 1
+	}
+	return 0
+}
+
+// isAhead order users by volume, then by address to keep ranks stable
+func (c *volumeChannel) isAhead(a1, a2 common.Address) bool {
+	if cmp := c.volumes[a1].Cmp(c.volumes[a2]); cmp != 0 {
+		return cmp > 0
+	}
+	return a1.Hex() < a2.Hex()
+}
+
+// addVolume add volume of user and move it in the ranking, a negative amount is a reverted trade
+// a user without volume left is removed with rank 0, nil if the user has no volume to revert
+func (c *volumeChannel) addVolume(user common.Address, amount *big.Int) *types.VolumeDelta {
+	previousRank := 0
+	index := len(c.ranking)
+	if _, ok := c.volumes[user]; ok {
+		for i, a := range c.ranking {
+			if a == user {
+				index = i
+				previousRank = i + 1
+				break
+			}
+		}
+		c.volumes[user] = new(big.Int).Add(c.volumes[user], amount)
+	} else if amount.Sign() > 0 {
+		c.volumes[user] = new(big.Int).Set(amount)
+		c.ranking = append(c.ranking, user)
+	} else {
+		return nil
+	}
+	if c.volumes[user].Sign() <= 0 {
+		delete(c.volumes, user)
+		c.ranking = append(c.ranking[:index], c.ranking[index+1:]...)
+		return &types.VolumeDelta{
+			UserAddress:  user,
+			Volume:       big.NewInt(0),
+			Delta:        amount,
+			Rank:         0,
+			PreviousRank: previousRank,
+		}
+	}
+	for index > 0 && c.isAhead(user, c.ranking[index-1]) {
+		c.ranking[index] = c.ranking[index-1]
+		index--
+	}
+	for index < len(c.ranking)-1 && c.isAhead(c.ranking[index+1], user) {
+		c.ranking[index] = c.ranking[index+1]
+		index++
+	}
+	c.ranking[index] = user
+	return &types.VolumeDelta{
+		UserAddress:  user,
+		Volume:       new(big.Int).Set(c.volumes[user]),
+		Delta:        amount,
+		Rank:         index + 1,
+		PreviousRank: previousRank,
+	}
+}
+
+func (c *volumeChannel) getSnapshot(top int) *types.VolumeStreamEvent {
+	if top > len(c.ranking) {
+		top = len(c.ranking)
+	}
+	users := make([]*types.UserVolume, 0, top)
+	for i, a := range c.ranking[:top] {
+		users = append(users, &types.UserVolume{
+			UserAddress: a,
+			Volume:      new(big.Int).Set(c.volumes[a]),
+			Rank:        i + 1,
+		})
+	}
+	return &types.VolumeStreamEvent{
+		Event:     VolumeEventSnapshot,
+		Channel:   c.id,
+		Users:     users,
+		TimeStamp: time.Now().Unix(),
+	}
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVolumeStreamWindow(t *testing.T) {
+	tradeService, _ := newTestTradeService()
+	s := NewVolumeStreamService(tradeService)
+	tradeService.AddNotifier(s)
+	now := time.Now().Unix()
+	tradeService.NotifyTrade(newTestTrade(1, now-2*24*60*60, 100, 10, sideBuy))
+	tradeService.NotifyTrade(newTestTrade(2, now-60, 100, 5, sideBuy))
+
+	assert.True(t, s.IsValidVolumeWindow(""))
+	assert.True(t, s.IsValidVolumeWindow(VolumeWindowWeek))
+	assert.False(t, s.IsValidVolumeWindow("1h"))
+
+	day, err := s.Subscribe(testRelayer, testBaseToken, testQuoteToken, VolumeWindowDay, 10)
+	assert.NoError(t, err)
+	snapshot := <-day.Events
+	if assert.Len(t, snapshot.Users, 2) {
+		assert.Equal(t, int64(500), snapshot.Users[0].Volume.Int64())
+	}
+	all, err := s.Subscribe(testRelayer, testBaseToken, testQuoteToken, "", 10)
+	assert.NoError(t, err)
+	snapshot = <-all.Events
+	if assert.Len(t, snapshot.Users, 2) {
+		assert.Equal(t, int64(1500), snapshot.Users[0].Volume.Int64())
+	}
+	assert.Len(t, s.channels, 2)
+
+	// a trade of the day is pushed to both windows
+	tradeService.NotifyTrade(newTestTrade(3, now-30, 100, 1, sideBuy))
+	delta := <-day.Events
+	assert.Equal(t, int64(600), delta.Deltas[0].Volume.Int64())
+	delta = <-all.Events
+	assert.Equal(t, int64(1600), delta.Deltas[0].Volume.Int64())
+
+	s.Unsubscribe(day)
+	assert.Len(t, s.channels, 1)
+
+	// no new channel above the limit, channels left without subscribers are removed by resync
+	for i := len(s.channels); i < maxVolumeChannels; i++ {
+		s.channels[fmt.Sprintf("test::%d", i)] = &volumeChannel{subscribers: make(map[*VolumeSubscription]bool)}
+	}
+	_, err = s.Subscribe(testRelayer, testBaseToken, testQuoteToken, VolumeWindowMonth, 10)
+	assert.Equal(t, ErrTooManyVolumeChannels, err)
+	s.resync()
+	assert.Len(t, s.channels, 1)
+	month, err := s.Subscribe(testRelayer, testBaseToken, testQuoteToken, VolumeWindowMonth, 10)
+	assert.NoError(t, err)
+	s.Unsubscribe(month)
+	s.Unsubscribe(all)
+	assert.Len(t, s.channels, 0)
+}
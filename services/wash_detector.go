@@ -0,0 +1,506 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/tomochain/tomox-stats/daos"
+	"github.com/tomochain/tomox-stats/types"
+	"github.com/tomochain/tomox-stats/utils"
+)
+
+const (
+	// washDetectionWindow is the period of trades analysed by the detector
+	washDetectionWindow = 7 * 24 * 60 * 60
+	// washSamePriceInterval is the max number of seconds between two suspicious trades at the same price
+	washSamePriceInterval = 10
+	// washMinTrades is the number of trades between two addresses needed to score them
+	washMinTrades = 4
+	// washMaxRoundTrips is the number of round trips giving the full round trip score
+	washMaxRoundTrips = 10
+	// WashMinScore is the default score of listed clusters
+	WashMinScore = 0.5
+)
+
+// FundingSourceProvider gives the address which first funded an address, if known
+type FundingSourceProvider interface {
+	GetFundingSource(address common.Address) (common.Address, bool)
+}
+
+// washPairStats is the activity between two addresses on a pair in one time frame
+type washPairStats struct {
+	count           int
+	volume          *big.Int
+	netPosition     *big.Int
+	roundTrips      int
+	samePriceTrades int
+	firstTrade      int64
+	lastTrade       int64
+}
+
+// washPair is the activity between two addresses on a pair, addressA is the lowest address
+type washPair struct {
+	addressA   common.Address
+	addressB   common.Address
+	baseToken  common.Address
+	quoteToken common.Address
+	// time => washPairStats
+	stats map[int64]*washPairStats
+	// trades of detection window in time order, to rebuild stats when a trade is reverted or late
+	trades []*types.Trade
+	// 1 if addressA bought in last trade, -1 if it sold
+	lastSide  int
+	lastPrice *big.Int
+	lastTime  int64
+}
+
+// WashDetectorService scores address pairs of the trade stream looking like wash trading
+// a pair is suspicious when it makes repeated round trips, ends with a near zero net position
+// over its volume, trades at the same price within seconds or has a common funding source
+type WashDetectorService struct {
+	tradeDao           *daos.TradeDao
+	addressListService *AddressListService
+	fundingSource      FundingSourceProvider
+	// addressA::addressB::pair => washPair
+	pairs map[string]*washPair
+	// tradeHash => trade time, trades already analysed
+	trades map[common.Hash]int64
+	// clusterID => cluster listed to users, whatever its filters, so that it can be promoted
+	listed      map[string]*listedCluster
+	listedMutex sync.Mutex
+	// trade statuses counted in volume
+	tradeStatuses map[string]bool
+	mutex         sync.RWMutex
+}
+
+// listedCluster is the addresses of a cluster returned by GetClusters
+type listedCluster struct {
+	addresses []common.Address
+	listedAt  int64
+}
+
+// NewWashDetectorService init new instance
+func NewWashDetectorService(tradeDao *daos.TradeDao, addressListService *AddressListService) *WashDetectorService {
+	return &WashDetectorService{
+		tradeDao:           tradeDao,
+		addressListService: addressListService,
+		pairs:              make(map[string]*washPair),
+		trades:             make(map[common.Hash]int64),
+		listed:             make(map[string]*listedCluster),
+		tradeStatuses:      newTradeStatuses(),
+	}
+}
+
+// SetFundingSourceProvider enable common funding source evidence, none is used by default
+func (s *WashDetectorService) SetFundingSourceProvider(p FundingSourceProvider) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.fundingSource = p
+}
+
+// Init analyse trades of detection window
+func (s *WashDetectorService) Init() {
+	now := time.Now().Unix()
+	s.fetch(now-washDetectionWindow, now)
+	ticker := time.NewTicker(60 * time.Second)
+	go func() {
+		for range ticker.C {
+			s.prune()
+		}
+	}()
+}
+
+func (s *WashDetectorService) fetch(fromdate int64, todate int64) {
+	pageOffset := 0
+	size := 1000
+	for {
+		trades, err := s.tradeDao.GetTradeByTime(fromdate, todate, pageOffset*size, size)
+		if err != nil || len(trades) == 0 {
+			break
+		}
+		s.mutex.Lock()
+		for _, trade := range trades {
+			if s.tradeStatuses[trade.Status] {
+				s.addTrade(trade)
+			}
+		}
+		s.mutex.Unlock()
+		pageOffset = pageOffset + 1
+	}
+}
+
+// NotifyTrade analyse trade
+// TradeService only notifies trades with counted status
+func (s *WashDetectorService) NotifyTrade(trade *types.Trade) error {
+	if trade == nil {
+		return nil
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.addTrade(trade)
+	return nil
+}
+
+// addTrade add trade to the stats of its maker and taker, need to be lock
+func (s *WashDetectorService) addTrade(trade *types.Trade) {
+	if _, ok := s.trades[trade.Hash]; ok {
+		return
+	}
+	tradeTime := trade.CreatedAt.Unix()
+	if tradeTime < time.Now().Unix()-washDetectionWindow || trade.Amount == nil || trade.PricePoint == nil {
+		return
+	}
+	s.trades[trade.Hash] = tradeTime
+	if trade.Maker == trade.Taker {
+		// self trades are always excluded
+		return
+	}
+
+	addressA, addressB := trade.Maker, trade.Taker
+	if strings.ToLower(addressA.Hex()) > strings.ToLower(addressB.Hex()) {
+		addressA, addressB = addressB, addressA
+	}
+	key := fmt.Sprintf("%s::%s::%s", addressA.Hex(), addressB.Hex(), utils.GetPairKey(trade.BaseToken, trade.QuoteToken))
+	pair, ok := s.pairs[key]
+	if !ok {
+		pair = &washPair{
+			addressA:   addressA,
+			addressB:   addressB,
+			baseToken:  trade.BaseToken,
+			quoteToken: trade.QuoteToken,
+			stats:      make(map[int64]*washPairStats),
+		}
+		s.pairs[key] = pair
+	}
+	pair.add(trade)
+}
+
+// NotifyRevertTrade remove trade from the stats of its maker and taker
+func (s *WashDetectorService) NotifyRevertTrade(trade *types.Trade) error {
+	if trade == nil {
+		return nil
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.trades[trade.Hash]; !ok {
+		return nil
+	}
+	delete(s.trades, trade.Hash)
+	if trade.Maker == trade.Taker {
+		return nil
+	}
+	addressA, addressB := trade.Maker, trade.Taker
+	if strings.ToLower(addressA.Hex()) > strings.ToLower(addressB.Hex()) {
+		addressA, addressB = addressB, addressA
+	}
+	key := fmt.Sprintf("%s::%s::%s", addressA.Hex(), addressB.Hex(), utils.GetPairKey(trade.BaseToken, trade.QuoteToken))
+	if pair, ok := s.pairs[key]; ok {
+		pair.remove(trade.Hash)
+		if len(pair.trades) == 0 {
+			delete(s.pairs, key)
+		}
+	}
+	return nil
+}
+
+// add insert trade in time order, stats are rebuilt if it is not the latest trade
+func (pair *washPair) add(trade *types.Trade) {
+	i := sort.Search(len(pair.trades), func(i int) bool {
+		t := pair.trades[i]
+		return trade.CreatedAt.Before(t.CreatedAt) || (trade.CreatedAt.Equal(t.CreatedAt) && trade.ID < t.ID)
+	})
+	pair.trades = append(pair.trades, nil)
+	copy(pair.trades[i+1:], pair.trades[i:])
+	pair.trades[i] = trade
+	if i == len(pair.trades)-1 {
+		pair.apply(trade)
+		return
+	}
+	pair.rebuild()
+}
+
+// remove trade by hash and rebuild stats without it
+func (pair *washPair) remove(hash common.Hash) {
+	for i, t := range pair.trades {
+		if t.Hash == hash {
+			pair.trades = append(pair.trades[:i], pair.trades[i+1:]...)
+			pair.rebuild()
+			return
+		}
+	}
+}
+
+// rebuild replay trades of pair in time order
+func (pair *washPair) rebuild() {
+	pair.stats = make(map[int64]*washPairStats)
+	pair.lastSide = 0
+	pair.lastPrice = nil
+	pair.lastTime = 0
+	for _, t := range pair.trades {
+		pair.apply(t)
+	}
+}
+
+// apply add trade to the stats of its time frame
+func (pair *washPair) apply(trade *types.Trade) {
+	tradeTime := trade.CreatedAt.Unix()
+	side := 1
+	if (trade.TakerOrderSide == sideBuy) != (pair.addressA == trade.Taker) {
+		side = -1
+	}
+	modTime, _ := utils.GetModTime(tradeTime, duration, unit)
+	stats, ok := pair.stats[modTime]
+	if !ok {
+		stats = &washPairStats{
+			volume:      big.NewInt(0),
+			netPosition: big.NewInt(0),
+			firstTrade:  tradeTime,
+			lastTrade:   tradeTime,
+		}
+		pair.stats[modTime] = stats
+	}
+	stats.count++
+	stats.volume = new(big.Int).Add(stats.volume, trade.Amount)
+	stats.netPosition = new(big.Int).Add(stats.netPosition, new(big.Int).Mul(trade.Amount, big.NewInt(int64(side))))
+	if tradeTime < stats.firstTrade {
+		stats.firstTrade = tradeTime
+	}
+	if tradeTime > stats.lastTrade {
+		stats.lastTrade = tradeTime
+	}
+	if pair.lastSide != 0 && pair.lastSide != side {
+		stats.roundTrips++
+	}
+	if pair.lastPrice != nil && pair.lastPrice.Cmp(trade.PricePoint) == 0 && tradeTime-pair.lastTime <= washSamePriceInterval {
+		stats.samePriceTrades++
+	}
+	pair.lastSide = side
+	pair.lastPrice = trade.PricePoint
+	pair.lastTime = tradeTime
+}
+
+// prune remove time frames out of detection window
+func (s *WashDetectorService) prune() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	horizon := time.Now().Unix() - washDetectionWindow
+	for key, pair := range s.pairs {
+		for t, stats := range pair.stats {
+			if stats.lastTrade < horizon {
+				delete(pair.stats, t)
+			}
+		}
+		n := 0
+		for n < len(pair.trades) && pair.trades[n].CreatedAt.Unix() < horizon {
+			n++
+		}
+		pair.trades = pair.trades[n:]
+		if len(pair.stats) == 0 {
+			delete(s.pairs, key)
+		}
+	}
+	for hash, t := range s.trades {
+		if t < horizon {
+			delete(s.trades, hash)
+		}
+	}
+	s.listedMutex.Lock()
+	defer s.listedMutex.Unlock()
+	for id, c := range s.listed {
+		if c.listedAt < horizon {
+			delete(s.listed, id)
+		}
+	}
+}
+
+// getEvidence sum stats of pair and score it, need to be lock
+func (s *WashDetectorService) getEvidence(pair *washPair) *types.WashPairEvidence {
+	e := &types.WashPairEvidence{
+		AddressA:    pair.addressA,
+		AddressB:    pair.addressB,
+		BaseToken:   pair.baseToken,
+		QuoteToken:  pair.quoteToken,
+		Volume:      big.NewInt(0),
+		NetPosition: big.NewInt(0),
+	}
+	for _, stats := range pair.stats {
+		e.Count += stats.count
+		e.RoundTrips += stats.roundTrips
+		e.SamePriceTrades += stats.samePriceTrades
+		e.Volume = new(big.Int).Add(e.Volume, stats.volume)
+		e.NetPosition = new(big.Int).Add(e.NetPosition, stats.netPosition)
+		if e.FirstTrade == 0 || stats.firstTrade < e.FirstTrade {
+			e.FirstTrade = stats.firstTrade
+		}
+		if stats.lastTrade > e.LastTrade {
+			e.LastTrade = stats.lastTrade
+		}
+	}
+	if s.fundingSource != nil {
+		sourceA, okA := s.fundingSource.GetFundingSource(pair.addressA)
+		sourceB, okB := s.fundingSource.GetFundingSource(pair.addressB)
+		e.CommonFundingSource = okA && okB && sourceA == sourceB
+	}
+	e.Score = s.score(e)
+	return e
+}
+
+// score weight the evidences of a pair, between 0 and 1
+func (s *WashDetectorService) score(e *types.WashPairEvidence) float64 {
+	if e.Count < washMinTrades || e.Volume.Sign() <= 0 {
+		return 0
+	}
+	roundTrips := float64(e.RoundTrips) / washMaxRoundTrips
+	if roundTrips > 1 {
+		roundTrips = 1
+	}
+	ratio, _ := new(big.Float).Quo(new(big.Float).SetInt(new(big.Int).Abs(e.NetPosition)), new(big.Float).SetInt(e.Volume)).Float64()
+	balance := 1 - ratio
+	samePrice := float64(e.SamePriceTrades) / float64(e.Count-1)
+	score := 0.35*roundTrips + 0.3*balance + 0.25*samePrice
+	if e.CommonFundingSource {
+		score += 0.1
+	}
+	return score
+}
+
+// GetClusters get groups of addresses linked by suspicious pairs, sorted by score
+// empty token address for all tokens, pairs already in a wash group are skipped
+func (s *WashDetectorService) GetClusters(baseToken, quoteToken common.Address, minScore float64) []*types.WashCluster {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	index := s.addressListService.GetIndex()
+
+	var evidences []*types.WashPairEvidence
+	for _, pair := range s.pairs {
+		if ((baseToken != common.Address{}) && baseToken != pair.baseToken) || ((quoteToken != common.Address{}) && quoteToken != pair.quoteToken) {
+			continue
+		}
+		if index.IsWashTrade(pair.addressA, pair.addressB) {
+			continue
+		}
+		e := s.getEvidence(pair)
+		if e.Score > 0 && e.Score >= minScore {
+			evidences = append(evidences, e)
+		}
+	}
+
+	// addresses linked by an evidence are in the same cluster
+	parent := make(map[common.Address]common.Address)
+	var find func(a common.Address) common.Address
+	find = func(a common.Address) common.Address {
+		if p, ok := parent[a]; ok && p != a {
+			root := find(p)
+			parent[a] = root
+			return root
+		}
+		parent[a] = a
+		return a
+	}
+	for _, e := range evidences {
+		parent[find(e.AddressA)] = find(e.AddressB)
+	}
+	clusters := make(map[common.Address]*types.WashCluster)
+	for _, e := range evidences {
+		root := find(e.AddressA)
+		c, ok := clusters[root]
+		if !ok {
+			c = &types.WashCluster{}
+			clusters[root] = c
+		}
+		c.Evidences = append(c.Evidences, e)
+		if e.Score > c.Score {
+			c.Score = e.Score
+		}
+	}
+
+	res := []*types.WashCluster{}
+	for _, c := range clusters {
+		addresses := make(map[common.Address]bool)
+		for _, e := range c.Evidences {
+			addresses[e.AddressA] = true
+			addresses[e.AddressB] = true
+		}
+		for a := range addresses {
+			c.Addresses = append(c.Addresses, a)
+		}
+		sort.Slice(c.Addresses, func(i, j int) bool {
+			return strings.ToLower(c.Addresses[i].Hex()) < strings.ToLower(c.Addresses[j].Hex())
+		})
+		sort.Slice(c.Evidences, func(i, j int) bool {
+			return c.Evidences[i].Score > c.Evidences[j].Score
+		})
+		c.ID = getClusterID(c.Addresses)
+		res = append(res, c)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Score > res[j].Score
+	})
+	s.rememberClusters(res)
+	return res
+}
+
+// rememberClusters keep addresses of listed clusters for the detection window
+func (s *WashDetectorService) rememberClusters(clusters []*types.WashCluster) {
+	s.listedMutex.Lock()
+	defer s.listedMutex.Unlock()
+	now := time.Now().Unix()
+	for _, c := range clusters {
+		s.listed[c.ID] = &listedCluster{
+			addresses: c.Addresses,
+			listedAt:  now,
+		}
+	}
+}
+
+// getClusterID identify cluster by its sorted addresses
+func getClusterID(addresses []common.Address) string {
+	h := sha256.New()
+	for _, a := range addresses {
+		h.Write(a.Bytes())
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// PromoteCluster add the addresses of a listed cluster as a wash group
+// a cluster listed with any filters and min score in the detection window is found by its id,
+// other clusters are searched among all current clusters
+// return nil if the cluster is not found
+func (s *WashDetectorService) PromoteCluster(id string) (*types.WashGroup, error) {
+	addresses := s.getListedCluster(id)
+	if addresses == nil {
+		for _, c := range s.GetClusters(common.Address{}, common.Address{}, 0) {
+			if c.ID == id {
+				addresses = c.Addresses
+				break
+			}
+		}
+	}
+	if addresses == nil {
+		return nil, nil
+	}
+	g := &types.WashGroup{
+		Name:      "detected " + id,
+		Addresses: addresses,
+	}
+	if err := s.addressListService.CreateWashGroup(g); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+func (s *WashDetectorService) getListedCluster(id string) []common.Address {
+	s.listedMutex.Lock()
+	defer s.listedMutex.Unlock()
+	if c, ok := s.listed[id]; ok {
+		return c.addresses
+	}
+	return nil
+}
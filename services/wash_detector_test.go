@@ -0,0 +1,47 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestWashDetectorService() *WashDetectorService {
+	s := NewWashDetectorService(nil, NewAddressListService(nil, nil, nil))
+	now := time.Now().Unix()
+	// round trips at the same price within seconds
+	for i := int64(0); i < 6; i++ {
+		side := sideBuy
+		if i%2 == 1 {
+			side = sideSell
+		}
+		s.NotifyTrade(newTestTrade(i+1, now-60+i, 10, 5, side))
+	}
+	return s
+}
+
+func TestWashClusterID(t *testing.T) {
+	s := newTestWashDetectorService()
+	clusters := s.GetClusters(testBaseToken, testQuoteToken, WashMinScore)
+	if !assert.Len(t, clusters, 1) {
+		return
+	}
+	id := clusters[0].ID
+	assert.Equal(t, getClusterID(clusters[0].Addresses), id)
+	assert.Len(t, clusters[0].Addresses, 2)
+
+	// the id does not depend on the filters of the listing
+	all := s.GetClusters(common.Address{}, common.Address{}, 0)
+	assert.Equal(t, id, all[0].ID)
+	assert.Equal(t, clusters[0].Addresses, s.getListedCluster(id))
+}
+
+func TestWashClusterRevert(t *testing.T) {
+	s := newTestWashDetectorService()
+	for i := int64(1); i <= 6; i++ {
+		s.NotifyRevertTrade(newTestTrade(i, 0, 0, 0, sideBuy))
+	}
+	assert.Empty(t, s.GetClusters(common.Address{}, common.Address{}, 0))
+}
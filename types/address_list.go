@@ -0,0 +1,134 @@
+package types
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/globalsign/mgo/bson"
+	validation "github.com/go-ozzo/ozzo-validation"
+)
+
+// WashGroup is a group of addresses owned by the same trader
+// trades between two addresses of a group are wash trades and are not counted in relayer volume
+type WashGroup struct {
+	ID        bson.ObjectId    `json:"id" bson:"_id"`
+	Name      string           `json:"name" bson:"name"`
+	Addresses []common.Address `json:"addresses" bson:"addresses"`
+	CreatedAt time.Time        `json:"createdAt" bson:"createdAt"`
+	UpdatedAt time.Time        `json:"updatedAt" bson:"updatedAt"`
+}
+
+// WashGroupRecord corresponds to what is stored in the DB
+type WashGroupRecord struct {
+	ID        bson.ObjectId `json:"id" bson:"_id"`
+	Name      string        `json:"name" bson:"name"`
+	Addresses []string      `json:"addresses" bson:"addresses"`
+	CreatedAt time.Time     `json:"createdAt" bson:"createdAt"`
+	UpdatedAt time.Time     `json:"updatedAt" bson:"updatedAt"`
+}
+
+// GetBSON implements bson.Getter
+func (g *WashGroup) GetBSON() (interface{}, error) {
+	r := WashGroupRecord{
+		ID:        g.ID,
+		Name:      g.Name,
+		Addresses: []string{},
+		CreatedAt: g.CreatedAt,
+		UpdatedAt: g.UpdatedAt,
+	}
+	for _, a := range g.Addresses {
+		r.Addresses = append(r.Addresses, a.Hex())
+	}
+	return r, nil
+}
+
+// SetBSON implemenets bson.Setter
+func (g *WashGroup) SetBSON(raw bson.Raw) error {
+	decoded := &WashGroupRecord{}
+	if err := raw.Unmarshal(decoded); err != nil {
+		return err
+	}
+	g.ID = decoded.ID
+	g.Name = decoded.Name
+	g.Addresses = []common.Address{}
+	for _, a := range decoded.Addresses {
+		g.Addresses = append(g.Addresses, common.HexToAddress(a))
+	}
+	g.CreatedAt = decoded.CreatedAt
+	g.UpdatedAt = decoded.UpdatedAt
+	return nil
+}
+
+// Validate enforces the wash group model
+func (g WashGroup) Validate() error {
+	return validation.ValidateStruct(&g,
+		validation.Field(&g.Addresses, validation.Required, validation.By(func(value interface{}) error {
+			addresses := map[common.Address]bool{}
+			for _, a := range g.Addresses {
+				addresses[a] = true
+			}
+			if len(addresses) < 2 {
+				return errors.New("must have at least two different addresses")
+			}
+			return nil
+		})),
+	)
+}
+
+// BotAddress is a market maker address excluded from user rankings
+type BotAddress struct {
+	ID        bson.ObjectId  `json:"id" bson:"_id"`
+	Address   common.Address `json:"address" bson:"address"`
+	Name      string         `json:"name" bson:"name"`
+	CreatedAt time.Time      `json:"createdAt" bson:"createdAt"`
+}
+
+// BotAddressRecord corresponds to what is stored in the DB
+type BotAddressRecord struct {
+	ID        bson.ObjectId `json:"id" bson:"_id"`
+	Address   string        `json:"address" bson:"address"`
+	Name      string        `json:"name" bson:"name"`
+	CreatedAt time.Time     `json:"createdAt" bson:"createdAt"`
+}
+
+// GetBSON implements bson.Getter
+func (b *BotAddress) GetBSON() (interface{}, error) {
+	return BotAddressRecord{
+		ID:        b.ID,
+		Address:   b.Address.Hex(),
+		Name:      b.Name,
+		CreatedAt: b.CreatedAt,
+	}, nil
+}
+
+// SetBSON implemenets bson.Setter
+func (b *BotAddress) SetBSON(raw bson.Raw) error {
+	decoded := &BotAddressRecord{}
+	if err := raw.Unmarshal(decoded); err != nil {
+		return err
+	}
+	b.ID = decoded.ID
+	b.Address = common.HexToAddress(decoded.Address)
+	b.Name = decoded.Name
+	b.CreatedAt = decoded.CreatedAt
+	return nil
+}
+
+// Validate enforces the bot address model
+func (b BotAddress) Validate() error {
+	return validation.ValidateStruct(&b,
+		validation.Field(&b.Address, validation.By(func(value interface{}) error {
+			if (b.Address == common.Address{}) {
+				return errors.New("cannot be blank")
+			}
+			return nil
+		})),
+	)
+}
+
+// AddressListChangeEvent change event of wash group and bot address collections
+type AddressListChangeEvent struct {
+	ID            interface{} `bson:"_id"`
+	OperationType string      `bson:"operationType"`
+}
@@ -0,0 +1,267 @@
+package types
+
+import (
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/globalsign/mgo/bson"
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/tomochain/tomox-stats/utils/math"
+)
+
+const (
+	// CampaignMetricVolume ranks traders by volume in quote token
+	CampaignMetricVolume = "volume"
+	// CampaignMetricPnL ranks traders by profit and loss in quote token
+	CampaignMetricPnL = "pnl"
+)
+
+// CampaignPair is a pair eligible to a campaign
+type CampaignPair struct {
+	BaseToken  common.Address `json:"baseToken" bson:"baseToken"`
+	QuoteToken common.Address `json:"quoteToken" bson:"quoteToken"`
+}
+
+// CampaignPrize is the prize of ranks FromRank to ToRank included
+type CampaignPrize struct {
+	FromRank int    `json:"fromRank" bson:"fromRank"`
+	ToRank   int    `json:"toRank" bson:"toRank"`
+	Prize    string `json:"prize" bson:"prize"`
+}
+
+// Campaign is a trading competition of a relayer
+// all pairs have the same quote token, so that volumes and PnL can be added up
+type Campaign struct {
+	ID                bson.ObjectId    `json:"id" bson:"_id"`
+	Name              string           `json:"name" bson:"name"`
+	RelayerAddress    common.Address   `json:"relayerAddress" bson:"relayerAddress"`
+	Pairs             []CampaignPair   `json:"pairs" bson:"pairs"`
+	StartTime         int64            `json:"startTime" bson:"startTime"`
+	EndTime           int64            `json:"endTime" bson:"endTime"`
+	Metric            string           `json:"metric" bson:"metric"`
+	MinVolume         *big.Int         `json:"minVolume" bson:"minVolume"`
+	ExcludedAddresses []common.Address `json:"excludedAddresses" bson:"excludedAddresses"`
+	Prizes            []CampaignPrize  `json:"prizes" bson:"prizes"`
+	Finalized         bool             `json:"finalized" bson:"finalized"`
+	CreatedAt         time.Time        `json:"createdAt" bson:"createdAt"`
+	UpdatedAt         time.Time        `json:"updatedAt" bson:"updatedAt"`
+}
+
+// CampaignPairRecord corresponds to what is stored in the DB
+type CampaignPairRecord struct {
+	BaseToken  string `json:"baseToken" bson:"baseToken"`
+	QuoteToken string `json:"quoteToken" bson:"quoteToken"`
+}
+
+// CampaignRecord corresponds to what is stored in the DB. big.Ints are encoded as strings
+type CampaignRecord struct {
+	ID                bson.ObjectId        `json:"id" bson:"_id"`
+	Name              string               `json:"name" bson:"name"`
+	RelayerAddress    string               `json:"relayerAddress" bson:"relayerAddress"`
+	Pairs             []CampaignPairRecord `json:"pairs" bson:"pairs"`
+	StartTime         int64                `json:"startTime" bson:"startTime"`
+	EndTime           int64                `json:"endTime" bson:"endTime"`
+	Metric            string               `json:"metric" bson:"metric"`
+	MinVolume         string               `json:"minVolume" bson:"minVolume"`
+	ExcludedAddresses []string             `json:"excludedAddresses" bson:"excludedAddresses"`
+	Prizes            []CampaignPrize      `json:"prizes" bson:"prizes"`
+	Finalized         bool                 `json:"finalized" bson:"finalized"`
+	CreatedAt         time.Time            `json:"createdAt" bson:"createdAt"`
+	UpdatedAt         time.Time            `json:"updatedAt" bson:"updatedAt"`
+}
+
+// GetBSON implements bson.Getter
+func (c *Campaign) GetBSON() (interface{}, error) {
+	r := CampaignRecord{
+		ID:                c.ID,
+		Name:              c.Name,
+		RelayerAddress:    c.RelayerAddress.Hex(),
+		Pairs:             []CampaignPairRecord{},
+		StartTime:         c.StartTime,
+		EndTime:           c.EndTime,
+		Metric:            c.Metric,
+		ExcludedAddresses: []string{},
+		Prizes:            c.Prizes,
+		Finalized:         c.Finalized,
+		CreatedAt:         c.CreatedAt,
+		UpdatedAt:         c.UpdatedAt,
+	}
+	if c.MinVolume != nil {
+		r.MinVolume = c.MinVolume.String()
+	}
+	for _, p := range c.Pairs {
+		r.Pairs = append(r.Pairs, CampaignPairRecord{
+			BaseToken:  p.BaseToken.Hex(),
+			QuoteToken: p.QuoteToken.Hex(),
+		})
+	}
+	for _, a := range c.ExcludedAddresses {
+		r.ExcludedAddresses = append(r.ExcludedAddresses, a.Hex())
+	}
+	return r, nil
+}
+
+// SetBSON implemenets bson.Setter
+func (c *Campaign) SetBSON(raw bson.Raw) error {
+	decoded := &CampaignRecord{}
+	if err := raw.Unmarshal(decoded); err != nil {
+		return err
+	}
+	c.ID = decoded.ID
+	c.Name = decoded.Name
+	c.RelayerAddress = common.HexToAddress(decoded.RelayerAddress)
+	c.Pairs = []CampaignPair{}
+	for _, p := range decoded.Pairs {
+		c.Pairs = append(c.Pairs, CampaignPair{
+			BaseToken:  common.HexToAddress(p.BaseToken),
+			QuoteToken: common.HexToAddress(p.QuoteToken),
+		})
+	}
+	c.StartTime = decoded.StartTime
+	c.EndTime = decoded.EndTime
+	c.Metric = decoded.Metric
+	if decoded.MinVolume != "" {
+		c.MinVolume = math.ToBigInt(decoded.MinVolume)
+	}
+	c.ExcludedAddresses = []common.Address{}
+	for _, a := range decoded.ExcludedAddresses {
+		c.ExcludedAddresses = append(c.ExcludedAddresses, common.HexToAddress(a))
+	}
+	c.Prizes = decoded.Prizes
+	c.Finalized = decoded.Finalized
+	c.CreatedAt = decoded.CreatedAt
+	c.UpdatedAt = decoded.UpdatedAt
+	return nil
+}
+
+// Validate enforces the campaign model
+func (c Campaign) Validate() error {
+	return validation.ValidateStruct(&c,
+		validation.Field(&c.Name, validation.Required),
+		validation.Field(&c.Pairs, validation.Required, validation.By(func(value interface{}) error {
+			for _, p := range c.Pairs {
+				if p.QuoteToken != c.Pairs[0].QuoteToken {
+					return errors.New("must have the same quote token")
+				}
+			}
+			return nil
+		})),
+		validation.Field(&c.StartTime, validation.Required),
+		validation.Field(&c.EndTime, validation.Required, validation.Min(c.StartTime+1)),
+		validation.Field(&c.Metric, validation.In(CampaignMetricVolume, CampaignMetricPnL)),
+		validation.Field(&c.Prizes, validation.By(func(value interface{}) error {
+			for _, p := range c.Prizes {
+				if p.FromRank < 1 || p.ToRank < p.FromRank {
+					return errors.New("must have valid rank ranges")
+				}
+			}
+			return nil
+		})),
+	)
+}
+
+// QuoteToken get the quote token of campaign pairs
+func (c *Campaign) QuoteToken() common.Address {
+	if len(c.Pairs) == 0 {
+		return common.Address{}
+	}
+	return c.Pairs[0].QuoteToken
+}
+
+// BaseTokens get the base tokens of campaign pairs
+func (c *Campaign) BaseTokens() []common.Address {
+	var baseTokens []common.Address
+	for _, p := range c.Pairs {
+		baseTokens = append(baseTokens, p.BaseToken)
+	}
+	return baseTokens
+}
+
+// CampaignRank is the result of a trader in a campaign
+type CampaignRank struct {
+	Rank        int            `json:"rank" bson:"rank"`
+	UserAddress common.Address `json:"userAddress" bson:"userAddress"`
+	Volume      *big.Int       `json:"volume" bson:"volume"`
+	PnL         *big.Int       `json:"pnl,omitempty" bson:"pnl,omitempty"`
+	Prize       string         `json:"prize,omitempty" bson:"prize,omitempty"`
+}
+
+// CampaignRankRecord corresponds to what is stored in the DB. big.Ints are encoded as strings
+type CampaignRankRecord struct {
+	Rank        int    `json:"rank" bson:"rank"`
+	UserAddress string `json:"userAddress" bson:"userAddress"`
+	Volume      string `json:"volume" bson:"volume"`
+	PnL         string `json:"pnl,omitempty" bson:"pnl,omitempty"`
+	Prize       string `json:"prize,omitempty" bson:"prize,omitempty"`
+}
+
+// CampaignLeaderboard is the ranking of a campaign
+// the final leaderboard is stored when the campaign ends and never changes
+type CampaignLeaderboard struct {
+	ID         bson.ObjectId   `json:"-" bson:"_id"`
+	CampaignID bson.ObjectId   `json:"campaignId" bson:"campaignId"`
+	Final      bool            `json:"final" bson:"final"`
+	Ranks      []*CampaignRank `json:"ranks" bson:"ranks"`
+	CreatedAt  time.Time       `json:"createdAt" bson:"createdAt"`
+}
+
+// CampaignLeaderboardRecord corresponds to what is stored in the DB
+type CampaignLeaderboardRecord struct {
+	ID         bson.ObjectId         `json:"id" bson:"_id"`
+	CampaignID bson.ObjectId         `json:"campaignId" bson:"campaignId"`
+	Final      bool                  `json:"final" bson:"final"`
+	Ranks      []*CampaignRankRecord `json:"ranks" bson:"ranks"`
+	CreatedAt  time.Time             `json:"createdAt" bson:"createdAt"`
+}
+
+// GetBSON implements bson.Getter
+func (l *CampaignLeaderboard) GetBSON() (interface{}, error) {
+	r := CampaignLeaderboardRecord{
+		ID:         l.ID,
+		CampaignID: l.CampaignID,
+		Final:      l.Final,
+		Ranks:      []*CampaignRankRecord{},
+		CreatedAt:  l.CreatedAt,
+	}
+	for _, rank := range l.Ranks {
+		rr := &CampaignRankRecord{
+			Rank:        rank.Rank,
+			UserAddress: rank.UserAddress.Hex(),
+			Volume:      rank.Volume.String(),
+			Prize:       rank.Prize,
+		}
+		if rank.PnL != nil {
+			rr.PnL = rank.PnL.String()
+		}
+		r.Ranks = append(r.Ranks, rr)
+	}
+	return r, nil
+}
+
+// SetBSON implemenets bson.Setter
+func (l *CampaignLeaderboard) SetBSON(raw bson.Raw) error {
+	decoded := &CampaignLeaderboardRecord{}
+	if err := raw.Unmarshal(decoded); err != nil {
+		return err
+	}
+	l.ID = decoded.ID
+	l.CampaignID = decoded.CampaignID
+	l.Final = decoded.Final
+	l.CreatedAt = decoded.CreatedAt
+	l.Ranks = []*CampaignRank{}
+	for _, rr := range decoded.Ranks {
+		rank := &CampaignRank{
+			Rank:        rr.Rank,
+			UserAddress: common.HexToAddress(rr.UserAddress),
+			Volume:      math.ToBigInt(rr.Volume),
+			Prize:       rr.Prize,
+		}
+		if rr.PnL != "" {
+			rank.PnL = math.ToBigInt(rr.PnL)
+		}
+		l.Ranks = append(l.Ranks, rank)
+	}
+	return nil
+}
@@ -34,14 +34,14 @@ type LendingTrade struct {
 	InvestingRelayer       common.Address `bson:"investingRelayer" json:"investingRelayer"`
 	Term                   uint64         `bson:"term" json:"term"`
 	Interest               uint64         `bson:"interest" json:"interest"`
-	CollateralPrice        *big.Int       `bson:"collateralPrice" json:"collateralPrice"`
-	LiquidationPrice       *big.Int       `bson:"liquidationPrice" json:"liquidationPrice"`
-	CollateralLockedAmount *big.Int       `bson:"collateralLockedAmount" json:"collateralLockedAmount"`
+	CollateralPrice        *big.Int       `bson:"collateralPrice" json:"collateralPrice" export:"lendingToken"`
+	LiquidationPrice       *big.Int       `bson:"liquidationPrice" json:"liquidationPrice" export:"lendingToken"`
+	CollateralLockedAmount *big.Int       `bson:"collateralLockedAmount" json:"collateralLockedAmount" export:"collateralToken"`
 	LiquidationTime        uint64         `bson:"liquidationTime" json:"liquidationTime"`
 	DepositRate            *big.Int       `bson:"depositRate" json:"depositRate"`
-	Amount                 *big.Int       `bson:"amount" json:"amount"`
-	BorrowingFee           *big.Int       `bson:"borrowingFee" json:"borrowingFee"`
-	InvestingFee           *big.Int       `bson:"investingFee" json:"investingFee"`
+	Amount                 *big.Int       `bson:"amount" json:"amount" export:"lendingToken"`
+	BorrowingFee           *big.Int       `bson:"borrowingFee" json:"borrowingFee" export:"lendingToken"`
+	InvestingFee           *big.Int       `bson:"investingFee" json:"investingFee" export:"lendingToken"`
 	Status                 string         `bson:"status" json:"status"`
 	TakerOrderSide         string         `bson:"takerOrderSide" json:"takerOrderSide"`
 	TakerOrderType         string         `bson:"takerOrderType" json:"takerOrderType"`
@@ -361,7 +361,7 @@ type LendingTradeSpec struct {
 // LendingTradeRes response api
 type LendingTradeRes struct {
 	Total         int             `json:"total" bson:"total"`
-	LendingTrades []*LendingTrade `json:"trades" bson:"trades"`
+	LendingTrades []*LendingTrade `json:"trades" bson:"trades" export:"rows"`
 }
 
 // ComputeHash returns hashes the trade
@@ -385,10 +385,81 @@ type LendingTradeChangeEvent struct {
 }
 
 // LendingUserTrade count lending trade
+// Volume is the lending token amount borrowed or lent by user
+// interests are in lending token, due at maturity for open loans and accrued until repay or liquidation otherwise
 type LendingUserTrade struct {
-	UserAddress    common.Address `json:"userAddress"`
-	Count          *big.Int       `json:"count"`
-	Volume         *big.Int       `json:"volume"`
-	RelayerAddress common.Address `json:"relayerAddress"`
-	TimeStamp      int64          `json:"timestamp"`
+	UserAddress     common.Address `json:"userAddress"`
+	Count           *big.Int       `json:"count"`
+	Volume          *big.Int       `json:"volume" export:"lendingToken"`
+	BorrowingCount  *big.Int       `json:"borrowingCount,omitempty"`
+	BorrowingVolume *big.Int       `json:"borrowingVolume,omitempty" export:"lendingToken"`
+	InterestPaid    *big.Int       `json:"interestPaid,omitempty" export:"lendingToken"`
+	InvestingCount  *big.Int       `json:"investingCount,omitempty"`
+	InvestingVolume *big.Int       `json:"investingVolume,omitempty" export:"lendingToken"`
+	InterestEarned  *big.Int       `json:"interestEarned,omitempty" export:"lendingToken"`
+	RelayerAddress  common.Address `json:"relayerAddress"`
+	LendingToken    common.Address `json:"lendingToken"`
+	Term            uint64         `json:"term"`
+	TimeStamp       int64          `json:"timestamp"`
+}
+
+// LendingUserVolume borrower or investor ranking
+// Interest is paid by a borrower and earned by an investor
+type LendingUserVolume struct {
+	UserAddress common.Address `json:"userAddress"`
+	Count       *big.Int       `json:"count"`
+	Volume      *big.Int       `json:"volume" export:"lendingToken"`
+	Interest    *big.Int       `json:"interest" export:"lendingToken"`
+	Rank        int            `json:"rank"`
+}
+
+// LendingMarketTrade lending trades of a relayer on a lending token and term in a time frame
+// interest sums are kept to compute the average and the volume weighted interest of any time range
+type LendingMarketTrade struct {
+	RelayerAddress      common.Address `json:"relayerAddress"`
+	LendingToken        common.Address `json:"lendingToken"`
+	Term                uint64         `json:"term"`
+	Count               *big.Int       `json:"count"`
+	Volume              *big.Int       `json:"volume" export:"lendingToken"`
+	InterestSum         *big.Int       `json:"interestSum"`
+	WeightedInterestSum *big.Int       `json:"weightedInterestSum"`
+	BorrowingFee        *big.Int       `json:"borrowingFee" export:"lendingToken"`
+	InvestingFee        *big.Int       `json:"investingFee" export:"lendingToken"`
+	// trades where the relayer matched the borrower or the investor, both for all relayers
+	Borrowing *LendingSideTrade `json:"borrowing,omitempty"`
+	Investing *LendingSideTrade `json:"investing,omitempty"`
+	TimeStamp int64             `json:"timestamp"`
+}
+
+// LendingSideTrade lending trades of one side in a LendingMarketTrade
+type LendingSideTrade struct {
+	Count               *big.Int `json:"count"`
+	Volume              *big.Int `json:"volume" export:"lendingToken"`
+	InterestSum         *big.Int `json:"interestSum"`
+	WeightedInterestSum *big.Int `json:"weightedInterestSum"`
+}
+
+// NewLendingSideTrade init empty side
+func NewLendingSideTrade() *LendingSideTrade {
+	return &LendingSideTrade{
+		Count:               big.NewInt(0),
+		Volume:              big.NewInt(0),
+		InterestSum:         big.NewInt(0),
+		WeightedInterestSum: big.NewInt(0),
+	}
+}
+
+// LendingMarket lending statistics of a lending token, by term if Term is not 0, VolumeUSD is set when volumes are valued in USD
+// interests have the unit of LendingTrade.Interest, fees are the revenue of the relayer
+type LendingMarket struct {
+	RelayerAddress   common.Address `json:"relayerAddress"`
+	LendingToken     common.Address `json:"lendingToken"`
+	Term             uint64         `json:"term,omitempty"`
+	Count            *big.Int       `json:"count"`
+	Volume           *big.Int       `json:"volume" export:"lendingToken"`
+	VolumeUSD        string         `json:"volumeUSD,omitempty"`
+	AverageInterest  float64        `json:"averageInterest"`
+	WeightedInterest float64        `json:"weightedInterest"`
+	BorrowingFee     *big.Int       `json:"borrowingFee" export:"lendingToken"`
+	InvestingFee     *big.Int       `json:"investingFee" export:"lendingToken"`
 }
@@ -0,0 +1,156 @@
+package types
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/globalsign/mgo/bson"
+	"github.com/tomochain/tomox-stats/utils/math"
+)
+
+// Liquidation is a lending trade liquidated before being repaid
+// Amount is the liquidated principal, CollateralSeized the collateral locked at liquidation
+// CollateralPrice is the price of the last trade of the collateral in lending token before liquidation,
+// the liquidation price if the pair has no trade
+type Liquidation struct {
+	ID               bson.ObjectId  `json:"-" bson:"_id"`
+	Hash             common.Hash    `json:"hash" bson:"hash"`
+	Borrower         common.Address `json:"borrower" bson:"borrower"`
+	Investor         common.Address `json:"investor" bson:"investor"`
+	BorrowingRelayer common.Address `json:"borrowingRelayer" bson:"borrowingRelayer"`
+	InvestingRelayer common.Address `json:"investingRelayer" bson:"investingRelayer"`
+	LendingToken     common.Address `json:"lendingToken" bson:"lendingToken"`
+	CollateralToken  common.Address `json:"collateralToken" bson:"collateralToken"`
+	Term             uint64         `json:"term" bson:"term"`
+	Amount           *big.Int       `json:"amount" bson:"amount" export:"lendingToken"`
+	CollateralSeized *big.Int       `json:"collateralSeized" bson:"collateralSeized" export:"collateralToken"`
+	LiquidationPrice *big.Int       `json:"liquidationPrice" bson:"liquidationPrice" export:"lendingToken"`
+	CollateralPrice  *big.Int       `json:"collateralPrice" bson:"collateralPrice" export:"lendingToken"`
+	LiquidatedAt     time.Time      `json:"liquidatedAt" bson:"liquidatedAt"`
+	CreatedAt        time.Time      `json:"createdAt" bson:"createdAt"`
+}
+
+// LiquidationRecord corresponds to what is stored in the DB. big.Ints are encoded as strings
+type LiquidationRecord struct {
+	ID               bson.ObjectId `json:"id" bson:"_id"`
+	Hash             string        `json:"hash" bson:"hash"`
+	Borrower         string        `json:"borrower" bson:"borrower"`
+	Investor         string        `json:"investor" bson:"investor"`
+	BorrowingRelayer string        `json:"borrowingRelayer" bson:"borrowingRelayer"`
+	InvestingRelayer string        `json:"investingRelayer" bson:"investingRelayer"`
+	LendingToken     string        `json:"lendingToken" bson:"lendingToken"`
+	CollateralToken  string        `json:"collateralToken" bson:"collateralToken"`
+	Term             uint64        `json:"term" bson:"term"`
+	Amount           string        `json:"amount" bson:"amount"`
+	CollateralSeized string        `json:"collateralSeized" bson:"collateralSeized"`
+	LiquidationPrice string        `json:"liquidationPrice" bson:"liquidationPrice"`
+	CollateralPrice  string        `json:"collateralPrice" bson:"collateralPrice"`
+	LiquidatedAt     time.Time     `json:"liquidatedAt" bson:"liquidatedAt"`
+	CreatedAt        time.Time     `json:"createdAt" bson:"createdAt"`
+}
+
+// NewLiquidation get liquidation of a liquidated lending trade
+func NewLiquidation(trade *LendingTrade) *Liquidation {
+	return &Liquidation{
+		Hash:             trade.Hash,
+		Borrower:         trade.Borrower,
+		Investor:         trade.Investor,
+		BorrowingRelayer: trade.BorrowingRelayer,
+		InvestingRelayer: trade.InvestingRelayer,
+		LendingToken:     trade.LendingToken,
+		CollateralToken:  trade.CollateralToken,
+		Term:             trade.Term,
+		Amount:           trade.Amount,
+		CollateralSeized: trade.CollateralLockedAmount,
+		LiquidationPrice: trade.LiquidationPrice,
+		CollateralPrice:  trade.CollateralPrice,
+		LiquidatedAt:     trade.UpdatedAt,
+	}
+}
+
+func bigIntString(b *big.Int) string {
+	if b == nil {
+		return "0"
+	}
+	return b.String()
+}
+
+// GetBSON implements bson.Getter
+func (l *Liquidation) GetBSON() (interface{}, error) {
+	return LiquidationRecord{
+		ID:               l.ID,
+		Hash:             l.Hash.Hex(),
+		Borrower:         l.Borrower.Hex(),
+		Investor:         l.Investor.Hex(),
+		BorrowingRelayer: l.BorrowingRelayer.Hex(),
+		InvestingRelayer: l.InvestingRelayer.Hex(),
+		LendingToken:     l.LendingToken.Hex(),
+		CollateralToken:  l.CollateralToken.Hex(),
+		Term:             l.Term,
+		Amount:           bigIntString(l.Amount),
+		CollateralSeized: bigIntString(l.CollateralSeized),
+		LiquidationPrice: bigIntString(l.LiquidationPrice),
+		CollateralPrice:  bigIntString(l.CollateralPrice),
+		LiquidatedAt:     l.LiquidatedAt,
+		CreatedAt:        l.CreatedAt,
+	}, nil
+}
+
+// SetBSON implemenets bson.Setter
+func (l *Liquidation) SetBSON(raw bson.Raw) error {
+	decoded := &LiquidationRecord{}
+	if err := raw.Unmarshal(decoded); err != nil {
+		return err
+	}
+	l.ID = decoded.ID
+	l.Hash = common.HexToHash(decoded.Hash)
+	l.Borrower = common.HexToAddress(decoded.Borrower)
+	l.Investor = common.HexToAddress(decoded.Investor)
+	l.BorrowingRelayer = common.HexToAddress(decoded.BorrowingRelayer)
+	l.InvestingRelayer = common.HexToAddress(decoded.InvestingRelayer)
+	l.LendingToken = common.HexToAddress(decoded.LendingToken)
+	l.CollateralToken = common.HexToAddress(decoded.CollateralToken)
+	l.Term = decoded.Term
+	l.Amount = math.ToBigInt(decoded.Amount)
+	l.CollateralSeized = math.ToBigInt(decoded.CollateralSeized)
+	l.LiquidationPrice = math.ToBigInt(decoded.LiquidationPrice)
+	l.CollateralPrice = math.ToBigInt(decoded.CollateralPrice)
+	l.LiquidatedAt = decoded.LiquidatedAt
+	l.CreatedAt = decoded.CreatedAt
+	return nil
+}
+
+// LiquidationSpec for query, empty fields are not filtered
+type LiquidationSpec struct {
+	RelayerAddress  common.Address
+	Borrower        common.Address
+	LendingToken    common.Address
+	CollateralToken common.Address
+	DateFrom        int64
+	DateTo          int64
+}
+
+// LiquidationRes response api
+type LiquidationRes struct {
+	Total        int            `json:"total"`
+	Liquidations []*Liquidation `json:"liquidations" export:"rows"`
+}
+
+// LoanRisk is an open loan ranked by the drop of collateral price which liquidates it
+// Distance is (CollateralPrice - LiquidationPrice) / CollateralPrice, 0 or less if the loan can be liquidated
+type LoanRisk struct {
+	Hash                   common.Hash    `json:"hash"`
+	Borrower               common.Address `json:"borrower"`
+	BorrowingRelayer       common.Address `json:"borrowingRelayer"`
+	InvestingRelayer       common.Address `json:"investingRelayer"`
+	LendingToken           common.Address `json:"lendingToken"`
+	CollateralToken        common.Address `json:"collateralToken"`
+	Term                   uint64         `json:"term"`
+	Amount                 *big.Int       `json:"amount" export:"lendingToken"`
+	CollateralLockedAmount *big.Int       `json:"collateralLockedAmount" export:"collateralToken"`
+	LiquidationPrice       *big.Int       `json:"liquidationPrice" export:"lendingToken"`
+	CollateralPrice        *big.Int       `json:"collateralPrice" export:"lendingToken"`
+	Distance               float64        `json:"distance"`
+	LiquidationTime        uint64         `json:"liquidationTime"`
+}
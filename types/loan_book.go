@@ -0,0 +1,46 @@
+package types
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// LoanTokenAmount amount of a token in open loans
+// AmountUSD is empty if the token has no USD price
+type LoanTokenAmount struct {
+	Token     common.Address `json:"token"`
+	Amount    *big.Int       `json:"amount" export:"token"`
+	AmountUSD string         `json:"amountUSD,omitempty"`
+	Count     int            `json:"count"`
+}
+
+// LoanTerm open loans of a lending token and term
+// PrincipalShare is the part of the outstanding principal of the lending token borrowed for this term
+type LoanTerm struct {
+	LendingToken   common.Address `json:"lendingToken"`
+	Term           uint64         `json:"term"`
+	Principal      *big.Int       `json:"principal" export:"lendingToken"`
+	Count          int            `json:"count"`
+	PrincipalShare float64        `json:"principalShare"`
+}
+
+// LoanBook open loans of a relayer, empty relayer address for all relayers
+// total value locked is the USD value of locked collateral
+type LoanBook struct {
+	RelayerAddress      common.Address     `json:"relayerAddress"`
+	Count               int                `json:"count"`
+	Principal           []*LoanTokenAmount `json:"principal" export:"rows"`
+	Collateral          []*LoanTokenAmount `json:"collateral" export:"rows"`
+	Terms               []*LoanTerm        `json:"terms" export:"rows"`
+	PrincipalUSD        string             `json:"principalUSD"`
+	TotalValueLockedUSD string             `json:"totalValueLockedUSD"`
+}
+
+// LoanMaturity principal of open loans of a lending token due the day starting at Date
+type LoanMaturity struct {
+	Date         int64          `json:"date"`
+	LendingToken common.Address `json:"lendingToken"`
+	Principal    *big.Int       `json:"principal" export:"lendingToken"`
+	Count        int            `json:"count"`
+}
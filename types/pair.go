@@ -283,3 +283,26 @@ type PairRecord struct {
 	CreatedAt          time.Time `json:"createdAt" bson:"createdAt"`
 	UpdatedAt          time.Time `json:"updatedAt" bson:"updatedAt"`
 }
+
+// PairTicker statistics of a pair over the last 24 hours
+// prices are in quote token smallest unit for one whole base token, Change is in percent
+// RelayerAddress is empty for statistics over all relayers, QuoteVolumeUSD is set when volumes are valued in USD
+type PairTicker struct {
+	RelayerAddress     common.Address `json:"relayerAddress"`
+	BaseTokenSymbol    string         `json:"baseTokenSymbol"`
+	BaseTokenAddress   common.Address `json:"baseTokenAddress"`
+	BaseTokenDecimals  int            `json:"baseTokenDecimals"`
+	QuoteTokenSymbol   string         `json:"quoteTokenSymbol"`
+	QuoteTokenAddress  common.Address `json:"quoteTokenAddress"`
+	QuoteTokenDecimals int            `json:"quoteTokenDecimals"`
+	LastPrice          *big.Int       `json:"lastPrice" export:"quoteTokenAddress"`
+	Open               *big.Int       `json:"open" export:"quoteTokenAddress"`
+	High               *big.Int       `json:"high" export:"quoteTokenAddress"`
+	Low                *big.Int       `json:"low" export:"quoteTokenAddress"`
+	Change             float64        `json:"change"`
+	Volume             *big.Int       `json:"volume" export:"baseTokenAddress"`
+	QuoteVolume        *big.Int       `json:"quoteVolume" export:"quoteTokenAddress"`
+	QuoteVolumeUSD     string         `json:"quoteVolumeUSD,omitempty"`
+	Count              int            `json:"count"`
+	ActiveTraders      int            `json:"activeTraders"`
+}
@@ -3,6 +3,7 @@ package types
 import (
 	"encoding/json"
 	"math/big"
+	"regexp"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -13,35 +14,44 @@ import (
 
 // Relayer corresponds to a single Ethereum address. It contains a list of token balances for that address
 type Relayer struct {
-	ID         bson.ObjectId  `json:"-" bson:"_id"`
-	RID        int            `json:"rid" bson:"rid"`
-	Owner      common.Address `json:"owner" bson:"owner"`
-	Deposit    *big.Int       `json:"deposit" bson:"deposit"`
-	Address    common.Address `json:"address" bson:"address"`
-	Domain     string         `json:"domain" bson:"domain"`
-	Name       string         `json:"name" bson:"name"`
-	Resign     bool           `json:"resign" bson:"resign"`
-	LockTime   int            `json:"lockTime" bson:"lockTime"`
-	MakeFee    *big.Int       `json:"makeFee,omitempty" bson:"makeFee,omitempty"`
-	TakeFee    *big.Int       `json:"takeFee,omitempty" bson:"makeFee,omitempty"`
-	LendingFee *big.Int       `json:"lendingFee,omitempty" bson:"lendingFee,omitempty"`
-	CreatedAt  time.Time      `json:"createdAt" bson:"createdAt"`
-	UpdatedAt  time.Time      `json:"updatedAt" bson:"updatedAt"`
+	ID          bson.ObjectId  `json:"-" bson:"_id"`
+	RID         int            `json:"rid" bson:"rid"`
+	Owner       common.Address `json:"owner" bson:"owner"`
+	Deposit     *big.Int       `json:"deposit" bson:"deposit"`
+	Address     common.Address `json:"address" bson:"address"`
+	Domain      string         `json:"domain" bson:"domain"`
+	Name        string         `json:"name" bson:"name"`
+	Logo        string         `json:"logo" bson:"logo"`
+	Description string         `json:"description" bson:"description"`
+	Resign      bool           `json:"resign" bson:"resign"`
+	LockTime    int            `json:"lockTime" bson:"lockTime"`
+	MakeFee     *big.Int       `json:"makeFee,omitempty" bson:"makeFee,omitempty"`
+	TakeFee     *big.Int       `json:"takeFee,omitempty" bson:"makeFee,omitempty"`
+	LendingFee  *big.Int       `json:"lendingFee,omitempty" bson:"lendingFee,omitempty"`
+	// LendingPairs and CollateralTokens are registered in the lending contract
+	LendingPairs     []*RelayerLendingPair `json:"lendingPairs,omitempty" bson:"lendingPairs,omitempty"`
+	CollateralTokens []*RelayerToken       `json:"collateralTokens,omitempty" bson:"collateralTokens,omitempty"`
+	CreatedAt        time.Time             `json:"createdAt" bson:"createdAt"`
+	UpdatedAt        time.Time             `json:"updatedAt" bson:"updatedAt"`
 }
 
 // GetBSON implements bson.Getter
 func (a *Relayer) GetBSON() (interface{}, error) {
 	ar := RelayerRecord{
-		RID:       a.RID,
-		Owner:     a.Owner.Hex(),
-		Deposit:   a.Deposit.String(),
-		Domain:    a.Domain,
-		Name:      a.Name,
-		Resign:    a.Resign,
-		LockTime:  a.LockTime,
-		Address:   a.Address.Hex(),
-		CreatedAt: a.CreatedAt,
-		UpdatedAt: a.UpdatedAt,
+		RID:              a.RID,
+		Owner:            a.Owner.Hex(),
+		Deposit:          a.Deposit.String(),
+		Domain:           a.Domain,
+		Name:             a.Name,
+		Logo:             a.Logo,
+		Description:      a.Description,
+		Resign:           a.Resign,
+		LockTime:         a.LockTime,
+		Address:          a.Address.Hex(),
+		LendingPairs:     a.LendingPairs,
+		CollateralTokens: a.CollateralTokens,
+		CreatedAt:        a.CreatedAt,
+		UpdatedAt:        a.UpdatedAt,
 	}
 
 	if a.ID.Hex() == "" {
@@ -81,8 +91,12 @@ func (a *Relayer) SetBSON(raw bson.Raw) error {
 	a.ID = decoded.ID
 	a.Domain = decoded.Domain
 	a.Name = decoded.Name
+	a.Logo = decoded.Logo
+	a.Description = decoded.Description
 	a.Resign = decoded.Resign
 	a.LockTime = decoded.LockTime
+	a.LendingPairs = decoded.LendingPairs
+	a.CollateralTokens = decoded.CollateralTokens
 	a.CreatedAt = decoded.CreatedAt
 	a.UpdatedAt = decoded.UpdatedAt
 	if decoded.MakeFee != "" {
@@ -103,17 +117,19 @@ func (a *Relayer) SetBSON(raw bson.Raw) error {
 // MarshalJSON implements the json.Marshal interface
 func (a *Relayer) MarshalJSON() ([]byte, error) {
 	relayer := map[string]interface{}{
-		"id":        a.ID,
-		"address":   a.Address.Hex(),
-		"domain":    a.Domain,
-		"name":      a.Name,
-		"resign":    a.Resign,
-		"lockTime":  a.LockTime,
-		"rid":       a.RID,
-		"owner":     a.Owner.Hex(),
-		"deposit":   a.Deposit.String(),
-		"createdAt": a.CreatedAt.String(),
-		"updatedAt": a.UpdatedAt.String(),
+		"id":          a.ID,
+		"address":     a.Address.Hex(),
+		"domain":      a.Domain,
+		"name":        a.Name,
+		"logo":        a.Logo,
+		"description": a.Description,
+		"resign":      a.Resign,
+		"lockTime":    a.LockTime,
+		"rid":         a.RID,
+		"owner":       a.Owner.Hex(),
+		"deposit":     a.Deposit.String(),
+		"createdAt":   a.CreatedAt.String(),
+		"updatedAt":   a.UpdatedAt.String(),
 	}
 
 	if a.MakeFee != nil {
@@ -128,6 +144,14 @@ func (a *Relayer) MarshalJSON() ([]byte, error) {
 		relayer["lendingFee"] = a.LendingFee.String()
 	}
 
+	if len(a.LendingPairs) > 0 {
+		relayer["lendingPairs"] = a.LendingPairs
+	}
+
+	if len(a.CollateralTokens) > 0 {
+		relayer["collateralTokens"] = a.CollateralTokens
+	}
+
 	return json.Marshal(relayer)
 }
 
@@ -166,6 +190,14 @@ func (a *Relayer) UnmarshalJSON(b []byte) error {
 		a.Name = relayer["name"].(string)
 	}
 
+	if relayer["logo"] != nil {
+		a.Logo = relayer["logo"].(string)
+	}
+
+	if relayer["description"] != nil {
+		a.Description = relayer["description"].(string)
+	}
+
 	if relayer["resign"] != nil {
 		a.Resign = relayer["resign"].(bool)
 	}
@@ -196,22 +228,154 @@ func (a Relayer) Validate() error {
 	)
 }
 
+// RelayerMetadata is the relayer information edited by admins, the domain is the host resolving the relayer of requests
+// nil fields are not in the update and keep their value, an empty domain, logo or description clears it
+type RelayerMetadata struct {
+	Name        *string `json:"name"`
+	Domain      *string `json:"domain"`
+	Logo        *string `json:"logo"`
+	Description *string `json:"description"`
+}
+
+var (
+	relayerDomainRegexp = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)*(:[0-9]{1,5})?$`)
+	relayerLogoRegexp   = regexp.MustCompile(`^https?://[^\s]+$`)
+)
+
+// Validate enforces the relayer metadata model
+func (m RelayerMetadata) Validate() error {
+	return validation.ValidateStruct(&m,
+		validation.Field(&m.Name, validation.NilOrNotEmpty, validation.Length(1, 100)),
+		validation.Field(&m.Domain, validation.Length(0, 253), validation.Match(relayerDomainRegexp).Error("must be a lowercase host name")),
+		validation.Field(&m.Logo, validation.Length(0, 500), validation.Match(relayerLogoRegexp).Error("must be a http or https url")),
+		validation.Field(&m.Description, validation.Length(0, 1000)),
+	)
+}
+
 // RelayerRecord corresponds to what is stored in the DB. big.Ints are encoded as strings
 type RelayerRecord struct {
-	ID         bson.ObjectId `json:"id" bson:"_id"`
-	RID        int           `json:"rid" bson:"rid"`
-	Owner      string        `json:"owner" bson:"owner"`
-	Deposit    string        `json:"deposit" bson:"deposit"`
-	Address    string        `json:"address" bson:"address"`
-	Domain     string        `json:"domain" bson:"domain"`
-	Name       string        `json:"name" bson:"name"`
-	Resign     bool          `json:"resign" bson:"resign"`
-	LockTime   int           `json:"lockTime" bson:"lockTime"`
-	MakeFee    string        `json:"makeFee,omitempty" bson:"makeFee,omitempty"`
-	TakeFee    string        `json:"takeFee,omitempty" bson:"takeFee,omitempty"`
-	LendingFee string        `json:"lendingFee,omitempty" bson:"lendingFee,omitempty"`
-	CreatedAt  time.Time     `json:"createdAt" bson:"createdAt"`
-	UpdatedAt  time.Time     `json:"updatedAt" bson:"updatedAt"`
+	ID               bson.ObjectId         `json:"id" bson:"_id"`
+	RID              int                   `json:"rid" bson:"rid"`
+	Owner            string                `json:"owner" bson:"owner"`
+	Deposit          string                `json:"deposit" bson:"deposit"`
+	Address          string                `json:"address" bson:"address"`
+	Domain           string                `json:"domain" bson:"domain"`
+	Name             string                `json:"name" bson:"name"`
+	Logo             string                `json:"logo" bson:"logo"`
+	Description      string                `json:"description" bson:"description"`
+	Resign           bool                  `json:"resign" bson:"resign"`
+	LockTime         int                   `json:"lockTime" bson:"lockTime"`
+	MakeFee          string                `json:"makeFee,omitempty" bson:"makeFee,omitempty"`
+	TakeFee          string                `json:"takeFee,omitempty" bson:"takeFee,omitempty"`
+	LendingFee       string                `json:"lendingFee,omitempty" bson:"lendingFee,omitempty"`
+	LendingPairs     []*RelayerLendingPair `json:"lendingPairs,omitempty" bson:"lendingPairs,omitempty"`
+	CollateralTokens []*RelayerToken       `json:"collateralTokens,omitempty" bson:"collateralTokens,omitempty"`
+	CreatedAt        time.Time             `json:"createdAt" bson:"createdAt"`
+	UpdatedAt        time.Time             `json:"updatedAt" bson:"updatedAt"`
+}
+
+// RelayerLendingPair lending token and term of a lending relayer
+type RelayerLendingPair struct {
+	Term               uint64         `json:"term"`
+	LendingToken       common.Address `json:"lendingToken"`
+	LendingTokenSymbol string         `json:"lendingTokenSymbol"`
+}
+
+// RelayerLendingPairRecord corresponds to what is stored in the DB
+type RelayerLendingPairRecord struct {
+	Term               uint64 `bson:"term"`
+	LendingToken       string `bson:"lendingToken"`
+	LendingTokenSymbol string `bson:"lendingTokenSymbol"`
+}
+
+// GetBSON implements bson.Getter
+func (p *RelayerLendingPair) GetBSON() (interface{}, error) {
+	return &RelayerLendingPairRecord{
+		Term:               p.Term,
+		LendingToken:       p.LendingToken.Hex(),
+		LendingTokenSymbol: p.LendingTokenSymbol,
+	}, nil
+}
+
+// SetBSON implemenets bson.Setter
+func (p *RelayerLendingPair) SetBSON(raw bson.Raw) error {
+	decoded := &RelayerLendingPairRecord{}
+	if err := raw.Unmarshal(decoded); err != nil {
+		return err
+	}
+	p.Term = decoded.Term
+	p.LendingToken = common.HexToAddress(decoded.LendingToken)
+	p.LendingTokenSymbol = decoded.LendingTokenSymbol
+	return nil
+}
+
+// RelayerToken token registered by a relayer
+type RelayerToken struct {
+	Address  common.Address `json:"address"`
+	Symbol   string         `json:"symbol"`
+	Decimals int            `json:"decimals"`
+}
+
+// RelayerTokenRecord corresponds to what is stored in the DB
+type RelayerTokenRecord struct {
+	Address  string `bson:"address"`
+	Symbol   string `bson:"symbol"`
+	Decimals int    `bson:"decimals"`
+}
+
+// GetBSON implements bson.Getter
+func (t *RelayerToken) GetBSON() (interface{}, error) {
+	return &RelayerTokenRecord{
+		Address:  t.Address.Hex(),
+		Symbol:   t.Symbol,
+		Decimals: t.Decimals,
+	}, nil
+}
+
+// SetBSON implemenets bson.Setter
+func (t *RelayerToken) SetBSON(raw bson.Raw) error {
+	decoded := &RelayerTokenRecord{}
+	if err := raw.Unmarshal(decoded); err != nil {
+		return err
+	}
+	t.Address = common.HexToAddress(decoded.Address)
+	t.Symbol = decoded.Symbol
+	t.Decimals = decoded.Decimals
+	return nil
+}
+
+// RelayerPair pair listed by a relayer
+type RelayerPair struct {
+	BaseToken        common.Address `json:"baseToken"`
+	BaseTokenSymbol  string         `json:"baseTokenSymbol"`
+	QuoteToken       common.Address `json:"quoteToken"`
+	QuoteTokenSymbol string         `json:"quoteTokenSymbol"`
+	Active           bool           `json:"active"`
+}
+
+// RelayerSummary public directory entry of a relayer
+// volumes are valued in USD over all quote tokens, Trader is the number of active users
+type RelayerSummary struct {
+	Address          common.Address        `json:"address"`
+	RID              int                   `json:"rid"`
+	Owner            common.Address        `json:"owner"`
+	Name             string                `json:"name"`
+	Domain           string                `json:"domain"`
+	Logo             string                `json:"logo"`
+	Description      string                `json:"description"`
+	Deposit          *big.Int              `json:"deposit"`
+	Resign           bool                  `json:"resign"`
+	LockTime         int                   `json:"lockTime"`
+	MakeFee          *big.Int              `json:"makeFee"`
+	TakeFee          *big.Int              `json:"takeFee"`
+	LendingFee       *big.Int              `json:"lendingFee"`
+	Pairs            []*RelayerPair        `json:"pairs"`
+	Tokens           []*RelayerToken       `json:"tokens"`
+	LendingPairs     []*RelayerLendingPair `json:"lendingPairs"`
+	CollateralTokens []*RelayerToken       `json:"collateralTokens"`
+	Volume24h        *TradeVolume          `json:"volume24h"`
+	Volume7d         *TradeVolume          `json:"volume7d"`
+	Volume30d        *TradeVolume          `json:"volume30d"`
 }
 
 type RelayerBSONUpdate struct {
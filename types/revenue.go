@@ -0,0 +1,53 @@
+package types
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// RelayerRevenue fee revenue of a relayer by time frame of interval
+type RelayerRevenue struct {
+	RelayerAddress common.Address  `json:"relayerAddress"`
+	Interval       string          `json:"interval"`
+	From           int64           `json:"from"`
+	To             int64           `json:"to"`
+	Frames         []*RevenueFrame `json:"frames" export:"rows"`
+	TotalUSD       string          `json:"totalUSD"`
+}
+
+// RevenueFrame fee revenue of a relayer in the time frame starting at TimeStamp
+// TotalUSD only counts fees of tokens with a USD price, FeeUSD is empty for the other tokens
+type RevenueFrame struct {
+	TimeStamp   int64             `json:"timestamp"`
+	Pairs       []*PairRevenue    `json:"pairs"`
+	QuoteTokens []*TokenRevenue   `json:"quoteTokens"`
+	Lending     []*LendingRevenue `json:"lending"`
+	TotalUSD    string            `json:"totalUSD"`
+}
+
+// PairRevenue trading fees of a pair, in quote token
+type PairRevenue struct {
+	BaseToken  common.Address `json:"baseToken"`
+	QuoteToken common.Address `json:"quoteToken"`
+	Count      *big.Int       `json:"count"`
+	MakeFee    *big.Int       `json:"makeFee" export:"quoteToken"`
+	TakeFee    *big.Int       `json:"takeFee" export:"quoteToken"`
+}
+
+// TokenRevenue trading fees of all pairs of a quote token
+type TokenRevenue struct {
+	Token   common.Address `json:"token"`
+	MakeFee *big.Int       `json:"makeFee" export:"token"`
+	TakeFee *big.Int       `json:"takeFee" export:"token"`
+	FeeUSD  string         `json:"feeUSD,omitempty"`
+}
+
+// LendingRevenue lending fees of a lending token, in lending token
+type LendingRevenue struct {
+	LendingToken common.Address `json:"lendingToken"`
+	Count        *big.Int       `json:"count"`
+	BorrowingFee *big.Int       `json:"borrowingFee" export:"lendingToken"`
+	InvestingFee *big.Int       `json:"investingFee" export:"lendingToken"`
+	FeeUSD       string         `json:"feeUSD,omitempty"`
+}
@@ -0,0 +1,26 @@
+package types
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Tick is an OHLCV candle of a pair for one time frame
+// RelayerAddress is empty for candles aggregated over all relayers
+type Tick struct {
+	RelayerAddress common.Address `json:"relayerAddress"`
+	BaseToken      common.Address `json:"baseToken"`
+	QuoteToken     common.Address `json:"quoteToken"`
+	Open           *big.Int       `json:"open" export:"quoteToken"`
+	High           *big.Int       `json:"high" export:"quoteToken"`
+	Low            *big.Int       `json:"low" export:"quoteToken"`
+	Close          *big.Int       `json:"close" export:"quoteToken"`
+	Volume         *big.Int       `json:"volume" export:"baseToken"`
+	Count          *big.Int       `json:"count"`
+	Timestamp      int64          `json:"timestamp"`
+	OpenTime       int64          `json:"openTime"`
+	CloseTime      int64          `json:"closeTime"`
+	Duration       int64          `json:"duration"`
+	Unit           string         `json:"unit"`
+}
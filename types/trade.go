@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -18,6 +20,9 @@ const (
 	TradeStatusPending = "PENDING"
 	TradeStatusSuccess = "SUCCESS"
 	TradeStatusError   = "ERROR"
+
+	TradeSideBuy  = "BUY"
+	TradeSideSell = "SELL"
 )
 
 // Trade struct holds arguments corresponding to a "Taker Order"
@@ -34,10 +39,10 @@ type Trade struct {
 	Hash           common.Hash    `json:"hash" bson:"hash"`
 	TxHash         common.Hash    `json:"txHash" bson:"txHash"`
 	PairName       string         `json:"pairName" bson:"pairName"`
-	PricePoint     *big.Int       `json:"pricepoint" bson:"pricepoint"`
-	Amount         *big.Int       `json:"amount" bson:"amount"`
-	MakeFee        *big.Int       `json:"makeFee" bson:"makeFee"`
-	TakeFee        *big.Int       `json:"takeFee" bson:"takeFee"`
+	PricePoint     *big.Int       `json:"pricepoint" bson:"pricepoint" export:"quoteToken"`
+	Amount         *big.Int       `json:"amount" bson:"amount" export:"baseToken"`
+	MakeFee        *big.Int       `json:"makeFee" bson:"makeFee" export:"quoteToken"`
+	TakeFee        *big.Int       `json:"takeFee" bson:"takeFee" export:"quoteToken"`
 	Status         string         `json:"status" bson:"status"`
 	CreatedAt      time.Time      `json:"createdAt" bson:"createdAt"`
 	UpdatedAt      time.Time      `json:"updatedAt" bson:"updatedAt"`
@@ -49,10 +54,15 @@ type Trade struct {
 }
 
 // TradeSpec for query
+// RelayerAddress matches maker or taker exchange, UserAddress matches maker or taker
+// Side is the order side of UserAddress if set, the taker order side otherwise
 type TradeSpec struct {
 	BaseToken      string
 	QuoteToken     string
 	RelayerAddress common.Address
+	UserAddress    common.Address
+	Side           string
+	Status         string
 	DateFrom       int64
 	DateTo         int64
 }
@@ -60,7 +70,40 @@ type TradeSpec struct {
 // TradeRes response api
 type TradeRes struct {
 	Total  int      `json:"total" bson:"total"`
-	Trades []*Trade `json:"trades" bson:"orders"`
+	Trades []*Trade `json:"trades" bson:"orders" export:"rows"`
+}
+
+// TradeCursor position of a trade in trade history, sorted by creation time then id
+type TradeCursor struct {
+	CreatedAt time.Time
+	ID        bson.ObjectId
+}
+
+// String encode cursor as creation time in milliseconds and hex id
+func (c *TradeCursor) String() string {
+	return fmt.Sprintf("%d_%s", c.CreatedAt.UnixNano()/int64(time.Millisecond), c.ID.Hex())
+}
+
+// ParseTradeCursor decode cursor of TradeCursor.String
+func ParseTradeCursor(s string) (*TradeCursor, error) {
+	parts := strings.Split(s, "_")
+	if len(parts) != 2 || !bson.IsObjectIdHex(parts[1]) {
+		return nil, errors.New("Invalid cursor")
+	}
+	ms, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, errors.New("Invalid cursor")
+	}
+	return &TradeCursor{
+		CreatedAt: time.Unix(0, ms*int64(time.Millisecond)),
+		ID:        bson.ObjectIdHex(parts[1]),
+	}, nil
+}
+
+// TradeHistory page of trade history, Next is the cursor of the next page, empty on last page
+type TradeHistory struct {
+	Trades []*Trade `json:"trades" export:"rows"`
+	Next   string   `json:"next,omitempty"`
 }
 type TradeRecord struct {
 	ID             bson.ObjectId `json:"id" bson:"_id"`
@@ -407,16 +450,19 @@ type TradeChangeEvent struct {
 }
 
 // UserTrade trade info of user
+// maker and taker volumes are nil in time frames stored before they were tracked
 type UserTrade struct {
 	UserAddress      common.Address `json:"userAddress"`
 	Count            *big.Int       `json:"count"`
-	Volume           *big.Int       `json:"volume"`
-	VolumeByQuote    *big.Int       `json:"volumeByQuote"`
-	VolumeAskByQuote *big.Int       `json:"volumeAskByQuote"`
-	VolumeBidByQuote *big.Int       `json:"volumeBidByQuote"`
-
-	VolumeAsk      *big.Int       `json:"volumeAsk"`
-	VolumeBid      *big.Int       `json:"volumeBid"`
+	Volume           *big.Int       `json:"volume" export:"baseToken"`
+	VolumeByQuote    *big.Int       `json:"volumeByQuote" export:"quoteToken"`
+	VolumeAskByQuote *big.Int       `json:"volumeAskByQuote" export:"quoteToken"`
+	VolumeBidByQuote *big.Int       `json:"volumeBidByQuote" export:"quoteToken"`
+
+	VolumeAsk      *big.Int       `json:"volumeAsk" export:"baseToken"`
+	VolumeBid      *big.Int       `json:"volumeBid" export:"baseToken"`
+	VolumeMaker    *big.Int       `json:"volumeMaker,omitempty" export:"baseToken"`
+	VolumeTaker    *big.Int       `json:"volumeTaker,omitempty" export:"baseToken"`
 	TimeStamp      int64          `json:"timestamp"`
 	RelayerAddress common.Address `json:"relayerAddress"`
 	BaseToken      common.Address `json:"baseToken"`
@@ -430,30 +476,150 @@ type RelayerTrade struct {
 	Volume         *big.Int    `json:"volume"`
 }
 
+// RelayerFee fees collected by a relayer on a pair in a time frame, in quote token
+// MakeFee is paid by the makers of the relayer, TakeFee by its takers
+type RelayerFee struct {
+	RelayerAddress common.Address `json:"relayerAddress"`
+	BaseToken      common.Address `json:"baseToken"`
+	QuoteToken     common.Address `json:"quoteToken"`
+	Count          *big.Int       `json:"count"`
+	MakeFee        *big.Int       `json:"makeFee" export:"quoteToken"`
+	TakeFee        *big.Int       `json:"takeFee" export:"quoteToken"`
+	TimeStamp      int64          `json:"timestamp"`
+}
+
+// RelayerFlow trades between makers of a relayer and takers of a relayer on a pair in a time frame
+type RelayerFlow struct {
+	MakerRelayer  common.Address `json:"makerRelayer"`
+	TakerRelayer  common.Address `json:"takerRelayer"`
+	BaseToken     common.Address `json:"baseToken"`
+	QuoteToken    common.Address `json:"quoteToken"`
+	Count         *big.Int       `json:"count"`
+	Volume        *big.Int       `json:"volume,omitempty" export:"baseToken"`
+	VolumeByQuote *big.Int       `json:"volumeByQuote" export:"quoteToken"`
+	VolumeUSD     string         `json:"volumeUSD,omitempty"`
+	TimeStamp     int64          `json:"timestamp,omitempty"`
+}
+
+// RelayerShare volume of a relayer on a pair, maker and taker volumes are in base token
+// Share is the part of the sides of all trades of the pair matched by the relayer
+type RelayerShare struct {
+	RelayerAddress common.Address `json:"relayerAddress"`
+	MakerVolume    *big.Int       `json:"makerVolume" export:"baseToken"`
+	TakerVolume    *big.Int       `json:"takerVolume" export:"baseToken"`
+	Share          float64        `json:"share"`
+}
+
+// MarketShareFrame market share of relayers on a pair in the time frame starting at TimeStamp
+type MarketShareFrame struct {
+	TimeStamp int64           `json:"timestamp"`
+	Volume    *big.Int        `json:"volume" export:"baseToken"`
+	Relayers  []*RelayerShare `json:"relayers"`
+}
+
+// PairMarketShare market share of relayers on a pair over time
+type PairMarketShare struct {
+	BaseToken  common.Address      `json:"baseToken"`
+	QuoteToken common.Address      `json:"quoteToken"`
+	Frames     []*MarketShareFrame `json:"frames"`
+}
+
+// PendingTrade volume of trades waiting for settlement
+type PendingTrade struct {
+	RelayerAddress common.Address `json:"relayerAddress"`
+	BaseToken      common.Address `json:"baseToken"`
+	QuoteToken     common.Address `json:"quoteToken"`
+	Count          *big.Int       `json:"count"`
+	Volume         *big.Int       `json:"volume" export:"baseToken"`
+	VolumeByQuote  *big.Int       `json:"volumeByQuote" export:"quoteToken"`
+	VolumeUSD      string         `json:"volumeUSD,omitempty"`
+}
+
 // UserTradeSpec user trade filter
 type UserTradeSpec struct {
 }
 
 // UserVolume user volume trade
+// VolumeUSD is set when volumes of all quote tokens are valued in USD, Volume is then nil
 type UserVolume struct {
 	UserAddress common.Address `json:"userAddress"`
-	Volume      *big.Int       `json:"volume"`
+	Volume      *big.Int       `json:"volume" export:"quoteToken"`
+	VolumeUSD   string         `json:"volumeUSD,omitempty"`
 	Rank        int            `json:"rank"`
 }
 
+// VolumeDelta change of a user volume in a volume stream
+// users between PreviousRank and Rank are shifted by one rank, PreviousRank is 0 for a new user
+// Rank is 0 for a user removed by a reverted trade
+type VolumeDelta struct {
+	UserAddress  common.Address `json:"userAddress"`
+	Volume       *big.Int       `json:"volume"`
+	Delta        *big.Int       `json:"delta"`
+	Rank         int            `json:"rank"`
+	PreviousRank int            `json:"previousRank"`
+}
+
+// VolumeStreamEvent event sent to the subscribers of a volume stream
+// a snapshot event has the top users of the channel, a delta event has the volume changes of a trade
+type VolumeStreamEvent struct {
+	Event     string         `json:"event"`
+	Channel   string         `json:"channel"`
+	Users     []*UserVolume  `json:"users,omitempty"`
+	Deltas    []*VolumeDelta `json:"deltas,omitempty"`
+	TimeStamp int64          `json:"timestamp"`
+}
+
 // TradeVolume trade volume info
+// TotalVolumeUSD is set when volumes of all quote tokens are valued in USD, TotalVolume is then nil
+// UnpricedVolumes are the volumes of quote tokens without USD price, they are not in TotalVolumeUSD
 type TradeVolume struct {
-	Trader      *big.Int `json:"trader"`
-	TotalVolume *big.Int `json:"totalVolume"`
+	Trader          *big.Int       `json:"trader"`
+	TotalVolume     *big.Int       `json:"totalVolume" export:"quoteToken"`
+	TotalVolumeUSD  string         `json:"totalVolumeUSD,omitempty"`
+	UnpricedVolumes []*QuoteVolume `json:"unpricedVolumes,omitempty"`
+}
+
+// QuoteVolume volume in a quote token
+type QuoteVolume struct {
+	QuoteToken common.Address `json:"quoteToken"`
+	Volume     *big.Int       `json:"volume" export:"quoteToken"`
+}
+
+// PairPrice last trade price of a pair
+type PairPrice struct {
+	BaseToken  common.Address `json:"baseToken"`
+	QuoteToken common.Address `json:"quoteToken"`
+	Price      *big.Int       `json:"price" export:"quoteToken"`
+	// Volume and VolumeByQuote of the last 24 hours, counted for maker and taker
+	Volume        *big.Int `json:"volume" export:"baseToken"`
+	VolumeByQuote *big.Int `json:"volumeByQuote" export:"quoteToken"`
 }
 
 // UserPnL user volume trade
 type UserPnL struct {
 	UserAddress      common.Address `json:"userAddress"`
-	VolumeAskByQuote *big.Int       `json:"volumeAskByQuote"`
-	VolumeBidByQuote *big.Int       `json:"volumeBidByQuote"`
-	VolumeAsk        *big.Int       `json:"volumeAsk"`
-	VolumeBid        *big.Int       `json:"volumeBid"`
-	CurrentPrice     *big.Int       `json:"currentPrice"`
-	PnL              *big.Int       `json:"currentPnL"`
+	VolumeAskByQuote *big.Int       `json:"volumeAskByQuote" export:"quoteToken"`
+	VolumeBidByQuote *big.Int       `json:"volumeBidByQuote" export:"quoteToken"`
+	VolumeAsk        *big.Int       `json:"volumeAsk" export:"baseToken"`
+	VolumeBid        *big.Int       `json:"volumeBid" export:"baseToken"`
+	CurrentPrice     *big.Int       `json:"currentPrice" export:"quoteToken"`
+	PnL              *big.Int       `json:"currentPnL" export:"quoteToken"`
+}
+
+// UserPosition is the position of a user on a pair with its profit and loss in quote token
+// Size is negative for a short position, Method is the cost basis matching method: fifo or average
+type UserPosition struct {
+	UserAddress       common.Address `json:"userAddress"`
+	BaseToken         common.Address `json:"baseToken"`
+	QuoteToken        common.Address `json:"quoteToken"`
+	Method            string         `json:"method"`
+	Size              *big.Int       `json:"size" export:"baseToken"`
+	AverageEntryPrice *big.Int       `json:"averageEntryPrice" export:"quoteToken"`
+	LastPrice         *big.Int       `json:"lastPrice" export:"quoteToken"`
+	RealizedPnL       *big.Int       `json:"realizedPnL" export:"quoteToken"`
+	UnrealizedPnL     *big.Int       `json:"unrealizedPnL" export:"quoteToken"`
+	TotalPnL          *big.Int       `json:"totalPnL" export:"quoteToken"`
+	Volume            *big.Int       `json:"volume" export:"baseToken"`
+	Count             int            `json:"count"`
+	LastTrade         int64          `json:"lastTrade"`
 }
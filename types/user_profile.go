@@ -0,0 +1,60 @@
+package types
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// UserProfile trading and lending activity of an address
+// volumes and ranks cover the time frames loaded in cache, trade times cover the whole trade collection
+type UserProfile struct {
+	UserAddress    common.Address        `json:"userAddress"`
+	FirstTradeTime int64                 `json:"firstTradeTime"`
+	LastTradeTime  int64                 `json:"lastTradeTime"`
+	Relayers       []common.Address      `json:"relayers"`
+	Pairs          []*UserPairProfile    `json:"pairs" export:"rows"`
+	QuoteTokens    []*UserQuoteProfile   `json:"quoteTokens" export:"rows"`
+	Lending        []*UserLendingProfile `json:"lending" export:"rows"`
+}
+
+// UserPairProfile trades of a user on a pair, volumes are in base token
+// bid and ask volumes are the buy and sell split, MakerShare is the part of volume traded as maker
+// VolumeUnsplit is the volume of time frames stored before maker and taker volumes were tracked,
+// it is not in VolumeMaker, VolumeTaker and MakerShare
+// Rank is the rank of the user by relayer volume of the pair, 0 if user is not ranked
+type UserPairProfile struct {
+	BaseToken     common.Address `json:"baseToken"`
+	QuoteToken    common.Address `json:"quoteToken"`
+	Count         *big.Int       `json:"count"`
+	Volume        *big.Int       `json:"volume" export:"baseToken"`
+	VolumeBid     *big.Int       `json:"volumeBid" export:"baseToken"`
+	VolumeAsk     *big.Int       `json:"volumeAsk" export:"baseToken"`
+	VolumeMaker   *big.Int       `json:"volumeMaker" export:"baseToken"`
+	VolumeTaker   *big.Int       `json:"volumeTaker" export:"baseToken"`
+	VolumeUnsplit *big.Int       `json:"volumeUnsplit" export:"baseToken"`
+	MakerShare    float64        `json:"makerShare"`
+	Rank          int            `json:"rank"`
+}
+
+// UserQuoteProfile trades of a user on all pairs of a quote token, volumes are in quote token
+// Rank is the rank of the user by relayer volume of the quote token, 0 if user is not ranked
+type UserQuoteProfile struct {
+	QuoteToken       common.Address `json:"quoteToken"`
+	Count            *big.Int       `json:"count"`
+	VolumeByQuote    *big.Int       `json:"volumeByQuote" export:"quoteToken"`
+	VolumeBidByQuote *big.Int       `json:"volumeBidByQuote" export:"quoteToken"`
+	VolumeAskByQuote *big.Int       `json:"volumeAskByQuote" export:"quoteToken"`
+	Rank             int            `json:"rank"`
+}
+
+// UserLendingProfile loans of a user on a lending token, amounts are in lending token
+type UserLendingProfile struct {
+	LendingToken    common.Address `json:"lendingToken"`
+	BorrowingCount  *big.Int       `json:"borrowingCount"`
+	BorrowingVolume *big.Int       `json:"borrowingVolume" export:"lendingToken"`
+	InterestPaid    *big.Int       `json:"interestPaid" export:"lendingToken"`
+	InvestingCount  *big.Int       `json:"investingCount"`
+	InvestingVolume *big.Int       `json:"investingVolume" export:"lendingToken"`
+	InterestEarned  *big.Int       `json:"interestEarned" export:"lendingToken"`
+}
@@ -0,0 +1,34 @@
+package types
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// WashPairEvidence is the trading activity between two addresses on a pair which looks like wash trading
+// NetPosition is the base token bought by AddressA from AddressB, minus what it sold to AddressB
+type WashPairEvidence struct {
+	AddressA            common.Address `json:"addressA"`
+	AddressB            common.Address `json:"addressB"`
+	BaseToken           common.Address `json:"baseToken"`
+	QuoteToken          common.Address `json:"quoteToken"`
+	Count               int            `json:"count"`
+	Volume              *big.Int       `json:"volume" export:"baseToken"`
+	NetPosition         *big.Int       `json:"netPosition" export:"baseToken"`
+	RoundTrips          int            `json:"roundTrips"`
+	SamePriceTrades     int            `json:"samePriceTrades"`
+	CommonFundingSource bool           `json:"commonFundingSource"`
+	Score               float64        `json:"score"`
+	FirstTrade          int64          `json:"firstTrade"`
+	LastTrade           int64          `json:"lastTrade"`
+}
+
+// WashCluster is a group of addresses suspected to be controlled by the same trader
+// Score is the highest score of its evidences, between 0 and 1
+type WashCluster struct {
+	ID        string              `json:"id"`
+	Addresses []common.Address    `json:"addresses"`
+	Score     float64             `json:"score"`
+	Evidences []*WashPairEvidence `json:"evidences"`
+}
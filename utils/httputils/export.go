@@ -0,0 +1,573 @@
+package httputils
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"path"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	// FormatCSV export data as csv, one row by item
+	FormatCSV = "csv"
+	// FormatNDJSON export data as newline delimited json, one line by item
+	FormatNDJSON = "ndjson"
+
+	contentTypeJSON   = "application/json"
+	contentTypeCSV    = "text/csv"
+	contentTypeNDJSON = "application/x-ndjson"
+
+	// exportTag is the struct tag of exported fields
+	// an amount field names the json key of its token, a list field of a response object has the value exportRows
+	exportTag = "export"
+	// exportRows mark the lists exported instead of the response object
+	exportRows = "rows"
+	// exportListKey is the key of the list name of items when an object has several exported lists
+	exportListKey = "list"
+	// exportHeaderPrefix is the prefix of the response headers of the other fields of an object with exported lists
+	exportHeaderPrefix = "X-Export-"
+)
+
+// GetFormat get export format of request from format param, then from Accept header
+// empty for json
+func GetFormat(r *http.Request) string {
+	switch strings.ToLower(r.URL.Query().Get("format")) {
+	case FormatCSV:
+		return FormatCSV
+	case FormatNDJSON:
+		return FormatNDJSON
+	}
+	accept := r.Header.Get("Accept")
+	if strings.Contains(accept, contentTypeCSV) {
+		return FormatCSV
+	}
+	if strings.Contains(accept, contentTypeNDJSON) {
+		return FormatNDJSON
+	}
+	return ""
+}
+
+// TokenDecimals get decimals of token, false if token is unknown
+type TokenDecimals func(token common.Address) (int, bool)
+
+// Exporter converts the data of successful WriteJSON responses to the requested format
+type Exporter struct {
+	decimals TokenDecimals
+	// reflect.Type => map[string]*exportAmount
+	amounts sync.Map
+}
+
+// NewExporter init new instance, amounts are scaled by the decimals of their token
+func NewExporter(decimals TokenDecimals) *Exporter {
+	return &Exporter{decimals: decimals}
+}
+
+// dataWriter is a response writer which writes the data of WriteJSON itself
+type dataWriter interface {
+	writeData(code int, data interface{}) bool
+}
+
+// exportWriter writes the data of WriteJSON in export format, other responses are written as is
+type exportWriter struct {
+	http.ResponseWriter
+	exporter *Exporter
+	request  *http.Request
+	format   string
+}
+
+// Export middleware converts the data of successful WriteJSON responses to the requested format
+// an array gives one row by item and an object gives a single row, the lists of an object tagged
+// export:"rows" are exported instead of it, its other scalar fields are sent as X-Export- headers
+// csv columns are the json keys, nested keys are joined with a dot, nested arrays are kept as json
+// amounts tagged with the json key of their token are written as decimals of the token, the token is
+// looked up in the item, then in the request params, amounts of unknown tokens are kept in smallest unit
+// items are written one by one until the client has gone away, csv items are encoded before the header
+// so that its columns cover the keys of all items
+func (e *Exporter) Export(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		format := GetFormat(r)
+		if format == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(&exportWriter{ResponseWriter: w, exporter: e, request: r, format: format}, r)
+	})
+}
+
+// Flush forwards to the response writer, so that streams written as is are not buffered
+func (w *exportWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// writeData write successful data in export format, the status is already sent when an item can not be written
+// so the export stops without error response
+func (w *exportWriter) writeData(code int, data interface{}) bool {
+	if code < 200 || code >= 300 {
+		return false
+	}
+	lists, headers := getExportLists(data)
+	for k, v := range headers {
+		w.Header().Set(exportHeaderPrefix+k, v)
+	}
+	name := path.Base(w.request.URL.Path)
+	switch w.format {
+	case FormatCSV:
+		w.Header().Set("Content-Type", contentTypeCSV)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.csv\"", name))
+		items := w.exporter.getItems(w.request, lists)
+		w.WriteHeader(code)
+		w.exporter.writeCSV(w.ResponseWriter, items)
+	case FormatNDJSON:
+		w.Header().Set("Content-Type", contentTypeNDJSON)
+		w.WriteHeader(code)
+		w.exporter.writeNDJSON(w.ResponseWriter, w.request, lists)
+	}
+	return true
+}
+
+// exportList exported items, name is the json key of the list when an object has several exported lists
+type exportList struct {
+	name  string
+	items reflect.Value
+	// single is true for an object exported as one item
+	single bool
+}
+
+func (l *exportList) len() int {
+	if l.single {
+		return 1
+	}
+	return l.items.Len()
+}
+
+func (l *exportList) item(i int) interface{} {
+	if l.single {
+		return l.items.Interface()
+	}
+	return l.items.Index(i).Interface()
+}
+
+// getExportLists get exported lists of data and the other scalar fields of an object with exported lists
+func getExportLists(data interface{}) ([]*exportList, map[string]string) {
+	v := reflect.ValueOf(data)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		return []*exportList{{items: v}}, nil
+	case reflect.Struct:
+		var lists []*exportList
+		headers := make(map[string]string)
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, ok := getJSONName(f)
+			if !ok {
+				continue
+			}
+			fv := v.Field(i)
+			if f.Tag.Get(exportTag) == exportRows {
+				lists = append(lists, &exportList{name: name, items: fv})
+				continue
+			}
+			switch fv.Kind() {
+			case reflect.String, reflect.Bool, reflect.Int, reflect.Int64, reflect.Uint64:
+				if cell := fmt.Sprint(fv.Interface()); cell != "" {
+					headers[name] = cell
+				}
+			}
+		}
+		if len(lists) == 1 {
+			lists[0].name = ""
+		}
+		if len(lists) > 0 {
+			return lists, headers
+		}
+	}
+	return []*exportList{{items: v, single: true}}, nil
+}
+
+// getJSONName get json key of exported struct field, false if field is not in json
+func getJSONName(f reflect.StructField) (string, bool) {
+	if f.PkgPath != "" {
+		return "", false
+	}
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+	name := strings.Split(tag, ",")[0]
+	if name == "" {
+		name = f.Name
+	}
+	return name, true
+}
+
+// exportAmount is an amount field, token is the json key of its token in the object of the amount
+type exportAmount struct {
+	token string
+}
+
+var (
+	bigIntType    = reflect.TypeOf(big.Int{})
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// getAmounts get amount fields of type by json key, nested keys are joined with a dot
+func (e *Exporter) getAmounts(t reflect.Type) map[string]*exportAmount {
+	if amounts, ok := e.amounts.Load(t); ok {
+		return amounts.(map[string]*exportAmount)
+	}
+	amounts := make(map[string]*exportAmount)
+	addAmounts(t, "", amounts, 0)
+	e.amounts.Store(t, amounts)
+	return amounts
+}
+
+func addAmounts(t reflect.Type, prefix string, amounts map[string]*exportAmount, depth int) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t == bigIntType || depth > 4 {
+		return
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, ok := getJSONName(f)
+		if !ok {
+			continue
+		}
+		key := joinKey(prefix, name)
+		if token := f.Tag.Get(exportTag); token != "" && token != exportRows {
+			amounts[key] = &exportAmount{token: token}
+			continue
+		}
+		ft := f.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		// custom json of a nested struct may not have its field keys
+		if ft.Implements(marshalerType) || reflect.PtrTo(ft).Implements(marshalerType) {
+			continue
+		}
+		if f.Anonymous && f.Tag.Get("json") == "" {
+			addAmounts(ft, prefix, amounts, depth+1)
+		} else {
+			addAmounts(ft, key, amounts, depth+1)
+		}
+	}
+}
+
+func joinKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+// exportItem is an exported item as json, with its cells for csv
+type exportItem struct {
+	raw  json.RawMessage
+	row  map[string]string
+	keys []string
+}
+
+// newExportItem encode item and scale its amounts in row cells
+func (e *Exporter) newExportItem(r *http.Request, list *exportList, item interface{}) (*exportItem, error) {
+	raw, err := json.Marshal(item)
+	if err != nil {
+		return nil, err
+	}
+	res := &exportItem{raw: raw, row: make(map[string]string)}
+	if list.name != "" {
+		res.row[exportListKey] = list.name
+		res.keys = append(res.keys, exportListKey)
+	}
+	if err := flatten("", raw, res.row, &res.keys); err != nil {
+		return nil, err
+	}
+	res.row = e.scaleRow(r, reflect.TypeOf(item), res.row)
+	return res, nil
+}
+
+// scaleRow write amounts of row as decimals of their token
+func (e *Exporter) scaleRow(r *http.Request, t reflect.Type, row map[string]string) map[string]string {
+	if e.decimals == nil || t == nil {
+		return row
+	}
+	for key, amount := range e.getAmounts(t) {
+		cell, ok := row[key]
+		if !ok || cell == "" {
+			continue
+		}
+		n, ok := new(big.Int).SetString(cell, 10)
+		if !ok {
+			continue
+		}
+		token, ok := getAmountToken(r, row, key, amount.token)
+		if !ok {
+			continue
+		}
+		if decimals, ok := e.decimals(token); ok {
+			row[key] = FormatDecimal(n, decimals)
+		}
+	}
+	return row
+}
+
+// getAmountToken get token of amount key from the cells of its object, then of its parent objects, then from request params
+func getAmountToken(r *http.Request, row map[string]string, key, token string) (common.Address, bool) {
+	prefix := key
+	for {
+		i := strings.LastIndex(prefix, ".")
+		if i < 0 {
+			break
+		}
+		prefix = prefix[:i]
+		if cell, ok := row[prefix+"."+token]; ok && common.IsHexAddress(cell) {
+			return common.HexToAddress(cell), true
+		}
+	}
+	if cell, ok := row[token]; ok && common.IsHexAddress(cell) {
+		return common.HexToAddress(cell), true
+	}
+	if param := r.URL.Query().Get(token); common.IsHexAddress(param) {
+		return common.HexToAddress(param), true
+	}
+	return common.Address{}, false
+}
+
+// FormatDecimal write amount in smallest unit as a decimal number of whole tokens, without trailing zeros
+func FormatDecimal(amount *big.Int, decimals int) string {
+	if decimals <= 0 {
+		return amount.String()
+	}
+	digits := new(big.Int).Abs(amount).String()
+	if len(digits) <= decimals {
+		digits = strings.Repeat("0", decimals-len(digits)+1) + digits
+	}
+	res := digits[:len(digits)-decimals]
+	if fraction := strings.TrimRight(digits[len(digits)-decimals:], "0"); fraction != "" {
+		res += "." + fraction
+	}
+	if amount.Sign() < 0 {
+		res = "-" + res
+	}
+	return res
+}
+
+// eachItem call fn on exported items in order, items which can not be encoded are skipped
+// it stops on the first error of fn, or once the request is canceled
+func (e *Exporter) eachItem(r *http.Request, lists []*exportList, fn func(item *exportItem) error) error {
+	for _, list := range lists {
+		for i := 0; i < list.len(); i++ {
+			if err := r.Context().Err(); err != nil {
+				return err
+			}
+			item, err := e.newExportItem(r, list, list.item(i))
+			if err != nil {
+				continue
+			}
+			if err := fn(item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (e *Exporter) writeNDJSON(w io.Writer, r *http.Request, lists []*exportList) error {
+	return e.eachItem(r, lists, func(item *exportItem) error {
+		var line bytes.Buffer
+		if err := json.Compact(&line, e.scaleJSON(item)); err != nil {
+			return nil
+		}
+		line.WriteByte('\n')
+		_, err := w.Write(line.Bytes())
+		return err
+	})
+}
+
+// scaleJSON set scaled amounts of item in its json, amounts are written as strings to keep their precision
+func (e *Exporter) scaleJSON(item *exportItem) json.RawMessage {
+	var obj map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(item.raw))
+	dec.UseNumber()
+	if err := dec.Decode(&obj); err != nil {
+		return item.raw
+	}
+	if list, ok := item.row[exportListKey]; ok {
+		obj[exportListKey] = list
+	}
+	changed := false
+	for key, cell := range item.row {
+		if setScaledValue(obj, strings.Split(key, "."), cell) {
+			changed = true
+		}
+	}
+	if !changed && item.row[exportListKey] == "" {
+		return item.raw
+	}
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		return item.raw
+	}
+	return raw
+}
+
+// setScaledValue replace the number at path of obj by cell if they differ
+func setScaledValue(obj map[string]interface{}, keys []string, cell string) bool {
+	for len(keys) > 1 {
+		child, ok := obj[keys[0]].(map[string]interface{})
+		if !ok {
+			return false
+		}
+		obj, keys = child, keys[1:]
+	}
+	switch v := obj[keys[0]].(type) {
+	case json.Number:
+		if formatNumber(v.String()) == cell {
+			return false
+		}
+	case string:
+		if v == cell {
+			return false
+		}
+	default:
+		return false
+	}
+	obj[keys[0]] = cell
+	return true
+}
+
+// getItems encode exported items in order
+func (e *Exporter) getItems(r *http.Request, lists []*exportList) []*exportItem {
+	var items []*exportItem
+	e.eachItem(r, lists, func(item *exportItem) error {
+		items = append(items, item)
+		return nil
+	})
+	return items
+}
+
+// writeCSV write items with a column for every key of items, in the order keys are met
+func (e *Exporter) writeCSV(w io.Writer, items []*exportItem) error {
+	var columns []string
+	known := make(map[string]bool)
+	for _, item := range items {
+		for _, k := range item.keys {
+			if !known[k] {
+				known[k] = true
+				columns = append(columns, k)
+			}
+		}
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(columns); err != nil {
+		return err
+	}
+	for _, item := range items {
+		record := make([]string, len(columns))
+		for i, c := range columns {
+			record[i] = item.row[c]
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// flatten add cells of json value to row, keys are added in json order
+func flatten(prefix string, value json.RawMessage, row map[string]string, keys *[]string) error {
+	value = bytes.TrimSpace(value)
+	if len(value) > 0 && value[0] == '{' {
+		dec := json.NewDecoder(bytes.NewReader(value))
+		dec.UseNumber()
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+		for dec.More() {
+			t, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			var v json.RawMessage
+			if err := dec.Decode(&v); err != nil {
+				return err
+			}
+			if err := flatten(joinKey(prefix, t.(string)), v, row, keys); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if prefix == "" {
+		prefix = "value"
+	}
+	cell, err := getCell(value)
+	if err != nil {
+		return err
+	}
+	if _, ok := row[prefix]; !ok {
+		*keys = append(*keys, prefix)
+	}
+	row[prefix] = cell
+	return nil
+}
+
+// getCell format json scalar, arrays are kept as compact json
+func getCell(value json.RawMessage) (string, error) {
+	if len(value) == 0 {
+		return "", nil
+	}
+	switch value[0] {
+	case 'n':
+		return "", nil
+	case '"':
+		var s string
+		err := json.Unmarshal(value, &s)
+		return s, err
+	case '[':
+		var b bytes.Buffer
+		err := json.Compact(&b, value)
+		return b.String(), err
+	case 't', 'f':
+		return string(value), nil
+	}
+	return formatNumber(string(value)), nil
+}
+
+// formatNumber write json number without exponent
+func formatNumber(n string) string {
+	if !strings.ContainsAny(n, "eE") {
+		return n
+	}
+	f, _, err := big.ParseFloat(n, 10, 256, big.ToNearestEven)
+	if err != nil {
+		return n
+	}
+	if f.IsInt() {
+		i, _ := f.Int(nil)
+		return i.String()
+	}
+	return f.Text('f', -1)
+}
@@ -0,0 +1,196 @@
+package httputils
+
+import (
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testToken        = common.HexToAddress("0x0000000000000000000000000000000000000001")
+	testUnknownToken = common.HexToAddress("0x0000000000000000000000000000000000000002")
+)
+
+type testSide struct {
+	Volume *big.Int `json:"volume" export:"token"`
+}
+
+type testItem struct {
+	Token  common.Address `json:"token"`
+	Amount *big.Int       `json:"amount" export:"token"`
+	Price  *big.Int       `json:"price" export:"quoteToken"`
+	Side   *testSide      `json:"side,omitempty"`
+}
+
+type testPage struct {
+	Items []*testItem `json:"items" export:"rows"`
+	Next  string      `json:"next,omitempty"`
+}
+
+type testBook struct {
+	Bids []*testItem `json:"bids" export:"rows"`
+	Asks []*testItem `json:"asks" export:"rows"`
+}
+
+func testDecimals(token common.Address) (int, bool) {
+	if token == testToken {
+		return 18, true
+	}
+	return 0, false
+}
+
+func TestExport(t *testing.T) {
+	amount, _ := new(big.Int).SetString("1500000000000000000", 10)
+	item := &testItem{Token: testToken, Amount: amount, Price: big.NewInt(25)}
+
+	tests := []struct {
+		name    string
+		url     string
+		code    int
+		data    interface{}
+		body    string
+		headers map[string]string
+	}{
+		{
+			name: "csv array scaled by token of item",
+			url:  "/stats/items?format=csv",
+			code: http.StatusOK,
+			data: []*testItem{item, {Token: testUnknownToken, Amount: big.NewInt(7)}},
+			body: "token,amount,price\n" +
+				"0x0000000000000000000000000000000000000001,1.5,25\n" +
+				"0x0000000000000000000000000000000000000002,7,\n",
+			headers: map[string]string{
+				"Content-Type":        contentTypeCSV,
+				"Content-Disposition": "attachment; filename=\"items.csv\"",
+			},
+		},
+		{
+			name: "csv token of request param",
+			url:  "/stats/items?format=csv&quoteToken=0x0000000000000000000000000000000000000001",
+			code: http.StatusOK,
+			data: &testItem{Token: testUnknownToken, Amount: big.NewInt(3), Price: amount},
+			body: "token,amount,price\n0x0000000000000000000000000000000000000002,3,1.5\n",
+		},
+		{
+			name: "csv rows of object and other fields in headers",
+			url:  "/stats/items?format=csv",
+			code: http.StatusOK,
+			data: &testPage{Items: []*testItem{item}, Next: "cursor"},
+			body: "token,amount,price\n0x0000000000000000000000000000000000000001,1.5,25\n",
+			headers: map[string]string{
+				"X-Export-Next": "cursor",
+			},
+		},
+		{
+			name: "csv several lists of object",
+			url:  "/stats/items?format=csv",
+			code: http.StatusOK,
+			data: &testBook{Bids: []*testItem{item}, Asks: []*testItem{item}},
+			body: "list,token,amount,price\n" +
+				"bids,0x0000000000000000000000000000000000000001,1.5,25\n" +
+				"asks,0x0000000000000000000000000000000000000001,1.5,25\n",
+		},
+		{
+			name: "ndjson scaled amounts as strings",
+			url:  "/stats/items?format=ndjson",
+			code: http.StatusOK,
+			data: &testPage{Items: []*testItem{item, {Token: testToken, Amount: big.NewInt(1), Side: &testSide{Volume: amount}}}},
+			body: `{"amount":"1.5","price":25,"token":"0x0000000000000000000000000000000000000001"}` + "\n" +
+				`{"amount":"0.000000000000000001","price":null,"side":{"volume":"1.5"},"token":"0x0000000000000000000000000000000000000001"}` + "\n",
+			headers: map[string]string{
+				"Content-Type": contentTypeNDJSON,
+			},
+		},
+		{
+			name: "error is written as json",
+			url:  "/stats/items?format=csv",
+			code: http.StatusBadRequest,
+			data: "Invalid cursor",
+			body: `{"data":"Invalid cursor"}`,
+			headers: map[string]string{
+				"Content-Type": contentTypeJSON,
+			},
+		},
+		{
+			name: "json without format",
+			url:  "/stats/items",
+			code: http.StatusOK,
+			data: []*testItem{},
+			body: `{"data":[]}`,
+		},
+	}
+
+	exporter := NewExporter(testDecimals)
+	for _, test := range tests {
+		handler := exporter.Export(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			WriteJSON(w, test.code, test.data)
+		}))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", test.url, nil))
+		assert.Equal(t, test.code, w.Code, test.name)
+		assert.Equal(t, test.body, w.Body.String(), test.name)
+		for k, v := range test.headers {
+			assert.Equal(t, v, w.Header().Get(k), test.name)
+		}
+	}
+}
+
+func TestFormatDecimal(t *testing.T) {
+	tests := []struct {
+		amount   int64
+		decimals int
+		res      string
+	}{
+		{1500, 3, "1.5"},
+		{1000, 3, "1"},
+		{5, 3, "0.005"},
+		{-25, 2, "-0.25"},
+		{0, 18, "0"},
+		{42, 0, "42"},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.res, FormatDecimal(big.NewInt(test.amount), test.decimals))
+	}
+}
+
+// failingWriter is a response writer of a client which has gone away
+type failingWriter struct {
+	*httptest.ResponseRecorder
+	writes int
+}
+
+func (w *failingWriter) Write(b []byte) (int, error) {
+	w.writes++
+	return 0, io.ErrClosedPipe
+}
+
+func TestExportStream(t *testing.T) {
+	exporter := NewExporter(testDecimals)
+
+	// streams written as is can be flushed
+	handler := exporter.Export(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, ok := w.(http.Flusher)
+		assert.True(t, ok)
+		w.(http.Flusher).Flush()
+	}))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/stats/items?format=csv", nil))
+	assert.True(t, w.Flushed)
+
+	// items are not written anymore once a write failed
+	items := make([]*testItem, 100)
+	for i := range items {
+		items[i] = &testItem{Token: testToken, Amount: big.NewInt(int64(i))}
+	}
+	handler = exporter.Export(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		WriteJSON(w, http.StatusOK, items)
+	}))
+	fw := &failingWriter{ResponseRecorder: httptest.NewRecorder()}
+	handler.ServeHTTP(fw, httptest.NewRequest("GET", "/stats/items?format=ndjson", nil))
+	assert.Equal(t, 1, fw.writes)
+}
@@ -12,6 +12,9 @@ func WriteMessage(w http.ResponseWriter, code int, message string) {
 	Write(w, code, map[string]string{"message": message})
 }
 func WriteJSON(w http.ResponseWriter, code int, payload interface{}) {
+	if dw, ok := w.(dataWriter); ok && dw.writeData(code, payload) {
+		return
+	}
 	Write(w, code, map[string]interface{}{"data": payload})
 }
 